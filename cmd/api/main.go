@@ -2,13 +2,25 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
 
 	"github.com/aerospike/aerospike-client-go/v6"
 	"github.com/dportaluppi/customer-profiles-api/internal/config"
+	ievent "github.com/dportaluppi/customer-profiles-api/internal/event"
+	iidentity "github.com/dportaluppi/customer-profiles-api/internal/identity"
 	iprofile "github.com/dportaluppi/customer-profiles-api/internal/profile"
+	irelationship "github.com/dportaluppi/customer-profiles-api/internal/relationship"
 	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	ischema "github.com/dportaluppi/customer-profiles-api/internal/schema"
+	isegment "github.com/dportaluppi/customer-profiles-api/internal/segment"
+	"github.com/dportaluppi/customer-profiles-api/pkg/event"
+	"github.com/dportaluppi/customer-profiles-api/pkg/identity"
 	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/dportaluppi/customer-profiles-api/pkg/relationship"
+	"github.com/dportaluppi/customer-profiles-api/pkg/schema"
+	"github.com/dportaluppi/customer-profiles-api/pkg/segment"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -23,49 +35,237 @@ func main() {
 		panic(err)
 	}
 
-	// Aerospike client
-	client, err := aerospike.NewClient(cfg.Aerospike.Address, cfg.Aerospike.Port)
-	if err != nil {
-		panic(err)
-	}
-	defer client.Close()
-
-	// Set up MongoDB client
-	clientOptions := options.Client().
-		ApplyURI(cfg.Mongo.Uri).
-		SetConnectTimeout(cfg.Mongo.ConnectionTimeout).
-		SetSocketTimeout(cfg.Mongo.Timeout)
-	mongoClient, err := mongo.Connect(context.Background(), clientOptions)
-	if err != nil {
-		log.Fatal(err)
+	// Aerospike client, only needed when storing or caching entities in Aerospike
+	var client *aerospike.Client
+	if cfg.Storage.Driver == config.StorageAerospike || cfg.Cache.Enabled {
+		client, err = aerospike.NewClient(cfg.Aerospike.Address, cfg.Aerospike.Port)
+		if err != nil {
+			panic(err)
+		}
+		defer client.Close()
 	}
 
-	// Ping the primary
-	if err = mongoClient.Ping(context.Background(), nil); err != nil {
-		log.Fatal(err)
+	// Set up MongoDB client, unless everything is kept in memory
+	var mongoClient *mongo.Client
+	if cfg.Storage.Driver != config.StorageMemory {
+		clientOptions := options.Client().
+			ApplyURI(cfg.Mongo.Uri).
+			SetConnectTimeout(cfg.Mongo.ConnectionTimeout).
+			SetSocketTimeout(cfg.Mongo.Timeout)
+		mongoClient, err = mongo.Connect(context.Background(), clientOptions)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Ping the primary
+		if err = mongoClient.Ping(context.Background(), nil); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	// Entities
 	router := gin.Default()
-	entities := repository.NewMongoRepository[*profile.Entity](mongoClient, cfg.Mongo.DB, "entities")
+	router.Use(rest.RequestID(), rest.Actor())
+	var store repository.Repository[*profile.Entity]
+	switch cfg.Storage.Driver {
+	case config.StorageAerospike:
+		store = repository.NewAerospikeRepository[*profile.Entity](client, cfg.Aerospike.Namespace, "entities")
+	case config.StorageMongo, config.StorageMemory:
+		store = newRepository[*profile.Entity](cfg, mongoClient, "entities")
+	default:
+		log.Fatalf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+	// Aerospike cannot search text, walk the relationship graph, aggregate or enforce unique fields, so
+	// those are only available on Mongo and memory
+	text, _ := store.(repository.TextSearcher[*profile.Entity])
+	graph, _ := store.(profile.GraphRepository)
+	aggregations, _ := store.(profile.AggregateRepository)
+	uniques, _ := store.(identity.Indexer)
+	entities := store
+	if cfg.Cache.Enabled {
+		cached := repository.NewCachedRepository[*profile.Entity](
+			entities,
+			repository.NewAerospikeCache[*profile.Entity](client, cfg.Aerospike.Namespace, cfg.Cache.Set),
+			cfg.Cache.DefaultTTL,
+			cfg.Cache.TypeTTLs,
+		)
+		expvar.Publish("entitiesCache", expvar.Func(func() any { return cached.Stats() }))
+		entities = cached
+		// Reindexing goes through the cache, which drops the reindexed entities
+		if text != nil {
+			text = cached
+		}
+	}
+
+	// Every entity write is recorded in the entity history
+	revisions := newRepository[*profile.Revision](cfg, mongoClient, "entityRevisions")
+	if err = profile.EnsureHistoryIndexes(ctx, revisions); err != nil {
+		log.Fatal(err)
+	}
+	entities = profile.NewRecorder(entities, revisions)
+	// Entities are indexed for free-text search with the fields configured for their type
+	searchFields := newRepository[*profile.SearchFields](cfg, mongoClient, "searchFields")
+	entities = profile.NewIndexer(entities, searchFields)
+	// Aerospike cannot find expired tombstones, so they are only purged from Mongo and memory
+	if cfg.Storage.Driver != config.StorageAerospike {
+		go profile.NewPurger(entities, cfg.SoftDelete.Retention).Run(ctx, cfg.SoftDelete.PurgeInterval)
+	}
+	if text != nil {
+		if err = text.EnsureTextIndex(ctx); err != nil {
+			log.Fatal(err)
+		}
+	}
+	router.GET(cfg.Server.MetricsPath, gin.WrapH(expvar.Handler()))
+	switch cfg.Relationships.OnDelete {
+	case profile.OnDeleteRestrict, profile.OnDeleteCascade, profile.OnDeleteIgnore:
+	default:
+		log.Fatalf("unknown relationships on delete behaviour %q", cfg.Relationships.OnDelete)
+	}
+	schemas := newRepository[*schema.Schema](cfg, mongoClient, "schemas")
+	validator := schema.NewValidator(schemas)
+	relationshipTypes := newRepository[*relationship.Definition](cfg, mongoClient, "relationshipTypes")
+	types := relationship.NewGetter(relationshipTypes)
+	identityKeys := newRepository[*identity.Key](cfg, mongoClient, "identityKeys")
+	keys := identity.NewGetter(identityKeys)
+	saver := profile.NewSaver(entities, validator, types)
+	deleter := profile.NewDeleter(entities, cfg.Relationships.OnDelete)
 	eHandler := iprofile.NewHandler(
-		profile.NewSaver(entities),
-		profile.NewDeleter(entities),
+		saver,
+		deleter,
 		profile.NewGetter(entities),
+		profile.NewMerger(entities, validator, types, deleter),
+		profile.NewBulker(entities, validator, types, cfg.Relationships.OnDelete),
+		profile.NewHistorian(entities, revisions, saver),
+		profile.NewChecker(entities),
+		profile.NewNavigator(entities, graph, types),
+		profile.NewResolver(entities, keys, saver),
+		profile.NewDeduplicator(
+			entities,
+			aggregations,
+			newRepository[*profile.MatchRules](cfg, mongoClient, "matchRules"),
+			newRepository[*profile.DuplicateScan](cfg, mongoClient, "duplicateScans"),
+		),
+		profile.NewSearcher(text, searchFields),
+		profile.NewAggregator(aggregations),
 	)
 	router.POST("/accounts/:accountId/entities", eHandler.Create)
 	router.PUT("/accounts/:accountId/entities/:id", eHandler.Update)
+	router.PATCH("/accounts/:accountId/entities/:id", eHandler.Patch)
 	router.DELETE("/accounts/:accountId/entities/:id", eHandler.Delete)
+	router.POST("/accounts/:accountId/entities/:id/restore", eHandler.Restore)
+	router.GET("/accounts/:accountId/entities/:id/history", eHandler.History)
+	router.POST("/accounts/:accountId/entities/:id/revert", eHandler.Revert)
 	router.GET("/accounts/:accountId/entities/:id", eHandler.GetByID)
+	router.GET("/accounts/:accountId/entities/by/:key/:value", eHandler.GetByIdentity)
+	router.PUT("/accounts/:accountId/entities/by/:key/:value", eHandler.UpsertByIdentity)
 	router.GET("/accounts/:accountId/entities", eHandler.GetAll)
+	router.POST("/accounts/:accountId/entities/merge", eHandler.Merge)
+	router.POST("/accounts/:accountId/entities/bulk", eHandler.Bulk)
 
 	router.POST("/accounts/:accountId/entities/search", eHandler.Query)
+	router.POST("/accounts/:accountId/entities/aggregate", eHandler.Aggregate)
 	router.POST("/accounts/:accountId/entities/queries/jsonlogic", eHandler.QueryJsonLogic) // TODO: Remove this endpoint
 
+	// Entities scoped by type, following the OpenAPI contract
+	router.GET("/accounts/:accountId/entity-types", eHandler.Types)
+	eHandler.TypedRoutes(router)
+
 	// Relationships
 	router.POST("/accounts/:accountId/entities/:id/relationships", eHandler.CreateRelationship)
 	router.PUT("/accounts/:accountId/entities/:id/relationships", eHandler.ReplaceRelationships)
+	router.PATCH("/accounts/:accountId/entities/:id/relationships/:relationshipId", eHandler.UpdateRelationship)
+	router.DELETE("/accounts/:accountId/entities/:id/relationships/:relationshipId", eHandler.RemoveRelationship)
+	router.GET("/accounts/:accountId/entities/:id/relationships", eHandler.Relationships)
+	router.GET("/accounts/:accountId/entities/:id/graph", eHandler.Traverse)
+	router.GET("/accounts/:accountId/relationships/dangling", eHandler.DanglingReferences)
+
+	// Duplicates
+	router.GET("/accounts/:accountId/entities/:id/duplicates", eHandler.Duplicates)
+	router.GET("/accounts/:accountId/match-rules/:entityType", eHandler.MatchRules)
+	router.PUT("/accounts/:accountId/match-rules/:entityType", eHandler.SetMatchRules)
+	router.POST("/accounts/:accountId/duplicate-scans", eHandler.StartDuplicateScan)
+	router.GET("/accounts/:accountId/duplicate-scans/:scanId", eHandler.DuplicateScan)
+
+	// Free-text search
+	router.GET("/accounts/:accountId/search-fields/:entityType", eHandler.SearchFields)
+	router.PUT("/accounts/:accountId/search-fields/:entityType", eHandler.SetSearchFields)
+
+	// Relationship types
+	rtHandler := irelationship.NewHandler(
+		relationship.NewSaver(relationshipTypes),
+		relationship.NewDeleter(relationshipTypes),
+		types,
+	)
+	router.POST("/accounts/:accountId/relationship-types", rtHandler.Create)
+	router.GET("/accounts/:accountId/relationship-types", rtHandler.GetAll)
+	router.GET("/accounts/:accountId/relationship-types/:name", rtHandler.Get)
+	router.PUT("/accounts/:accountId/relationship-types/:name", rtHandler.Update)
+	router.DELETE("/accounts/:accountId/relationship-types/:name", rtHandler.Delete)
+
+	// Identity keys
+	ikHandler := iidentity.NewHandler(
+		identity.NewSaver(identityKeys, uniques),
+		identity.NewDeleter(identityKeys, uniques),
+		keys,
+	)
+	router.POST("/accounts/:accountId/identity-keys", ikHandler.Create)
+	router.GET("/accounts/:accountId/identity-keys", ikHandler.GetAll)
+	router.DELETE("/accounts/:accountId/identity-keys/:entityType/:field", ikHandler.Delete)
+
+	// Schemas
+	scHandler := ischema.NewHandler(
+		schema.NewSaver(schemas),
+		schema.NewDeleter(schemas),
+		schema.NewGetter(schemas),
+	)
+	router.POST("/accounts/:accountId/schemas", scHandler.Create)
+	router.GET("/accounts/:accountId/schemas", scHandler.GetAll)
+	router.GET("/accounts/:accountId/schemas/:entityType", scHandler.Get)
+	router.PUT("/accounts/:accountId/schemas/:entityType", scHandler.Update)
+	router.DELETE("/accounts/:accountId/schemas/:entityType", scHandler.Delete)
+	router.POST("/accounts/:accountId/schemas/:entityType/validate", scHandler.Validate)
+
+	// Segments
+	segments := newRepository[*segment.Segment](cfg, mongoClient, "segments")
+	segmentGetter := segment.NewGetter(segments)
+	sHandler := isegment.NewHandler(
+		segment.NewSaver(segments),
+		segment.NewDeleter(segments),
+		segmentGetter,
+		segment.NewEvaluator(segmentGetter, profile.NewGetter(entities)),
+	)
+	router.POST("/accounts/:accountId/segments", sHandler.Create)
+	router.GET("/accounts/:accountId/segments", sHandler.GetAll)
+	router.GET("/accounts/:accountId/segments/:segmentId", sHandler.GetByID)
+	router.PUT("/accounts/:accountId/segments/:segmentId", sHandler.Update)
+	router.DELETE("/accounts/:accountId/segments/:segmentId", sHandler.Delete)
+	router.GET("/accounts/:accountId/segments/:segmentId/count", sHandler.Count)
+	router.GET("/accounts/:accountId/segments/:segmentId/entities", sHandler.Members)
+	router.GET("/accounts/:accountId/segments/:segmentId/entities/:id", sHandler.IsMember)
+	router.GET("/accounts/:accountId/entities/:id/segments", sHandler.SegmentsOf)
+
+	// Events
+	events := newRepository[*event.Event](cfg, mongoClient, "events")
+	if err = event.EnsureIndexes(ctx, events); err != nil {
+		log.Fatal(err)
+	}
+	evHandler := ievent.NewHandler(
+		event.NewIngester(events, profile.NewGetter(entities)),
+		event.NewGetter(events),
+	)
+	router.POST("/accounts/:accountId/events", evHandler.Ingest)
+	router.GET("/accounts/:accountId/entities/:id/events", evHandler.Timeline)
+
 	if err = router.Run(":8030"); err != nil {
 		panic(err)
 	}
 }
+
+// newRepository returns the repository of a collection for the configured storage driver.
+// Aerospike cannot run queries, so collections other than entities stay in Mongo when it is selected.
+func newRepository[T repository.Entity](cfg *config.Config, mongoClient *mongo.Client, collection string) repository.Repository[T] {
+	if cfg.Storage.Driver == config.StorageMemory {
+		return repository.NewMemoryRepository[T]()
+	}
+	return repository.NewMongoRepository[T](mongoClient, cfg.Mongo.DB, collection)
+}
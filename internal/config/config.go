@@ -45,15 +45,46 @@ type Mongo struct {
 	DB                string        `default:"customers-profiles-api"`
 }
 
+// Storage drivers backing the entities repository.
+const (
+	StorageMongo     = "mongo"
+	StorageAerospike = "aerospike"
+	StorageMemory    = "memory"
+)
+
+type Storage struct {
+	Driver string `default:"mongo"`
+}
+
+type Cache struct {
+	Enabled    bool                     `default:"false"`
+	Set        string                   `default:"entities-cache"`
+	DefaultTTL time.Duration            `split_words:"true" default:"5m"`
+	TypeTTLs   map[string]time.Duration `split_words:"true"` // e.g. Contact:10m,Store:1h
+}
+
+type SoftDelete struct {
+	Retention     time.Duration `default:"720h"`
+	PurgeInterval time.Duration `split_words:"true" default:"1h"`
+}
+
+type Relationships struct {
+	OnDelete string `split_words:"true" default:"ignore"` // restrict, cascade or ignore
+}
+
 type Config struct {
-	Environment config.Environment
-	Trace       Trace
-	HealthCheck HealthCheck `split_words:"true"`
-	Engine      Engine
-	Server      Server
-	Log         logging.Config
-	Aerospike   Aerospike
-	Mongo       Mongo
+	Environment   config.Environment
+	Trace         Trace
+	HealthCheck   HealthCheck `split_words:"true"`
+	Engine        Engine
+	Server        Server
+	Log           logging.Config
+	Aerospike     Aerospike
+	Mongo         Mongo
+	Storage       Storage
+	Cache         Cache
+	SoftDelete    SoftDelete `split_words:"true"`
+	Relationships Relationships
 }
 
 // Load returns a hydrated Config object for the current environment.
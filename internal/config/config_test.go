@@ -104,6 +104,20 @@ func TestGet(t *testing.T) {
 						Port:      3000,
 						Namespace: "customers-profiles-api",
 					},
+					Storage: Storage{
+						Driver: StorageMongo,
+					},
+					Cache: Cache{
+						Set:        "entities-cache",
+						DefaultTTL: 5 * time.Minute,
+					},
+					SoftDelete: SoftDelete{
+						Retention:     720 * time.Hour,
+						PurgeInterval: time.Hour,
+					},
+					Relationships: Relationships{
+						OnDelete: "ignore",
+					},
 				}, c, "invalid config returned")
 			},
 		},
@@ -147,6 +161,20 @@ func TestGet(t *testing.T) {
 						Port:      3000,
 						Namespace: "customers-profiles-api",
 					},
+					Storage: Storage{
+						Driver: StorageMongo,
+					},
+					Cache: Cache{
+						Set:        "entities-cache",
+						DefaultTTL: 5 * time.Minute,
+					},
+					SoftDelete: SoftDelete{
+						Retention:     720 * time.Hour,
+						PurgeInterval: time.Hour,
+					},
+					Relationships: Relationships{
+						OnDelete: "ignore",
+					},
 				}, c, "invalid config returned")
 			},
 		},
@@ -0,0 +1,81 @@
+package event
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/event"
+	"github.com/gin-gonic/gin"
+)
+
+// service define business logic for event.
+type service struct {
+	event.Ingester
+	event.Getter
+}
+
+// Handler rest api for event.
+type Handler struct {
+	service *service
+}
+
+// NewHandler creates a new handler for event.
+func NewHandler(ingester event.Ingester, getter event.Getter) *Handler {
+	s := &service{
+		Ingester: ingester,
+		Getter:   getter,
+	}
+	return &Handler{service: s}
+}
+
+// Ingest manages receiving a new event for an entity.
+func (h *Handler) Ingest(c *gin.Context) {
+	var e event.Event
+	if err := c.ShouldBindJSON(&e); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	ingested, err := h.service.Ingest(ctx, c.Param("accountId"), &e)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, ingested)
+}
+
+// Timeline manages fetching the events of an entity, optionally restricted to a time range given by
+// the RFC 3339 'from' and 'to' query parameters.
+func (h *Handler) Timeline(c *gin.Context) {
+	var timeRange event.TimeRange
+	var err error
+	if from := c.Query("from"); from != "" {
+		if timeRange.From, err = time.Parse(time.RFC3339, from); err != nil {
+			rest.Error(c, event.ErrInvalidTimeRange)
+			return
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if timeRange.To, err = time.Parse(time.RFC3339, to); err != nil {
+			rest.Error(c, event.ErrInvalidTimeRange)
+			return
+		}
+	}
+	currentPage, perPage := rest.Page(c)
+
+	ctx := c.Request.Context()
+	events, totalItems, err := h.service.Timeline(ctx, c.Param("accountId"), c.Param("id"), timeRange, currentPage, perPage)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":     events,
+		"pagination": pkg.NewPagination(currentPage, perPage, totalItems),
+	})
+}
@@ -0,0 +1,105 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg/event"
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, *profile.Entity) {
+	gin.SetMode(gin.TestMode)
+	entities := repository.NewMemoryRepository[*profile.Entity]()
+	contact, err := entities.Upsert(context.Background(), "acc", &profile.Entity{AccountID: "acc", Type: "Contact"})
+	require.NoError(t, err)
+	events := repository.NewMemoryRepository[*event.Event]()
+	h := NewHandler(event.NewIngester(events, profile.NewGetter(entities)), event.NewGetter(events))
+
+	router := gin.New()
+	router.Use(rest.RequestID())
+	router.POST("/accounts/:accountId/events", h.Ingest)
+	router.GET("/accounts/:accountId/entities/:id/events", h.Timeline)
+	return router, contact
+}
+
+func doRequest(t *testing.T, router *gin.Engine, method, path string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIngest(t *testing.T) {
+	router, contact := newTestRouter(t)
+
+	tests := []struct {
+		it     string
+		event  event.Event
+		status int
+	}{
+		{it: "accepts events of known entities", event: event.Event{EntityID: contact.ID, EventType: "login"}, status: http.StatusAccepted},
+		{it: "rejects events of unknown entities", event: event.Event{EntityID: "65a000000000000000000000", EventType: "login"}, status: http.StatusNotFound},
+		{it: "rejects events without an entity", event: event.Event{EventType: "login"}, status: http.StatusBadRequest},
+		{it: "rejects events without a type", event: event.Event{EntityID: contact.ID}, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPost, "/accounts/acc/events", tt.event)
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	router, contact := newTestRouter(t)
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	for _, d := range []int{3, 1, 4, 2} {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/events", event.Event{EntityID: contact.ID, EventType: "purchase", Timestamp: day(d)})
+		require.Equal(t, http.StatusAccepted, rec.Code)
+	}
+
+	tests := []struct {
+		it    string
+		query string
+		want  []time.Time
+		total int
+	}{
+		{it: "lists the events oldest first", query: "", want: []time.Time{day(1), day(2), day(3), day(4)}, total: 4},
+		{it: "pages the ordered events", query: "?currentPage=2&perPage=3", want: []time.Time{day(4)}, total: 4},
+		{it: "restricts the events to the time range", query: "?from=2024-01-02T00:00:00Z&to=2024-01-04T00:00:00Z", want: []time.Time{day(2), day(3)}, total: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+contact.ID+"/events"+tt.query, nil)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var body struct {
+				Events     []event.Event `json:"events"`
+				Pagination struct {
+					TotalItems int `json:"totalItems"`
+				} `json:"pagination"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			var got []time.Time
+			for _, e := range body.Events {
+				got = append(got, e.Timestamp.UTC())
+			}
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.total, body.Pagination.TotalItems)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package identity
+
+import (
+	"net/http"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/identity"
+	"github.com/gin-gonic/gin"
+)
+
+// service define business logic for identity keys.
+type service struct {
+	identity.Saver
+	identity.Deleter
+	identity.Getter
+}
+
+// Handler rest api for identity keys.
+type Handler struct {
+	service *service
+}
+
+// NewHandler creates a new handler for identity keys.
+func NewHandler(saver identity.Saver, deleter identity.Deleter, getter identity.Getter) *Handler {
+	s := &service{
+		Saver:   saver,
+		Deleter: deleter,
+		Getter:  getter,
+	}
+	return &Handler{service: s}
+}
+
+// Create manages the declaration of an identity key.
+func (h *Handler) Create(c *gin.Context) {
+	var k identity.Key
+	if err := c.ShouldBindJSON(&k); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	created, err := h.service.Create(ctx, c.Param("accountId"), &k)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// Delete manages the removal of an identity key.
+func (h *Handler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := h.service.Delete(ctx, c.Param("accountId"), c.Param("entityType"), c.Param("field"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity key deleted"})
+}
+
+// GetAll manages listing the identity keys of an account, optionally of a single entity type.
+func (h *Handler) GetAll(c *gin.Context) {
+	currentPage, perPage := rest.Page(c)
+
+	ctx := c.Request.Context()
+	keys, totalItems, err := h.service.GetAll(ctx, c.Param("accountId"), c.Query("entityType"), currentPage, perPage)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"identityKeys": keys,
+		"pagination":   pkg.NewPagination(currentPage, perPage, totalItems),
+	})
+}
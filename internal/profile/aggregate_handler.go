@@ -0,0 +1,51 @@
+package profile
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
+	"github.com/gin-gonic/gin"
+)
+
+// aggregateRequest holds the facets computed over the entities matching filters, written like those of
+// search criteria.
+//
+//	{"filters": [{"field": "type", "value": "Contact"}],
+//	 "facets": {
+//	   "cities": {"type": "terms", "field": "attributes.city", "size": 5},
+//	   "signups": {"type": "dateHistogram", "field": "createdAt", "unit": "day"},
+//	   "ages": {"type": "stats", "field": "attributes.age"}
+//	 }}
+type aggregateRequest struct {
+	Filters []search.Condition   `json:"filters"`
+	Facets  map[string]pkg.Facet `json:"facets"`
+}
+
+// Aggregate manages counting the entities matching the request filters and computing its facets over them.
+// Unknown keys are rejected, like in Query.
+func (h *Handler) Aggregate(c *gin.Context) {
+	var request aggregateRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+	query, err := search.Compile(search.Criteria{Filters: request.Filters})
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	aggregation, err := h.service.Aggregate(ctx, c.Param("accountId"), query, request.Facets, queryOptions(c))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, aggregation)
+}
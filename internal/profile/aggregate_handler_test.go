@@ -0,0 +1,99 @@
+package profile
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregate(t *testing.T) {
+	router := newTestRouter()
+
+	var deleted profile.Entity
+	for _, e := range []profile.Entity{
+		{Type: "Contact", Attributes: profile.Attribute{"city": "Rosario", "age": 31, "tags": []any{"vip", "new"}}},
+		{Type: "Contact", Attributes: profile.Attribute{"city": "Rosario", "age": 45, "tags": []any{"vip"}}},
+		{Type: "Contact", Attributes: profile.Attribute{"city": "Córdoba", "age": "unknown"}},
+		{Type: "Store", Attributes: profile.Attribute{"city": "Rosario"}},
+		{Type: "Store", Attributes: profile.Attribute{"city": "Salta", "age": 3}},
+	} {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", e)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &deleted))
+	}
+	rec := doRequest(t, router, http.MethodDelete, "/accounts/acc/entities/"+deleted.ID, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	aggregate := func(t *testing.T, body any) pkg.Aggregation {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/aggregate", body)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var aggregation pkg.Aggregation
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &aggregation))
+		return aggregation
+	}
+
+	t.Run("computes every kind of facet over the active entities", func(t *testing.T) {
+		aggregation := aggregate(t, map[string]any{"facets": map[string]any{
+			"types":  map[string]any{"type": "terms", "field": "type"},
+			"cities": map[string]any{"type": "terms", "field": "attributes.city", "size": 1},
+			"tags":   map[string]any{"type": "terms", "field": "attributes.tags"},
+			"ages":   map[string]any{"type": "histogram", "field": "attributes.age", "interval": 10},
+			"stats":  map[string]any{"type": "stats", "field": "attributes.age"},
+			"daily":  map[string]any{"type": "dateHistogram", "field": "createdAt", "unit": "day"},
+		}})
+		require.Equal(t, 4, aggregation.Total)
+		require.Equal(t, []pkg.Bucket{{Key: "Contact", Count: 3}, {Key: "Store", Count: 1}}, aggregation.Facets["types"].Buckets)
+		require.Equal(t, []pkg.Bucket{{Key: "Rosario", Count: 3}}, aggregation.Facets["cities"].Buckets)
+		require.Equal(t, []pkg.Bucket{{Key: "vip", Count: 2}, {Key: "new", Count: 1}}, aggregation.Facets["tags"].Buckets)
+		require.Equal(t, []pkg.Bucket{{Key: 30.0, Count: 1}, {Key: 40.0, Count: 1}}, aggregation.Facets["ages"].Buckets)
+
+		stats := aggregation.Facets["stats"].Stats
+		require.Equal(t, 2, stats.Count)
+		require.Equal(t, 31.0, *stats.Min)
+		require.Equal(t, 45.0, *stats.Max)
+		require.Equal(t, 38.0, *stats.Avg)
+		require.Equal(t, 76.0, *stats.Sum)
+
+		today := time.Now().UTC().Format(time.DateOnly) + "T00:00:00Z"
+		require.Equal(t, []pkg.Bucket{{Key: today, Count: 4}}, aggregation.Facets["daily"].Buckets)
+	})
+
+	t.Run("restricts to the matching entities", func(t *testing.T) {
+		aggregation := aggregate(t, map[string]any{
+			"filters": []any{map[string]any{"field": "type", "value": "Store"}},
+			"facets":  map[string]any{"stats": map[string]any{"type": "stats", "field": "attributes.age"}},
+		})
+		require.Equal(t, 1, aggregation.Total)
+		require.Equal(t, 0, aggregation.Facets["stats"].Stats.Count)
+		require.Nil(t, aggregation.Facets["stats"].Stats.Avg)
+	})
+
+	tests := []struct {
+		it    string
+		facet map[string]any
+	}{
+		{it: "rejects an unknown facet type", facet: map[string]any{"type": "median", "field": "attributes.age"}},
+		{it: "rejects a field outside the entity", facet: map[string]any{"type": "terms", "field": "accountId"}},
+		{it: "rejects a histogram without interval", facet: map[string]any{"type": "histogram", "field": "attributes.age"}},
+		{it: "rejects an unknown calendar unit", facet: map[string]any{"type": "dateHistogram", "field": "createdAt", "unit": "fortnight"}},
+		{it: "rejects too many terms", facet: map[string]any{"type": "terms", "field": "type", "size": 1000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/aggregate", map[string]any{
+				"facets": map[string]any{"facet": tt.facet},
+			})
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+
+	t.Run("rejects a free-text query", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/aggregate", map[string]any{"query": "rosario"})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,76 @@
+package profile
+
+import (
+	"net/http"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/gin-gonic/gin"
+)
+
+// Duplicates manages listing the entities likely to be duplicates of an entity, best first.
+func (h *Handler) Duplicates(c *gin.Context) {
+	ctx := c.Request.Context()
+	duplicates, err := h.service.Duplicates(ctx, c.Param("accountId"), c.Param("id"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"duplicates": duplicates})
+}
+
+// MatchRules manages fetching the duplicate detection rules of an entity type.
+func (h *Handler) MatchRules(c *gin.Context) {
+	ctx := c.Request.Context()
+	rules, err := h.service.MatchRules(ctx, c.Param("accountId"), c.Param("entityType"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// SetMatchRules manages replacing the duplicate detection rules of an entity type.
+func (h *Handler) SetMatchRules(c *gin.Context) {
+	var rules profile.MatchRules
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	saved, err := h.service.SetMatchRules(ctx, c.Param("accountId"), c.Param("entityType"), &rules)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// StartDuplicateScan manages starting a scan for the duplicate entities of an account, restricted to the
+// entity type in ?type when given. The scan runs in the background and is polled by its ID.
+func (h *Handler) StartDuplicateScan(c *gin.Context) {
+	ctx := c.Request.Context()
+	scan, err := h.service.StartScan(ctx, c.Param("accountId"), c.Query("type"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, scan)
+}
+
+// DuplicateScan manages fetching a duplicate scan along with its merge suggestions once completed.
+func (h *Handler) DuplicateScan(c *gin.Context) {
+	ctx := c.Request.Context()
+	scan, err := h.service.Scan(ctx, c.Param("accountId"), c.Param("scanId"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, scan)
+}
@@ -1,14 +1,17 @@
 package profile
 
 import (
+	"encoding/json"
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
 	"github.com/dportaluppi/customer-profiles-api/pkg"
 	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
 	"github.com/gin-gonic/gin"
 	gojsonlogicmongodb "github.com/kubeesio/go-jsonlogic-mongodb"
-	"github.com/pkg/errors"
-	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // service define business logic for entity.
@@ -16,6 +19,15 @@ type service struct {
 	profile.Saver
 	profile.Deleter
 	profile.Getter
+	profile.Merger
+	profile.Bulker
+	profile.Historian
+	profile.Checker
+	profile.Navigator
+	profile.Resolver
+	profile.Deduplicator
+	profile.Searcher
+	profile.Aggregator
 }
 
 // Handler rest api for entity.
@@ -24,11 +36,33 @@ type Handler struct {
 }
 
 // NewHandler creates a new handler for entity.
-func NewHandler(upserter profile.Saver, deleter profile.Deleter, getter profile.Getter) *Handler {
+func NewHandler(
+	upserter profile.Saver,
+	deleter profile.Deleter,
+	getter profile.Getter,
+	merger profile.Merger,
+	bulker profile.Bulker,
+	historian profile.Historian,
+	checker profile.Checker,
+	navigator profile.Navigator,
+	resolver profile.Resolver,
+	deduplicator profile.Deduplicator,
+	searcher profile.Searcher,
+	aggregator profile.Aggregator,
+) *Handler {
 	s := &service{
-		Saver:   upserter,
-		Deleter: deleter,
-		Getter:  getter,
+		Saver:        upserter,
+		Deleter:      deleter,
+		Getter:       getter,
+		Merger:       merger,
+		Bulker:       bulker,
+		Historian:    historian,
+		Checker:      checker,
+		Navigator:    navigator,
+		Resolver:     resolver,
+		Deduplicator: deduplicator,
+		Searcher:     searcher,
+		Aggregator:   aggregator,
 	}
 	return &Handler{service: s}
 }
@@ -37,7 +71,7 @@ func NewHandler(upserter profile.Saver, deleter profile.Deleter, getter profile.
 func (h *Handler) Create(c *gin.Context) {
 	var e profile.Entity
 	if err := c.ShouldBindJSON(&e); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		rest.InvalidRequest(c, err)
 		return
 	}
 	accountId := c.Param("accountId")
@@ -45,13 +79,11 @@ func (h *Handler) Create(c *gin.Context) {
 	ctx := c.Request.Context()
 	createdUser, err := h.service.Create(ctx, accountId, &e)
 	if err != nil {
-		err = errors.WithStack(err)
-		log.Printf("%+v", err)
-		// TODO: Handle error.
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		rest.Error(c, err)
 		return
 	}
 
+	rest.SetETag(c, createdUser.Version)
 	c.JSON(http.StatusOK, createdUser)
 }
 
@@ -59,206 +91,639 @@ func (h *Handler) Create(c *gin.Context) {
 func (h *Handler) Update(c *gin.Context) {
 	var entity profile.Entity
 	if err := c.ShouldBindJSON(&entity); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		rest.InvalidRequest(c, err)
 		return
 	}
 	accountId := c.Param("accountId")
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID is required for update"})
+		rest.Error(c, profile.ErrIDMissing)
 		return
 	}
+	version, err := rest.IfMatch(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+	if version != 0 {
+		entity.Version = version
+	}
 
 	ctx := c.Request.Context()
 	updatedEntity, err := h.service.Update(ctx, accountId, id, &entity)
 	if err != nil {
-		err = errors.WithStack(err)
-		log.Printf("%+v", err)
-		// TODO: Handle error.
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		rest.Error(c, err)
 		return
 	}
 
+	rest.SetETag(c, updatedEntity.Version)
 	c.JSON(http.StatusOK, updatedEntity)
 }
 
+// Patch manages the partial update of an entity with a JSON Merge Patch or a JSON Patch, as told by the
+// Content-Type header.
+func (h *Handler) Patch(c *gin.Context) {
+	patch, err := c.GetRawData()
+	if err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+	version, err := rest.IfMatch(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	patched, err := h.service.Patch(ctx, c.Param("accountId"), c.Param("id"), version, c.ContentType(), patch)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	rest.SetETag(c, patched.Version)
+	c.JSON(http.StatusOK, patched)
+}
+
 // Delete manages the deletion of a entity.
 func (h *Handler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing ID"})
+		rest.Error(c, profile.ErrIDMissing)
+		return
+	}
+
+	version, err := rest.IfMatch(c)
+	if err != nil {
+		rest.Error(c, err)
 		return
 	}
 
 	ctx := c.Request.Context()
-	err := h.service.Delete(ctx, c.Param("accountId"), id)
+	err = h.service.Delete(ctx, c.Param("accountId"), id, version)
 	if err != nil {
-		err = errors.WithStack(err)
-		log.Printf("%+v", err)
-		// TODO: Handle error more specifically if needed.
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		rest.Error(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Entity deleted"})
 }
 
+// Restore manages bringing a soft deleted entity back.
+func (h *Handler) Restore(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		rest.Error(c, profile.ErrIDMissing)
+		return
+	}
+
+	version, err := rest.IfMatch(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	entity, err := h.service.Restore(ctx, c.Param("accountId"), id, version)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	rest.SetETag(c, entity.Version)
+	c.JSON(http.StatusOK, entity)
+}
+
 // GetByID manages fetching a entity by its ID.
 func (h *Handler) GetByID(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing ID"})
+		rest.Error(c, profile.ErrIDMissing)
 		return
 	}
 
 	ctx := c.Request.Context()
-	entity, err := h.service.GetByID(ctx, c.Param("accountId"), id)
+	var entity *profile.Entity
+	var err error
+	if asOf := c.Query("asOf"); asOf != "" {
+		at, parseErr := time.Parse(time.RFC3339, asOf)
+		if parseErr != nil {
+			rest.InvalidRequest(c, parseErr)
+			return
+		}
+		entity, err = h.service.AsOf(ctx, c.Param("accountId"), id, at)
+	} else {
+		entity, err = h.service.GetByID(ctx, c.Param("accountId"), id, queryOptions(c))
+	}
 	if err != nil {
-		err = errors.WithStack(err)
-		log.Printf("%+v", err)
-		// TODO: Handle error more specifically if needed.
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		rest.Error(c, err)
 		return
 	}
 
+	rest.SetETag(c, entity.Version)
 	c.JSON(http.StatusOK, entity)
 }
 
 // GetAll manages fetching all entities with pagination.
 func (h *Handler) GetAll(c *gin.Context) {
-	currentPage, _ := strconv.Atoi(c.DefaultQuery("currentPage", "1"))
-	perPage, _ := strconv.Atoi(c.DefaultQuery("perPage", "50"))
+	currentPage, perPage := rest.Page(c)
+	opts, err := listOptions(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
 
-	if currentPage < 1 {
-		currentPage = 1
+	ctx := c.Request.Context()
+	if cursor, ok, count := rest.Cursor(c); ok {
+		page, err := h.service.Seek(ctx, c.Param("accountId"), nil, cursor, perPage, count, opts)
+		if err != nil {
+			rest.Error(c, err)
+			return
+		}
+		cursorPage(c, "entities", perPage, page, opts.Fields)
+		return
+	}
+
+	entities, totalItems, err := h.service.GetAll(ctx, c.Param("accountId"), currentPage, perPage, opts)
+	if err != nil {
+		rest.Error(c, err)
+		return
 	}
-	if perPage <= 0 {
-		perPage = 50
+
+	pagination := pkg.NewPagination(currentPage, perPage, totalItems)
+
+	response := gin.H{
+		"entities":   sparse(entities, opts.Fields),
+		"pagination": pagination,
 	}
 
+	c.JSON(http.StatusOK, response)
+}
+
+// History manages listing the revisions of an entity.
+func (h *Handler) History(c *gin.Context) {
+	currentPage, perPage := rest.Page(c)
+
 	ctx := c.Request.Context()
-	entities, totalItems, err := h.service.GetAll(ctx, c.Param("accountId"), currentPage, perPage)
+	revisions, totalItems, err := h.service.History(ctx, c.Param("accountId"), c.Param("id"), currentPage, perPage)
 	if err != nil {
-		err = errors.WithStack(err)
-		log.Printf("%+v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		rest.Error(c, err)
 		return
 	}
 
 	pagination := pkg.NewPagination(currentPage, perPage, totalItems)
 
 	response := gin.H{
-		"entities":   entities,
+		"revisions":  revisions,
 		"pagination": pagination,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) Query(c *gin.Context) {
-	var query map[string]interface{}
-	if err := c.BindJSON(&query); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+// Revert manages bringing an entity back to a past version.
+func (h *Handler) Revert(c *gin.Context) {
+	var body struct {
+		Version int64 `json:"version"` // Version to revert to
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+	id := c.Param("id")
+	if id == "" {
+		rest.Error(c, profile.ErrIDMissing)
+		return
+	}
+
+	version, err := rest.IfMatch(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	entity, err := h.service.Revert(ctx, c.Param("accountId"), id, version, body.Version)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	rest.SetETag(c, entity.Version)
+	c.JSON(http.StatusOK, entity)
+}
+
+// Merge manages folding duplicate entities into a surviving entity.
+func (h *Handler) Merge(c *gin.Context) {
+	var criteria profile.MergeCriteria
+	if err := c.ShouldBindJSON(&criteria); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	merged, err := h.service.Merge(ctx, c.Param("accountId"), criteria)
+	if err != nil {
+		rest.Error(c, err)
 		return
 	}
 
-	currentPage, _ := strconv.Atoi(c.DefaultQuery("currentPage", "1"))
-	perPage, _ := strconv.Atoi(c.DefaultQuery("perPage", "50"))
+	rest.SetETag(c, merged.Version)
+	c.JSON(http.StatusOK, merged)
+}
+
+// Bulk manages creating, updating and deleting many entities in one request.
+func (h *Handler) Bulk(c *gin.Context) {
+	var operations profile.BulkOperations
+	if err := c.ShouldBindJSON(&operations); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	results, err := h.service.Bulk(ctx, c.Param("accountId"), operations)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
 
-	if currentPage < 1 {
-		currentPage = 1
+// Query manages searching entities with search criteria. Unknown keys are rejected, so that a raw Mongo
+// filter is not mistaken for empty criteria matching every entity. A free-text query orders the results by
+// relevance, offset paginated.
+func (h *Handler) Query(c *gin.Context) {
+	var criteria search.Criteria
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&criteria); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+	query, err := search.Compile(criteria)
+	if err != nil {
+		rest.Error(c, err)
+		return
 	}
-	if perPage <= 0 {
-		perPage = 50
+
+	currentPage, perPage := rest.Page(c)
+	opts, err := listOptions(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
 	}
 
 	ctx := c.Request.Context()
-	results, totalItems, err := h.service.Query(ctx, c.Param("accountId"), query, currentPage, perPage)
+	if criteria.Query != "" {
+		h.search(c, criteria.Query, query, currentPage, perPage, opts)
+		return
+	}
+	if cursor, ok, count := rest.Cursor(c); ok {
+		page, err := h.service.Seek(ctx, c.Param("accountId"), query, cursor, perPage, count, opts)
+		if err != nil {
+			rest.Error(c, err)
+			return
+		}
+		cursorPage(c, "results", perPage, page, opts.Fields)
+		return
+	}
+
+	results, totalItems, err := h.service.Query(ctx, c.Param("accountId"), query, currentPage, perPage, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		rest.Error(c, err)
 		return
 	}
 
 	pagination := pkg.NewPagination(currentPage, perPage, totalItems)
 
 	response := gin.H{
-		"results":    results,
+		"results":    sparse(results, opts.Fields),
 		"pagination": pagination,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) QueryJsonLogic(c *gin.Context) {
-	mongoQuery, err := gojsonlogicmongodb.Convert(c.Request.Body)
+// search manages the free-text search of the entities matching query, ordered by relevance.
+func (h *Handler) search(c *gin.Context, text string, query map[string]any, currentPage, perPage int, opts profile.QueryOptions) {
+	if _, ok, _ := rest.Cursor(c); ok {
+		rest.Error(c, profile.ErrTextSearchCursor)
+		return
+	}
+
+	ctx := c.Request.Context()
+	results, totalItems, err := h.service.Search(ctx, c.Param("accountId"), text, query, currentPage, perPage, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error converting JSON logic: " + err.Error()})
+		rest.Error(c, err)
 		return
 	}
 
-	currentPage, _ := strconv.Atoi(c.DefaultQuery("currentPage", "1"))
-	perPage, _ := strconv.Atoi(c.DefaultQuery("perPage", "50"))
+	c.JSON(http.StatusOK, gin.H{
+		"results":    sparse(results, opts.Fields),
+		"pagination": pkg.NewPagination(currentPage, perPage, totalItems),
+	})
+}
 
-	if currentPage < 1 {
-		currentPage = 1
+func (h *Handler) QueryJsonLogic(c *gin.Context) {
+	mongoQuery, err := gojsonlogicmongodb.Convert(c.Request.Body)
+	if err != nil {
+		rest.InvalidRequest(c, err)
+		return
 	}
-	if perPage <= 0 {
-		perPage = 50
+
+	currentPage, perPage := rest.Page(c)
+	opts, err := listOptions(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
 	}
 
 	ctx := c.Request.Context()
-	results, totalItems, err := h.service.Pipeline(ctx, c.Param("accountId"), mongoQuery.Map(), currentPage, perPage)
+	if cursor, ok, count := rest.Cursor(c); ok {
+		page, err := h.service.SeekPipeline(ctx, c.Param("accountId"), mongoQuery.Map(), cursor, perPage, count, opts)
+		if err != nil {
+			rest.Error(c, err)
+			return
+		}
+		cursorPage(c, "results", perPage, page, opts.Fields)
+		return
+	}
+
+	results, totalItems, err := h.service.Pipeline(ctx, c.Param("accountId"), mongoQuery.Map(), currentPage, perPage, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		rest.Error(c, err)
 		return
 	}
 
 	pagination := pkg.NewPagination(currentPage, perPage, totalItems)
 
 	response := gin.H{
-		"results":    results,
+		"results":    sparse(results, opts.Fields),
 		"pagination": pagination,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// DanglingReferences manages reporting the relationships of an account whose target is not a valid entity.
+func (h *Handler) DanglingReferences(c *gin.Context) {
+	ctx := c.Request.Context()
+	dangling, err := h.service.DanglingReferences(ctx, c.Param("accountId"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+	if dangling == nil {
+		dangling = []profile.DanglingReference{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dangling": dangling})
+}
+
 func (h *Handler) CreateRelationship(context *gin.Context) {
 	var relationship profile.Relationship
 	if err := context.ShouldBindJSON(&relationship); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		rest.InvalidRequest(context, err)
 		return
 	}
 	accountId := context.Param("accountId")
 	entityId := context.Param("id")
+	version, err := rest.IfMatch(context)
+	if err != nil {
+		rest.Error(context, err)
+		return
+	}
 
 	ctx := context.Request.Context()
-	entityWithRelationships, err := h.service.AddRelationship(ctx, accountId, entityId, relationship)
+	entityWithRelationships, err := h.service.AddRelationship(ctx, accountId, entityId, version, relationship)
 	if err != nil {
-		err = errors.WithStack(err)
-		log.Printf("%+v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		rest.Error(context, err)
 		return
 	}
+	rest.SetETag(context, entityWithRelationships.Version)
 	context.JSON(http.StatusOK, entityWithRelationships)
 }
 
 func (h *Handler) ReplaceRelationships(context *gin.Context) {
 	var newRelationships []profile.Relationship
-	if err := context.BindJSON(&newRelationships); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	if err := context.ShouldBindJSON(&newRelationships); err != nil {
+		rest.InvalidRequest(context, err)
 		return
 	}
 	accountId := context.Param("accountId")
 	entityId := context.Param("id")
+	version, err := rest.IfMatch(context)
+	if err != nil {
+		rest.Error(context, err)
+		return
+	}
 
 	ctx := context.Request.Context()
-	entityWithRelationships, err := h.service.ReplaceRelationships(ctx, accountId, entityId, newRelationships)
+	entityWithRelationships, err := h.service.ReplaceRelationships(ctx, accountId, entityId, version, newRelationships)
 	if err != nil {
-		err = errors.WithStack(err)
-		log.Printf("%+v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		rest.Error(context, err)
 		return
 	}
+	rest.SetETag(context, entityWithRelationships.Version)
 	context.JSON(http.StatusOK, entityWithRelationships)
 }
+
+// UpdateRelationship manages merging attributes into those of a single relationship of an entity.
+func (h *Handler) UpdateRelationship(c *gin.Context) {
+	var body struct {
+		Attributes map[string]any `json:"attributes"` // Attributes to set, null removing one
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+	version, err := rest.IfMatch(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	entity, err := h.service.UpdateRelationship(
+		ctx, c.Param("accountId"), c.Param("id"), version, c.Param("relationshipId"), body.Attributes,
+	)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	rest.SetETag(c, entity.Version)
+	c.JSON(http.StatusOK, entity)
+}
+
+// RemoveRelationship manages removing a single relationship of an entity.
+func (h *Handler) RemoveRelationship(c *gin.Context) {
+	version, err := rest.IfMatch(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	entity, err := h.service.RemoveRelationship(ctx, c.Param("accountId"), c.Param("id"), version, c.Param("relationshipId"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	rest.SetETag(c, entity.Version)
+	c.JSON(http.StatusOK, entity)
+}
+
+// Relationships manages listing the relationships held by an entity, targeting it or both.
+func (h *Handler) Relationships(c *gin.Context) {
+	currentPage, perPage := rest.Page(c)
+
+	ctx := c.Request.Context()
+	edges, totalItems, err := h.service.Relationships(
+		ctx, c.Param("accountId"), c.Param("id"), c.Query("direction"), c.QueryArray("type"), currentPage, perPage,
+	)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"relationships": edges,
+		"pagination":    pkg.NewPagination(currentPage, perPage, totalItems),
+	})
+}
+
+// GetByIdentity manages fetching the entity holding a value of an identity key, e.g.
+// /entities/by/attributes.email/jane@example.com, optionally restricted to the entity type in ?type.
+func (h *Handler) GetByIdentity(c *gin.Context) {
+	ctx := c.Request.Context()
+	e, err := h.service.Resolve(ctx, c.Param("accountId"), c.Param("key"), c.Param("value"), c.Query("type"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	rest.SetETag(c, e.Version)
+	c.JSON(http.StatusOK, e)
+}
+
+// UpsertByIdentity manages replacing the entity holding a value of an identity key, creating it when no
+// entity holds the value.
+func (h *Handler) UpsertByIdentity(c *gin.Context) {
+	var entity profile.Entity
+	if err := c.ShouldBindJSON(&entity); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+	version, err := rest.IfMatch(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+	if version != 0 {
+		entity.Version = version
+	}
+
+	ctx := c.Request.Context()
+	e, created, err := h.service.Upsert(ctx, c.Param("accountId"), c.Param("key"), c.Param("value"), &entity)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	rest.SetETag(c, e.Version)
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, e)
+}
+
+// Traverse manages walking the relationship graph from an entity, returning the subgraph reached.
+func (h *Handler) Traverse(c *gin.Context) {
+	criteria := profile.TraversalCriteria{
+		Direction: c.Query("direction"),
+		Depth:     profile.DefaultTraversalDepth,
+		Types:     c.QueryArray("type"),
+	}
+	if depth, ok := c.GetQuery("depth"); ok {
+		var err error
+		if criteria.Depth, err = strconv.Atoi(depth); err != nil {
+			rest.Error(c, profile.ErrInvalidDepth)
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	graph, err := h.service.Traverse(ctx, c.Param("accountId"), c.Param("id"), criteria)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
+
+// cursorPage writes a page of cursor pagination, its entities under key restricted to fields.
+func cursorPage(c *gin.Context, key string, perPage int, page *profile.CursorPage, fields []string) {
+	entities := page.Entities
+	if entities == nil {
+		entities = []*profile.Entity{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		key: sparse(entities, fields),
+		"pagination": pkg.CursorPagination{
+			PerPage:    perPage,
+			NextCursor: page.NextCursor,
+			PrevCursor: page.PrevCursor,
+			TotalItems: page.TotalItems,
+		},
+	})
+}
+
+// sparse presents entities restricted to the top level keys of fields, along with the ID, so that the
+// fields left out are not rendered as empty values. It returns the entities as they are without fields.
+func sparse(entities []*profile.Entity, fields []string) any {
+	if len(fields) == 0 {
+		return entities
+	}
+	keys := map[string]bool{"id": true}
+	for _, field := range fields {
+		key, _, _ := strings.Cut(field, ".")
+		keys[key] = true
+	}
+
+	views := make([]map[string]any, 0, len(entities))
+	for _, e := range entities {
+		var view map[string]any
+		data, _ := json.Marshal(e)
+		_ = json.Unmarshal(data, &view)
+		for key := range view {
+			if !keys[key] {
+				delete(view, key)
+			}
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// listOptions returns the query options of a list request, along with its sort and fields.
+func listOptions(c *gin.Context) (profile.QueryOptions, error) {
+	opts := queryOptions(c)
+	sort, err := rest.Sort(c)
+	if err != nil {
+		return opts, err
+	}
+	opts.Sort = sort
+	opts.Fields = rest.Fields(c)
+	return opts, nil
+}
+
+// queryOptions reads the includeDeleted query parameter, which makes reads return soft deleted entities.
+func queryOptions(c *gin.Context) profile.QueryOptions {
+	includeDeleted, _ := strconv.ParseBool(c.Query("includeDeleted"))
+	return profile.QueryOptions{IncludeDeleted: includeDeleted}
+}
@@ -0,0 +1,769 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	iidentity "github.com/dportaluppi/customer-profiles-api/internal/identity"
+	irelationship "github.com/dportaluppi/customer-profiles-api/internal/relationship"
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/identity"
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/dportaluppi/customer-profiles-api/pkg/relationship"
+	"github.com/dportaluppi/customer-profiles-api/pkg/schema"
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	revisions := repository.NewMemoryRepository[*profile.Revision]()
+	validator := schema.NewValidator(repository.NewMemoryRepository[*schema.Schema]())
+	searchFields := repository.NewMemoryRepository[*profile.SearchFields]()
+	store := repository.NewMemoryRepository[*profile.Entity]()
+	repo := profile.NewIndexer(profile.NewRecorder(store, revisions), searchFields)
+	relationshipTypes := repository.NewMemoryRepository[*relationship.Definition]()
+	types := relationship.NewGetter(relationshipTypes)
+	identityKeys := repository.NewMemoryRepository[*identity.Key]()
+	saver := profile.NewSaver(repo, validator, types)
+	deleter := profile.NewDeleter(repo, profile.OnDeleteRestrict)
+	h := NewHandler(
+		saver,
+		deleter,
+		profile.NewGetter(repo),
+		profile.NewMerger(repo, validator, types, deleter),
+		profile.NewBulker(repo, validator, types, profile.OnDeleteRestrict),
+		profile.NewHistorian(repo, revisions, saver),
+		profile.NewChecker(repo),
+		profile.NewNavigator(repo, store, types),
+		profile.NewResolver(repo, identity.NewGetter(identityKeys), saver),
+		profile.NewDeduplicator(
+			repo,
+			store,
+			repository.NewMemoryRepository[*profile.MatchRules](),
+			repository.NewMemoryRepository[*profile.DuplicateScan](),
+		),
+		profile.NewSearcher(store, searchFields),
+		profile.NewAggregator(store),
+	)
+
+	router := gin.New()
+	router.Use(rest.RequestID(), rest.Actor())
+	router.POST("/accounts/:accountId/entities", h.Create)
+	router.PUT("/accounts/:accountId/entities/:id", h.Update)
+	router.PATCH("/accounts/:accountId/entities/:id", h.Patch)
+	router.DELETE("/accounts/:accountId/entities/:id", h.Delete)
+	router.POST("/accounts/:accountId/entities/:id/restore", h.Restore)
+	router.GET("/accounts/:accountId/entities/:id/history", h.History)
+	router.POST("/accounts/:accountId/entities/:id/revert", h.Revert)
+	router.GET("/accounts/:accountId/entities/:id", h.GetByID)
+	router.GET("/accounts/:accountId/entities/by/:key/:value", h.GetByIdentity)
+	router.PUT("/accounts/:accountId/entities/by/:key/:value", h.UpsertByIdentity)
+	router.GET("/accounts/:accountId/entities", h.GetAll)
+	router.POST("/accounts/:accountId/entities/search", h.Query)
+	router.POST("/accounts/:accountId/entities/aggregate", h.Aggregate)
+	router.POST("/accounts/:accountId/entities/:id/relationships", h.CreateRelationship)
+	router.PUT("/accounts/:accountId/entities/:id/relationships", h.ReplaceRelationships)
+	router.PATCH("/accounts/:accountId/entities/:id/relationships/:relationshipId", h.UpdateRelationship)
+	router.DELETE("/accounts/:accountId/entities/:id/relationships/:relationshipId", h.RemoveRelationship)
+	router.GET("/accounts/:accountId/entities/:id/relationships", h.Relationships)
+	router.GET("/accounts/:accountId/entities/:id/graph", h.Traverse)
+	router.GET("/accounts/:accountId/relationships/dangling", h.DanglingReferences)
+	router.GET("/accounts/:accountId/entities/:id/duplicates", h.Duplicates)
+	router.PUT("/accounts/:accountId/match-rules/:entityType", h.SetMatchRules)
+	router.POST("/accounts/:accountId/duplicate-scans", h.StartDuplicateScan)
+	router.GET("/accounts/:accountId/duplicate-scans/:scanId", h.DuplicateScan)
+	router.GET("/accounts/:accountId/search-fields/:entityType", h.SearchFields)
+	router.PUT("/accounts/:accountId/search-fields/:entityType", h.SetSearchFields)
+	router.GET("/accounts/:accountId/entity-types", h.Types)
+	h.TypedRoutes(router)
+
+	rt := irelationship.NewHandler(relationship.NewSaver(relationshipTypes), relationship.NewDeleter(relationshipTypes), types)
+	router.POST("/accounts/:accountId/relationship-types", rt.Create)
+	router.PUT("/accounts/:accountId/relationship-types/:name", rt.Update)
+
+	ik := iidentity.NewHandler(identity.NewSaver(identityKeys, store), identity.NewDeleter(identityKeys, store), identity.NewGetter(identityKeys))
+	router.POST("/accounts/:accountId/identity-keys", ik.Create)
+	router.DELETE("/accounts/:accountId/identity-keys/:entityType/:field", ik.Delete)
+	return router
+}
+
+func doRequest(t *testing.T, router *gin.Engine, method, path string, body any, headers ...string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	router := newTestRouter()
+
+	rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", profile.Entity{
+		Type:       "Contact",
+		Attributes: profile.Attribute{"email": "ana@example.com"},
+	})
+	require.Equal(t, http.StatusOK, rec.Code)
+	var created profile.Entity
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.NotEmpty(t, created.ID)
+	require.Equal(t, "acc", created.AccountID)
+
+	t.Run("gets an entity by id", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+created.ID, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("answers 404 for an entity of another account", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/other/entities/"+created.ID, nil)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+
+		var body rest.ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Equal(t, rest.CodeNotFound, body.Error.Code)
+		require.Equal(t, rec.Header().Get(rest.RequestIDHeader), body.Error.RequestID)
+	})
+
+	t.Run("answers 400 for a malformed id", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/not-an-id", nil)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("updates with a matching If-Match and rejects a stale one", func(t *testing.T) {
+		update := profile.Entity{Type: "Contact", Attributes: profile.Attribute{"email": "ana@example.com", "name": "Ana"}}
+		rec := doRequest(t, router, http.MethodPut, "/accounts/acc/entities/"+created.ID, update, "If-Match", `"1"`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+		rec = doRequest(t, router, http.MethodPut, "/accounts/acc/entities/"+created.ID, update, "If-Match", `"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	})
+
+	t.Run("lists the history of an entity", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+created.ID+"/history", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Revisions []profile.Revision `json:"revisions"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Revisions, 2)
+		require.Equal(t, profile.OperationCreate, body.Revisions[0].Operation)
+		require.Equal(t, profile.OperationUpdate, body.Revisions[1].Operation)
+		require.Equal(t, []profile.Change{
+			{Op: profile.ChangeAdd, Path: "/attributes/name", Value: "Ana"},
+		}, body.Revisions[1].Changes)
+	})
+
+	t.Run("reads an entity as of a past time", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+created.ID+"?asOf="+time.Now().Add(time.Second).UTC().Format(time.RFC3339), nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rec = doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+created.ID+"?asOf=2000-01-01T00:00:00Z", nil)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("reverts an entity to a past version", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/"+created.ID+"/revert", map[string]any{"version": 1}, "If-Match", `"2"`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+		var reverted profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reverted))
+		require.Equal(t, profile.Attribute{"email": "ana@example.com"}, reverted.Attributes)
+
+		rec = doRequest(t, router, http.MethodPost, "/accounts/acc/entities/"+created.ID+"/revert", map[string]any{"version": 9})
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("searches entities", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/search", search.Criteria{
+			Filters: []search.Condition{{Field: "attributes.email", Value: "ana@example.com"}},
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Results []profile.Entity `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Results, 1)
+		require.Equal(t, created.ID, body.Results[0].ID)
+	})
+
+	t.Run("soft deletes an entity", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodDelete, "/accounts/acc/entities/"+created.ID, nil, rest.ActorHeader, "ops@example.com")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rec = doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+created.ID, nil)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = doRequest(t, router, http.MethodPost, "/accounts/acc/entities/search", search.Criteria{
+			Filters: []search.Condition{{Field: "attributes.email", Value: "ana@example.com"}},
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var body struct {
+			Results []profile.Entity `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Empty(t, body.Results)
+
+		rec = doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+created.ID+"?includeDeleted=true", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var deleted profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &deleted))
+		require.NotNil(t, deleted.DeletedAt)
+		require.Equal(t, "ops@example.com", deleted.DeletedBy)
+	})
+
+	t.Run("restores a deleted entity", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/"+created.ID+"/restore", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rec = doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+created.ID, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var restored profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &restored))
+		require.Nil(t, restored.DeletedAt)
+		require.Empty(t, restored.DeletedBy)
+
+		rec = doRequest(t, router, http.MethodPost, "/accounts/acc/entities/"+created.ID+"/restore", nil)
+		require.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestTypedHandler(t *testing.T) {
+	router := newTestRouter()
+
+	rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entity-types/Contact/entities", profile.Entity{
+		Attributes: profile.Attribute{"email": "ana@example.com"},
+	})
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var contact profile.Entity
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &contact))
+	require.Equal(t, "Contact", contact.Type)
+
+	rec = doRequest(t, router, http.MethodPost, "/accounts/acc/entity-types/Store/entities", profile.Entity{Type: "Store"})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	t.Run("rejects a body of another type", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entity-types/Contact/entities", profile.Entity{Type: "Store"})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("answers 404 for an entity of another type", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entity-types/Store/entities/"+contact.ID, nil)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = doRequest(t, router, http.MethodDelete, "/accounts/acc/entity-types/Store/entities/"+contact.ID, nil)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = doRequest(t, router, http.MethodGet, "/accounts/acc/entity-types/Contact/entities/"+contact.ID, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("lists only the entities of the path type", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entity-types/Contact/entities", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Entities []profile.Entity `json:"entities"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Entities, 1)
+		require.Equal(t, contact.ID, body.Entities[0].ID)
+	})
+
+	t.Run("serves the first contract paths as an alias", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/account/acc/entities/Contact/"+contact.ID, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("lists the types in use with their counts", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entity-types", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"types": [{"type": "Contact", "count": 1}, {"type": "Store", "count": 1}]}`, rec.Body.String())
+	})
+
+	t.Run("deletes an entity of the path type", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodDelete, "/accounts/acc/entity-types/Contact/entities/"+contact.ID, nil)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = doRequest(t, router, http.MethodGet, "/accounts/acc/entity-types", nil)
+		require.JSONEq(t, `{"types": [{"type": "Store", "count": 1}]}`, rec.Body.String())
+	})
+}
+
+func TestRelationshipIntegrity(t *testing.T) {
+	router := newTestRouter()
+
+	create := func(t *testing.T, account string) profile.Entity {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/"+account+"/entities", profile.Entity{Type: "Contact"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var e profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		return e
+	}
+	contact, store, foreign := create(t, "acc"), create(t, "acc"), create(t, "other")
+
+	tests := []struct {
+		it       string
+		targetID string
+		want     int
+	}{
+		{it: "rejects a missing target", targetID: "65a000000000000000000000", want: http.StatusBadRequest},
+		{it: "rejects a target of another account", targetID: foreign.ID, want: http.StatusBadRequest},
+		{it: "rejects a self reference", targetID: contact.ID, want: http.StatusBadRequest},
+		{it: "accepts an active target of the account", targetID: store.ID, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/"+contact.ID+"/relationships",
+				profile.Relationship{Type: "buysFrom", TargetID: tt.targetID})
+			require.Equal(t, tt.want, rec.Code)
+		})
+	}
+
+	t.Run("restricts deleting a referenced entity", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodDelete, "/accounts/acc/entities/"+store.ID, nil)
+		require.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("reports no dangling references in a consistent account", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/relationships/dangling", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"dangling": []}`, rec.Body.String())
+	})
+}
+
+func TestRelationshipGraph(t *testing.T) {
+	router := newTestRouter()
+
+	create := func(t *testing.T, e profile.Entity) profile.Entity {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", e)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		return e
+	}
+	store := create(t, profile.Entity{Type: "Store"})
+	rep := create(t, profile.Entity{Type: "SalesRep", Relationships: []profile.Relationship{{Type: "sellsFor", TargetID: store.ID}}})
+	contact := create(t, profile.Entity{Type: "Contact", Relationships: []profile.Relationship{{Type: "buysFrom", TargetID: store.ID}}})
+
+	t.Run("lists the relationships targeting an entity", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+store.ID+"/relationships?direction=in&type=buysFrom", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Relationships []profile.Edge `json:"relationships"`
+			Pagination    pkg.Pagination `json:"pagination"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Equal(t, []profile.Edge{{SourceID: contact.ID, Type: "buysFrom", TargetID: store.ID}}, withoutIDs(body.Relationships))
+		require.Equal(t, 1, body.Pagination.TotalItems)
+	})
+
+	t.Run("returns the subgraph reached from an entity", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+rep.ID+"/graph?direction=both&depth=2", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var graph profile.Graph
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &graph))
+		require.Len(t, graph.Nodes, 3)
+		require.Equal(t, rep.ID, graph.Nodes[0].ID)
+		require.ElementsMatch(t, []profile.Edge{
+			{SourceID: rep.ID, Type: "sellsFor", TargetID: store.ID},
+			{SourceID: contact.ID, Type: "buysFrom", TargetID: store.ID},
+		}, withoutIDs(graph.Edges))
+	})
+
+	tests := []struct {
+		it    string
+		query string
+	}{
+		{it: "rejects an unknown direction", query: "?direction=sideways"},
+		{it: "rejects a malformed depth", query: "?depth=two"},
+		{it: "rejects a zero depth", query: "?depth=0"},
+		{it: "rejects a depth over the limit", query: "?depth=50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+rep.ID+"/graph"+tt.query, nil)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
+
+// withoutIDs clears the generated relationship IDs of edges so they can be compared.
+func withoutIDs(edges []profile.Edge) []profile.Edge {
+	for i := range edges {
+		edges[i].ID = ""
+	}
+	return edges
+}
+
+func TestRelationshipTypes(t *testing.T) {
+	router := newTestRouter()
+
+	create := func(t *testing.T, entityType string) profile.Entity {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", profile.Entity{Type: entityType})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var e profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		return e
+	}
+	store, other, contact, rep := create(t, "Store"), create(t, "Store"), create(t, "Contact"), create(t, "SalesRep")
+
+	rec := doRequest(t, router, http.MethodPost, "/accounts/acc/relationship-types", relationship.Definition{
+		Name:        "buysFrom",
+		Inverse:     "sellsTo",
+		SourceTypes: []string{"Contact"},
+		TargetTypes: []string{"Store"},
+		Cardinality: relationship.OneToOne,
+		Attributes:  []relationship.Attribute{{Name: "since", Type: relationship.AttributeDate, Required: true}},
+	})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	t.Run("rejects a name already used as an inverse", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/relationship-types", relationship.Definition{Name: "sellsTo"})
+		require.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	tests := []struct {
+		it           string
+		sourceID     string
+		relationship profile.Relationship
+		want         int
+	}{
+		{
+			it:           "rejects a source of another entity type",
+			sourceID:     rep.ID,
+			relationship: profile.Relationship{Type: "buysFrom", TargetID: store.ID, Attributes: map[string]any{"since": "2024-01-31"}},
+			want:         http.StatusBadRequest,
+		},
+		{
+			it:           "rejects a missing required attribute",
+			sourceID:     contact.ID,
+			relationship: profile.Relationship{Type: "buysFrom", TargetID: store.ID},
+			want:         http.StatusBadRequest,
+		},
+		{
+			it:           "rejects an attribute of the wrong type",
+			sourceID:     contact.ID,
+			relationship: profile.Relationship{Type: "buysFrom", TargetID: store.ID, Attributes: map[string]any{"since": "yesterday"}},
+			want:         http.StatusBadRequest,
+		},
+		{
+			it:           "accepts a relationship following its type",
+			sourceID:     contact.ID,
+			relationship: profile.Relationship{Type: "buysFrom", TargetID: store.ID, Attributes: map[string]any{"since": "2024-01-31"}},
+			want:         http.StatusOK,
+		},
+		{
+			it:           "rejects a second target of a one-to-one type",
+			sourceID:     contact.ID,
+			relationship: profile.Relationship{Type: "buysFrom", TargetID: other.ID, Attributes: map[string]any{"since": "2024-01-31"}},
+			want:         http.StatusConflict,
+		},
+		{
+			it:           "accepts free form relationship types",
+			sourceID:     rep.ID,
+			relationship: profile.Relationship{Type: "visits", TargetID: store.ID},
+			want:         http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/"+tt.sourceID+"/relationships", tt.relationship)
+			require.Equal(t, tt.want, rec.Code)
+		})
+	}
+
+	t.Run("rejects a second source of a one-to-one target", func(t *testing.T) {
+		second := create(t, "Contact")
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/"+second.ID+"/relationships",
+			profile.Relationship{Type: "buysFrom", TargetID: store.ID, Attributes: map[string]any{"since": "2024-02-01"}})
+		require.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("presents the relationship from the target with its inverse name", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+store.ID+"/relationships?direction=in&type=sellsTo", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Relationships []profile.Edge `json:"relationships"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Relationships, 1)
+		edge := body.Relationships[0]
+		require.Equal(t, contact.ID, edge.SourceID)
+		require.Equal(t, "buysFrom", edge.Type)
+		require.Equal(t, "sellsTo", edge.Inverse)
+		require.Equal(t, map[string]any{"since": "2024-01-31"}, edge.Attributes)
+	})
+}
+
+func TestRelationshipLifecycle(t *testing.T) {
+	router := newTestRouter()
+
+	create := func(t *testing.T, e profile.Entity) profile.Entity {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", e)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		return e
+	}
+	store, other := create(t, profile.Entity{Type: "Store"}), create(t, profile.Entity{Type: "Store"})
+	contact := create(t, profile.Entity{Type: "Contact", Relationships: []profile.Relationship{
+		{Type: "buysFrom", TargetID: store.ID, Attributes: map[string]any{"role": "owner", "since": "2024"}},
+		{Type: "buysFrom", TargetID: other.ID},
+	}})
+	first := contact.Relationships[0]
+	require.NotEmpty(t, first.ID)
+	require.NotNil(t, first.CreatedAt)
+	path := "/accounts/acc/entities/" + contact.ID + "/relationships/"
+
+	t.Run("patches the attributes of a relationship", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPatch, path+first.ID,
+			map[string]any{"attributes": map[string]any{"role": "manager", "since": nil}}, "If-Match", `"1"`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var e profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		patched := e.Relationships[0]
+		require.Equal(t, first.ID, patched.ID)
+		require.Equal(t, map[string]any{"role": "manager"}, patched.Attributes)
+		require.NotNil(t, patched.UpdatedAt)
+		require.Nil(t, e.Relationships[1].UpdatedAt)
+	})
+
+	t.Run("removes a single relationship", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodDelete, path+first.ID, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var e profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		require.Len(t, e.Relationships, 1)
+		require.Equal(t, other.ID, e.Relationships[0].TargetID)
+	})
+
+	t.Run("answers 404 for an unknown relationship", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodDelete, path+first.ID, nil)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("rejects a stale version", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPatch, path+contact.Relationships[1].ID,
+			map[string]any{"attributes": map[string]any{"role": "owner"}}, "If-Match", `"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	})
+}
+
+func TestIdentity(t *testing.T) {
+	router := newTestRouter()
+
+	create := func(t *testing.T, e profile.Entity) *httptest.ResponseRecorder {
+		return doRequest(t, router, http.MethodPost, "/accounts/acc/entities", e)
+	}
+	contact := func(email string) profile.Entity {
+		return profile.Entity{Type: "Contact", Attributes: profile.Attribute{"email": email}}
+	}
+	require.Equal(t, http.StatusOK, create(t, contact("jane@example.com")).Code)
+
+	t.Run("rejects a key over values already shared", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, create(t, profile.Entity{Type: "Store", Attributes: profile.Attribute{"code": "A"}}).Code)
+		require.Equal(t, http.StatusOK, create(t, profile.Entity{Type: "Store", Attributes: profile.Attribute{"code": "A"}}).Code)
+
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/identity-keys", identity.Key{EntityType: "Store", Field: "attributes.code"})
+		require.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("rejects a nested field", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/identity-keys", identity.Key{EntityType: "Contact", Field: "attributes.address.zip"})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	rec := doRequest(t, router, http.MethodPost, "/accounts/acc/identity-keys", identity.Key{EntityType: "Contact", Field: "attributes.email"})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	t.Run("rejects a create colliding with an identity", func(t *testing.T) {
+		require.Equal(t, http.StatusConflict, create(t, contact("jane@example.com")).Code)
+	})
+
+	t.Run("leaves other entity types alone", func(t *testing.T) {
+		rec := create(t, profile.Entity{Type: "Store", Attributes: profile.Attribute{"email": "jane@example.com"}})
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("resolves an entity by identity", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/by/attributes.email/jane@example.com", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var e profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		require.Equal(t, "Contact", e.Type)
+
+		rec = doRequest(t, router, http.MethodGet, "/accounts/acc/entities/by/attributes.email/john@example.com", nil)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+		rec = doRequest(t, router, http.MethodGet, "/accounts/acc/entities/by/attributes.name/jane", nil)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("upserts an entity by identity", func(t *testing.T) {
+		path := "/accounts/acc/entities/by/attributes.email/john@example.com"
+		rec := doRequest(t, router, http.MethodPut, path, profile.Entity{Attributes: profile.Attribute{"name": "John"}})
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var created profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		require.Equal(t, "Contact", created.Type)
+		require.Equal(t, "john@example.com", created.Attributes["email"])
+
+		rec = doRequest(t, router, http.MethodPut, path, profile.Entity{Attributes: profile.Attribute{"name": "Johnny"}}, "If-Match", `"1"`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var updated profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		require.Equal(t, created.ID, updated.ID)
+		require.Equal(t, "Johnny", updated.Attributes["name"])
+		require.Equal(t, "john@example.com", updated.Attributes["email"])
+
+		rec = doRequest(t, router, http.MethodPut, path, profile.Entity{}, "If-Match", `"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	})
+
+	t.Run("drops the key and its index", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodDelete, "/accounts/acc/identity-keys/Contact/attributes.email", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, http.StatusOK, create(t, contact("jane@example.com")).Code)
+	})
+}
+
+func TestDuplicates(t *testing.T) {
+	router := newTestRouter()
+
+	create := func(t *testing.T, attributes profile.Attribute) profile.Entity {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", profile.Entity{Type: "Contact", Attributes: attributes})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var e profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		return e
+	}
+	jane := create(t, profile.Attribute{"phone": "+1 555 010 2030"})
+	again := create(t, profile.Attribute{"phone": "555-010-2030"})
+	create(t, profile.Attribute{"phone": "555-010-9999"})
+
+	t.Run("lists the duplicates of an entity", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities/"+jane.ID+"/duplicates", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var body struct {
+			Duplicates []profile.Duplicate `json:"duplicates"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Duplicates, 1)
+		require.Equal(t, again.ID, body.Duplicates[0].Entity.ID)
+		require.Equal(t, []string{"attributes.phone"}, body.Duplicates[0].Reasons)
+	})
+
+	t.Run("rejects invalid match rules", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPut, "/accounts/acc/match-rules/Contact", profile.MatchRules{Threshold: 2})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("scans the account for merge suggestions", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/duplicate-scans?type=Contact", nil)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		var scan profile.DuplicateScan
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &scan))
+
+		require.Eventually(t, func() bool {
+			rec = doRequest(t, router, http.MethodGet, "/accounts/acc/duplicate-scans/"+scan.ID, nil)
+			return rec.Code == http.StatusOK && json.Unmarshal(rec.Body.Bytes(), &scan) == nil && scan.Status != profile.ScanRunning
+		}, time.Second, 10*time.Millisecond)
+		require.Equal(t, profile.ScanCompleted, scan.Status)
+		require.Equal(t, []profile.MergeSuggestion{{
+			TargetID:   jane.ID,
+			SourceID:   again.ID,
+			EntityType: "Contact",
+			Score:      0.8,
+			Reasons:    []string{"attributes.phone"},
+		}}, scan.Suggestions)
+	})
+}
+
+func TestPatch(t *testing.T) {
+	router := newTestRouter()
+
+	rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", profile.Entity{
+		Type:       "Contact",
+		Attributes: profile.Attribute{"email": "jane@example.com", "name": "Jane"},
+	})
+	require.Equal(t, http.StatusOK, rec.Code)
+	var created profile.Entity
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	path := "/accounts/acc/entities/" + created.ID
+
+	patch := func(t *testing.T, contentType string, body any, headers ...string) *httptest.ResponseRecorder {
+		return doRequest(t, router, http.MethodPatch, path, body, append([]string{"Content-Type", contentType}, headers...)...)
+	}
+
+	t.Run("merges the patch into the entity", func(t *testing.T) {
+		rec := patch(t, profile.PatchMerge, map[string]any{"attributes": map[string]any{"email": "jane@doe.com", "name": nil}}, "If-Match", `"1"`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, `"2"`, rec.Header().Get("ETag"))
+		var patched profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &patched))
+		require.Equal(t, profile.Attribute{"email": "jane@doe.com"}, patched.Attributes)
+		require.Equal(t, "Contact", patched.Type)
+	})
+
+	t.Run("applies a json patch", func(t *testing.T) {
+		rec := patch(t, profile.PatchJSON, []map[string]any{
+			{"op": "test", "path": "/attributes/email", "value": "jane@doe.com"},
+			{"op": "add", "path": "/metadata/source", "value": "crm"},
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var patched profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &patched))
+		require.Equal(t, int64(3), patched.Version)
+		require.Equal(t, profile.Metadata{"source": "crm"}, patched.Metadata)
+	})
+
+	t.Run("records the patch in the history", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, path+"/history", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var body struct {
+			Revisions []profile.Revision `json:"revisions"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		last := body.Revisions[len(body.Revisions)-1]
+		require.Equal(t, profile.OperationPatch, last.Operation)
+		require.Equal(t, []profile.Change{{Op: profile.ChangeAdd, Path: "/metadata/source", Value: "crm"}}, last.Changes)
+	})
+
+	tests := []struct {
+		it          string
+		contentType string
+		body        any
+		headers     []string
+		want        int
+	}{
+		{it: "rejects a stale If-Match", contentType: profile.PatchMerge, body: map[string]any{}, headers: []string{"If-Match", `"1"`}, want: http.StatusPreconditionFailed},
+		{it: "rejects a failed test", contentType: profile.PatchJSON, body: []map[string]any{{"op": "test", "path": "/type", "value": "Store"}}, want: http.StatusConflict},
+		{it: "rejects patching the id", contentType: profile.PatchMerge, body: map[string]any{"id": "other"}, want: http.StatusBadRequest},
+		{it: "rejects a plain json body", contentType: "application/json", body: map[string]any{}, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			require.Equal(t, tt.want, patch(t, tt.contentType, tt.body, tt.headers...).Code)
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package profile
+
+import (
+	"net/http"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/gin-gonic/gin"
+)
+
+// The handlers below serve the entity type scoped routes of the OpenAPI contract, where the type in the
+// path is enforced on writes and entities of other types are not found.
+
+// TypedRoutes registers the entity type scoped routes under /accounts/:accountId/entity-types/:entityType/entities,
+// next to the other routes of the account, since a type following /accounts/:accountId/entities would collide
+// with the entity IDs. The /account/:accountId/entities/:entityType paths of the first revision of the
+// contract are kept as an alias.
+func (h *Handler) TypedRoutes(router gin.IRouter) {
+	for _, typed := range []gin.IRouter{
+		router.Group("/accounts/:accountId/entity-types/:entityType/entities"),
+		router.Group("/account/:accountId/entities/:entityType"),
+	} {
+		typed.POST("", h.CreateOfType)
+		typed.GET("", h.GetAllOfType)
+		typed.POST("/search", h.QueryOfType)
+		typed.GET("/:id", h.GetByIDOfType)
+		typed.PUT("/:id", h.UpdateOfType)
+		typed.DELETE("/:id", h.DeleteOfType)
+	}
+}
+
+// CreateOfType manages the creation of a new entity of the path type.
+func (h *Handler) CreateOfType(c *gin.Context) {
+	var e profile.Entity
+	if err := c.ShouldBindJSON(&e); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+	if err := enforceType(c, &e); err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	created, err := h.service.Create(ctx, c.Param("accountId"), &e)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	rest.SetETag(c, created.Version)
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateOfType manages the update of an existing entity of the path type.
+func (h *Handler) UpdateOfType(c *gin.Context) {
+	var entity profile.Entity
+	if err := c.ShouldBindJSON(&entity); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+	if err := enforceType(c, &entity); err != nil {
+		rest.Error(c, err)
+		return
+	}
+	version, err := rest.IfMatch(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+	if version != 0 {
+		entity.Version = version
+	}
+
+	ctx := c.Request.Context()
+	accountId, id := c.Param("accountId"), c.Param("id")
+	if _, err = h.service.GetByID(ctx, accountId, id, typeOptions(c)); err != nil {
+		rest.Error(c, err)
+		return
+	}
+	updated, err := h.service.Update(ctx, accountId, id, &entity)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	rest.SetETag(c, updated.Version)
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteOfType manages the deletion of an entity of the path type.
+func (h *Handler) DeleteOfType(c *gin.Context) {
+	version, err := rest.IfMatch(c)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	accountId, id := c.Param("accountId"), c.Param("id")
+	if _, err = h.service.GetByID(ctx, accountId, id, typeOptions(c)); err != nil {
+		rest.Error(c, err)
+		return
+	}
+	if err = h.service.Delete(ctx, accountId, id, version); err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetByIDOfType manages fetching an entity of the path type by its ID.
+func (h *Handler) GetByIDOfType(c *gin.Context) {
+	ctx := c.Request.Context()
+	entity, err := h.service.GetByID(ctx, c.Param("accountId"), c.Param("id"), typeOptions(c))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	rest.SetETag(c, entity.Version)
+	c.JSON(http.StatusOK, entity)
+}
+
+// GetAllOfType manages fetching all entities of the path type with pagination.
+func (h *Handler) GetAllOfType(c *gin.Context) {
+	currentPage, perPage := rest.Page(c)
+
+	ctx := c.Request.Context()
+	entities, totalItems, err := h.service.GetAll(ctx, c.Param("accountId"), currentPage, perPage, typeOptions(c))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entities":   entities,
+		"pagination": pkg.NewPagination(currentPage, perPage, totalItems),
+	})
+}
+
+// QueryOfType manages searching the entities of the path type.
+func (h *Handler) QueryOfType(c *gin.Context) {
+	var query map[string]interface{}
+	if err := c.ShouldBindJSON(&query); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	currentPage, perPage := rest.Page(c)
+
+	ctx := c.Request.Context()
+	results, totalItems, err := h.service.Query(ctx, c.Param("accountId"), query, currentPage, perPage, typeOptions(c))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":    results,
+		"pagination": pkg.NewPagination(currentPage, perPage, totalItems),
+	})
+}
+
+// Types manages listing the entity types in use in an account with their counts.
+func (h *Handler) Types(c *gin.Context) {
+	ctx := c.Request.Context()
+	types, err := h.service.Types(ctx, c.Param("accountId"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"types": types})
+}
+
+// typeOptions returns the query options of the request restricted to the path type.
+func typeOptions(c *gin.Context) profile.QueryOptions {
+	opts := queryOptions(c)
+	opts.Type = c.Param("entityType")
+	return opts
+}
+
+// enforceType sets the path type on an entity, rejecting a body holding a different one.
+func enforceType(c *gin.Context, e *profile.Entity) error {
+	entityType := c.Param("entityType")
+	if e.Type != "" && e.Type != entityType {
+		return profile.ErrTypeMismatch
+	}
+	e.Type = entityType
+	return nil
+}
@@ -0,0 +1,184 @@
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorPagination(t *testing.T) {
+	router := newTestRouter()
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", profile.Entity{
+			Type:       "Contact",
+			Attributes: profile.Attribute{"rank": i},
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var created profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		ids = append(ids, created.ID)
+	}
+	rec := doRequest(t, router, http.MethodPost, "/accounts/other/entities", profile.Entity{Type: "Contact"})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	type page struct {
+		Entities   []profile.Entity     `json:"entities"`
+		Results    []profile.Entity     `json:"results"`
+		Pagination pkg.CursorPagination `json:"pagination"`
+	}
+	get := func(t *testing.T, method, path string, body any) page {
+		rec := doRequest(t, router, method, path, body)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var p page
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+		return p
+	}
+	pageIDs := func(entities []profile.Entity) []string {
+		var found []string
+		for _, e := range entities {
+			found = append(found, e.ID)
+		}
+		return found
+	}
+
+	t.Run("walks the pages forward and back", func(t *testing.T) {
+		first := get(t, http.MethodGet, "/accounts/acc/entities?cursor=&perPage=2&count=true", nil)
+		require.Equal(t, ids[:2], pageIDs(first.Entities))
+		require.Empty(t, first.Pagination.PrevCursor)
+		require.Equal(t, 5, *first.Pagination.TotalItems)
+
+		second := get(t, http.MethodGet, "/accounts/acc/entities?perPage=2&cursor="+first.Pagination.NextCursor, nil)
+		require.Equal(t, ids[2:4], pageIDs(second.Entities))
+		require.Nil(t, second.Pagination.TotalItems)
+
+		last := get(t, http.MethodGet, "/accounts/acc/entities?perPage=2&cursor="+second.Pagination.NextCursor, nil)
+		require.Equal(t, ids[4:], pageIDs(last.Entities))
+		require.Empty(t, last.Pagination.NextCursor)
+
+		back := get(t, http.MethodGet, "/accounts/acc/entities?perPage=2&cursor="+last.Pagination.PrevCursor, nil)
+		require.Equal(t, ids[2:4], pageIDs(back.Entities))
+		require.Equal(t, second.Pagination, back.Pagination)
+
+		start := get(t, http.MethodGet, "/accounts/acc/entities?perPage=2&cursor="+back.Pagination.PrevCursor, nil)
+		require.Equal(t, ids[:2], pageIDs(start.Entities))
+		require.Empty(t, start.Pagination.PrevCursor)
+	})
+
+	t.Run("pages through query results", func(t *testing.T) {
+		query := search.Criteria{Filters: []search.Condition{{Field: "attributes.rank", Operator: search.OpGte, Value: 1}}}
+		first := get(t, http.MethodPost, "/accounts/acc/entities/search?cursor=&perPage=3&count=true", query)
+		require.Equal(t, ids[1:4], pageIDs(first.Results))
+		require.Equal(t, 4, *first.Pagination.TotalItems)
+
+		next := get(t, http.MethodPost, "/accounts/acc/entities/search?perPage=3&cursor="+first.Pagination.NextCursor, query)
+		require.Equal(t, ids[4:], pageIDs(next.Results))
+	})
+
+	t.Run("counts only the entities of the account in offset pagination", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities?perPage=2", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var body struct {
+			Pagination pkg.Pagination `json:"pagination"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Equal(t, 5, body.Pagination.TotalItems)
+	})
+
+	t.Run("rejects a malformed cursor", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities?cursor=bogus", nil)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestSortAndFields(t *testing.T) {
+	router := newTestRouter()
+
+	purchases := []string{"2024-03-01", "2024-01-15", "", "2024-02-10"}
+	ids := map[string]string{}
+	for i, purchase := range purchases {
+		attributes := profile.Attribute{"email": fmt.Sprintf("user%d@example.com", i), "name": fmt.Sprintf("User %d", i)}
+		if purchase != "" {
+			attributes["lastPurchase"] = purchase
+		}
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", profile.Entity{Type: "Contact", Attributes: attributes})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var created profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		ids[purchase] = created.ID
+	}
+	byPurchase := []string{ids["2024-03-01"], ids["2024-02-10"], ids["2024-01-15"], ids[""]}
+
+	type page struct {
+		Entities   []map[string]any     `json:"entities"`
+		Results    []map[string]any     `json:"results"`
+		Pagination pkg.CursorPagination `json:"pagination"`
+	}
+	get := func(t *testing.T, method, path string, body any) page {
+		rec := doRequest(t, router, method, path, body)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var p page
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+		return p
+	}
+	pageIDs := func(views []map[string]any) []string {
+		var found []string
+		for _, v := range views {
+			found = append(found, v["id"].(string))
+		}
+		return found
+	}
+
+	t.Run("sorts and restricts the listed entities", func(t *testing.T) {
+		p := get(t, http.MethodGet, "/accounts/acc/entities?sort=attributes.lastPurchase:-1,createdAt:1&fields=attributes.email,type", nil)
+		require.Equal(t, byPurchase, pageIDs(p.Entities))
+		require.Equal(t, map[string]any{
+			"id":         ids["2024-03-01"],
+			"type":       "Contact",
+			"attributes": map[string]any{"email": "user0@example.com"},
+		}, p.Entities[0])
+	})
+
+	t.Run("sorts search results", func(t *testing.T) {
+		query := search.Criteria{Filters: []search.Condition{{Field: "type", Value: "Contact"}}}
+		p := get(t, http.MethodPost, "/accounts/acc/entities/search?sort=attributes.lastPurchase&perPage=2", query)
+		require.Equal(t, []string{ids[""], ids["2024-01-15"]}, pageIDs(p.Results))
+	})
+
+	t.Run("walks sorted cursor pages", func(t *testing.T) {
+		first := get(t, http.MethodGet, "/accounts/acc/entities?cursor=&perPage=3&sort=attributes.lastPurchase:-1", nil)
+		require.Equal(t, byPurchase[:3], pageIDs(first.Entities))
+
+		next := get(t, http.MethodGet, "/accounts/acc/entities?perPage=3&sort=attributes.lastPurchase:-1&cursor="+first.Pagination.NextCursor, nil)
+		require.Equal(t, byPurchase[3:], pageIDs(next.Entities))
+		require.Empty(t, next.Pagination.NextCursor)
+
+		back := get(t, http.MethodGet, "/accounts/acc/entities?perPage=3&sort=attributes.lastPurchase:-1&cursor="+next.Pagination.PrevCursor, nil)
+		require.Equal(t, byPurchase[:3], pageIDs(back.Entities))
+		require.Empty(t, back.Pagination.PrevCursor)
+	})
+
+	tests := []struct {
+		it    string
+		query string
+	}{
+		{it: "rejects a malformed sort", query: "sort=createdAt:2"},
+		{it: "rejects a field that is not sortable", query: "sort=accountId"},
+		{it: "rejects an operator path", query: "sort=attributes.$where"},
+		{it: "rejects a repeated sort field", query: "sort=type,type:-1"},
+		{it: "rejects an unknown field", query: "fields=password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodGet, "/accounts/acc/entities?"+tt.query, nil)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
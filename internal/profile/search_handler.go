@@ -0,0 +1,39 @@
+package profile
+
+import (
+	"net/http"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/gin-gonic/gin"
+)
+
+// SearchFields manages fetching the fields free-text search matches for an entity type.
+func (h *Handler) SearchFields(c *gin.Context) {
+	ctx := c.Request.Context()
+	fields, err := h.service.SearchFields(ctx, c.Param("accountId"), c.Param("entityType"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, fields)
+}
+
+// SetSearchFields manages replacing the fields free-text search matches for an entity type.
+func (h *Handler) SetSearchFields(c *gin.Context) {
+	var fields profile.SearchFields
+	if err := c.ShouldBindJSON(&fields); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	saved, err := h.service.SetSearchFields(ctx, c.Param("accountId"), c.Param("entityType"), &fields)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
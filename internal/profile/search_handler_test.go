@@ -0,0 +1,157 @@
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	router := newTestRouter()
+
+	for _, email := range []string{"ana@example.com", "bob@example.org"} {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", profile.Entity{
+			Type:       "Contact",
+			Attributes: profile.Attribute{"email": email},
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	t.Run("searches with groups of conditions", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/search", map[string]any{
+			"filters": []any{
+				map[string]any{"field": "type", "value": "Contact"},
+				map[string]any{"not": map[string]any{"field": "attributes.email", "operator": "contains", "value": "EXAMPLE.COM"}},
+			},
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var body struct {
+			Results []profile.Entity `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Results, 1)
+		require.Equal(t, "bob@example.org", body.Results[0].Attributes["email"])
+	})
+
+	tests := []struct {
+		it   string
+		body any
+	}{
+		{it: "rejects a raw Mongo filter", body: map[string]any{"$where": "sleep(1000)"}},
+		{it: "rejects an operator as a field", body: map[string]any{"filters": []any{map[string]any{"field": "$where", "value": "true"}}}},
+		{it: "rejects a field outside the entity", body: map[string]any{"filters": []any{map[string]any{"field": "accountId", "value": "other"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/search", tt.body)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
+
+func TestFreeTextSearch(t *testing.T) {
+	router := newTestRouter()
+
+	for _, e := range []profile.Entity{
+		{Type: "Contact", Attributes: profile.Attribute{"name": "María Gómez", "email": "maria@example.com"}},
+		{Type: "Contact", Attributes: profile.Attribute{"name": "Mariano Pérez", "notes": "gomez"}},
+		{Type: "Store", Attributes: profile.Attribute{"storeName": "Gomez Market", "name": "Downtown"}},
+	} {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", e)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	search := func(t *testing.T, body any) []string {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities/search", body)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page struct {
+			Results []profile.Entity `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		names := []string{}
+		for _, e := range page.Results {
+			names = append(names, fmt.Sprint(e.Attributes["name"]))
+		}
+		return names
+	}
+
+	tests := []struct {
+		it    string
+		query string
+		want  []string
+	}{
+		{it: "matches whole words ignoring case and accents", query: "MARIA gomez", want: []string{"María Gómez"}},
+		{it: "ranks prefixes before typos", query: "marian", want: []string{"Mariano Pérez", "María Gómez"}},
+		{it: "ranks whole words before prefixes", query: "maria", want: []string{"María Gómez", "Mariano Pérez"}},
+		{it: "tolerates a typo", query: "gomes", want: []string{"María Gómez"}},
+		{it: "only matches the search fields", query: "market", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			require.Equal(t, tt.want, search(t, map[string]any{"query": tt.query}))
+		})
+	}
+
+	t.Run("searches the configured fields of a type", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPut, "/accounts/acc/search-fields/Store", map[string]any{
+			"fields": []string{"attributes.storeName"},
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		require.Equal(t, []string{"Downtown"}, search(t, map[string]any{"query": "market"}))
+		require.Equal(t, []string{"María Gómez", "Downtown"}, search(t, map[string]any{"query": "gomez"}))
+	})
+
+	t.Run("combines with filters", func(t *testing.T) {
+		body := map[string]any{
+			"query":   "gomez",
+			"filters": []any{map[string]any{"field": "type", "value": "Store"}},
+		}
+		require.Equal(t, []string{"Downtown"}, search(t, body))
+	})
+
+	t.Run("follows patched entities", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPost, "/accounts/acc/entities", profile.Entity{
+			Type:       "Contact",
+			Attributes: profile.Attribute{"name": "Lucía Fernández"},
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var created profile.Entity
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+
+		rec = doRequest(t, router, http.MethodPatch, "/accounts/acc/entities/"+created.ID,
+			map[string]any{"attributes": map[string]any{"name": "Lucía Torres"}},
+			"Content-Type", "application/merge-patch+json")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		require.Equal(t, []string{}, search(t, map[string]any{"query": "fernandez"}))
+		require.Equal(t, []string{"Lucía Torres"}, search(t, map[string]any{"query": "torres"}))
+	})
+
+	rejected := []struct {
+		it   string
+		path string
+		body any
+	}{
+		{it: "rejects a query without words", path: "/accounts/acc/entities/search", body: map[string]any{"query": "  ,. "}},
+		{it: "rejects a sorted query", path: "/accounts/acc/entities/search?sort=createdAt:1", body: map[string]any{"query": "gomez"}},
+		{it: "rejects cursor pagination", path: "/accounts/acc/entities/search?cursor=", body: map[string]any{"query": "gomez"}},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.it, func(t *testing.T) {
+			rec := doRequest(t, router, http.MethodPost, tt.path, tt.body)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+
+	t.Run("rejects search fields outside attributes and metadata", func(t *testing.T) {
+		rec := doRequest(t, router, http.MethodPut, "/accounts/acc/search-fields/Store", map[string]any{
+			"fields": []string{"type"},
+		})
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,108 @@
+package relationship
+
+import (
+	"net/http"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/relationship"
+	"github.com/gin-gonic/gin"
+)
+
+// service define business logic for relationship types.
+type service struct {
+	relationship.Saver
+	relationship.Deleter
+	relationship.Getter
+}
+
+// Handler rest api for relationship types.
+type Handler struct {
+	service *service
+}
+
+// NewHandler creates a new handler for relationship types.
+func NewHandler(saver relationship.Saver, deleter relationship.Deleter, getter relationship.Getter) *Handler {
+	s := &service{
+		Saver:   saver,
+		Deleter: deleter,
+		Getter:  getter,
+	}
+	return &Handler{service: s}
+}
+
+// Create manages the registration of a relationship type.
+func (h *Handler) Create(c *gin.Context) {
+	var d relationship.Definition
+	if err := c.ShouldBindJSON(&d); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	created, err := h.service.Create(ctx, c.Param("accountId"), &d)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// Update manages replacing the definition of a relationship type.
+func (h *Handler) Update(c *gin.Context) {
+	var d relationship.Definition
+	if err := c.ShouldBindJSON(&d); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	updated, err := h.service.Update(ctx, c.Param("accountId"), c.Param("name"), &d)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// Delete manages the removal of a relationship type.
+func (h *Handler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.Delete(ctx, c.Param("accountId"), c.Param("name")); err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Relationship type deleted"})
+}
+
+// Get manages fetching the definition of a relationship type.
+func (h *Handler) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	d, err := h.service.Get(ctx, c.Param("accountId"), c.Param("name"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, d)
+}
+
+// GetAll manages listing the relationship types of an account.
+func (h *Handler) GetAll(c *gin.Context) {
+	currentPage, perPage := rest.Page(c)
+
+	ctx := c.Request.Context()
+	definitions, totalItems, err := h.service.GetAll(ctx, c.Param("accountId"), currentPage, perPage)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"relationshipTypes": definitions,
+		"pagination":        pkg.NewPagination(currentPage, perPage, totalItems),
+	})
+}
@@ -0,0 +1,400 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v6"
+	"github.com/aerospike/aerospike-client-go/v6/types"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	accountIDBin = "accountId"
+	dataBin      = "data"
+	// deletedBin is 1 for soft deleted entities and 0 otherwise, so that scans can skip them.
+	deletedBin = "deleted"
+)
+
+// AerospikeRepository is a generic repository for Aerospike.
+// Each entity is stored as a BSON document in a record keyed by accountId and id,
+// in a set named after the collection. Whether the entity is soft deleted is also kept in a bin of its own.
+type AerospikeRepository[T Entity] struct {
+	client    *aerospike.Client
+	namespace string
+	set       string
+}
+
+// NewAerospikeRepository creates a new instance of AerospikeRepository.
+func NewAerospikeRepository[T Entity](client *aerospike.Client, namespace, set string) Repository[T] {
+	return &AerospikeRepository[T]{
+		client:    client,
+		namespace: namespace,
+		set:       set,
+	}
+}
+
+// Upsert creates or replaces an entity.
+func (r *AerospikeRepository[T]) Upsert(_ context.Context, accountID string, entity T) (T, error) {
+	plan, err := prepareUpsert(entity)
+	if err != nil {
+		return *new(T), err
+	}
+
+	key, bins, err := r.record(accountID, entity)
+	if err != nil {
+		plan.rollback(entity)
+		return *new(T), err
+	}
+	gen, err := r.generation(key, plan)
+	if err != nil {
+		plan.rollback(entity)
+		return *new(T), err
+	}
+
+	policy := aerospike.NewWritePolicy(0, 0)
+	if gen > 0 {
+		policy.GenerationPolicy = aerospike.EXPECT_GEN_EQUAL
+		policy.Generation = gen
+	}
+	if err := r.client.Put(policy, key, bins); err != nil {
+		plan.rollback(entity)
+		if err.Matches(types.GENERATION_ERROR) {
+			return *new(T), ErrVersionConflict
+		}
+		return *new(T), errors.WithStack(err)
+	}
+	return entity, nil
+}
+
+// BulkWrite upserts and deletes entities in a single batch.
+// The returned slice holds one error per operation, upserts first and then deletes, nil on success.
+func (r *AerospikeRepository[T]) BulkWrite(_ context.Context, accountID string, upserts []T, deleteIDs []string) ([]error, error) {
+	errs := make([]error, len(upserts)+len(deleteIDs))
+	records := make([]aerospike.BatchRecordIfc, 0, len(errs))
+	indexes := make([]int, 0, len(errs))
+
+	plans := make([]*upsertPlan, len(upserts))
+	for i, entity := range upserts {
+		plan, err := prepareUpsert(entity)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		plans[i] = plan
+		key, bins, err := r.record(accountID, entity)
+		if err != nil {
+			plan.rollback(entity)
+			errs[i] = err
+			continue
+		}
+		gen, err := r.generation(key, plan)
+		if err != nil {
+			plan.rollback(entity)
+			errs[i] = err
+			continue
+		}
+
+		policy := aerospike.NewBatchWritePolicy()
+		if gen > 0 {
+			policy.GenerationPolicy = aerospike.EXPECT_GEN_EQUAL
+			policy.Generation = gen
+		}
+		ops := make([]*aerospike.Operation, 0, len(bins))
+		for name, value := range bins {
+			ops = append(ops, aerospike.PutOp(aerospike.NewBin(name, value)))
+		}
+		records = append(records, aerospike.NewBatchWrite(policy, key, ops...))
+		indexes = append(indexes, i)
+	}
+
+	for i, id := range deleteIDs {
+		key, err := r.key(accountID, id)
+		if err != nil {
+			errs[len(upserts)+i] = err
+			continue
+		}
+		records = append(records, aerospike.NewBatchDelete(nil, key))
+		indexes = append(indexes, len(upserts)+i)
+	}
+
+	if len(records) == 0 {
+		return errs, nil
+	}
+
+	if err := r.client.BatchOperate(nil, records); err != nil && !err.Matches(types.BATCH_FAILED) {
+		return nil, errors.WithStack(err)
+	}
+	for i, record := range records {
+		batchErr := record.BatchRec().Err
+		if batchErr == nil {
+			continue
+		}
+		pos := indexes[i]
+		errs[pos] = batchErr
+		if pos < len(upserts) {
+			plans[pos].rollback(upserts[pos])
+			if batchErr.Matches(types.GENERATION_ERROR) {
+				errs[pos] = ErrVersionConflict
+			}
+		}
+	}
+	return errs, nil
+}
+
+// Patch reads the entity, sets and unsets its fields and writes it back, failing with ErrVersionConflict
+// when the entity changed in between.
+func (r *AerospikeRepository[T]) Patch(
+	ctx context.Context,
+	accountID, id string,
+	version int64,
+	set map[string]any,
+	unset []string,
+) (T, error) {
+	stored, err := r.GetByID(ctx, accountID, id)
+	if err != nil {
+		return *new(T), err
+	}
+	if v, ok := any(stored).(Versioned); ok && version != 0 && v.GetVersion() != version {
+		return *new(T), ErrVersionConflict
+	}
+
+	doc, err := toDocument(stored)
+	if err != nil {
+		return *new(T), err
+	}
+	patched, err := applyPatch(doc, set, unset)
+	if err != nil {
+		return *new(T), err
+	}
+	entity, err := fromDocument[T](patched)
+	if err != nil {
+		return *new(T), err
+	}
+	return r.Upsert(ctx, accountID, entity)
+}
+
+// GetByID finds an entity by its ID.
+func (r *AerospikeRepository[T]) GetByID(_ context.Context, accountID, id string) (T, error) {
+	key, err := r.key(accountID, id)
+	if err != nil {
+		return *new(T), err
+	}
+
+	record, aerr := r.client.Get(nil, key, dataBin)
+	if aerr != nil {
+		if aerr.Matches(types.KEY_NOT_FOUND_ERROR) {
+			return *new(T), ErrNotFound
+		}
+		return *new(T), errors.WithStack(aerr)
+	}
+	return decode[T](record.Bins)
+}
+
+// Delete removes an entity by its ID.
+func (r *AerospikeRepository[T]) Delete(_ context.Context, accountID, id string) error {
+	key, err := r.key(accountID, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.client.Delete(nil, key); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// GetAll retrieves all entities of an account with pagination.
+// Aerospike has no ordering nor offsets, so the whole set is scanned filtering by account
+// and the requested page is cut from the stream.
+func (r *AerospikeRepository[T]) GetAll(_ context.Context, accountID string, page, limit int, includeDeleted bool) ([]T, int, error) {
+	policy := aerospike.NewScanPolicy()
+	policy.FilterExpression = aerospike.ExpEq(aerospike.ExpStringBin(accountIDBin), aerospike.ExpStringVal(accountID))
+	if !includeDeleted {
+		// Records written before the deleted bin was introduced lack it and are active
+		policy.FilterExpression = aerospike.ExpAnd(policy.FilterExpression, aerospike.ExpOr(
+			aerospike.ExpNot(aerospike.ExpBinExists(deletedBin)),
+			aerospike.ExpEq(aerospike.ExpIntBin(deletedBin), aerospike.ExpIntVal(0)),
+		))
+	}
+
+	recordset, err := r.client.ScanAll(policy, r.namespace, r.set, dataBin)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	defer recordset.Close()
+
+	skip := (page - 1) * limit
+	var results []T
+	count := 0
+	for res := range recordset.Results() {
+		if res.Err != nil {
+			return nil, 0, errors.WithStack(res.Err)
+		}
+		if count >= skip && len(results) < limit {
+			entity, err := decode[T](res.Record.Bins)
+			if err != nil {
+				return nil, 0, err
+			}
+			results = append(results, entity)
+		}
+		count++
+	}
+
+	return results, count, nil
+}
+
+// GetByIDs finds the entities with the given IDs in a single batch.
+func (r *AerospikeRepository[T]) GetByIDs(_ context.Context, accountID string, ids []string, includeDeleted bool) ([]T, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	keys := make([]*aerospike.Key, 0, len(ids))
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		key, err := r.key(accountID, id)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	records, err := r.client.BatchGet(nil, keys, dataBin, deletedBin)
+	if err != nil && !err.Matches(types.KEY_NOT_FOUND_ERROR) {
+		return nil, errors.WithStack(err)
+	}
+	var results []T
+	for _, record := range records {
+		if record == nil || (!includeDeleted && deleted(record.Bins)) {
+			continue
+		}
+		entity, err := decode[T](record.Bins)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, entity)
+	}
+	return results, nil
+}
+
+// ExecuteQuery is not supported by Aerospike.
+func (r *AerospikeRepository[T]) ExecuteQuery(context.Context, string, map[string]any, int, int) ([]T, int, error) {
+	return nil, 0, ErrQueryNotSupported
+}
+
+// ExecutePipeline is not supported by Aerospike.
+func (r *AerospikeRepository[T]) ExecutePipeline(context.Context, string, map[string]any, int, int) ([]T, int, error) {
+	return nil, 0, ErrQueryNotSupported
+}
+
+// Find is not supported by Aerospike.
+func (r *AerospikeRepository[T]) Find(context.Context, string, map[string]any, []pkg.Sort, []string, int, int) ([]T, int, error) {
+	return nil, 0, ErrQueryNotSupported
+}
+
+// Seek is not supported by Aerospike.
+func (r *AerospikeRepository[T]) Seek(context.Context, string, map[string]any, []pkg.Sort, []string, string, string, int) ([]T, error) {
+	return nil, ErrQueryNotSupported
+}
+
+// Count is not supported by Aerospike.
+func (r *AerospikeRepository[T]) Count(context.Context, string, map[string]any) (int, error) {
+	return 0, ErrQueryNotSupported
+}
+
+// DeleteOlderThan is not supported by Aerospike, expired entities are left in place.
+func (r *AerospikeRepository[T]) DeleteOlderThan(context.Context, string, time.Time) (map[string][]string, error) {
+	return nil, ErrQueryNotSupported
+}
+
+// EnsureIndex is not supported by Aerospike.
+func (r *AerospikeRepository[T]) EnsureIndex(context.Context, string, []string) error {
+	return ErrQueryNotSupported
+}
+
+// generation returns the generation the stored record must still have for an upsert to honour the
+// entity's expected version, or 0 when the write is unconditional.
+func (r *AerospikeRepository[T]) generation(key *aerospike.Key, plan *upsertPlan) (uint32, error) {
+	if !plan.versioned || plan.isNew {
+		return 0, nil
+	}
+
+	record, err := r.client.Get(nil, key, dataBin)
+	if err != nil {
+		if err.Matches(types.KEY_NOT_FOUND_ERROR) {
+			return 0, nil
+		}
+		return 0, errors.WithStack(err)
+	}
+
+	stored, decodeErr := decode[T](record.Bins)
+	if decodeErr != nil {
+		return 0, decodeErr
+	}
+	if v, ok := any(stored).(Versioned); ok && v.GetVersion() != plan.expected {
+		return 0, ErrVersionConflict
+	}
+	return record.Generation, nil
+}
+
+func (r *AerospikeRepository[T]) key(accountID, id string) (*aerospike.Key, error) {
+	return aerospikeKey(r.namespace, r.set, accountID, id)
+}
+
+func (r *AerospikeRepository[T]) record(accountID string, entity T) (*aerospike.Key, aerospike.BinMap, error) {
+	key, err := r.key(accountID, entity.GetID())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bins, err := encode(accountID, entity)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, bins, nil
+}
+
+func aerospikeKey(namespace, set, accountID, id string) (*aerospike.Key, error) {
+	key, err := aerospike.NewKey(namespace, set, accountID+":"+id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return key, nil
+}
+
+func encode[T Entity](accountID string, entity T) (aerospike.BinMap, error) {
+	data, err := bson.Marshal(entity)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	isDeleted := 0
+	if v, err := bson.Raw(data).LookupErr(deletedAtKey); err == nil && v.Type != bson.TypeNull {
+		isDeleted = 1
+	}
+	return aerospike.BinMap{accountIDBin: accountID, dataBin: data, deletedBin: isDeleted}, nil
+}
+
+// deleted reports whether the bins of a record hold a soft deleted entity.
+func deleted(bins aerospike.BinMap) bool {
+	n, ok := bins[deletedBin].(int)
+	return ok && n == 1
+}
+
+func decode[T Entity](bins aerospike.BinMap) (T, error) {
+	data, ok := bins[dataBin].([]byte)
+	if !ok {
+		return *new(T), errors.New("aerospike record has no data")
+	}
+
+	var entity = *new(T)
+	if err := bson.Unmarshal(data, &entity); err != nil {
+		return *new(T), errors.WithStack(err)
+	}
+	return entity, nil
+}
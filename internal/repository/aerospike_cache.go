@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v6"
+	"github.com/aerospike/aerospike-client-go/v6/types"
+	"github.com/pkg/errors"
+)
+
+// AerospikeCache is a Cache storing entities in an Aerospike set, expiring them after their TTL.
+type AerospikeCache[T Entity] struct {
+	client    *aerospike.Client
+	namespace string
+	set       string
+}
+
+// NewAerospikeCache creates a new instance of AerospikeCache.
+func NewAerospikeCache[T Entity](client *aerospike.Client, namespace, set string) Cache[T] {
+	return &AerospikeCache[T]{
+		client:    client,
+		namespace: namespace,
+		set:       set,
+	}
+}
+
+// Get returns the cached entity, if any.
+func (c *AerospikeCache[T]) Get(_ context.Context, accountID, id string) (T, bool, error) {
+	key, err := aerospikeKey(c.namespace, c.set, accountID, id)
+	if err != nil {
+		return *new(T), false, err
+	}
+
+	record, aerr := c.client.Get(nil, key, dataBin)
+	if aerr != nil {
+		if aerr.Matches(types.KEY_NOT_FOUND_ERROR) {
+			return *new(T), false, nil
+		}
+		return *new(T), false, errors.WithStack(aerr)
+	}
+
+	entity, err := decode[T](record.Bins)
+	if err != nil {
+		return *new(T), false, err
+	}
+	return entity, true, nil
+}
+
+// Set caches the entity for the given TTL.
+func (c *AerospikeCache[T]) Set(_ context.Context, accountID string, entity T, ttl time.Duration) error {
+	key, err := aerospikeKey(c.namespace, c.set, accountID, entity.GetID())
+	if err != nil {
+		return err
+	}
+	bins, err := encode(accountID, entity)
+	if err != nil {
+		return err
+	}
+
+	policy := aerospike.NewWritePolicy(0, uint32(ttl.Seconds()))
+	if err := c.client.Put(policy, key, bins); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Invalidate removes the entity from the cache.
+func (c *AerospikeCache[T]) Invalidate(_ context.Context, accountID, id string) error {
+	key, err := aerospikeKey(c.namespace, c.set, accountID, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.client.Delete(nil, key); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAerospikeRepositoryUnsupported(t *testing.T) {
+	ctx := context.Background()
+	// The unsupported paths never reach the client, so the repository needs no server.
+	repo := NewAerospikeRepository[*testEntity](nil, "test", "entities")
+
+	tests := []struct {
+		it   string
+		call func() error
+	}{
+		{it: "queries", call: func() error { _, _, err := repo.ExecuteQuery(ctx, "acc", nil, 1, 10); return err }},
+		{it: "pipelines", call: func() error { _, _, err := repo.ExecutePipeline(ctx, "acc", nil, 1, 10); return err }},
+		{it: "finds", call: func() error { _, _, err := repo.Find(ctx, "acc", nil, nil, nil, 1, 10); return err }},
+		{it: "seeks", call: func() error { _, err := repo.Seek(ctx, "acc", nil, nil, nil, "", "", 10); return err }},
+		{it: "counts", call: func() error { _, err := repo.Count(ctx, "acc", nil); return err }},
+		{it: "deletes expired entities", call: func() error { _, err := repo.DeleteOlderThan(ctx, "deletedAt", time.Now()); return err }},
+		{it: "ensures indexes", call: func() error { return repo.EnsureIndex(ctx, "type", []string{"type"}) }},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.it, func(t *testing.T) {
+			require.ErrorIs(t, tt.call(), ErrQueryNotSupported)
+		})
+	}
+}
+
+func TestAerospikeRecords(t *testing.T) {
+	key, err := aerospikeKey("test", "entities", "acc", "e1")
+	require.NoError(t, err)
+	require.Equal(t, "test", key.Namespace())
+	require.Equal(t, "entities", key.SetName())
+	require.Equal(t, "acc:e1", key.Value().GetObject())
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entity := &testEntity{ID: "e1", Type: "Contact", Attributes: map[string]any{"email": "ana@example.com"}, CreatedAt: &created}
+	bins, err := encode("acc", entity)
+	require.NoError(t, err)
+	require.Equal(t, "acc", bins[accountIDBin])
+	require.False(t, deleted(bins))
+
+	decoded, err := decode[*testEntity](bins)
+	require.NoError(t, err)
+	require.Equal(t, "Contact", decoded.Type)
+	require.Equal(t, "ana@example.com", decoded.Attributes["email"])
+	require.True(t, created.Equal(*decoded.CreatedAt))
+
+	entity.DeletedAt = &created
+	bins, err = encode("acc", entity)
+	require.NoError(t, err)
+	require.True(t, deleted(bins), "soft deleted entities are flagged")
+
+	_, err = decode[*testEntity](aerospike.BinMap{accountIDBin: "acc"})
+	require.Error(t, err, "records without data are rejected")
+}
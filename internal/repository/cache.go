@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache stores entities by account and ID.
+type Cache[T any] interface {
+	Get(ctx context.Context, accountID, id string) (T, bool, error)
+	Set(ctx context.Context, accountID string, entity T, ttl time.Duration) error
+	Invalidate(ctx context.Context, accountID, id string) error
+}
+
+// Typed is implemented by entities exposing their type, used to pick a per-type cache TTL.
+type Typed interface {
+	GetType() string
+}
+
+// CacheStats holds the cache hit and miss counters.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// CachedRepository is a read-through cache in front of another repository.
+// GetByID is served from the cache when present and caches the entity on a miss. Writes, whether they
+// succeed or not, remove the written entities from the cache rather than caching them, so that a cached
+// entity is always one read from the repository. A miss is not cached when a write of the entity ran
+// while it was being read, since the entity read may predate the write; writes made through another
+// instance are only bounded by the TTL. Queries and pipelines always go to the underlying repository.
+// Cache failures are logged and never fail the request.
+type CachedRepository[T Entity] struct {
+	Repository[T]
+	cache      Cache[T]
+	defaultTTL time.Duration
+	typeTTLs   map[string]time.Duration
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+
+	mu    sync.Mutex
+	reads map[string][]*cacheRead // Misses being read from the repository, by cache key
+}
+
+// cacheRead is a miss being read from the repository, stale once the entity is written meanwhile.
+type cacheRead struct {
+	stale bool
+}
+
+// NewCachedRepository creates a new instance of CachedRepository.
+// Entities implementing Typed are cached for the TTL configured for their type, or defaultTTL otherwise.
+func NewCachedRepository[T Entity](repo Repository[T], cache Cache[T], defaultTTL time.Duration, typeTTLs map[string]time.Duration) *CachedRepository[T] {
+	return &CachedRepository[T]{
+		Repository: repo,
+		cache:      cache,
+		defaultTTL: defaultTTL,
+		typeTTLs:   typeTTLs,
+		reads:      map[string][]*cacheRead{},
+	}
+}
+
+// Stats returns the current hit and miss counters.
+func (r *CachedRepository[T]) Stats() CacheStats {
+	return CacheStats{
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+	}
+}
+
+// Upsert writes the entity to the underlying repository and removes it from the cache.
+func (r *CachedRepository[T]) Upsert(ctx context.Context, accountID string, entity T) (T, error) {
+	id := entity.GetID()
+	saved, err := r.Repository.Upsert(ctx, accountID, entity)
+	if id != "" {
+		r.invalidate(ctx, accountID, id)
+	}
+	return saved, err
+}
+
+// BulkWrite writes to the underlying repository and removes every touched entity from the cache.
+func (r *CachedRepository[T]) BulkWrite(ctx context.Context, accountID string, upserts []T, deleteIDs []string) ([]error, error) {
+	ids := make([]string, 0, len(upserts)+len(deleteIDs))
+	for _, entity := range upserts {
+		if entity.GetID() != "" {
+			ids = append(ids, entity.GetID())
+		}
+	}
+	ids = append(ids, deleteIDs...)
+
+	errs, err := r.Repository.BulkWrite(ctx, accountID, upserts, deleteIDs)
+	for _, id := range ids {
+		r.invalidate(ctx, accountID, id)
+	}
+	return errs, err
+}
+
+// Patch patches the entity in the underlying repository and removes it from the cache.
+func (r *CachedRepository[T]) Patch(
+	ctx context.Context,
+	accountID, id string,
+	version int64,
+	set map[string]any,
+	unset []string,
+) (T, error) {
+	entity, err := r.Repository.Patch(ctx, accountID, id, version, set, unset)
+	r.invalidate(ctx, accountID, id)
+	return entity, err
+}
+
+// GetByID serves the entity from the cache, loading and caching it on a miss unless it was written
+// while being loaded.
+func (r *CachedRepository[T]) GetByID(ctx context.Context, accountID, id string) (T, error) {
+	entity, ok, err := r.cache.Get(ctx, accountID, id)
+	if err != nil {
+		log.Printf("cache get %s/%s: %+v", accountID, id, err)
+	}
+	if ok {
+		r.hits.Add(1)
+		return entity, nil
+	}
+	r.misses.Add(1)
+
+	key := cacheKey(accountID, id)
+	read := r.startRead(key)
+	entity, err = r.Repository.GetByID(ctx, accountID, id)
+	if !r.endRead(key, read) || err != nil {
+		return entity, err
+	}
+	if err := r.cache.Set(ctx, accountID, entity, r.ttl(entity)); err != nil {
+		log.Printf("cache set %s/%s: %+v", accountID, id, err)
+		r.invalidate(ctx, accountID, id)
+	}
+	return entity, nil
+}
+
+// Delete removes the entity from the underlying repository and the cache.
+func (r *CachedRepository[T]) Delete(ctx context.Context, accountID, id string) error {
+	err := r.Repository.Delete(ctx, accountID, id)
+	r.invalidate(ctx, accountID, id)
+	return err
+}
+
+// DeleteOlderThan removes the expired entities from the underlying repository and the cache.
+func (r *CachedRepository[T]) DeleteOlderThan(ctx context.Context, field string, before time.Time) (map[string][]string, error) {
+	deleted, err := r.Repository.DeleteOlderThan(ctx, field, before)
+	for accountID, ids := range deleted {
+		for _, id := range ids {
+			r.invalidate(ctx, accountID, id)
+		}
+	}
+	return deleted, err
+}
+
+// EnsureTextIndex creates the text index of the underlying repository, which must be a TextSearcher.
+func (r *CachedRepository[T]) EnsureTextIndex(ctx context.Context) error {
+	text, ok := r.Repository.(TextSearcher[T])
+	if !ok {
+		return ErrQueryNotSupported
+	}
+	return text.EnsureTextIndex(ctx)
+}
+
+// TextSearch searches the underlying repository, which must be a TextSearcher.
+func (r *CachedRepository[T]) TextSearch(ctx context.Context, accountID string, terms []string, query map[string]any, limit int) ([]T, error) {
+	text, ok := r.Repository.(TextSearcher[T])
+	if !ok {
+		return nil, ErrQueryNotSupported
+	}
+	return text.TextSearch(ctx, accountID, terms, query, limit)
+}
+
+// Reindex reindexes the entities in the underlying repository, which must be a TextSearcher, and removes
+// the reindexed ones from the cache.
+func (r *CachedRepository[T]) Reindex(ctx context.Context, accountID string, query map[string]any, set map[string]any) ([]string, error) {
+	text, ok := r.Repository.(TextSearcher[T])
+	if !ok {
+		return nil, ErrQueryNotSupported
+	}
+	reindexed, err := text.Reindex(ctx, accountID, query, set)
+	for _, id := range reindexed {
+		r.invalidate(ctx, accountID, id)
+	}
+	return reindexed, err
+}
+
+// invalidate removes a written entity from the cache, and prevents the misses being read from caching it.
+func (r *CachedRepository[T]) invalidate(ctx context.Context, accountID, id string) {
+	r.mu.Lock()
+	for _, read := range r.reads[cacheKey(accountID, id)] {
+		read.stale = true
+	}
+	r.mu.Unlock()
+
+	if err := r.cache.Invalidate(ctx, accountID, id); err != nil {
+		log.Printf("cache invalidate %s/%s: %+v", accountID, id, err)
+	}
+}
+
+func (r *CachedRepository[T]) startRead(key string) *cacheRead {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	read := &cacheRead{}
+	r.reads[key] = append(r.reads[key], read)
+	return read
+}
+
+// endRead reports whether the entity read can be cached.
+func (r *CachedRepository[T]) endRead(key string, read *cacheRead) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reads := r.reads[key]
+	for i, other := range reads {
+		if other == read {
+			reads = append(reads[:i], reads[i+1:]...)
+			break
+		}
+	}
+	if len(reads) == 0 {
+		delete(r.reads, key)
+	} else {
+		r.reads[key] = reads
+	}
+	return !read.stale
+}
+
+func (r *CachedRepository[T]) ttl(entity T) time.Duration {
+	if typed, ok := any(entity).(Typed); ok {
+		if ttl, ok := r.typeTTLs[typed.GetType()]; ok {
+			return ttl
+		}
+	}
+	return r.defaultTTL
+}
+
+func cacheKey(accountID, id string) string {
+	return accountID + "/" + id
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCache is an in-process Cache recording its calls.
+type fakeCache struct {
+	entities    map[string]*testEntity
+	sets        int
+	invalidated []string
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{entities: map[string]*testEntity{}}
+}
+
+func (c *fakeCache) Get(_ context.Context, accountID, id string) (*testEntity, bool, error) {
+	e, ok := c.entities[cacheKey(accountID, id)]
+	return e, ok, nil
+}
+
+func (c *fakeCache) Set(_ context.Context, accountID string, entity *testEntity, _ time.Duration) error {
+	c.sets++
+	c.entities[cacheKey(accountID, entity.ID)] = entity
+	return nil
+}
+
+func (c *fakeCache) Invalidate(_ context.Context, accountID, id string) error {
+	c.invalidated = append(c.invalidated, cacheKey(accountID, id))
+	delete(c.entities, cacheKey(accountID, id))
+	return nil
+}
+
+// failingRepository fails every write with err, and runs beforeGet ahead of every read.
+type failingRepository struct {
+	Repository[*testEntity]
+	err       error
+	beforeGet func()
+}
+
+func (r *failingRepository) Upsert(ctx context.Context, accountID string, entity *testEntity) (*testEntity, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.Repository.Upsert(ctx, accountID, entity)
+}
+
+func (r *failingRepository) Patch(ctx context.Context, accountID, id string, version int64, set map[string]any, unset []string) (*testEntity, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.Repository.Patch(ctx, accountID, id, version, set, unset)
+}
+
+func (r *failingRepository) Delete(ctx context.Context, accountID, id string) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.Repository.Delete(ctx, accountID, id)
+}
+
+func (r *failingRepository) BulkWrite(ctx context.Context, accountID string, upserts []*testEntity, deleteIDs []string) ([]error, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.Repository.BulkWrite(ctx, accountID, upserts, deleteIDs)
+}
+
+func (r *failingRepository) GetByID(ctx context.Context, accountID, id string) (*testEntity, error) {
+	if r.beforeGet != nil {
+		r.beforeGet()
+	}
+	return r.Repository.GetByID(ctx, accountID, id)
+}
+
+func TestCachedRepository(t *testing.T) {
+	ctx := context.Background()
+	errWrite := errors.New("version conflict")
+
+	setup := func(t *testing.T) (*failingRepository, *fakeCache, *CachedRepository[*testEntity], *testEntity) {
+		t.Helper()
+		repo := &failingRepository{Repository: NewMemoryRepository[*testEntity]()}
+		cache := newFakeCache()
+		cached := NewCachedRepository[*testEntity](repo, cache, time.Minute, nil)
+		saved, err := cached.Upsert(ctx, "acc", &testEntity{Type: "Contact"})
+		require.NoError(t, err)
+		return repo, cache, cached, saved
+	}
+
+	t.Run("caches a miss and serves the hit", func(t *testing.T) {
+		_, cache, cached, saved := setup(t)
+
+		for i := 0; i < 2; i++ {
+			e, err := cached.GetByID(ctx, "acc", saved.ID)
+			require.NoError(t, err)
+			require.Equal(t, saved.ID, e.ID)
+		}
+		require.Equal(t, 1, cache.sets)
+		require.Equal(t, CacheStats{Hits: 1, Misses: 1}, cached.Stats())
+	})
+
+	t.Run("does not cache on write", func(t *testing.T) {
+		_, cache, cached, saved := setup(t)
+
+		_, err := cached.Upsert(ctx, "acc", saved)
+		require.NoError(t, err)
+		_, err = cached.Patch(ctx, "acc", saved.ID, 0, map[string]any{"type": "Lead"}, nil)
+		require.NoError(t, err)
+		require.Zero(t, cache.sets)
+		require.Empty(t, cache.entities)
+	})
+
+	for _, tc := range []struct {
+		it    string
+		write func(cached *CachedRepository[*testEntity], id string) error
+	}{
+		{
+			it: "invalidates when an upsert fails",
+			write: func(cached *CachedRepository[*testEntity], id string) error {
+				_, err := cached.Upsert(ctx, "acc", &testEntity{ID: id})
+				return err
+			},
+		},
+		{
+			it: "invalidates when a patch fails",
+			write: func(cached *CachedRepository[*testEntity], id string) error {
+				_, err := cached.Patch(ctx, "acc", id, 1, map[string]any{"type": "Lead"}, nil)
+				return err
+			},
+		},
+		{
+			it: "invalidates when a delete fails",
+			write: func(cached *CachedRepository[*testEntity], id string) error {
+				return cached.Delete(ctx, "acc", id)
+			},
+		},
+		{
+			it: "invalidates when a bulk write fails",
+			write: func(cached *CachedRepository[*testEntity], id string) error {
+				_, err := cached.BulkWrite(ctx, "acc", []*testEntity{{ID: id}}, nil)
+				return err
+			},
+		},
+	} {
+		t.Run(tc.it, func(t *testing.T) {
+			repo, cache, cached, saved := setup(t)
+			_, err := cached.GetByID(ctx, "acc", saved.ID)
+			require.NoError(t, err)
+			require.Contains(t, cache.entities, cacheKey("acc", saved.ID))
+
+			repo.err = errWrite
+			require.ErrorIs(t, tc.write(cached, saved.ID), errWrite)
+			require.NotContains(t, cache.entities, cacheKey("acc", saved.ID))
+		})
+	}
+
+	t.Run("does not cache a read racing a write", func(t *testing.T) {
+		repo, cache, cached, saved := setup(t)
+		repo.beforeGet = func() {
+			repo.beforeGet = nil
+			_, err := cached.Patch(ctx, "acc", saved.ID, 0, map[string]any{"type": "Lead"}, nil)
+			require.NoError(t, err)
+		}
+
+		_, err := cached.GetByID(ctx, "acc", saved.ID)
+		require.NoError(t, err)
+		require.Zero(t, cache.sets)
+		require.Empty(t, cached.reads)
+
+		_, err = cached.GetByID(ctx, "acc", saved.ID)
+		require.NoError(t, err)
+		require.Equal(t, 1, cache.sets)
+	})
+
+	t.Run("invalidates purged entities", func(t *testing.T) {
+		_, cache, cached, saved := setup(t)
+		_, err := cached.GetByID(ctx, "acc", saved.ID)
+		require.NoError(t, err)
+
+		deleted, err := cached.DeleteOlderThan(ctx, "createdAt", time.Now().Add(time.Second))
+		require.NoError(t, err)
+		require.Equal(t, map[string][]string{"acc": {saved.ID}}, deleted)
+		require.Empty(t, cache.entities)
+	})
+
+	t.Run("invalidates reindexed entities", func(t *testing.T) {
+		cache := newFakeCache()
+		cached := NewCachedRepository[*testEntity](NewMemoryRepository[*testEntity](), cache, time.Minute, nil)
+		saved, err := cached.Upsert(ctx, "acc", &testEntity{Type: "Contact"})
+		require.NoError(t, err)
+		_, err = cached.GetByID(ctx, "acc", saved.ID)
+		require.NoError(t, err)
+
+		reindexed, err := cached.Reindex(ctx, "acc", nil, map[string]any{"type": "Lead"})
+		require.NoError(t, err)
+		require.Equal(t, []string{saved.ID}, reindexed)
+		require.Empty(t, cache.entities)
+
+		e, err := cached.GetByID(ctx, "acc", saved.ID)
+		require.NoError(t, err)
+		require.Equal(t, "Lead", e.Type)
+	})
+
+	t.Run("rejects reindexing unless the repository searches text", func(t *testing.T) {
+		_, _, cached, _ := setup(t)
+		_, err := cached.Reindex(ctx, "acc", nil, map[string]any{"type": "Lead"})
+		require.ErrorIs(t, err, ErrQueryNotSupported)
+	})
+}
@@ -0,0 +1,14 @@
+package repository
+
+import "github.com/dportaluppi/customer-profiles-api/pkg"
+
+var (
+	ErrNotFound          = pkg.NewErrNotFound("record not found")
+	ErrVersionConflict   = pkg.NewErrConflict("record was modified concurrently")
+	ErrDuplicateKey      = pkg.NewErrConflict("record collides with another on a unique index")
+	ErrQueryNotSupported = pkg.NewErrNotImplemented("queries are not supported by this repository")
+	ErrInvalidQuery      = pkg.NewErrInvalid("invalid query")
+	ErrUnsupportedOp     = pkg.NewErrInvalid("unsupported query operator")
+	ErrInvalidPatch      = pkg.NewErrInvalid("patch traverses a field that is not a document")
+	ErrCursorNotFound    = pkg.NewErrInvalid("pagination cursor points at a missing record")
+)
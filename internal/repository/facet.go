@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// totalFacet is the $facet output counting the aggregated documents. Facet names start with a letter, so
+// it cannot collide with one.
+const totalFacet = "_total"
+
+// mongoFacet translates a facet to the sub-pipeline of a $facet stage. The values of the field are
+// unwound, so that lists are summarized one by one, and filtered by the BSON types the facet expects.
+func mongoFacet(f pkg.Facet) bson.A {
+	stages := bson.A{
+		bson.M{"$project": bson.M{"_id": 0, "v": "$" + f.Field}},
+		bson.M{"$unwind": "$v"},
+		bson.M{"$match": bson.M{"v": bson.M{"$type": facetTypes(f.Type)}}},
+	}
+	switch f.Type {
+	case pkg.FacetTerms:
+		return append(stages,
+			bson.M{"$group": bson.M{"_id": "$v", "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{"count", -1}, {"_id", 1}}},
+			bson.M{"$limit": f.Size},
+		)
+	case pkg.FacetHistogram:
+		key := bson.M{"$multiply": bson.A{bson.M{"$floor": bson.M{"$divide": bson.A{"$v", f.Interval}}}, f.Interval}}
+		return append(stages,
+			bson.M{"$group": bson.M{"_id": key, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+			bson.M{"$limit": f.Size},
+		)
+	case pkg.FacetDateHistogram:
+		key := bson.M{"$dateTrunc": bson.M{"date": "$v", "unit": f.Unit, "startOfWeek": "monday"}}
+		return append(stages,
+			bson.M{"$group": bson.M{"_id": key, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+			bson.M{"$limit": f.Size},
+		)
+	default:
+		return append(stages, bson.M{"$group": bson.M{
+			"_id":   nil,
+			"count": bson.M{"$sum": 1},
+			"min":   bson.M{"$min": "$v"},
+			"max":   bson.M{"$max": "$v"},
+			"avg":   bson.M{"$avg": "$v"},
+			"sum":   bson.M{"$sum": "$v"},
+		}})
+	}
+}
+
+// facetTypes returns the BSON types of the values a facet summarizes.
+func facetTypes(facetType string) bson.A {
+	switch facetType {
+	case pkg.FacetTerms:
+		return bson.A{"string", "number", "bool", "date"}
+	case pkg.FacetDateHistogram:
+		return bson.A{"date"}
+	default:
+		return bson.A{"number"}
+	}
+}
+
+// facetResult converts the documents output by the sub-pipeline of a facet.
+func facetResult(f pkg.Facet, docs []bson.M) pkg.FacetResult {
+	if f.Type == pkg.FacetStats {
+		stats := &pkg.Stats{}
+		if len(docs) > 0 {
+			stats.Count = int(toFloat(docs[0]["count"]))
+			for key, target := range map[string]**float64{"min": &stats.Min, "max": &stats.Max, "avg": &stats.Avg, "sum": &stats.Sum} {
+				v := toFloat(docs[0][key])
+				*target = &v
+			}
+		}
+		return pkg.FacetResult{Stats: stats}
+	}
+
+	buckets := make([]pkg.Bucket, 0, len(docs))
+	for _, doc := range docs {
+		key := normalize(doc["_id"])
+		if t, ok := key.(time.Time); ok {
+			key = t.UTC()
+		}
+		buckets = append(buckets, pkg.Bucket{Key: key, Count: int(toFloat(doc["count"]))})
+	}
+	return pkg.FacetResult{Buckets: buckets}
+}
+
+// memoryFacet computes a facet over documents like the sub-pipeline built by mongoFacet.
+func memoryFacet(f pkg.Facet, docs []bson.M) pkg.FacetResult {
+	counts := map[any]int{}
+	var keys []any
+	stats := &pkg.Stats{}
+	for _, doc := range docs {
+		for _, v := range facetValues(doc, f) {
+			if f.Type == pkg.FacetStats {
+				n := v.(float64)
+				stats.Count++
+				stats.Min = ptr(math.Min(deref(stats.Min, n), n))
+				stats.Max = ptr(math.Max(deref(stats.Max, n), n))
+				stats.Sum = ptr(deref(stats.Sum, 0) + n)
+				continue
+			}
+			key := bucketKey(f, v)
+			if _, ok := counts[key]; !ok {
+				keys = append(keys, key)
+			}
+			counts[key]++
+		}
+	}
+	if f.Type == pkg.FacetStats {
+		if stats.Count > 0 {
+			stats.Avg = ptr(*stats.Sum / float64(stats.Count))
+		}
+		return pkg.FacetResult{Stats: stats}
+	}
+
+	slices.SortFunc(keys, func(a, b any) int {
+		if f.Type == pkg.FacetTerms && counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return compareValues(a, b)
+	})
+	buckets := make([]pkg.Bucket, 0, min(len(keys), f.Size))
+	for _, key := range keys[:min(len(keys), f.Size)] {
+		buckets = append(buckets, pkg.Bucket{Key: key, Count: counts[key]})
+	}
+	return pkg.FacetResult{Buckets: buckets}
+}
+
+// facetValues returns the values of a document a facet summarizes, the items of a list one by one.
+func facetValues(doc bson.M, f pkg.Facet) []any {
+	found, ok := lookup(doc, f.Field)
+	if !ok {
+		return nil
+	}
+	var values []any
+	for _, v := range found {
+		switch v := normalize(v).(type) {
+		case float64:
+			if f.Type != pkg.FacetDateHistogram {
+				values = append(values, v)
+			}
+		case time.Time:
+			if f.Type == pkg.FacetTerms || f.Type == pkg.FacetDateHistogram {
+				values = append(values, v.UTC())
+			}
+		case string, bool:
+			if f.Type == pkg.FacetTerms {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// bucketKey returns the key of the bucket holding a value.
+func bucketKey(f pkg.Facet, v any) any {
+	switch f.Type {
+	case pkg.FacetHistogram:
+		return math.Floor(v.(float64)/f.Interval) * f.Interval
+	case pkg.FacetDateHistogram:
+		return truncate(v.(time.Time), f.Unit)
+	}
+	return v
+}
+
+// truncate returns the start of the calendar unit holding a UTC time, like $dateTrunc.
+func truncate(t time.Time, unit string) time.Time {
+	switch unit {
+	case pkg.UnitHour:
+		return t.Truncate(time.Hour)
+	case pkg.UnitWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case pkg.UnitMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case pkg.UnitYear:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// toFloat converts a number decoded from BSON, 0 for anything else.
+func toFloat(v any) float64 {
+	if n, ok := normalize(v).(float64); ok {
+		return n
+	}
+	return 0
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+// deref returns the value p points at, or fallback when nil.
+func deref(p *float64, fallback float64) float64 {
+	if p == nil {
+		return fallback
+	}
+	return *p
+}
@@ -0,0 +1,509 @@
+package repository
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matchFilter reports whether doc matches a Mongo query filter. It supports the logical operators
+// $and, $or and $nor and the field operators $eq, $ne, $in, $nin, $gt, $gte, $lt, $lte, $exists,
+// $regex, $not and $elemMatch on dotted paths, with Mongo's array semantics, and $expr aggregation
+// expressions.
+func matchFilter(doc map[string]any, filter map[string]any) (bool, error) {
+	for key, cond := range filter {
+		var (
+			ok  bool
+			err error
+		)
+		switch key {
+		case "$and", "$or", "$nor":
+			ok, err = matchLogical(doc, key, cond)
+		case "$expr":
+			var v any
+			v, err = evalExpr(doc, cond)
+			ok = truthy(v)
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, errors.Wrap(ErrUnsupportedOp, key)
+			}
+			values, found := lookup(doc, key)
+			ok, err = matchCondition(values, found, cond)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchLogical(doc map[string]any, op string, cond any) (bool, error) {
+	clauses, ok := asSlice(cond)
+	if !ok || len(clauses) == 0 {
+		return false, errors.Wrap(ErrInvalidQuery, op+" expects a non-empty array")
+	}
+
+	for _, clause := range clauses {
+		filter, ok := asMapping(clause)
+		if !ok {
+			return false, errors.Wrap(ErrInvalidQuery, op+" expects an array of filters")
+		}
+		matched, err := matchFilter(doc, filter)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case op == "$and" && !matched:
+			return false, nil
+		case op == "$or" && matched:
+			return true, nil
+		case op == "$nor" && matched:
+			return false, nil
+		}
+	}
+	return op != "$or", nil
+}
+
+// matchCondition evaluates the condition of a single field against the values found at its path.
+func matchCondition(values []any, found bool, cond any) (bool, error) {
+	ops, ok := asMapping(cond)
+	if !ok || !isOperatorMap(ops) {
+		return anyEqual(values, cond), nil
+	}
+
+	for op, arg := range ops {
+		var matched bool
+		switch op {
+		case "$eq":
+			matched = anyEqual(values, arg)
+		case "$ne":
+			matched = !anyEqual(values, arg)
+		case "$in", "$nin":
+			list, ok := asSlice(arg)
+			if !ok {
+				return false, errors.Wrap(ErrInvalidQuery, op+" expects an array")
+			}
+			for _, item := range list {
+				if anyEqual(values, item) {
+					matched = true
+					break
+				}
+			}
+			if op == "$nin" {
+				matched = !matched
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			for _, v := range values {
+				c, ok := compare(v, arg)
+				if ok && compareOp(op, c) {
+					matched = true
+					break
+				}
+			}
+		case "$exists":
+			matched = found == truthy(arg)
+		case "$regex":
+			options, _ := ops["$options"].(string)
+			re, err := compileRegex(arg, options)
+			if err != nil {
+				return false, err
+			}
+			for _, v := range values {
+				if s, ok := v.(string); ok && re.MatchString(s) {
+					matched = true
+					break
+				}
+			}
+		case "$options":
+			continue
+		case "$elemMatch":
+			filter, ok := asMapping(arg)
+			if !ok {
+				return false, errors.Wrap(ErrInvalidQuery, op+" expects a filter")
+			}
+			for _, v := range values {
+				m, err := matchElement(v, filter)
+				if err != nil {
+					return false, err
+				}
+				if m {
+					matched = true
+					break
+				}
+			}
+		case "$not":
+			inner, err := matchCondition(values, found, arg)
+			if err != nil {
+				return false, err
+			}
+			matched = !inner
+		default:
+			return false, errors.Wrap(ErrUnsupportedOp, op)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchElement reports whether v is an array holding a document matching filter.
+func matchElement(v any, filter map[string]any) (bool, error) {
+	list, ok := asSlice(v)
+	if !ok {
+		return false, nil
+	}
+	for _, item := range list {
+		doc, ok := asMapping(item)
+		if !ok {
+			continue
+		}
+		matched, err := matchFilter(doc, filter)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// evalExpr evaluates an aggregation expression, as used in $expr, against doc. Field references
+// are written as "$path" and the supported operators are $and, $or, $not, $eq, $ne, $gt, $gte,
+// $lt, $lte and $in.
+func evalExpr(doc map[string]any, expr any) (any, error) {
+	if s, ok := expr.(string); ok && strings.HasPrefix(s, "$") {
+		values, found := lookup(doc, s[1:])
+		if !found {
+			return nil, nil
+		}
+		return values[0], nil
+	}
+	if list, ok := asSlice(expr); ok {
+		out := make([]any, len(list))
+		for i, item := range list {
+			v, err := evalExpr(doc, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+
+	ops, ok := asMapping(expr)
+	if !ok || !isOperatorMap(ops) {
+		return expr, nil
+	}
+	if len(ops) != 1 {
+		return nil, errors.Wrap(ErrInvalidQuery, "an expression must have exactly one operator")
+	}
+
+	for op, arg := range ops {
+		evaluated, err := evalExpr(doc, arg)
+		if err != nil {
+			return nil, err
+		}
+		args, ok := evaluated.([]any)
+		if !ok {
+			args = []any{evaluated}
+		}
+
+		switch op {
+		case "$and":
+			for _, a := range args {
+				if !truthy(a) {
+					return false, nil
+				}
+			}
+			return true, nil
+		case "$or":
+			for _, a := range args {
+				if truthy(a) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "$not":
+			return !truthy(args[0]), nil
+		case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte":
+			if len(args) != 2 {
+				return nil, errors.Wrap(ErrInvalidQuery, op+" expects two arguments")
+			}
+			if op == "$eq" || op == "$ne" {
+				return equal(args[0], args[1]) == (op == "$eq"), nil
+			}
+			c, ok := compare(args[0], args[1])
+			return ok && compareOp(op, c), nil
+		case "$in":
+			if len(args) != 2 {
+				return nil, errors.Wrap(ErrInvalidQuery, op+" expects two arguments")
+			}
+			list, _ := asSlice(args[1])
+			for _, item := range list {
+				if equal(args[0], item) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "$ifNull":
+			if len(args) < 2 {
+				return nil, errors.Wrap(ErrInvalidQuery, op+" expects at least two arguments")
+			}
+			for _, a := range args[:len(args)-1] {
+				if a != nil {
+					return a, nil
+				}
+			}
+			return args[len(args)-1], nil
+		default:
+			return nil, errors.Wrap(ErrUnsupportedOp, op)
+		}
+	}
+	return nil, nil
+}
+
+// lookup resolves a dotted path in doc, traversing arrays like Mongo does.
+// Array values are returned along with their elements so that conditions match any of them.
+func lookup(doc map[string]any, path string) ([]any, bool) {
+	current := []any{doc}
+	for _, segment := range strings.Split(path, ".") {
+		var next []any
+		for _, v := range current {
+			if m, ok := asMapping(v); ok {
+				if child, ok := m[segment]; ok {
+					next = append(next, child)
+				}
+				continue
+			}
+			list, ok := asSlice(v)
+			if !ok {
+				continue
+			}
+			if i, err := strconv.Atoi(segment); err == nil {
+				if i >= 0 && i < len(list) {
+					next = append(next, list[i])
+				}
+				continue
+			}
+			for _, item := range list {
+				if m, ok := asMapping(item); ok {
+					if child, ok := m[segment]; ok {
+						next = append(next, child)
+					}
+				}
+			}
+		}
+		current = next
+	}
+	if len(current) == 0 {
+		return nil, false
+	}
+
+	values := make([]any, 0, len(current))
+	for _, v := range current {
+		values = append(values, v)
+		if list, ok := asSlice(v); ok {
+			values = append(values, list...)
+		}
+	}
+	return values, true
+}
+
+func anyEqual(values []any, target any) bool {
+	if len(values) == 0 {
+		return target == nil
+	}
+	for _, v := range values {
+		if equal(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyIn reports whether any of values equals one of targets.
+func anyIn(values, targets []any) bool {
+	for _, target := range targets {
+		if anyEqual(values, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b any) bool {
+	a, b = normalize(a), normalize(b)
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders two values of the same kind, reporting false when they cannot be compared.
+func compare(a, b any) (int, bool) {
+	a, b = normalize(a), normalize(b)
+	switch va := a.(type) {
+	case float64:
+		vb, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case va < vb:
+			return -1, true
+		case va > vb:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		vb, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(va, vb), true
+	case time.Time:
+		vb, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		return va.Compare(vb), true
+	}
+	return 0, false
+}
+
+func compareOp(op string, c int) bool {
+	switch op {
+	case "$gt":
+		return c > 0
+	case "$gte":
+		return c >= 0
+	case "$lt":
+		return c < 0
+	case "$lte":
+		return c <= 0
+	}
+	return false
+}
+
+// normalize converts numbers to float64, dates to time.Time and containers to plain maps and slices
+// so values decoded from BSON compare equal to values decoded from JSON.
+func normalize(v any) any {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case primitive.DateTime:
+		return n.Time()
+	case *time.Time:
+		if n == nil {
+			return nil
+		}
+		return *n
+	}
+	if m, ok := asMapping(v); ok {
+		out := make(map[string]any, len(m))
+		for k, item := range m {
+			out[k] = normalize(item)
+		}
+		return out
+	}
+	if list, ok := asSlice(v); ok {
+		out := make([]any, len(list))
+		for i, item := range list {
+			out[i] = normalize(item)
+		}
+		return out
+	}
+	return v
+}
+
+func truthy(v any) bool {
+	switch b := normalize(v).(type) {
+	case nil:
+		return false
+	case bool:
+		return b
+	case float64:
+		return b != 0
+	}
+	return true
+}
+
+func isOperatorMap(m map[string]any) bool {
+	if len(m) == 0 {
+		return false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+func compileRegex(pattern any, options string) (*regexp.Regexp, error) {
+	s, ok := pattern.(string)
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidQuery, "$regex expects a string")
+	}
+	flags := ""
+	for _, o := range options {
+		if strings.ContainsRune("ims", o) {
+			flags += string(o)
+		}
+	}
+	if flags != "" {
+		s = "(?" + flags + ")" + s
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidQuery, err.Error())
+	}
+	return re, nil
+}
+
+var (
+	mappingType = reflect.TypeOf(map[string]any(nil))
+	sliceType   = reflect.TypeOf([]any(nil))
+)
+
+// asMapping returns v as a plain map, accepting named map types such as bson.M and ordered bson.D documents.
+func asMapping(v any) (map[string]any, bool) {
+	if d, ok := v.(primitive.D); ok {
+		return d.Map(), true
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() != reflect.Map || !rv.Type().ConvertibleTo(mappingType) {
+		return nil, false
+	}
+	return rv.Convert(mappingType).Interface().(map[string]any), true
+}
+
+// asSlice returns v as a plain slice, accepting any slice type such as primitive.A or []string.
+func asSlice(v any) ([]any, bool) {
+	if _, ok := v.(primitive.D); ok {
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	if rv.Type().ConvertibleTo(sliceType) {
+		return rv.Convert(sliceType).Interface().([]any), true
+	}
+	out := make([]any, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out, true
+}
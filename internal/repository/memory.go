@@ -0,0 +1,788 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryRepository is a thread-safe in-memory repository, meant for tests and local development.
+// Entities are kept as BSON documents so callers never share state with the store, and queries
+// are evaluated with the same Mongo filter syntax MongoRepository accepts.
+type MemoryRepository[T Entity] struct {
+	mu       sync.RWMutex
+	accounts map[string]*memoryCollection
+}
+
+// memoryCollection holds the documents of an account in insertion order, along with its unique indexes and
+// the text index of the searchable ones.
+type memoryCollection struct {
+	ids     []string
+	docs    map[string]bson.M
+	indexes map[string]memoryIndex
+	text    memoryTextIndex
+}
+
+// memoryIndex is a unique index: field holds distinct values among the documents matching filter.
+type memoryIndex struct {
+	field  string
+	filter map[string]any
+}
+
+// memoryTextIndex is an inverted index from the search terms of the documents to their IDs.
+type memoryTextIndex struct {
+	postings map[string]map[string]bool
+	terms    map[string][]string // Terms indexed for each document ID
+}
+
+// NewMemoryRepository creates a new instance of MemoryRepository.
+func NewMemoryRepository[T Entity]() *MemoryRepository[T] {
+	return &MemoryRepository[T]{accounts: map[string]*memoryCollection{}}
+}
+
+// Upsert creates an entity or sets the given fields on an existing one, like a Mongo $set upsert.
+func (r *MemoryRepository[T]) Upsert(_ context.Context, accountID string, entity T) (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.upsert(accountID, entity); err != nil {
+		return *new(T), err
+	}
+	return entity, nil
+}
+
+// BulkWrite upserts and deletes entities.
+// The returned slice holds one error per operation, upserts first and then deletes, nil on success.
+func (r *MemoryRepository[T]) BulkWrite(_ context.Context, accountID string, upserts []T, deleteIDs []string) ([]error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := make([]error, len(upserts)+len(deleteIDs))
+	for i, entity := range upserts {
+		errs[i] = r.upsert(accountID, entity)
+	}
+	for i, id := range deleteIDs {
+		if _, err := primitive.ObjectIDFromHex(id); err != nil {
+			errs[len(upserts)+i] = err
+			continue
+		}
+		r.collection(accountID).delete(id)
+	}
+	return errs, nil
+}
+
+// Patch sets and unsets fields of a stored entity, like a Mongo $set and $unset update.
+func (r *MemoryRepository[T]) Patch(
+	_ context.Context,
+	accountID, id string,
+	version int64,
+	set map[string]any,
+	unset []string,
+) (T, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return *new(T), err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coll := r.collection(accountID)
+	doc, ok := coll.docs[id]
+	if !ok {
+		return *new(T), ErrNotFound
+	}
+	stored, _ := doc[versionKey].(int64)
+	if version != 0 && stored != version {
+		return *new(T), ErrVersionConflict
+	}
+
+	patched, err := applyPatch(doc, set, unset)
+	if err != nil {
+		return *new(T), err
+	}
+	patched[updatedAtKey] = primitive.NewDateTimeFromTime(time.Now())
+	if _, ok := any(*new(T)).(Versioned); ok {
+		patched[versionKey] = stored + 1
+	}
+	if err = coll.checkUnique(id, patched); err != nil {
+		return *new(T), err
+	}
+	coll.docs[id] = patched
+
+	entity, err := fromDocument[T](patched)
+	if err != nil {
+		return *new(T), err
+	}
+	if s, ok := any(entity).(Searchable); ok {
+		coll.text.add(id, s.SearchTerms())
+	}
+	return entity, nil
+}
+
+// GetByID finds an entity by its ID.
+func (r *MemoryRepository[T]) GetByID(_ context.Context, accountID, id string) (T, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return *new(T), err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		return *new(T), ErrNotFound
+	}
+	doc, ok := coll.docs[id]
+	if !ok {
+		return *new(T), ErrNotFound
+	}
+	return fromDocument[T](doc)
+}
+
+// Delete removes an entity by its ID.
+func (r *MemoryRepository[T]) Delete(_ context.Context, accountID, id string) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if coll, ok := r.accounts[accountID]; ok {
+		coll.delete(id)
+	}
+	return nil
+}
+
+// GetAll retrieves all entities of an account, with pagination.
+func (r *MemoryRepository[T]) GetAll(_ context.Context, accountID string, page, limit int, includeDeleted bool) ([]T, int, error) {
+	return r.find(accountID, func(doc bson.M) (bool, error) {
+		return includeDeleted || doc[deletedAtKey] == nil, nil
+	}, page, limit)
+}
+
+// GetByIDs finds the entities with the given IDs.
+func (r *MemoryRepository[T]) GetByIDs(_ context.Context, accountID string, ids []string, includeDeleted bool) ([]T, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		return nil, nil
+	}
+	var found []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if doc, ok := coll.docs[id]; ok && !seen[id] && (includeDeleted || doc[deletedAtKey] == nil) {
+			seen[id] = true
+			found = append(found, id)
+		}
+	}
+	return load[T](coll, found, nil)
+}
+
+// ExecuteQuery evaluates a Mongo filter and returns a slice of entities with pagination.
+func (r *MemoryRepository[T]) ExecuteQuery(_ context.Context, accountID string, query map[string]any, currentPage, perPage int) ([]T, int, error) {
+	return r.find(accountID, func(doc bson.M) (bool, error) {
+		return matchFilter(doc, query)
+	}, currentPage, perPage)
+}
+
+// ExecutePipeline evaluates an aggregation expression, as used in a $expr match, and returns
+// a slice of entities with pagination.
+func (r *MemoryRepository[T]) ExecutePipeline(_ context.Context, accountID string, pipeline map[string]any, currentPage, perPage int) ([]T, int, error) {
+	return r.find(accountID, func(doc bson.M) (bool, error) {
+		v, err := evalExpr(doc, map[string]any(pipeline))
+		if err != nil {
+			return false, err
+		}
+		return truthy(v), nil
+	}, currentPage, perPage)
+}
+
+// Find is ExecuteQuery ordered by sort and loading only the given fields.
+func (r *MemoryRepository[T]) Find(
+	_ context.Context,
+	accountID string,
+	query map[string]any,
+	sort []pkg.Sort,
+	fields []string,
+	currentPage, perPage int,
+) ([]T, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		return nil, 0, nil
+	}
+	ids, err := coll.sorted(query, sort)
+	if err != nil {
+		return nil, 0, err
+	}
+	start := min((currentPage-1)*perPage, len(ids))
+	results, err := load[T](coll, ids[start:min(start+perPage, len(ids))], fields)
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, len(ids), nil
+}
+
+// Seek returns up to limit entities matching query ordered by sort, following the entity with the ID after
+// or preceding the one with the ID before.
+func (r *MemoryRepository[T]) Seek(
+	_ context.Context,
+	accountID string,
+	query map[string]any,
+	sort []pkg.Sort,
+	fields []string,
+	after, before string,
+	limit int,
+) ([]T, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		return nil, nil
+	}
+	ids, err := coll.sorted(query, sort)
+	if err != nil {
+		return nil, err
+	}
+
+	boundary := after
+	if before != "" {
+		boundary = before
+	}
+	if boundary != "" {
+		doc, ok := coll.docs[boundary]
+		if !ok {
+			return nil, ErrCursorNotFound
+		}
+		ids = slices.DeleteFunc(ids, func(id string) bool {
+			c := compareDocs(id, coll.docs[id], boundary, doc, sort)
+			return (before != "" && c >= 0) || (before == "" && c <= 0)
+		})
+	}
+	if before != "" {
+		ids = ids[max(0, len(ids)-limit):]
+	} else {
+		ids = ids[:min(limit, len(ids))]
+	}
+	return load[T](coll, ids, fields)
+}
+
+// Count counts the entities of the account matching query.
+func (r *MemoryRepository[T]) Count(_ context.Context, accountID string, query map[string]any) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		return 0, nil
+	}
+	count := 0
+	for _, id := range coll.ids {
+		ok, err := matchFilter(coll.docs[id], query)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Aggregate computes the count and every facet over the documents matching query.
+func (r *MemoryRepository[T]) Aggregate(_ context.Context, accountID string, query map[string]any, facets map[string]pkg.Facet) (*pkg.Aggregation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var docs []bson.M
+	if coll, ok := r.accounts[accountID]; ok {
+		for _, id := range coll.ids {
+			ok, err := matchFilter(coll.docs[id], query)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				docs = append(docs, coll.docs[id])
+			}
+		}
+	}
+
+	aggregation := &pkg.Aggregation{Total: len(docs), Facets: make(map[string]pkg.FacetResult, len(facets))}
+	for name, f := range facets {
+		aggregation.Facets[name] = memoryFacet(f, docs)
+	}
+	return aggregation, nil
+}
+
+// CountBy counts the entities matching query for each value of field.
+func (r *MemoryRepository[T]) CountBy(_ context.Context, accountID, field string, query map[string]any) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := map[string]int{}
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		return counts, nil
+	}
+	for _, id := range coll.ids {
+		doc := coll.docs[id]
+		ok, err := matchFilter(doc, query)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		var value any
+		if values, found := lookup(doc, field); found {
+			value = values[0]
+		}
+		counts[countKey(value)]++
+	}
+	return counts, nil
+}
+
+// DeleteOlderThan removes the entities of every account whose time field is before the given time.
+func (r *MemoryRepository[T]) DeleteOlderThan(_ context.Context, field string, before time.Time) (map[string][]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	filter := map[string]any{field: map[string]any{"$lt": before}}
+	deleted := map[string][]string{}
+	for accountID, coll := range r.accounts {
+		for _, id := range append([]string(nil), coll.ids...) {
+			ok, err := matchFilter(coll.docs[id], filter)
+			if err != nil {
+				return deleted, err
+			}
+			if ok {
+				coll.delete(id)
+				deleted[accountID] = append(deleted[accountID], id)
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// GraphLookup returns the entities matching query reached from the entity with the given ID in at most
+// maxDepth hops, visiting each entity once in breadth first order.
+func (r *MemoryRepository[T]) GraphLookup(
+	_ context.Context,
+	accountID, id, connectFromField, connectToField string,
+	maxDepth int,
+	query map[string]any,
+) ([]T, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		return nil, nil
+	}
+	root, ok := coll.docs[id]
+	if !ok {
+		return nil, nil
+	}
+
+	frontier, _ := lookup(root, connectFromField)
+	visited := map[string]bool{}
+	var results []T
+	for depth := 0; depth < maxDepth && len(frontier) > 0; depth++ {
+		var next []any
+		for _, docID := range coll.ids {
+			if visited[docID] {
+				continue
+			}
+			doc := coll.docs[docID]
+			values, found := lookup(doc, connectToField)
+			if !found || !anyIn(values, frontier) {
+				continue
+			}
+			ok, err := matchFilter(doc, query)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			visited[docID] = true
+			entity, err := fromDocument[T](doc)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, entity)
+			from, _ := lookup(doc, connectFromField)
+			next = append(next, from...)
+		}
+		frontier = next
+	}
+	return results, nil
+}
+
+// EnsureIndex does nothing, queries scan the documents of the account.
+func (r *MemoryRepository[T]) EnsureIndex(context.Context, string, []string) error {
+	return nil
+}
+
+// EnsureUniqueIndex creates, unless it exists, the named index making field unique among the entities of
+// the account matching filter. It fails with ErrDuplicateKey when stored entities already collide.
+func (r *MemoryRepository[T]) EnsureUniqueIndex(_ context.Context, accountID, name, field string, filter map[string]any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coll := r.collection(accountID)
+	if _, ok := coll.indexes[name]; ok {
+		return nil
+	}
+
+	index := memoryIndex{field: field, filter: filter}
+	var values []any
+	for _, id := range coll.ids {
+		value, ok, err := index.key(coll.docs[id])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		for _, v := range values {
+			if equal(v, value) {
+				return ErrDuplicateKey
+			}
+		}
+		values = append(values, value)
+	}
+
+	if coll.indexes == nil {
+		coll.indexes = map[string]memoryIndex{}
+	}
+	coll.indexes[name] = index
+	return nil
+}
+
+// DropIndex removes an index created by EnsureUniqueIndex.
+func (r *MemoryRepository[T]) DropIndex(_ context.Context, accountID, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if coll, ok := r.accounts[accountID]; ok {
+		delete(coll.indexes, name)
+	}
+	return nil
+}
+
+// EnsureTextIndex does nothing, the text index is maintained on every write.
+func (r *MemoryRepository[T]) EnsureTextIndex(context.Context) error {
+	return nil
+}
+
+// Reindex sets fields of the matching entities and indexes them again, leaving their version as it is.
+func (r *MemoryRepository[T]) Reindex(_ context.Context, accountID string, query map[string]any, set map[string]any) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		return nil, nil
+	}
+	var reindexed []string
+	for _, id := range coll.ids {
+		ok, err := matchFilter(coll.docs[id], query)
+		if err != nil {
+			return reindexed, err
+		}
+		if !ok {
+			continue
+		}
+		patched, err := applyPatch(coll.docs[id], set, nil)
+		if err != nil {
+			return reindexed, err
+		}
+		entity, err := fromDocument[T](patched)
+		if err != nil {
+			return reindexed, err
+		}
+		coll.docs[id] = patched
+		if s, ok := any(entity).(Searchable); ok {
+			coll.text.add(id, s.SearchTerms())
+		}
+		reindexed = append(reindexed, id)
+	}
+	return reindexed, nil
+}
+
+// TextSearch scores the documents by the number of distinct terms they hold, looked up in the text index.
+func (r *MemoryRepository[T]) TextSearch(_ context.Context, accountID string, terms []string, query map[string]any, limit int) ([]T, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		return nil, nil
+	}
+	distinct := slices.Clone(terms)
+	slices.Sort(distinct)
+	scores := map[string]int{}
+	for _, term := range slices.Compact(distinct) {
+		for id := range coll.text.postings[term] {
+			scores[id]++
+		}
+	}
+
+	var ids []string
+	for id := range scores {
+		ok, err := matchFilter(coll.docs[id], query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		if scores[a] != scores[b] {
+			return scores[b] - scores[a]
+		}
+		return strings.Compare(a, b)
+	})
+	return load[T](coll, ids[:min(len(ids), limit)], nil)
+}
+
+func (r *MemoryRepository[T]) find(accountID string, match func(bson.M) (bool, error), page, limit int) ([]T, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		return nil, 0, nil
+	}
+
+	skip := (page - 1) * limit
+	var results []T
+	count := 0
+	for _, id := range coll.ids {
+		ok, err := match(coll.docs[id])
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			continue
+		}
+		if count >= skip && len(results) < limit {
+			entity, err := fromDocument[T](coll.docs[id])
+			if err != nil {
+				return nil, 0, err
+			}
+			results = append(results, entity)
+		}
+		count++
+	}
+	return results, count, nil
+}
+
+// upsert must be called with the write lock held.
+func (r *MemoryRepository[T]) upsert(accountID string, entity T) error {
+	plan, err := prepareUpsert(entity)
+	if err != nil {
+		return err
+	}
+
+	coll := r.collection(accountID)
+	id := entity.GetID()
+	existing, ok := coll.docs[id]
+	if ok && plan.versioned && !plan.isNew && !equal(existing[versionKey], plan.expected) &&
+		!(plan.expected == 0 && existing[versionKey] == nil) {
+		plan.rollback(entity)
+		return ErrVersionConflict
+	}
+
+	doc, err := toDocument(entity)
+	if err != nil {
+		plan.rollback(entity)
+		return err
+	}
+	doc[accountIDKey] = accountID
+
+	candidate := doc
+	if ok {
+		candidate = bson.M{}
+		for k, v := range existing {
+			candidate[k] = v
+		}
+		for k, v := range doc {
+			candidate[k] = v
+		}
+	}
+	if err = coll.checkUnique(id, candidate); err != nil {
+		plan.rollback(entity)
+		return err
+	}
+
+	if !ok {
+		coll.ids = append(coll.ids, id)
+	}
+	coll.docs[id] = candidate
+	if s, ok := any(entity).(Searchable); ok {
+		coll.text.add(id, s.SearchTerms())
+	}
+	return nil
+}
+
+// sorted returns the IDs of the documents matching query ordered by sort, ties broken by ID.
+func (c *memoryCollection) sorted(query map[string]any, sort []pkg.Sort) ([]string, error) {
+	var ids []string
+	for _, id := range c.ids {
+		ok, err := matchFilter(c.docs[id], query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		return compareDocs(a, c.docs[a], b, c.docs[b], sort)
+	})
+	return ids, nil
+}
+
+// load decodes the documents with the given IDs, keeping only fields unless empty.
+func load[T Entity](c *memoryCollection, ids []string, fields []string) ([]T, error) {
+	results := make([]T, 0, len(ids))
+	for _, id := range ids {
+		entity, err := fromDocument[T](project(c.docs[id], fields))
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, entity)
+	}
+	return results, nil
+}
+
+// collection must be called with the write lock held.
+func (r *MemoryRepository[T]) collection(accountID string) *memoryCollection {
+	coll, ok := r.accounts[accountID]
+	if !ok {
+		coll = &memoryCollection{docs: map[string]bson.M{}}
+		r.accounts[accountID] = coll
+	}
+	return coll
+}
+
+// checkUnique fails with ErrDuplicateKey when doc, stored under id, collides with another document on one
+// of the unique indexes.
+func (c *memoryCollection) checkUnique(id string, doc bson.M) error {
+	for _, index := range c.indexes {
+		value, ok, err := index.key(doc)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		for _, other := range c.ids {
+			if other == id {
+				continue
+			}
+			v, ok, err := index.key(c.docs[other])
+			if err != nil {
+				return err
+			}
+			if ok && equal(v, value) {
+				return ErrDuplicateKey
+			}
+		}
+	}
+	return nil
+}
+
+// key returns the value doc holds for the index, false when the index does not cover doc.
+func (i memoryIndex) key(doc bson.M) (any, bool, error) {
+	ok, err := matchFilter(doc, i.filter)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	values, found := lookup(doc, i.field)
+	if !found {
+		return nil, false, nil
+	}
+	return values[0], true, nil
+}
+
+func (c *memoryCollection) delete(id string) {
+	if _, ok := c.docs[id]; !ok {
+		return
+	}
+	delete(c.docs, id)
+	c.text.remove(id)
+	for i, existing := range c.ids {
+		if existing == id {
+			c.ids = append(c.ids[:i], c.ids[i+1:]...)
+			break
+		}
+	}
+}
+
+// add indexes the terms of the document with the given ID, replacing those indexed before.
+func (i *memoryTextIndex) add(id string, terms []string) {
+	i.remove(id)
+	if i.postings == nil {
+		i.postings = map[string]map[string]bool{}
+		i.terms = map[string][]string{}
+	}
+	for _, term := range terms {
+		if i.postings[term] == nil {
+			i.postings[term] = map[string]bool{}
+		}
+		i.postings[term][id] = true
+	}
+	i.terms[id] = slices.Clone(terms)
+}
+
+// remove drops the terms of the document with the given ID from the index.
+func (i *memoryTextIndex) remove(id string) {
+	for _, term := range i.terms[id] {
+		delete(i.postings[term], id)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+		}
+	}
+	delete(i.terms, id)
+}
+
+func toDocument(entity any) (bson.M, error) {
+	data, err := bson.Marshal(entity)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return doc, nil
+}
+
+func fromDocument[T Entity](doc bson.M) (T, error) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return *new(T), errors.WithStack(err)
+	}
+	var entity = *new(T)
+	if err := bson.Unmarshal(data, &entity); err != nil {
+		return *new(T), errors.WithStack(err)
+	}
+	return entity, nil
+}
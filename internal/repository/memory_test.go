@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEntity struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type" bson:"type"`
+	Attributes map[string]any `json:"attributes" bson:"attributes"`
+	DeletedAt  *time.Time     `json:"deletedAt" bson:"deletedAt"`
+	CreatedAt  *time.Time     `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  *time.Time     `json:"updatedAt" bson:"updatedAt"`
+}
+
+func (e *testEntity) GetID() string            { return e.ID }
+func (e *testEntity) SetID(id string)          { e.ID = id }
+func (e *testEntity) GetCreatedAt() *time.Time { return e.CreatedAt }
+func (e *testEntity) SetCreatedAt(t time.Time) { e.CreatedAt = &t }
+func (e *testEntity) GetUpdatedAt() *time.Time { return e.UpdatedAt }
+func (e *testEntity) SetUpdatedAt(t time.Time) { e.UpdatedAt = &t }
+
+func TestMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testEntity]()
+
+	seed := []*testEntity{
+		{Type: "Contact", Attributes: map[string]any{"email": "ana@example.com", "age": 31, "tags": []any{"vip", "new"}}},
+		{Type: "Contact", Attributes: map[string]any{"email": "bob@example.com", "age": 45, "orders": []any{
+			map[string]any{"sku": "a", "qty": 1},
+			map[string]any{"sku": "b", "qty": 5},
+		}}},
+		{Type: "Store", Attributes: map[string]any{"name": "Corner"}},
+	}
+	for _, e := range seed {
+		_, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+	}
+	_, err := repo.Upsert(ctx, "other", &testEntity{Type: "Contact"})
+	require.NoError(t, err)
+
+	t.Run("scopes by account and paginates", func(t *testing.T) {
+		page, total, err := repo.GetAll(ctx, "acc", 2, 2, false)
+		require.NoError(t, err)
+		require.Equal(t, 3, total)
+		require.Len(t, page, 1)
+		require.Equal(t, seed[2].ID, page[0].ID)
+	})
+
+	t.Run("returns copies of the stored entities", func(t *testing.T) {
+		e, err := repo.GetByID(ctx, "acc", seed[0].ID)
+		require.NoError(t, err)
+		e.Type = "Changed"
+
+		e, err = repo.GetByID(ctx, "acc", seed[0].ID)
+		require.NoError(t, err)
+		require.Equal(t, "Contact", e.Type)
+
+		_, err = repo.GetByID(ctx, "other", seed[0].ID)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	tests := []struct {
+		it     string
+		filter string
+		want   []int
+	}{
+		{it: "matches implicit equality on dotted paths", filter: `{"attributes.email": "bob@example.com"}`, want: []int{1}},
+		{it: "matches $ne", filter: `{"type": {"$ne": "Contact"}}`, want: []int{2}},
+		{it: "matches $in", filter: `{"attributes.email": {"$in": ["ana@example.com", "x@example.com"]}}`, want: []int{0}},
+		{it: "matches array elements", filter: `{"attributes.tags": "vip"}`, want: []int{0}},
+		{it: "matches $gt and $lt", filter: `{"attributes.age": {"$gt": 30, "$lt": 40}}`, want: []int{0}},
+		{it: "matches $exists", filter: `{"attributes.age": {"$exists": false}}`, want: []int{2}},
+		{it: "matches $elemMatch", filter: `{"attributes.orders": {"$elemMatch": {"sku": "b", "qty": {"$gt": 2}}}}`, want: []int{1}},
+		{it: "matches $elemMatch on a single element", filter: `{"attributes.orders": {"$elemMatch": {"sku": "a", "qty": {"$gt": 2}}}}`, want: []int{}},
+		{it: "matches $and and $or", filter: `{"$and": [{"type": "Contact"}, {"$or": [{"attributes.age": 45}, {"attributes.tags": "new"}]}]}`, want: []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			var filter map[string]any
+			require.NoError(t, json.Unmarshal([]byte(tt.filter), &filter))
+
+			results, total, err := repo.ExecuteQuery(ctx, "acc", filter, 1, 10)
+			require.NoError(t, err)
+			require.Equal(t, len(tt.want), total)
+			for i, idx := range tt.want {
+				require.Equal(t, seed[idx].ID, results[i].ID)
+			}
+		})
+	}
+
+	t.Run("rejects unsupported operators", func(t *testing.T) {
+		_, _, err := repo.ExecuteQuery(ctx, "acc", map[string]any{"$where": "true"}, 1, 10)
+		require.ErrorIs(t, err, ErrUnsupportedOp)
+	})
+
+	t.Run("hides soft deleted entities unless included", func(t *testing.T) {
+		repo := NewMemoryRepository[*testEntity]()
+		deletedAt := time.Now()
+		active, err := repo.Upsert(ctx, "acc", &testEntity{Type: "Contact"})
+		require.NoError(t, err)
+		deleted, err := repo.Upsert(ctx, "acc", &testEntity{Type: "Contact", DeletedAt: &deletedAt})
+		require.NoError(t, err)
+
+		page, total, err := repo.GetAll(ctx, "acc", 1, 10, false)
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		require.Equal(t, active.ID, page[0].ID)
+		_, total, err = repo.GetAll(ctx, "acc", 1, 10, true)
+		require.NoError(t, err)
+		require.Equal(t, 2, total)
+
+		ids := []string{deleted.ID, active.ID, active.ID, "65a000000000000000000000", "malformed"}
+		found, err := repo.GetByIDs(ctx, "acc", ids, false)
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		require.Equal(t, active.ID, found[0].ID)
+		found, err = repo.GetByIDs(ctx, "acc", ids, true)
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		found, err = repo.GetByIDs(ctx, "other", ids, true)
+		require.NoError(t, err)
+		require.Empty(t, found)
+	})
+
+	t.Run("deletes entities older than a time across accounts", func(t *testing.T) {
+		repo := NewMemoryRepository[*testEntity]()
+		for _, acc := range []string{"acc", "other"} {
+			_, err := repo.Upsert(ctx, acc, &testEntity{Type: "Contact"})
+			require.NoError(t, err)
+		}
+
+		deleted, err := repo.DeleteOlderThan(ctx, "createdAt", time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, deleted)
+
+		deleted, err = repo.DeleteOlderThan(ctx, "createdAt", time.Now().Add(time.Second))
+		require.NoError(t, err)
+		require.Len(t, deleted["acc"], 1)
+		require.Len(t, deleted["other"], 1)
+
+		_, total, err := repo.GetAll(ctx, "acc", 1, 10, false)
+		require.NoError(t, err)
+		require.Zero(t, total)
+	})
+}
+
+func TestMemoryRepositoryGraphLookup(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testEntity]()
+	upsert := func(e *testEntity) *testEntity {
+		e, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+		return e
+	}
+
+	// d <- c <- b <- a, with a also pointing at a Store.
+	d := upsert(&testEntity{Type: "Contact"})
+	c := upsert(&testEntity{Type: "Contact", Attributes: map[string]any{"next": []any{d.ID}}})
+	b := upsert(&testEntity{Type: "Contact", Attributes: map[string]any{"next": []any{c.ID}}})
+	store := upsert(&testEntity{Type: "Store"})
+	a := upsert(&testEntity{Type: "Contact", Attributes: map[string]any{"next": []any{b.ID, store.ID}}})
+
+	ids := func(entities []*testEntity) []string {
+		var got []string
+		for _, e := range entities {
+			got = append(got, e.ID)
+		}
+		return got
+	}
+
+	tests := []struct {
+		it       string
+		from, to string
+		depth    int
+		query    map[string]any
+		want     []string
+	}{
+		{it: "follows the connect from values", from: "attributes.next", to: "id", depth: 2, want: []string{c.ID, b.ID, store.ID}},
+		{it: "walks the edges backwards", from: "id", to: "attributes.next", depth: 3, want: []string{c.ID, b.ID, a.ID}},
+		{it: "restricts the reached entities", from: "attributes.next", to: "id", depth: 5, query: map[string]any{"type": "Contact"}, want: []string{d.ID, c.ID, b.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			start := a.ID
+			if tt.from == "id" {
+				start = d.ID
+			}
+			got, err := repo.GraphLookup(ctx, "acc", start, tt.from, tt.to, tt.depth, tt.query)
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.want, ids(got))
+		})
+	}
+
+	t.Run("is scoped by account", func(t *testing.T) {
+		got, err := repo.GraphLookup(ctx, "other", a.ID, "attributes.next", "id", 5, nil)
+		require.NoError(t, err)
+		require.Empty(t, got)
+	})
+}
+
+func TestMemoryRepositoryUniqueIndex(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testEntity]()
+	upsert := func(e *testEntity) error {
+		_, err := repo.Upsert(ctx, "acc", e)
+		return err
+	}
+	contacts := map[string]any{"type": "Contact"}
+
+	ana := &testEntity{Type: "Contact", Attributes: map[string]any{"email": "ana@example.com"}}
+	require.NoError(t, upsert(ana))
+	require.NoError(t, upsert(&testEntity{Type: "Store", Attributes: map[string]any{"code": "a"}}))
+	require.NoError(t, upsert(&testEntity{Type: "Store", Attributes: map[string]any{"code": "a"}}))
+
+	require.ErrorIs(t, repo.EnsureUniqueIndex(ctx, "acc", "code", "attributes.code", nil), ErrDuplicateKey)
+	require.NoError(t, repo.EnsureUniqueIndex(ctx, "acc", "email", "attributes.email", contacts))
+
+	require.ErrorIs(t, upsert(&testEntity{Type: "Contact", Attributes: map[string]any{"email": "ana@example.com"}}), ErrDuplicateKey)
+	require.NoError(t, upsert(&testEntity{Type: "Store", Attributes: map[string]any{"email": "ana@example.com"}}))
+	require.NoError(t, upsert(&testEntity{Type: "Contact"}))
+	require.NoError(t, upsert(&testEntity{ID: ana.ID, Type: "Contact", Attributes: map[string]any{"email": "ana@example.com"}}))
+	_, err := repo.Upsert(ctx, "other", &testEntity{Type: "Contact", Attributes: map[string]any{"email": "ana@example.com"}})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DropIndex(ctx, "acc", "email"))
+	require.NoError(t, upsert(&testEntity{Type: "Contact", Attributes: map[string]any{"email": "ana@example.com"}}))
+}
+
+func TestMemoryRepositoryPatch(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*testEntity]()
+	e, err := repo.Upsert(ctx, "acc", &testEntity{Type: "Contact", Attributes: map[string]any{
+		"email":   "ana@example.com",
+		"address": map[string]any{"city": "Rosario", "zip": "2000"},
+	}})
+	require.NoError(t, err)
+
+	patched, err := repo.Patch(ctx, "acc", e.ID, 0,
+		map[string]any{"attributes.address.city": "Córdoba", "attributes.phone.mobile": "555"},
+		[]string{"attributes.address.zip", "attributes.missing.key"},
+	)
+	require.NoError(t, err)
+	attributes, err := json.Marshal(patched.Attributes)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"email":"ana@example.com","address":{"city":"Córdoba"},"phone":{"mobile":"555"}}`, string(attributes))
+	require.NotNil(t, patched.UpdatedAt)
+
+	_, err = repo.Patch(ctx, "acc", e.ID, 0, map[string]any{"attributes.email.user": "ana"}, nil)
+	require.ErrorIs(t, err, ErrInvalidPatch)
+	_, err = repo.Patch(ctx, "other", e.ID, 0, map[string]any{"type": "Store"}, nil)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+// textEntity is searchable by its tags.
+type textEntity struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type" bson:"type"`
+	Tags      []string   `json:"tags" bson:"tags"`
+	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+func (e *textEntity) GetID() string            { return e.ID }
+func (e *textEntity) SetID(id string)          { e.ID = id }
+func (e *textEntity) GetCreatedAt() *time.Time { return e.CreatedAt }
+func (e *textEntity) SetCreatedAt(t time.Time) { e.CreatedAt = &t }
+func (e *textEntity) GetUpdatedAt() *time.Time { return e.UpdatedAt }
+func (e *textEntity) SetUpdatedAt(t time.Time) { e.UpdatedAt = &t }
+func (e *textEntity) SearchTerms() []string    { return e.Tags }
+
+func TestMemoryRepositoryTextSearch(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[*textEntity]()
+
+	seed := []*textEntity{
+		{Type: "Contact", Tags: []string{"ana", "gomez"}},
+		{Type: "Contact", Tags: []string{"gomez"}},
+		{Type: "Store", Tags: []string{"ana", "gomez", "market"}},
+	}
+	for _, e := range seed {
+		_, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+	}
+	ids := func(entities []*textEntity) []string {
+		var ids []string
+		for _, e := range entities {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	t.Run("ranks by matched terms, ties broken by ID", func(t *testing.T) {
+		found, err := repo.TextSearch(ctx, "acc", []string{"ana", "gomez", "gomez"}, nil, 10)
+		require.NoError(t, err)
+		require.Equal(t, []string{seed[0].ID, seed[2].ID, seed[1].ID}, ids(found))
+	})
+
+	t.Run("filters and limits", func(t *testing.T) {
+		found, err := repo.TextSearch(ctx, "acc", []string{"gomez"}, map[string]any{"type": "Contact"}, 1)
+		require.NoError(t, err)
+		require.Equal(t, []string{seed[0].ID}, ids(found))
+	})
+
+	t.Run("follows writes", func(t *testing.T) {
+		seed[0].Tags = []string{"ana"}
+		_, err := repo.Upsert(ctx, "acc", seed[0])
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(ctx, "acc", seed[2].ID))
+
+		found, err := repo.TextSearch(ctx, "acc", []string{"gomez"}, nil, 10)
+		require.NoError(t, err)
+		require.Equal(t, []string{seed[1].ID}, ids(found))
+	})
+
+	t.Run("scopes by account", func(t *testing.T) {
+		found, err := repo.TextSearch(ctx, "other", []string{"gomez"}, nil, 10)
+		require.NoError(t, err)
+		require.Empty(t, found)
+	})
+	t.Run("reindexes the matching entities", func(t *testing.T) {
+		before, err := repo.GetByID(ctx, "acc", seed[1].ID)
+		require.NoError(t, err)
+
+		reindexed, err := repo.Reindex(ctx, "acc", map[string]any{"type": "Contact"}, map[string]any{"tags": []string{"vip"}})
+		require.NoError(t, err)
+		require.Equal(t, []string{seed[0].ID, seed[1].ID}, reindexed)
+
+		found, err := repo.TextSearch(ctx, "acc", []string{"vip"}, nil, 10)
+		require.NoError(t, err)
+		require.Equal(t, []string{seed[0].ID, seed[1].ID}, ids(found))
+		found, err = repo.TextSearch(ctx, "acc", []string{"gomez"}, nil, 10)
+		require.NoError(t, err)
+		require.Empty(t, found)
+
+		after, err := repo.GetByID(ctx, "acc", seed[1].ID)
+		require.NoError(t, err)
+		require.Equal(t, before.UpdatedAt, after.UpdatedAt)
+	})
+}
@@ -2,15 +2,32 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"slices"
+	"strings"
 	"time"
 )
 
-const accountIDKey = "accountId"
+const (
+	accountIDKey = "accountId"
+	versionKey   = "version"
+	// deletedAtKey is the document field holding the time soft deleted entities were deleted at.
+	deletedAtKey = "deletedAt"
+	// searchTermsKey is the document field holding the terms of searchable entities.
+	searchTermsKey = "searchTerms"
+	// scanBatchSize is the number of entities looked up at once by DeleteOlderThan and Reindex.
+	scanBatchSize = 1000
+	// textIndex names the text index on the search terms.
+	textIndex = "searchTerms_text"
+	// primaryKeyIndex identifies the _id index in duplicate key errors, as opposed to the unique indexes.
+	primaryKeyIndex = "index: _id_ "
+)
 
 // MongoRepository is a generic repository for MongoDB.
 type MongoRepository[T Entity] struct {
@@ -20,7 +37,7 @@ type MongoRepository[T Entity] struct {
 }
 
 // NewMongoRepository creates a new instance of MongoRepository.
-func NewMongoRepository[T Entity](client *mongo.Client, db, collection string) Repository[T] {
+func NewMongoRepository[T Entity](client *mongo.Client, db, collection string) *MongoRepository[T] {
 	return &MongoRepository[T]{
 		client:     client,
 		db:         db,
@@ -31,32 +48,200 @@ func NewMongoRepository[T Entity](client *mongo.Client, db, collection string) R
 func (r *MongoRepository[T]) Upsert(ctx context.Context, accountId string, entity T) (T, error) {
 	coll := r.client.Database(r.db).Collection(r.collection)
 
-	var objID primitive.ObjectID
-	var err error
+	plan, err := prepareUpsert(entity)
+	if err != nil {
+		return *new(T), err
+	}
 
-	isNew := entity.GetID() == ""
-	if isNew {
-		objID = primitive.NewObjectID()
-		entity.SetID(objID.Hex())
-		entity.SetCreatedAt(time.Now())
-	} else {
-		objID, err = primitive.ObjectIDFromHex(entity.GetID())
+	fields, err := withTerms(entity)
+	if err != nil {
+		plan.rollback(entity)
+		return *new(T), err
+	}
+	update := bson.M{"$set": fields}
+	opts := options.Update().SetUpsert(true)
+
+	_, err = coll.UpdateOne(ctx, plan.filter(accountId), update, opts)
+	if err != nil {
+		plan.rollback(entity)
+		if plan.conflict(err) {
+			return *new(T), ErrVersionConflict
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return *new(T), ErrDuplicateKey
+		}
+		return *new(T), err
+	}
+
+	return entity, nil
+}
+
+// BulkWrite upserts and deletes entities in a single unordered bulk write.
+// The returned slice holds one error per operation, upserts first and then deletes, nil on success.
+func (r *MongoRepository[T]) BulkWrite(ctx context.Context, accountID string, upserts []T, deleteIDs []string) ([]error, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	errs := make([]error, len(upserts)+len(deleteIDs))
+	models := make([]mongo.WriteModel, 0, len(errs))
+	indexes := make([]int, 0, len(errs))
+
+	plans := make([]*upsertPlan, len(upserts))
+	for i, entity := range upserts {
+		plan, err := prepareUpsert(entity)
 		if err != nil {
-			return *new(T), err
+			errs[i] = err
+			continue
 		}
-		entity.SetUpdatedAt(time.Now())
+		fields, err := withTerms(entity)
+		if err != nil {
+			plan.rollback(entity)
+			errs[i] = err
+			continue
+		}
+		plans[i] = plan
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(plan.filter(accountID)).
+			SetUpdate(bson.M{"$set": fields}).
+			SetUpsert(true))
+		indexes = append(indexes, i)
 	}
 
-	update := bson.M{"$set": entity}
-	opts := options.Update().SetUpsert(true)
+	for i, id := range deleteIDs {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			errs[len(upserts)+i] = err
+			continue
+		}
+		models = append(models, mongo.NewDeleteOneModel().
+			SetFilter(bson.M{"_id": objID, accountIDKey: accountID}))
+		indexes = append(indexes, len(upserts)+i)
+	}
+
+	if len(models) == 0 {
+		return errs, nil
+	}
+
+	_, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			i := indexes[writeErr.Index]
+			errs[i] = writeErr
+			if i < len(upserts) {
+				plans[i].rollback(upserts[i])
+				if plans[i].conflict(writeErr) {
+					errs[i] = ErrVersionConflict
+				} else if mongo.IsDuplicateKeyError(writeErr) {
+					errs[i] = ErrDuplicateKey
+				}
+			}
+		}
+		return errs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+// Patch sets and unsets fields of a stored entity in a single update, returning the patched entity.
+// The search terms of searchable entities depend on the whole patched entity, so the stored one is read and
+// patched in memory to compute them. They are written along with the patch, which only applies when the
+// stored entity did not change meanwhile.
+func (r *MongoRepository[T]) Patch(
+	ctx context.Context,
+	accountID, id string,
+	version int64,
+	set map[string]any,
+	unset []string,
+) (T, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
 
-	filter := bson.M{"_id": objID, accountIDKey: accountId}
-	_, err = coll.UpdateOne(ctx, filter, update, opts)
+	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return *new(T), err
 	}
+	filter := bson.M{"_id": objID, accountIDKey: accountID}
+	if version != 0 {
+		filter[versionKey] = version
+	}
 
-	return entity, nil
+	fields := bson.M{updatedAtKey: time.Now()}
+	for k, v := range set {
+		fields[k] = v
+	}
+	update := bson.M{"$set": fields}
+	if len(unset) > 0 {
+		removed := bson.M{}
+		for _, k := range unset {
+			removed[k] = ""
+		}
+		update["$unset"] = removed
+	}
+	_, versioned := any(*new(T)).(Versioned)
+	if versioned {
+		update["$inc"] = bson.M{versionKey: 1}
+	}
+	if _, ok := any(*new(T)).(Searchable); ok {
+		stored, terms, err := r.patchedTerms(ctx, coll, filter, set, unset)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments) && version != 0:
+			return *new(T), ErrVersionConflict
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return *new(T), ErrNotFound
+		case err != nil:
+			return *new(T), err
+		}
+		fields[searchTermsKey] = terms
+		switch {
+		case versioned && stored == 0:
+			// Entities stored before versioning was introduced have no version
+			filter[versionKey] = bson.M{"$in": bson.A{nil, 0}}
+		case versioned:
+			filter[versionKey] = stored
+		}
+	}
+
+	var result = *new(T)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(mongoProjection(nil))
+	err = coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments) && (version != 0 || filter[versionKey] != nil):
+		return *new(T), ErrVersionConflict
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return *new(T), ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return *new(T), ErrDuplicateKey
+	case err != nil:
+		return *new(T), err
+	}
+	return result, nil
+}
+
+// patchedTerms reads the entity matching filter and returns its version along with the search terms of
+// the entity once patched.
+func (r *MongoRepository[T]) patchedTerms(
+	ctx context.Context,
+	coll *mongo.Collection,
+	filter bson.M,
+	set map[string]any,
+	unset []string,
+) (int64, []string, error) {
+	var doc bson.M
+	if err := coll.FindOne(ctx, filter, options.FindOne().SetProjection(mongoProjection(nil))).Decode(&doc); err != nil {
+		return 0, nil, err
+	}
+	patched, err := applyPatch(doc, set, unset)
+	if err != nil {
+		return 0, nil, err
+	}
+	entity, err := fromDocument[T](patched)
+	if err != nil {
+		return 0, nil, err
+	}
+	stored, _ := doc[versionKey].(int64)
+	return stored, any(entity).(Searchable).SearchTerms(), nil
 }
 
 // GetByID finds an entity by its ID.
@@ -72,7 +257,7 @@ func (r *MongoRepository[T]) GetByID(ctx context.Context, accountID, id string)
 
 	var result = *new(T)
 
-	err = coll.FindOne(ctx, filter).Decode(&result)
+	err = coll.FindOne(ctx, filter, options.FindOne().SetProjection(mongoProjection(nil))).Decode(&result)
 	if err != nil {
 		return result, err
 	}
@@ -95,12 +280,18 @@ func (r *MongoRepository[T]) Delete(ctx context.Context, accountID, id string) e
 }
 
 // GetAll retrieves all entities, with pagination.
-func (r *MongoRepository[T]) GetAll(ctx context.Context, accountID string, page, limit int) ([]T, int, error) {
+func (r *MongoRepository[T]) GetAll(ctx context.Context, accountID string, page, limit int, includeDeleted bool) ([]T, int, error) {
 	coll := r.client.Database(r.db).Collection(r.collection)
 
-	findOptions := options.Find().SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	findOptions := options.Find().
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit)).
+		SetProjection(mongoProjection(nil))
 
 	filter := bson.M{accountIDKey: accountID}
+	if !includeDeleted {
+		filter[deletedAtKey] = nil
+	}
 	cursor, err := coll.Find(ctx, filter, findOptions)
 	if err != nil {
 		return nil, 0, err
@@ -117,7 +308,7 @@ func (r *MongoRepository[T]) GetAll(ctx context.Context, accountID string, page,
 		results = append(results, entity)
 	}
 
-	count, err := coll.CountDocuments(ctx, bson.M{})
+	count, err := coll.CountDocuments(ctx, filter)
 	if err != nil {
 		return nil, 0, errors.WithStack(err)
 	}
@@ -125,6 +316,177 @@ func (r *MongoRepository[T]) GetAll(ctx context.Context, accountID string, page,
 	return results, int(count), nil
 }
 
+// GetByIDs finds the entities with the given IDs.
+func (r *MongoRepository[T]) GetByIDs(ctx context.Context, accountID string, ids []string, includeDeleted bool) ([]T, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	objIDs := make(bson.A, 0, len(ids))
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+	if len(objIDs) == 0 {
+		return nil, nil
+	}
+
+	filter := bson.M{"_id": bson.M{"$in": objIDs}, accountIDKey: accountID}
+	if !includeDeleted {
+		filter[deletedAtKey] = nil
+	}
+	return r.decodeAll(ctx, coll, filter, options.Find().SetProjection(mongoProjection(nil)))
+}
+
+// Find is ExecuteQuery ordered by sort and loading only the given fields.
+func (r *MongoRepository[T]) Find(
+	ctx context.Context,
+	accountID string,
+	query map[string]any,
+	sort []pkg.Sort,
+	fields []string,
+	currentPage, perPage int,
+) ([]T, int, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	filter := scopedFilter(accountID, query)
+	findOptions := options.Find().
+		SetSort(mongoSort(sort)).
+		SetSkip(int64((currentPage - 1) * perPage)).
+		SetLimit(int64(perPage)).
+		SetProjection(mongoProjection(fields))
+	results, err := r.decodeAll(ctx, coll, filter, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return results, int(count), nil
+}
+
+// Seek returns up to limit entities matching query ordered by sort, following the entity with the ID after
+// or preceding the one with the ID before. Unlike a skip, the page is selected by comparing the sort keys
+// with those of that entity, which an index on them resolves however deep the page is.
+func (r *MongoRepository[T]) Seek(
+	ctx context.Context,
+	accountID string,
+	query map[string]any,
+	sort []pkg.Sort,
+	fields []string,
+	after, before string,
+	limit int,
+) ([]T, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	filter := scopedFilter(accountID, query)
+	order := mongoSort(sort)
+	boundary := after
+	// Preceding pages are read backwards from before, the entities are returned in sort order anyway.
+	if before != "" {
+		boundary = before
+		for i := range order {
+			order[i].Value = -order[i].Value.(int)
+		}
+	}
+	if boundary != "" {
+		keyset, err := r.keyset(ctx, coll, accountID, boundary, order)
+		if err != nil {
+			return nil, err
+		}
+		filter["$and"] = append(asArray(filter["$and"]), keyset)
+	}
+
+	findOptions := options.Find().SetSort(order).SetLimit(int64(limit)).SetProjection(mongoProjection(fields))
+	results, err := r.decodeAll(ctx, coll, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	if before != "" {
+		slices.Reverse(results)
+	}
+	return results, nil
+}
+
+// keyset returns the filter matching the documents following the one with the given ID in order. Each
+// key in turn may be beyond the value of that document while the previous keys are equal to theirs.
+// Missing and null values come first, as in Mongo sorts.
+func (r *MongoRepository[T]) keyset(ctx context.Context, coll *mongo.Collection, accountID, id string, order bson.D) (bson.M, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var doc bson.M
+	err = coll.FindOne(ctx, bson.M{"_id": objID, accountIDKey: accountID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrCursorNotFound
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var terms bson.A
+	equal := bson.A{}
+	for _, key := range order {
+		var value any
+		if values, found := lookup(doc, key.Key); found {
+			value = values[0]
+		}
+		var beyond bson.M
+		switch {
+		case key.Value.(int) > 0 && value == nil:
+			beyond = bson.M{key.Key: bson.M{"$ne": nil}}
+		case key.Value.(int) > 0:
+			beyond = bson.M{key.Key: bson.M{"$gt": value}}
+		case value != nil:
+			beyond = bson.M{"$or": bson.A{bson.M{key.Key: bson.M{"$lt": value}}, bson.M{key.Key: nil}}}
+		}
+		if beyond != nil {
+			terms = append(terms, bson.M{"$and": append(slices.Clone(equal), beyond)})
+		}
+		equal = append(equal, bson.M{key.Key: value})
+	}
+	if len(terms) == 0 {
+		// Nothing follows a document last in every key, which cannot happen while _id is among them.
+		return bson.M{"_id": bson.M{"$exists": false}}, nil
+	}
+	return bson.M{"$or": terms}, nil
+}
+
+// decodeAll finds the documents matching filter and decodes them.
+func (r *MongoRepository[T]) decodeAll(ctx context.Context, coll *mongo.Collection, filter bson.M, findOptions *options.FindOptions) ([]T, error) {
+	cursor, err := coll.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []T
+	for cursor.Next(ctx) {
+		var entity = *new(T)
+		if err := cursor.Decode(&entity); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		results = append(results, entity)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return results, nil
+}
+
+// Count counts the entities of the account matching query.
+func (r *MongoRepository[T]) Count(ctx context.Context, accountID string, query map[string]any) (int, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	count, err := coll.CountDocuments(ctx, scopedFilter(accountID, query))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return int(count), nil
+}
+
 // ExecuteQuery executes a query and returns a slice of entities with pagination.
 func (r *MongoRepository[T]) ExecuteQuery(
 	ctx context.Context,
@@ -141,7 +503,8 @@ func (r *MongoRepository[T]) ExecuteQuery(
 
 	findOptions := options.Find().
 		SetSkip(int64((currentPage - 1) * perPage)).
-		SetLimit(int64(perPage))
+		SetLimit(int64(perPage)).
+		SetProjection(mongoProjection(nil))
 
 	cursor, err := coll.Find(ctx, mongoQuery, findOptions)
 	if err != nil {
@@ -178,8 +541,7 @@ func (r *MongoRepository[T]) ExecutePipeline(
 
 	coll := r.client.Database(r.db).Collection(r.collection)
 
-	pipeline[accountIDKey] = accountID
-	matchStage := bson.D{{"$match", bson.D{{"$expr", pipeline}}}}
+	matchStage := bson.D{{"$match", bson.D{{accountIDKey, accountID}, {"$expr", pipeline}}}}
 
 	countPipeline := mongo.Pipeline{
 		matchStage,
@@ -223,3 +585,403 @@ func (r *MongoRepository[T]) ExecutePipeline(
 
 	return results, countResult.Total, nil
 }
+
+// DeleteOlderThan removes the entities of every account whose time field is before the given time.
+// The entities are looked up and then removed by batches of scanBatchSize.
+func (r *MongoRepository[T]) DeleteOlderThan(ctx context.Context, field string, before time.Time) (map[string][]string, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	filter := bson.M{field: bson.M{"$lt": before}}
+	opts := options.Find().SetProjection(bson.M{accountIDKey: 1}).SetLimit(scanBatchSize)
+	deleted := map[string][]string{}
+	for {
+		cursor, err := coll.Find(ctx, filter, opts)
+		if err != nil {
+			return deleted, errors.WithStack(err)
+		}
+		var found []struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			AccountID string             `bson:"accountId"`
+		}
+		if err = cursor.All(ctx, &found); err != nil {
+			return deleted, errors.WithStack(err)
+		}
+		if len(found) == 0 {
+			return deleted, nil
+		}
+
+		objIDs := make(bson.A, 0, len(found))
+		for _, doc := range found {
+			objIDs = append(objIDs, doc.ID)
+		}
+		if _, err = coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": objIDs}, field: bson.M{"$lt": before}}); err != nil {
+			return deleted, errors.WithStack(err)
+		}
+		for _, doc := range found {
+			deleted[doc.AccountID] = append(deleted[doc.AccountID], doc.ID.Hex())
+		}
+		if len(found) < scanBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// Aggregate computes the count and every facet in a single pass, each facet being a sub-pipeline of a
+// $facet stage.
+func (r *MongoRepository[T]) Aggregate(
+	ctx context.Context,
+	accountID string,
+	query map[string]any,
+	facets map[string]pkg.Facet,
+) (*pkg.Aggregation, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	stages := bson.M{totalFacet: bson.A{bson.M{"$count": "count"}}}
+	for name, f := range facets {
+		stages[name] = mongoFacet(f)
+	}
+	pipeline := mongo.Pipeline{
+		{{"$match", scopedFilter(accountID, query)}},
+		{{"$facet", stages}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var outputs []map[string][]bson.M
+	if err = cursor.All(ctx, &outputs); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	aggregation := &pkg.Aggregation{Facets: make(map[string]pkg.FacetResult, len(facets))}
+	var output map[string][]bson.M
+	if len(outputs) > 0 {
+		output = outputs[0]
+	}
+	if total := output[totalFacet]; len(total) > 0 {
+		aggregation.Total = int(toFloat(total[0]["count"]))
+	}
+	for name, f := range facets {
+		aggregation.Facets[name] = facetResult(f, output[name])
+	}
+	return aggregation, nil
+}
+
+// CountBy counts the entities matching query for each value of field.
+func (r *MongoRepository[T]) CountBy(ctx context.Context, accountID, field string, query map[string]any) (map[string]int, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	match := bson.M{}
+	for k, v := range query {
+		match[k] = v
+	}
+	match[accountIDKey] = accountID
+
+	pipeline := mongo.Pipeline{
+		{{"$match", match}},
+		{{"$group", bson.D{{"_id", "$" + field}, {"count", bson.D{{"$sum", 1}}}}}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := map[string]int{}
+	for cursor.Next(ctx) {
+		var group struct {
+			Value any `bson:"_id"`
+			Count int `bson:"count"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return nil, err
+		}
+		counts[countKey(group.Value)] += group.Count
+	}
+
+	return counts, cursor.Err()
+}
+
+// GraphLookup returns the entities matching query reached from the entity with the given ID in at most
+// maxDepth hops, walking the collection with $graphLookup.
+func (r *MongoRepository[T]) GraphLookup(
+	ctx context.Context,
+	accountID,
+	id,
+	connectFromField,
+	connectToField string,
+	maxDepth int,
+	query map[string]any,
+) ([]T, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	restrict := bson.M{}
+	for k, v := range query {
+		restrict[k] = v
+	}
+	restrict[accountIDKey] = accountID
+
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.M{"_id": objID, accountIDKey: accountID}}},
+		{{"$graphLookup", bson.D{
+			{"from", r.collection},
+			{"startWith", "$" + connectFromField},
+			{"connectFromField", connectFromField},
+			{"connectToField", connectToField},
+			{"as", "reached"},
+			{"maxDepth", maxDepth - 1},
+			{"restrictSearchWithMatch", restrict},
+		}}},
+		{{"$unwind", "$reached"}},
+		{{"$replaceRoot", bson.D{{"newRoot", "$reached"}}}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []T
+	for cursor.Next(ctx) {
+		var entity = *new(T)
+		if err := cursor.Decode(&entity); err != nil {
+			return nil, err
+		}
+		results = append(results, entity)
+	}
+
+	return results, cursor.Err()
+}
+
+// EnsureIndex creates, unless it exists, the named ascending index on the account ID and the fields.
+func (r *MongoRepository[T]) EnsureIndex(ctx context.Context, name string, fields []string) error {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	keys := bson.D{{accountIDKey, 1}}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+	}
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: options.Index().SetName(name)})
+	return err
+}
+
+// EnsureUniqueIndex creates, unless it exists, the named partial index making field unique among the
+// entities of the account matching filter that hold the field.
+func (r *MongoRepository[T]) EnsureUniqueIndex(ctx context.Context, accountID, name, field string, filter map[string]any) error {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	partial := bson.M{}
+	for k, v := range filter {
+		partial[k] = v
+	}
+	partial[accountIDKey] = accountID
+	partial[field] = bson.M{"$exists": true}
+
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{accountIDKey, 1}, {field, 1}},
+		Options: options.Index().SetName(name).SetUnique(true).SetPartialFilterExpression(partial),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+// DropIndex removes an index created by EnsureUniqueIndex.
+func (r *MongoRepository[T]) DropIndex(ctx context.Context, _, name string) error {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	_, err := coll.Indexes().DropOne(ctx, name)
+	return err
+}
+
+// EnsureTextIndex creates, unless it exists, the text index on the search terms of the entities. Terms are
+// matched as they are, without stemming nor stop words, since they are not words of a single language.
+func (r *MongoRepository[T]) EnsureTextIndex(ctx context.Context) error {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{accountIDKey, 1}, {searchTermsKey, "text"}},
+		Options: options.Index().SetName(textIndex).SetDefaultLanguage("none"),
+	})
+	return err
+}
+
+// TextSearch finds the entities holding the terms through the text index, ordered by text score.
+func (r *MongoRepository[T]) TextSearch(ctx context.Context, accountID string, terms []string, query map[string]any, limit int) ([]T, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	filter := scopedFilter(accountID, query)
+	filter["$text"] = bson.M{"$search": strings.Join(terms, " ")}
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score, searchTermsKey: 0}).
+		SetSort(bson.D{{"score", score}, {"_id", 1}}).
+		SetLimit(int64(limit))
+
+	return r.decodeAll(ctx, coll, filter, opts)
+}
+
+// Reindex updates the entities one by one, each on condition that its version did not change, by batches of
+// scanBatchSize.
+func (r *MongoRepository[T]) Reindex(ctx context.Context, accountID string, query map[string]any, set map[string]any) ([]string, error) {
+	coll := r.client.Database(r.db).Collection(r.collection)
+
+	filter := scopedFilter(accountID, query)
+	opts := options.Find().SetSort(bson.D{{"_id", 1}}).SetLimit(scanBatchSize).SetProjection(mongoProjection(nil))
+	var reindexed []string
+	var last primitive.ObjectID
+	for {
+		cursor, err := coll.Find(ctx, bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$gt": last}}}}, opts)
+		if err != nil {
+			return reindexed, errors.WithStack(err)
+		}
+		var docs []bson.M
+		if err = cursor.All(ctx, &docs); err != nil {
+			return reindexed, errors.WithStack(err)
+		}
+
+		for _, doc := range docs {
+			fields := bson.M{}
+			for k, v := range set {
+				fields[k] = v
+			}
+			patched, err := applyPatch(doc, set, nil)
+			if err != nil {
+				return reindexed, err
+			}
+			entity, err := fromDocument[T](patched)
+			if err != nil {
+				return reindexed, err
+			}
+			if s, ok := any(entity).(Searchable); ok {
+				fields[searchTermsKey] = s.SearchTerms()
+			}
+
+			id, _ := doc["_id"].(primitive.ObjectID)
+			target := bson.M{"_id": id, versionKey: doc[versionKey]}
+			res, err := coll.UpdateOne(ctx, target, bson.M{"$set": fields})
+			if err != nil {
+				// The update may have been applied anyway
+				return append(reindexed, id.Hex()), errors.WithStack(err)
+			}
+			if res.MatchedCount > 0 {
+				reindexed = append(reindexed, id.Hex())
+			}
+		}
+		if len(docs) < scanBatchSize {
+			return reindexed, nil
+		}
+		last, _ = docs[len(docs)-1]["_id"].(primitive.ObjectID)
+	}
+}
+
+// withTerms returns what an upsert sets for an entity: the entity itself, along with its search terms when
+// searchable.
+func withTerms(entity any) (any, error) {
+	s, ok := entity.(Searchable)
+	if !ok {
+		return entity, nil
+	}
+	doc, err := toDocument(entity)
+	if err != nil {
+		return nil, err
+	}
+	doc[searchTermsKey] = s.SearchTerms()
+	return doc, nil
+}
+
+// countKey returns the key under which CountBy reports a value, the empty string for a missing one.
+func countKey(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// upsertPlan describes how an entity prepared by prepareUpsert must be written.
+type upsertPlan struct {
+	objID     primitive.ObjectID
+	isNew     bool
+	versioned bool
+	expected  int64 // version the stored entity must have
+}
+
+// prepareUpsert assigns an ID and creation time to new entities, or the update time to existing ones,
+// and bumps the version of versioned entities.
+func prepareUpsert[T Entity](entity T) (*upsertPlan, error) {
+	plan := &upsertPlan{}
+	if entity.GetID() == "" {
+		plan.isNew = true
+		plan.objID = primitive.NewObjectID()
+		entity.SetID(plan.objID.Hex())
+		entity.SetCreatedAt(time.Now())
+	} else {
+		objID, err := primitive.ObjectIDFromHex(entity.GetID())
+		if err != nil {
+			return nil, err
+		}
+		plan.objID = objID
+		entity.SetUpdatedAt(time.Now())
+	}
+
+	if v, ok := any(entity).(Versioned); ok {
+		plan.versioned = true
+		plan.expected = v.GetVersion()
+		if plan.isNew {
+			plan.expected = 0
+		}
+		v.SetVersion(plan.expected + 1)
+	}
+	return plan, nil
+}
+
+// asArray returns the clauses of a $and built by scopedFilter.
+func asArray(v any) bson.A {
+	clauses, _ := v.(bson.A)
+	return clauses
+}
+
+// scopedFilter restricts query to the entities of the account without modifying it.
+func scopedFilter(accountID string, query map[string]any) bson.M {
+	filter := bson.M{accountIDKey: accountID}
+	if len(query) > 0 {
+		filter["$and"] = bson.A{bson.M(query)}
+	}
+	return filter
+}
+
+// filter returns the Mongo filter matching the entity to upsert, including its expected version.
+// Entities stored before versioning was introduced have no version and match version 0.
+func (p *upsertPlan) filter(accountID string) bson.M {
+	filter := bson.M{"_id": p.objID, accountIDKey: accountID}
+	if p.versioned && !p.isNew {
+		if p.expected == 0 {
+			filter[versionKey] = bson.M{"$in": bson.A{nil, 0}}
+		} else {
+			filter[versionKey] = p.expected
+		}
+	}
+	return filter
+}
+
+// conflict reports whether a write error means the stored version did not match: the upsert
+// filter missed the document and the fallback insert collided with its _id.
+func (p *upsertPlan) conflict(err error) bool {
+	return p.versioned && !p.isNew && mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), primaryKeyIndex)
+}
+
+// rollback restores the version of an entity whose write failed.
+func (p *upsertPlan) rollback(entity any) {
+	if v, ok := entity.(Versioned); ok && p.versioned {
+		v.SetVersion(p.expected)
+	}
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// updatedAtKey is the document field holding the update time of an entity.
+const updatedAtKey = "updatedAt"
+
+// applyPatch returns a copy of doc with the given dotted paths set and unset, like the Mongo $set and
+// $unset operators: missing intermediate documents are created and unsetting a missing path is a no-op.
+func applyPatch(doc bson.M, set map[string]any, unset []string) (bson.M, error) {
+	patched, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	values, err := toDocument(set)
+	if err != nil {
+		return nil, err
+	}
+
+	for path, value := range values {
+		parent, key, err := parentOf(patched, path, true)
+		if err != nil {
+			return nil, err
+		}
+		parent[key] = value
+	}
+	for _, path := range unset {
+		parent, key, err := parentOf(patched, path, false)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			delete(parent, key)
+		}
+	}
+	return patched, nil
+}
+
+// parentOf returns the document holding the last key of a dotted path, creating the missing ones when
+// create is set or returning nil otherwise.
+func parentOf(doc bson.M, path string, create bool) (map[string]any, string, error) {
+	keys := strings.Split(path, ".")
+	current := map[string]any(doc)
+	for _, key := range keys[:len(keys)-1] {
+		next, found := current[key]
+		if !found || next == nil {
+			if !create {
+				return nil, "", nil
+			}
+			next = bson.M{}
+		}
+		m, ok := asMapping(next)
+		if !ok {
+			return nil, "", ErrInvalidPatch
+		}
+		current[key] = m
+		current = m
+	}
+	return current, keys[len(keys)-1], nil
+}
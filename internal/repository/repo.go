@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
 )
 
 // Entity is an interface that all entities must implement.
@@ -16,12 +18,92 @@ type Entity interface {
 	SetUpdatedAt(t time.Time)
 }
 
+// Versioned is implemented by entities using optimistic concurrency control.
+// Upserts of an existing versioned entity only succeed when the stored version equals
+// the entity's version, which is then incremented.
+type Versioned interface {
+	GetVersion() int64
+	SetVersion(v int64)
+}
+
+// Searchable is implemented by entities found by free-text search. The terms they return are stored along
+// with them on every write, replacing the previous ones, and matched by TextSearch.
+type Searchable interface {
+	SearchTerms() []string
+}
+
 // Repository is a generic interface for a repository.
 type Repository[T any] interface {
 	Upsert(ctx context.Context, accountId string, entity T) (T, error)
+	BulkWrite(ctx context.Context, accountId string, upserts []T, deleteIDs []string) ([]error, error)
+	// Patch sets and unsets the given dotted paths of a stored entity, bumping its update time and the
+	// version of versioned entities. When version is not 0 the stored entity must be at that version,
+	// otherwise ErrVersionConflict. It returns the patched entity.
+	Patch(ctx context.Context, accountId, id string, version int64, set map[string]interface{}, unset []string) (T, error)
 	GetByID(ctx context.Context, accountId, id string) (T, error)
 	Delete(ctx context.Context, accountId, id string) error
-	GetAll(ctx context.Context, accountId string, page, limit int) ([]T, int, error)
+	// GetAll returns a page of the entities of the account, hiding the soft deleted ones, those holding a
+	// deletedAt time, unless includeDeleted.
+	GetAll(ctx context.Context, accountId string, page, limit int, includeDeleted bool) ([]T, int, error)
+	// GetByIDs returns the entities of the account with the given IDs, in no particular order. Unknown and
+	// malformed IDs are skipped, and so are soft deleted entities unless includeDeleted.
+	GetByIDs(ctx context.Context, accountId string, ids []string, includeDeleted bool) ([]T, error)
 	ExecuteQuery(ctx context.Context, accountId string, query map[string]interface{}, currentPage, perPage int) ([]T, int, error)
 	ExecutePipeline(ctx context.Context, accountId string, pipeline map[string]interface{}, currentPage, perPage int) ([]T, int, error)
+	// Find is ExecuteQuery ordered by sort, ties broken by ID, and only loading the given dotted fields, along
+	// with the ID, unless fields is empty.
+	Find(ctx context.Context, accountId string, query map[string]interface{}, sort []pkg.Sort, fields []string, currentPage, perPage int) ([]T, int, error)
+	// Seek returns up to limit entities matching query ordered by sort, ties broken by ID, keyset paginated:
+	// those following the entity with the ID after or, when before is set, the last ones preceding the entity
+	// with the ID before. A nil query matches every entity of the account, and fields restricts the loaded
+	// fields like in Find.
+	Seek(ctx context.Context, accountId string, query map[string]interface{}, sort []pkg.Sort, fields []string, after, before string, limit int) ([]T, error)
+	// Count counts the entities of the account matching query.
+	Count(ctx context.Context, accountId string, query map[string]interface{}) (int, error)
+	// DeleteOlderThan removes the entities of every account whose time field is before the given time. It
+	// returns the IDs of the removed entities keyed by account, along with the error that stopped it.
+	DeleteOlderThan(ctx context.Context, field string, before time.Time) (map[string][]string, error)
+	// EnsureIndex creates, unless it exists, the named index on the account ID followed by the given dotted
+	// fields, for the queries of an account filtering and sorting on them.
+	EnsureIndex(ctx context.Context, name string, fields []string) error
+}
+
+// Aggregator is implemented by the repositories summarizing the entities of an account.
+type Aggregator interface {
+	// Aggregate counts the entities of the account matching query, nil for all, and computes the facets over
+	// them, keyed by name.
+	Aggregate(ctx context.Context, accountId string, query map[string]interface{}, facets map[string]pkg.Facet) (*pkg.Aggregation, error)
+	// CountBy counts the entities matching query for each value of field.
+	CountBy(ctx context.Context, accountId, field string, query map[string]interface{}) (map[string]int, error)
+}
+
+// Grapher is implemented by the repositories walking the references between the entities of an account.
+type Grapher[T any] interface {
+	// GraphLookup returns the entities matching query reached from the entity with the given ID in at most
+	// maxDepth hops, where each hop goes from the values of connectFromField to the entities whose
+	// connectToField holds one of them.
+	GraphLookup(ctx context.Context, accountId, id, connectFromField, connectToField string, maxDepth int, query map[string]interface{}) ([]T, error)
+}
+
+// UniqueIndexer is implemented by the repositories enforcing unique fields.
+type UniqueIndexer interface {
+	// EnsureUniqueIndex creates, unless it exists, the named index making field unique among the entities of
+	// the account matching filter. Writes colliding on the index fail with ErrDuplicateKey.
+	EnsureUniqueIndex(ctx context.Context, accountId, name, field string, filter map[string]interface{}) error
+	// DropIndex removes an index created by EnsureUniqueIndex.
+	DropIndex(ctx context.Context, accountId, name string) error
+}
+
+// TextSearcher is implemented by the repositories finding searchable entities by their search terms.
+type TextSearcher[T any] interface {
+	// EnsureTextIndex creates, unless it exists, the index TextSearch reads the search terms from.
+	EnsureTextIndex(ctx context.Context) error
+	// TextSearch returns up to limit searchable entities of the account matching query and holding at least
+	// one of the given search terms, best matching first, ties broken by ID.
+	TextSearch(ctx context.Context, accountId string, terms []string, query map[string]interface{}, limit int) ([]T, error)
+	// Reindex sets the given dotted fields of the entities of the account matching query and writes their
+	// search terms again, leaving their version and update time as they are, since it changes how they are
+	// indexed rather than the entities. Entities written meanwhile are left to that write. It returns the
+	// IDs of the reindexed entities, along with the error that stopped it.
+	Reindex(ctx context.Context, accountId string, query map[string]interface{}, set map[string]interface{}) ([]string, error)
 }
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// entityIDKey is the document field holding the ID of an entity.
+const entityIDKey = "id"
+
+// compareDocs orders the documents stored under two IDs by sort, ties broken by ID. Like Mongo, missing
+// and null values come first and values of different types are ordered by type.
+func compareDocs(idA string, a bson.M, idB string, b bson.M, sort []pkg.Sort) int {
+	for _, s := range sort {
+		c := compareValues(sortValue(a, s.Field), sortValue(b, s.Field))
+		if s.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(idA, idB)
+}
+
+// sortValue returns the value a document is sorted by for a field, nil when missing.
+func sortValue(doc bson.M, field string) any {
+	values, found := lookup(doc, field)
+	if !found {
+		return nil
+	}
+	return normalize(values[0])
+}
+
+func compareValues(a, b any) int {
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		return ra - rb
+	}
+	if c, ok := compare(a, b); ok {
+		return c
+	}
+	if a, ok := a.(bool); ok && a != b.(bool) {
+		if a {
+			return 1
+		}
+		return -1
+	}
+	return 0
+}
+
+// typeRank follows the order of the BSON types in Mongo sorts.
+func typeRank(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case float64:
+		return 1
+	case string:
+		return 2
+	case map[string]any:
+		return 3
+	case []any:
+		return 4
+	case bool:
+		return 5
+	case time.Time:
+		return 6
+	}
+	return 7
+}
+
+// project keeps the given dotted fields of a document, along with the entity ID, or the whole document
+// when fields is empty.
+func project(doc bson.M, fields []string) bson.M {
+	if len(fields) == 0 {
+		return doc
+	}
+	projected := bson.M{entityIDKey: doc[entityIDKey]}
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		var current any = map[string]any(doc)
+		for _, key := range path[:len(path)-1] {
+			m, ok := asMapping(current)
+			if !ok {
+				current = nil
+				break
+			}
+			current = m[key]
+		}
+		m, ok := asMapping(current)
+		if !ok {
+			continue
+		}
+		value, ok := m[path[len(path)-1]]
+		if !ok {
+			continue
+		}
+
+		target := map[string]any(projected)
+		for _, key := range path[:len(path)-1] {
+			child, ok := target[key].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				target[key] = child
+			}
+			target = child
+		}
+		target[path[len(path)-1]] = value
+	}
+	return projected
+}
+
+// mongoSort translates sort to a Mongo sort document, ties broken by _id.
+func mongoSort(sort []pkg.Sort) bson.D {
+	order := make(bson.D, 0, len(sort)+1)
+	for _, s := range sort {
+		if s.Field == entityIDKey {
+			break
+		}
+		order = append(order, bson.E{Key: s.Field, Value: direction(s.Desc)})
+	}
+	last := len(order)
+	if last < len(sort) {
+		return append(order, bson.E{Key: "_id", Value: direction(sort[last].Desc)})
+	}
+	return append(order, bson.E{Key: "_id", Value: 1})
+}
+
+// mongoProjection translates fields to a Mongo projection. Without fields every field is loaded but the
+// search terms, which are only read by the text index.
+func mongoProjection(fields []string) bson.M {
+	if len(fields) == 0 {
+		return bson.M{searchTermsKey: 0}
+	}
+	projection := bson.M{entityIDKey: 1}
+	for _, field := range fields {
+		projection[field] = 1
+	}
+	return projection
+}
+
+func direction(desc bool) int {
+	if desc {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,19 @@
+package rest
+
+import (
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// ActorHeader is the header identifying who performs the request, e.g. a user or an integration.
+const ActorHeader = "X-Actor-ID"
+
+// Actor is a middleware that stores the X-Actor-ID header in the request context.
+func Actor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if actor := c.GetHeader(ActorHeader); actor != "" {
+			c.Request = c.Request.WithContext(pkg.WithActor(c.Request.Context(), actor))
+		}
+		c.Next()
+	}
+}
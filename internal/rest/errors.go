@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Stable error codes returned in the error envelope.
+const (
+	CodeInvalidID      = "invalid_id"
+	CodeInvalid        = "invalid_argument"
+	CodeInvalidRequest = "invalid_request"
+	CodeNotFound       = "not_found"
+	CodeConflict       = "conflict"
+	CodeInternal       = "internal"
+	CodeNotImplemented = "not_implemented"
+	CodePrecondition   = "precondition_failed"
+)
+
+// ErrorBody is the JSON error envelope returned by every endpoint.
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// ErrorResponse wraps ErrorBody under the "error" key.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// detailer is implemented by errors that carry extra information for the client.
+type detailer interface {
+	Details() any
+}
+
+// Error translates err into an HTTP status and writes the error envelope.
+// Domain errors from pkg and well-known driver errors are mapped to 4xx,
+// everything else is logged and answered with a generic 500.
+func Error(c *gin.Context, err error) {
+	status, body := translate(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("%+v", err)
+	}
+	body.RequestID = RequestIDFrom(c)
+	c.AbortWithStatusJSON(status, ErrorResponse{Error: body})
+}
+
+// InvalidRequest writes a 400 for a malformed request, e.g. a body binding error.
+func InvalidRequest(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: ErrorBody{
+		Code:      CodeInvalidRequest,
+		Message:   "invalid request",
+		Details:   err.Error(),
+		RequestID: RequestIDFrom(c),
+	}})
+}
+
+func translate(err error) (int, ErrorBody) {
+	var (
+		idErr       pkg.ErrIDType
+		invalidErr  pkg.ErrInvalidType
+		notFoundErr pkg.ErrNotFoundType
+		conflictErr pkg.ErrConflictType
+		internalErr pkg.ErrInternalErrorType
+		notImplErr  pkg.ErrNotImplementedType
+		precondErr  pkg.ErrPreconditionFailedType
+	)
+
+	var body ErrorBody
+	status := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &idErr):
+		status, body = http.StatusBadRequest, ErrorBody{Code: CodeInvalidID, Message: err.Error()}
+	case errors.As(err, &invalidErr):
+		status, body = http.StatusBadRequest, ErrorBody{Code: CodeInvalid, Message: err.Error()}
+	case errors.As(err, &notFoundErr):
+		status, body = http.StatusNotFound, ErrorBody{Code: CodeNotFound, Message: err.Error()}
+	case errors.As(err, &conflictErr):
+		status, body = http.StatusConflict, ErrorBody{Code: CodeConflict, Message: err.Error()}
+	case errors.As(err, &precondErr):
+		status, body = http.StatusPreconditionFailed, ErrorBody{Code: CodePrecondition, Message: err.Error()}
+	case errors.As(err, &internalErr):
+		body = ErrorBody{Code: CodeInternal, Message: internalErr.Error()}
+	case errors.As(err, &notImplErr):
+		status, body = http.StatusNotImplemented, ErrorBody{Code: CodeNotImplemented, Message: notImplErr.Error()}
+	case errors.Is(err, mongo.ErrNoDocuments):
+		status, body = http.StatusNotFound, ErrorBody{Code: CodeNotFound, Message: "resource not found"}
+	case errors.Is(err, primitive.ErrInvalidHex):
+		status, body = http.StatusBadRequest, ErrorBody{Code: CodeInvalidID, Message: "malformed id"}
+	case mongo.IsDuplicateKeyError(err):
+		status, body = http.StatusConflict, ErrorBody{Code: CodeConflict, Message: "resource already exists"}
+	default:
+		body = ErrorBody{Code: CodeInternal, Message: "internal server error"}
+	}
+
+	var d detailer
+	if errors.As(err, &d) {
+		body.Details = d.Details()
+	}
+	return status, body
+}
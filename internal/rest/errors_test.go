@@ -0,0 +1,71 @@
+package rest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		it     string
+		err    error
+		status int
+		code   string
+	}{
+		{
+			it:     "maps id errors to 400",
+			err:    errors.WithStack(pkg.NewErrID("missing entity id")),
+			status: http.StatusBadRequest,
+			code:   CodeInvalidID,
+		},
+		{
+			it:     "maps invalid errors to 400",
+			err:    pkg.NewErrInvalid("invalid entity data"),
+			status: http.StatusBadRequest,
+			code:   CodeInvalid,
+		},
+		{
+			it:     "maps not found errors to 404",
+			err:    errors.WithStack(pkg.NewErrNotFound("entity not found")),
+			status: http.StatusNotFound,
+			code:   CodeNotFound,
+		},
+		{
+			it:     "maps conflict errors to 409",
+			err:    pkg.NewErrConflict("entity conflict occurred"),
+			status: http.StatusConflict,
+			code:   CodeConflict,
+		},
+		{
+			it:     "maps mongo no documents to 404",
+			err:    errors.WithStack(mongo.ErrNoDocuments),
+			status: http.StatusNotFound,
+			code:   CodeNotFound,
+		},
+		{
+			it:     "maps malformed object ids to 400",
+			err:    errors.WithStack(primitive.ErrInvalidHex),
+			status: http.StatusBadRequest,
+			code:   CodeInvalidID,
+		},
+		{
+			it:     "hides unknown errors behind a 500",
+			err:    errors.New("connection refused"),
+			status: http.StatusInternalServerError,
+			code:   CodeInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			status, body := translate(tt.err)
+			require.Equal(t, tt.status, status)
+			require.Equal(t, tt.code, body.Code)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidIfMatch = pkg.NewErrPreconditionFailed("If-Match header does not match any version")
+
+// SetETag sets the ETag header of the response to the given version.
+func SetETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// IfMatch returns the version requested by the If-Match header, or 0 when the header is
+// missing or '*', meaning the write is unconditional.
+func IfMatch(c *gin.Context) (int64, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	tag, err := strconv.Unquote(strings.TrimPrefix(header, "W/"))
+	if err != nil {
+		return 0, ErrInvalidIfMatch
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version < 1 {
+		return 0, ErrInvalidIfMatch
+	}
+	return version, nil
+}
@@ -0,0 +1,36 @@
+package rest
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCurrentPage = 1
+	defaultPerPage     = 50
+)
+
+// Page reads the currentPage and perPage query parameters, falling back to
+// the defaults when they are missing or out of range.
+func Page(c *gin.Context) (currentPage, perPage int) {
+	currentPage, _ = strconv.Atoi(c.DefaultQuery("currentPage", strconv.Itoa(defaultCurrentPage)))
+	perPage, _ = strconv.Atoi(c.DefaultQuery("perPage", strconv.Itoa(defaultPerPage)))
+
+	if currentPage < 1 {
+		currentPage = defaultCurrentPage
+	}
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	return currentPage, perPage
+}
+
+// Cursor reads the cursor query parameter, reporting whether it is present. Its presence, even empty for
+// the first page, selects cursor pagination over currentPage, the page size still being perPage. count
+// tells whether the client asked for the total with count=true.
+func Cursor(c *gin.Context) (cursor string, ok, count bool) {
+	cursor, ok = c.GetQuery("cursor")
+	count, _ = strconv.ParseBool(c.Query("count"))
+	return cursor, ok, count
+}
@@ -0,0 +1,30 @@
+package rest
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header used to propagate the request id.
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "requestId"
+
+// RequestID is a middleware that reuses the incoming X-Request-ID header or
+// generates a new one, and echoes it back in the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestIDFrom returns the request id set by the RequestID middleware.
+func RequestIDFrom(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"strings"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidSort = pkg.NewErrInvalid("sort must be a comma separated list of field:1 or field:-1")
+
+// Sort reads the sort query parameter, a comma separated list of dotted fields each followed by :1 to
+// order ascending, the default, or :-1 to order descending, e.g. 'attributes.lastPurchase:-1,createdAt:1'.
+func Sort(c *gin.Context) ([]pkg.Sort, error) {
+	param := c.Query("sort")
+	if param == "" {
+		return nil, nil
+	}
+	var sort []pkg.Sort
+	for _, item := range strings.Split(param, ",") {
+		field, order, _ := strings.Cut(strings.TrimSpace(item), ":")
+		if field == "" {
+			return nil, ErrInvalidSort
+		}
+		switch order {
+		case "", "1":
+			sort = append(sort, pkg.Sort{Field: field})
+		case "-1":
+			sort = append(sort, pkg.Sort{Field: field, Desc: true})
+		default:
+			return nil, ErrInvalidSort
+		}
+	}
+	return sort, nil
+}
+
+// Fields reads the fields query parameter, a comma separated list of dotted fields.
+func Fields(c *gin.Context) []string {
+	var fields []string
+	for _, field := range strings.Split(c.Query("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
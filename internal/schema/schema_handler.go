@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// service define business logic for schema.
+type service struct {
+	schema.Saver
+	schema.Deleter
+	schema.Getter
+}
+
+// Handler rest api for schema.
+type Handler struct {
+	service *service
+}
+
+// NewHandler creates a new handler for schema.
+func NewHandler(saver schema.Saver, deleter schema.Deleter, getter schema.Getter) *Handler {
+	s := &service{
+		Saver:   saver,
+		Deleter: deleter,
+		Getter:  getter,
+	}
+	return &Handler{service: s}
+}
+
+// Create manages the registration of the schema of an entity type.
+func (h *Handler) Create(c *gin.Context) {
+	var sc schema.Schema
+	if err := c.ShouldBindJSON(&sc); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	created, err := h.service.Create(ctx, c.Param("accountId"), &sc)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// Update manages registering a new version of the schema of an entity type.
+func (h *Handler) Update(c *gin.Context) {
+	var sc schema.Schema
+	if err := c.ShouldBindJSON(&sc); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	updated, err := h.service.Update(ctx, c.Param("accountId"), c.Param("entityType"), &sc)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// Delete manages the removal of the schema of an entity type.
+func (h *Handler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.Delete(ctx, c.Param("accountId"), c.Param("entityType")); err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schema deleted"})
+}
+
+// Get manages fetching the schema of an entity type, the latest version unless one is requested.
+func (h *Handler) Get(c *gin.Context) {
+	version, ok := h.version(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	sc, err := h.service.Get(ctx, c.Param("accountId"), c.Param("entityType"), version)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sc)
+}
+
+// GetAll manages listing the schema versions of an account, optionally filtered by entity type.
+func (h *Handler) GetAll(c *gin.Context) {
+	currentPage, perPage := rest.Page(c)
+
+	ctx := c.Request.Context()
+	schemas, totalItems, err := h.service.GetAll(ctx, c.Param("accountId"), c.Query("entityType"), currentPage, perPage)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schemas":    schemas,
+		"pagination": pkg.NewPagination(currentPage, perPage, totalItems),
+	})
+}
+
+// Validate manages a dry-run validation of attributes against the schema of an entity type.
+func (h *Handler) Validate(c *gin.Context) {
+	var body struct {
+		Attributes map[string]any `json:"attributes"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+	version, ok := h.version(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	sc, err := h.service.Get(ctx, c.Param("accountId"), c.Param("entityType"), version)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+	fieldErrors, err := sc.Validate(body.Attributes)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+	if fieldErrors == nil {
+		fieldErrors = []schema.FieldError{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":   len(fieldErrors) == 0,
+		"version": sc.Version,
+		"errors":  fieldErrors,
+	})
+}
+
+// version reads the optional version query parameter, writing a 400 when it is malformed.
+func (h *Handler) version(c *gin.Context) (int, bool) {
+	v := c.Query("version")
+	if v == "" {
+		return 0, true
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil || version < 1 {
+		rest.Error(c, schema.ErrInvalidVersion)
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,167 @@
+package segment
+
+import (
+	"net/http"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/rest"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/segment"
+	"github.com/gin-gonic/gin"
+)
+
+// service define business logic for segment.
+type service struct {
+	segment.Saver
+	segment.Deleter
+	segment.Getter
+	segment.Evaluator
+}
+
+// Handler rest api for segment.
+type Handler struct {
+	service *service
+}
+
+// NewHandler creates a new handler for segment.
+func NewHandler(saver segment.Saver, deleter segment.Deleter, getter segment.Getter, evaluator segment.Evaluator) *Handler {
+	s := &service{
+		Saver:     saver,
+		Deleter:   deleter,
+		Getter:    getter,
+		Evaluator: evaluator,
+	}
+	return &Handler{service: s}
+}
+
+// Create manages the creation of a new segment.
+func (h *Handler) Create(c *gin.Context) {
+	var sg segment.Segment
+	if err := c.ShouldBindJSON(&sg); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	created, err := h.service.Create(ctx, c.Param("accountId"), &sg)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// Update manages the update of an existing segment.
+func (h *Handler) Update(c *gin.Context) {
+	var sg segment.Segment
+	if err := c.ShouldBindJSON(&sg); err != nil {
+		rest.InvalidRequest(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	updated, err := h.service.Update(ctx, c.Param("accountId"), c.Param("segmentId"), &sg)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// Delete manages the deletion of a segment.
+func (h *Handler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.Delete(ctx, c.Param("accountId"), c.Param("segmentId")); err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Segment deleted"})
+}
+
+// GetByID manages fetching a segment by its ID.
+func (h *Handler) GetByID(c *gin.Context) {
+	ctx := c.Request.Context()
+	sg, err := h.service.GetByID(ctx, c.Param("accountId"), c.Param("segmentId"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sg)
+}
+
+// GetAll manages listing the segments of an account, optionally filtered by name.
+func (h *Handler) GetAll(c *gin.Context) {
+	currentPage, perPage := rest.Page(c)
+
+	ctx := c.Request.Context()
+	segments, totalItems, err := h.service.GetAll(ctx, c.Param("accountId"), c.Query("name"), currentPage, perPage)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"segments":   segments,
+		"pagination": pkg.NewPagination(currentPage, perPage, totalItems),
+	})
+}
+
+// Count manages counting the entities in a segment.
+func (h *Handler) Count(c *gin.Context) {
+	ctx := c.Request.Context()
+	count, err := h.service.Count(ctx, c.Param("accountId"), c.Param("segmentId"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"count": count})
+}
+
+// Members manages listing the entities in a segment with pagination.
+func (h *Handler) Members(c *gin.Context) {
+	currentPage, perPage := rest.Page(c)
+	filter := segment.MemberFilter{
+		EntityType:  c.Query("entityType"),
+		ExcludeType: c.Query("excludeType"),
+	}
+
+	ctx := c.Request.Context()
+	entities, totalItems, err := h.service.Members(ctx, c.Param("accountId"), c.Param("segmentId"), filter, currentPage, perPage)
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entities":   entities,
+		"pagination": pkg.NewPagination(currentPage, perPage, totalItems),
+	})
+}
+
+// IsMember manages checking whether an entity belongs to a segment.
+func (h *Handler) IsMember(c *gin.Context) {
+	ctx := c.Request.Context()
+	isMember, err := h.service.IsMember(ctx, c.Param("accountId"), c.Param("segmentId"), c.Param("id"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"isMember": isMember})
+}
+
+// SegmentsOf manages listing the segments an entity belongs to.
+func (h *Handler) SegmentsOf(c *gin.Context) {
+	ctx := c.Request.Context()
+	segments, err := h.service.SegmentsOf(ctx, c.Param("accountId"), c.Param("id"))
+	if err != nil {
+		rest.Error(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"segments": segments})
+}
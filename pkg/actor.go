@@ -0,0 +1,16 @@
+package pkg
+
+import "context"
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the ID of who performs the request.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the ID of who performs the request, or an empty string when unknown.
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
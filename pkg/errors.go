@@ -59,3 +59,27 @@ func (e ErrInternalErrorType) Error() string {
 func NewErrInternalError(msg string) ErrInternalErrorType {
 	return ErrInternalErrorType{msg: msg}
 }
+
+type ErrNotImplementedType struct {
+	msg string
+}
+
+func (e ErrNotImplementedType) Error() string {
+	return e.msg
+}
+
+func NewErrNotImplemented(msg string) ErrNotImplementedType {
+	return ErrNotImplementedType{msg: msg}
+}
+
+type ErrPreconditionFailedType struct {
+	msg string
+}
+
+func (e ErrPreconditionFailedType) Error() string {
+	return e.msg
+}
+
+func NewErrPreconditionFailed(msg string) ErrPreconditionFailedType {
+	return ErrPreconditionFailedType{msg: msg}
+}
@@ -0,0 +1,74 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+)
+
+// Payload represents the additional data carried by an event.
+type Payload map[string]any
+
+// Event represents something that happened to an entity at a given point in time, e.g. a purchase or a login.
+type Event struct {
+	ID        string    `json:"id"`                         // Unique identifier for the event
+	AccountID string    `json:"accountId" bson:"accountId"` // ID of the associated account
+	EntityID  string    `json:"entityId" bson:"entityId"`   // ID of the entity the event is associated with
+	EventType string    `json:"eventType" bson:"eventType"` // Type of event, e.g., 'purchase', 'login'
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"` // Time at which the event occurred
+	Payload   Payload   `json:"payload" bson:"payload"`     // Additional data related to the event
+
+	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"` // Timestamp of event ingestion
+	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"` // Timestamp of last event update
+}
+
+// GetID returns the event's unique identifier.
+func (e *Event) GetID() string {
+	return e.ID
+}
+
+// SetID sets the event's unique identifier.
+func (e *Event) SetID(id string) {
+	e.ID = id
+}
+
+// GetCreatedAt returns the timestamp of when the event was ingested.
+func (e *Event) GetCreatedAt() *time.Time {
+	return e.CreatedAt
+}
+
+// SetCreatedAt sets the timestamp of when the event was ingested.
+func (e *Event) SetCreatedAt(t time.Time) {
+	e.CreatedAt = &t
+}
+
+// GetUpdatedAt returns the timestamp of the last update to the event.
+func (e *Event) GetUpdatedAt() *time.Time {
+	return e.UpdatedAt
+}
+
+// SetUpdatedAt sets the timestamp of the last update to the event.
+func (e *Event) SetUpdatedAt(t time.Time) {
+	e.UpdatedAt = &t
+}
+
+// TimeRange restricts a timeline to the events that occurred in [From, To). Zero values leave the range open.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+type Ingester interface {
+	Ingest(ctx context.Context, accountId string, event *Event) (*Event, error)
+}
+
+type Getter interface {
+	Timeline(ctx context.Context, accountId, entityId string, timeRange TimeRange, currentPage, perPage int) ([]*Event, int, error)
+}
+
+type Repository interface {
+	Upsert(ctx context.Context, accountId string, event *Event) (*Event, error)
+	Find(ctx context.Context, accountId string, query map[string]any, sort []pkg.Sort, fields []string, page, limit int) ([]*Event, int, error)
+	EnsureIndex(ctx context.Context, name string, fields []string) error
+}
@@ -0,0 +1,12 @@
+package event
+
+import "github.com/dportaluppi/customer-profiles-api/pkg"
+
+var (
+	ErrAccountIDMissing            = pkg.NewErrID("missing account id")
+	ErrEntityIDMissing             = pkg.NewErrID("missing entity id")
+	ErrInvalid                     = pkg.NewErrInvalid("invalid event data")
+	ErrEventTypeMissing            = pkg.NewErrInvalid("missing event type")
+	ErrInvalidTimeRange            = pkg.NewErrInvalid("invalid event time range")
+	ErrInvalidPaginationParameters = pkg.NewErrInvalid("invalid event pagination parameters")
+)
@@ -0,0 +1,63 @@
+package event
+
+import (
+	"context"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/pkg/errors"
+)
+
+// TimelineIndex is the name of the index of the events repository serving Timeline.
+const TimelineIndex = "accountId_entityId_timestamp"
+
+// timelineFields are the fields indexed by TimelineIndex, after the account ID.
+var timelineFields = []string{"entityId", "timestamp"}
+
+// EnsureIndexes creates, unless they exist, the indexes of the events repository.
+func EnsureIndexes(ctx context.Context, repo Repository) error {
+	return repo.EnsureIndex(ctx, TimelineIndex, timelineFields)
+}
+
+// getter implements the event retrieval service.
+type getter struct {
+	repo Repository
+}
+
+func NewGetter(repo Repository) Getter {
+	return &getter{repo: repo}
+}
+
+// Timeline returns the events of an entity within the given time range, oldest first.
+func (s *getter) Timeline(ctx context.Context, accountID, entityID string, timeRange TimeRange, currentPage, perPage int) ([]*Event, int, error) {
+	if accountID == "" {
+		return nil, 0, ErrAccountIDMissing
+	}
+	if entityID == "" {
+		return nil, 0, ErrEntityIDMissing
+	}
+	if currentPage < 1 || perPage < 1 {
+		return nil, 0, ErrInvalidPaginationParameters
+	}
+	if !timeRange.From.IsZero() && !timeRange.To.IsZero() && !timeRange.From.Before(timeRange.To) {
+		return nil, 0, ErrInvalidTimeRange
+	}
+
+	query := map[string]any{"entityId": entityID}
+	timestamp := map[string]any{}
+	if !timeRange.From.IsZero() {
+		timestamp["$gte"] = timeRange.From
+	}
+	if !timeRange.To.IsZero() {
+		timestamp["$lt"] = timeRange.To
+	}
+	if len(timestamp) > 0 {
+		query["timestamp"] = timestamp
+	}
+
+	order := []pkg.Sort{{Field: "timestamp"}}
+	events, count, err := s.repo.Find(ctx, accountID, query, order, nil, currentPage, perPage)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return events, count, nil
+}
@@ -0,0 +1,56 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeline(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository[*Event]()
+	at := func(h int) time.Time { return time.Date(2024, 1, 1, h, 0, 0, 0, time.UTC) }
+	for _, e := range []*Event{
+		{EntityID: "e1", EventType: "login", Timestamp: at(2)},
+		{EntityID: "e2", EventType: "login", Timestamp: at(1)},
+		{EntityID: "e1", EventType: "purchase", Timestamp: at(1)},
+		{EntityID: "e1", EventType: "logout", Timestamp: at(3)},
+	} {
+		e.AccountID = "acc"
+		_, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		it        string
+		timeRange TimeRange
+		page      int
+		want      []string
+		err       error
+	}{
+		{it: "lists the events of the entity oldest first", page: 1, want: []string{"purchase", "login", "logout"}},
+		{it: "starts the range at from", timeRange: TimeRange{From: at(2)}, page: 1, want: []string{"login", "logout"}},
+		{it: "ends the range before to", timeRange: TimeRange{To: at(3)}, page: 1, want: []string{"purchase", "login"}},
+		{it: "rejects empty ranges", timeRange: TimeRange{From: at(2), To: at(2)}, page: 1, err: ErrInvalidTimeRange},
+		{it: "rejects invalid pages", page: 0, err: ErrInvalidPaginationParameters},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			events, total, err := NewGetter(repo).Timeline(ctx, "acc", "e1", tt.timeRange, tt.page, 10)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			var types []string
+			for _, e := range events {
+				types = append(types, e.EventType)
+			}
+			require.Equal(t, tt.want, types)
+			require.Equal(t, len(tt.want), total)
+		})
+	}
+}
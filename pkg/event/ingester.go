@@ -0,0 +1,51 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/pkg/errors"
+)
+
+// ingester implements the event ingestion service.
+type ingester struct {
+	repo     Repository
+	entities profile.Getter
+}
+
+func NewIngester(repo Repository, entities profile.Getter) Ingester {
+	return &ingester{repo: repo, entities: entities}
+}
+
+// Ingest stores an event once the referenced entity is known to exist for the account.
+func (s *ingester) Ingest(ctx context.Context, accountID string, event *Event) (*Event, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if event == nil {
+		return nil, ErrInvalid
+	}
+	if event.EntityID == "" {
+		return nil, ErrEntityIDMissing
+	}
+	if event.EventType == "" {
+		return nil, ErrEventTypeMissing
+	}
+
+	if _, err := s.entities.GetByID(ctx, accountID, event.EntityID, profile.QueryOptions{}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	event.ID = ""
+	event.AccountID = accountID
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	e, err := s.repo.Upsert(ctx, accountID, event)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return e, nil
+}
@@ -0,0 +1,55 @@
+package pkg
+
+// Types of Facet.
+const (
+	FacetTerms         = "terms"         // Counts of the most frequent values
+	FacetHistogram     = "histogram"     // Counts of the numbers falling in buckets of a fixed width
+	FacetDateHistogram = "dateHistogram" // Counts of the timestamps falling in the same calendar unit
+	FacetStats         = "stats"         // Count, minimum, maximum, average and sum of the numbers
+)
+
+// Calendar units of a date histogram, in UTC. Weeks start on Monday.
+const (
+	UnitHour  = "hour"
+	UnitDay   = "day"
+	UnitWeek  = "week"
+	UnitMonth = "month"
+	UnitYear  = "year"
+)
+
+// Facet summarizes the values at a dotted field path, e.g. 'attributes.city', over a set of items. The
+// values of a list are summarized one by one, and those of another kind than the facet expects are ignored.
+type Facet struct {
+	Type     string  `json:"type"`               // Type of the facet
+	Field    string  `json:"field"`              // Dotted path of the summarized field
+	Size     int     `json:"size,omitempty"`     // Maximum number of buckets, the most frequent ones for terms and the first ones for histograms
+	Interval float64 `json:"interval,omitempty"` // Width of the buckets of a histogram
+	Unit     string  `json:"unit,omitempty"`     // Calendar unit of the buckets of a date histogram
+}
+
+// Bucket counts the items holding a value, or a value within the bucket range for histograms.
+type Bucket struct {
+	Key   any `json:"key"`   // Value, or lower bound of the bucket range
+	Count int `json:"count"` // Number of values in the bucket
+}
+
+// Stats summarizes numbers, the minimum, maximum, average and sum being nil without any.
+type Stats struct {
+	Count int      `json:"count"`
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+	Avg   *float64 `json:"avg"`
+	Sum   *float64 `json:"sum"`
+}
+
+// FacetResult holds the buckets of a terms or histogram facet, or the stats of a stats facet.
+type FacetResult struct {
+	Buckets []Bucket `json:"buckets,omitempty"` // Buckets, most frequent first for terms and ordered by key for histograms, omitted when empty
+	Stats   *Stats   `json:"stats,omitempty"`   // Statistics of the numbers
+}
+
+// Aggregation reports the number of items of a set along with the results of its facets, keyed by name.
+type Aggregation struct {
+	Total  int                    `json:"total"`
+	Facets map[string]FacetResult `json:"facets"`
+}
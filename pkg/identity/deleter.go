@@ -0,0 +1,40 @@
+package identity
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// deleter implements the identity key deletion service.
+type deleter struct {
+	repo    Repository
+	indexer Indexer
+}
+
+// NewDeleter creates the identity key deletion service. Indexer is nil when the entity storage cannot
+// enforce unique fields, there is then no index to drop.
+func NewDeleter(repo Repository, indexer Indexer) *deleter {
+	return &deleter{repo: repo, indexer: indexer}
+}
+
+// Delete removes an identity key along with its unique index.
+func (s *deleter) Delete(ctx context.Context, accountID, entityType, field string) error {
+	if accountID == "" {
+		return ErrAccountIDMissing
+	}
+	if entityType == "" {
+		return ErrEntityTypeMissing
+	}
+
+	k, err := byField(ctx, s.repo, accountID, entityType, field)
+	if err != nil {
+		return err
+	}
+	if s.indexer != nil {
+		if err = s.indexer.DropIndex(ctx, accountID, k.IndexName()); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return errors.WithStack(s.repo.Delete(ctx, accountID, k.ID))
+}
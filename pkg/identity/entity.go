@@ -0,0 +1,99 @@
+package identity
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Prefixes of the entity fields that may serve as identity keys.
+const (
+	attributesPrefix = "attributes."
+	metadataPrefix   = "metadata."
+)
+
+// Key declares a field identifying the entities of a type within an account, e.g. 'attributes.email' for
+// contacts. Each key is backed by a unique index, so no two entities of the type hold the same value.
+type Key struct {
+	ID         string `json:"id"`                           // Unique identifier for the key
+	AccountID  string `json:"accountId" bson:"accountId"`   // ID of the associated account
+	EntityType string `json:"entityType" bson:"entityType"` // Type of the identified entities, e.g. 'Contact'
+	Field      string `json:"field" bson:"field"`           // Identifying field, e.g. 'attributes.email' or 'metadata.externalId'
+
+	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"` // Timestamp of key creation
+	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"` // Timestamp of last key update
+}
+
+// GetID returns the key's unique identifier.
+func (k *Key) GetID() string {
+	return k.ID
+}
+
+// SetID sets the key's unique identifier.
+func (k *Key) SetID(id string) {
+	k.ID = id
+}
+
+// GetCreatedAt returns the timestamp of when the key was created.
+func (k *Key) GetCreatedAt() *time.Time {
+	return k.CreatedAt
+}
+
+// SetCreatedAt sets the timestamp of when the key was created.
+func (k *Key) SetCreatedAt(t time.Time) {
+	k.CreatedAt = &t
+}
+
+// GetUpdatedAt returns the timestamp of the last update to the key.
+func (k *Key) GetUpdatedAt() *time.Time {
+	return k.UpdatedAt
+}
+
+// SetUpdatedAt sets the timestamp of the last update to the key.
+func (k *Key) SetUpdatedAt(t time.Time) {
+	k.UpdatedAt = &t
+}
+
+// IndexName returns the name of the unique index backing the key.
+func (k *Key) IndexName() string {
+	return "identity_" + k.AccountID + "_" + k.EntityType + "_" + k.Field
+}
+
+// ValidField reports whether field names a single attribute or metadata entry, e.g. 'attributes.phone'.
+func ValidField(field string) bool {
+	var name string
+	switch {
+	case strings.HasPrefix(field, attributesPrefix):
+		name = strings.TrimPrefix(field, attributesPrefix)
+	case strings.HasPrefix(field, metadataPrefix):
+		name = strings.TrimPrefix(field, metadataPrefix)
+	default:
+		return false
+	}
+	return name != "" && !strings.ContainsAny(name, ".$")
+}
+
+// Indexer maintains the unique indexes of the entities backing the identity keys.
+type Indexer interface {
+	EnsureUniqueIndex(ctx context.Context, accountId, name, field string, filter map[string]any) error
+	DropIndex(ctx context.Context, accountId, name string) error
+}
+
+type Saver interface {
+	Create(ctx context.Context, accountId string, key *Key) (*Key, error)
+}
+
+type Deleter interface {
+	Delete(ctx context.Context, accountId, entityType, field string) error
+}
+
+type Getter interface {
+	GetAll(ctx context.Context, accountId, entityType string, currentPage, perPage int) ([]*Key, int, error)
+	Types(ctx context.Context, accountId, field string) ([]string, error)
+}
+
+type Repository interface {
+	Upsert(ctx context.Context, accountId string, key *Key) (*Key, error)
+	Delete(ctx context.Context, accountId, id string) error
+	ExecuteQuery(ctx context.Context, accountId string, query map[string]any, page, limit int) ([]*Key, int, error)
+}
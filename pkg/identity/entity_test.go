@@ -0,0 +1,27 @@
+package identity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidField(t *testing.T) {
+	tests := []struct {
+		it    string
+		field string
+		want  bool
+	}{
+		{it: "accepts an attribute", field: "attributes.email", want: true},
+		{it: "accepts a metadata entry", field: "metadata.externalId", want: true},
+		{it: "rejects a nested attribute", field: "attributes.address.zip"},
+		{it: "rejects an operator", field: "attributes.$email"},
+		{it: "rejects a bare prefix", field: "attributes."},
+		{it: "rejects other fields", field: "type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			require.Equal(t, tt.want, ValidField(tt.field))
+		})
+	}
+}
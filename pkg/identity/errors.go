@@ -0,0 +1,15 @@
+package identity
+
+import "github.com/dportaluppi/customer-profiles-api/pkg"
+
+var (
+	ErrAccountIDMissing            = pkg.NewErrID("missing account id")
+	ErrInvalid                     = pkg.NewErrInvalid("invalid identity key data")
+	ErrEntityTypeMissing           = pkg.NewErrInvalid("missing identity key entity type")
+	ErrInvalidField                = pkg.NewErrInvalid("identity key field must be a single attribute or metadata entry")
+	ErrAlreadyExists               = pkg.NewErrConflict("the identity key already exists")
+	ErrDuplicateValues             = pkg.NewErrConflict("entities of the type already share values of the identity key field")
+	ErrNotFound                    = pkg.NewErrNotFound("identity key not found")
+	ErrInvalidPaginationParameters = pkg.NewErrInvalid("invalid identity key pagination parameters")
+	ErrNotSupported                = pkg.NewErrNotImplemented("the entity storage does not support unique indexes")
+)
@@ -0,0 +1,82 @@
+package identity
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// keysPageSize is the page size used when loading every key of a field.
+const keysPageSize = 100
+
+// getter implements the identity key retrieval service.
+type getter struct {
+	repo Repository
+}
+
+func NewGetter(repo Repository) Getter {
+	return &getter{repo: repo}
+}
+
+// GetAll lists the identity keys of an account, restricted to an entity type unless empty.
+func (s *getter) GetAll(ctx context.Context, accountID, entityType string, currentPage, perPage int) ([]*Key, int, error) {
+	if accountID == "" {
+		return nil, 0, ErrAccountIDMissing
+	}
+	if currentPage < 1 || perPage < 1 {
+		return nil, 0, ErrInvalidPaginationParameters
+	}
+
+	query := map[string]any{}
+	if entityType != "" {
+		query["entityType"] = entityType
+	}
+	keys, count, err := s.repo.ExecuteQuery(ctx, accountID, query, currentPage, perPage)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return keys, count, nil
+}
+
+// Types returns the entity types identified by the given field.
+func (s *getter) Types(ctx context.Context, accountID, field string) ([]string, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	keys, err := all(ctx, s.repo, accountID, map[string]any{"field": field})
+	if err != nil {
+		return nil, err
+	}
+	types := make([]string, 0, len(keys))
+	for _, k := range keys {
+		types = append(types, k.EntityType)
+	}
+	return types, nil
+}
+
+// all loads every identity key of the account matching query.
+func all(ctx context.Context, repo Repository, accountID string, query map[string]any) ([]*Key, error) {
+	var found []*Key
+	for page := 1; ; page++ {
+		keys, total, err := repo.ExecuteQuery(ctx, accountID, query, page, keysPageSize)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		found = append(found, keys...)
+		if len(keys) == 0 || page*keysPageSize >= total {
+			return found, nil
+		}
+	}
+}
+
+// byField returns the identity key of an entity type on the given field.
+func byField(ctx context.Context, repo Repository, accountID, entityType, field string) (*Key, error) {
+	keys, _, err := repo.ExecuteQuery(ctx, accountID, map[string]any{"entityType": entityType, "field": field}, 1, 1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(keys) == 0 {
+		return nil, ErrNotFound
+	}
+	return keys[0], nil
+}
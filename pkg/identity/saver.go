@@ -0,0 +1,67 @@
+package identity
+
+import (
+	"context"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	errstack "github.com/pkg/errors"
+)
+
+// saver implements the identity key saver service.
+type saver struct {
+	repo    Repository
+	indexer Indexer
+}
+
+// NewSaver creates the identity key saver service. Indexer is nil when the entity storage cannot enforce
+// unique fields, creating keys then fails with ErrNotSupported.
+func NewSaver(repo Repository, indexer Indexer) *saver {
+	return &saver{repo: repo, indexer: indexer}
+}
+
+// Create declares an identity key and builds its unique index. Soft deleted entities are indexed too, so
+// they keep their identity until purged. Creation fails when stored entities already share a value.
+func (s *saver) Create(ctx context.Context, accountID string, key *Key) (*Key, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if key == nil {
+		return nil, ErrInvalid
+	}
+	if key.EntityType == "" {
+		return nil, ErrEntityTypeMissing
+	}
+	if !ValidField(key.Field) {
+		return nil, ErrInvalidField
+	}
+	if s.indexer == nil {
+		return nil, ErrNotSupported
+	}
+
+	_, err := byField(ctx, s.repo, accountID, key.EntityType, key.Field)
+	if err == nil {
+		return nil, ErrAlreadyExists
+	}
+	if !errstack.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	key.ID = ""
+	key.AccountID = accountID
+	key.CreatedAt = nil
+	key.UpdatedAt = nil
+	filter := map[string]any{"type": key.EntityType}
+	if err = s.indexer.EnsureUniqueIndex(ctx, accountID, key.IndexName(), key.Field, filter); err != nil {
+		var conflict pkg.ErrConflictType
+		if errstack.As(err, &conflict) {
+			return nil, ErrDuplicateValues
+		}
+		return nil, errstack.WithStack(err)
+	}
+
+	k, err := s.repo.Upsert(ctx, accountID, key)
+	if err != nil {
+		return nil, errstack.WithStack(err)
+	}
+	return k, nil
+}
@@ -20,3 +20,12 @@ func NewPagination(currentPage, perPage, totalItems int) Pagination {
 		TotalItems:  totalItems,
 	}
 }
+
+// CursorPagination describes a page of cursor pagination. TotalItems is only set when the client asks
+// for it, counting being as expensive as reading every matching item.
+type CursorPagination struct {
+	PerPage    int    `json:"perPage"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+	TotalItems *int   `json:"totalItems,omitempty"`
+}
@@ -0,0 +1,142 @@
+package profile
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"sort"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxFacets caps the facets of an aggregation, each one costing a pass over the matching entities.
+	maxFacets = 10
+	// defaultTermsSize is the number of buckets of a terms facet unless given.
+	defaultTermsSize = 10
+	// maxTermsSize caps the buckets of a terms facet.
+	maxTermsSize = 100
+	// maxHistogramBuckets caps the buckets of a histogram, and is their number unless given.
+	maxHistogramBuckets = 1000
+)
+
+// facetName restricts facet names to identifiers, which cannot collide with the outputs of the repository.
+var facetName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// aggregator implements the faceted aggregation of entities.
+type aggregator struct {
+	repo AggregateRepository
+}
+
+// NewAggregator creates the aggregation service. Repo is nil when the storage cannot aggregate, every
+// aggregation then fails with ErrNotSupported.
+func NewAggregator(repo AggregateRepository) *aggregator {
+	return &aggregator{repo: repo}
+}
+
+// Aggregate counts the entities matching query, or every entity when empty, and computes the facets over
+// them, keyed by name. Like in Query, query may only use the fields and operators search criteria compile
+// to, and facets may only summarize searchable fields.
+func (s *aggregator) Aggregate(
+	ctx context.Context,
+	accountID string,
+	query map[string]any,
+	facets map[string]pkg.Facet,
+	opts QueryOptions,
+) (*pkg.Aggregation, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if s.repo == nil {
+		return nil, ErrNotSupported
+	}
+	if err := search.Validate(query); err != nil {
+		return nil, err
+	}
+	facets, err := checkFacets(facets)
+	if err != nil {
+		return nil, err
+	}
+	if filter := optionsFilter(opts); len(filter) > 0 {
+		if len(query) == 0 {
+			query = filter
+		} else {
+			query = map[string]any{"$and": []any{query, filter}}
+		}
+	}
+
+	aggregation, err := s.repo.Aggregate(ctx, accountID, query, facets)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return aggregation, nil
+}
+
+// Types lists the types of the active entities of an account with their counts, sorted by type.
+func (s *aggregator) Types(ctx context.Context, accountId string) ([]TypeCount, error) {
+	if accountId == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if s.repo == nil {
+		return nil, ErrNotSupported
+	}
+
+	counts, err := s.repo.CountBy(ctx, accountId, typeKey, activeFilter())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	types := make([]TypeCount, 0, len(counts))
+	for t, count := range counts {
+		types = append(types, TypeCount{Type: t, Count: count})
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types, nil
+}
+
+// checkFacets validates the facets, returning them with the number of buckets set.
+func checkFacets(facets map[string]pkg.Facet) (map[string]pkg.Facet, error) {
+	if len(facets) > maxFacets {
+		return nil, ErrTooManyFacets
+	}
+	checked := make(map[string]pkg.Facet, len(facets))
+	for name, f := range facets {
+		if !facetName.MatchString(name) || !search.ValidField(f.Field) || f.Size < 0 {
+			return nil, errors.Wrap(ErrInvalidFacet, name)
+		}
+		switch f.Type {
+		case pkg.FacetTerms:
+			if f.Size == 0 {
+				f.Size = defaultTermsSize
+			}
+			if f.Size > maxTermsSize {
+				return nil, errors.Wrap(ErrInvalidFacet, name)
+			}
+		case pkg.FacetHistogram:
+			if f.Interval <= 0 || math.IsInf(f.Interval, 0) {
+				return nil, errors.Wrap(ErrInvalidFacet, name)
+			}
+		case pkg.FacetDateHistogram:
+			switch f.Unit {
+			case pkg.UnitHour, pkg.UnitDay, pkg.UnitWeek, pkg.UnitMonth, pkg.UnitYear:
+			default:
+				return nil, errors.Wrap(ErrInvalidFacet, name)
+			}
+		case pkg.FacetStats:
+		default:
+			return nil, errors.Wrap(ErrInvalidFacet, name)
+		}
+		if f.Type == pkg.FacetHistogram || f.Type == pkg.FacetDateHistogram {
+			if f.Size == 0 {
+				f.Size = maxHistogramBuckets
+			}
+			if f.Size > maxHistogramBuckets {
+				return nil, errors.Wrap(ErrInvalidFacet, name)
+			}
+		}
+		checked[name] = f
+	}
+	return checked, nil
+}
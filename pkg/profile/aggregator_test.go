@@ -0,0 +1,116 @@
+package profile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFacets(t *testing.T) {
+	t.Run("sets the number of buckets", func(t *testing.T) {
+		checked, err := checkFacets(map[string]pkg.Facet{
+			"cities": {Type: pkg.FacetTerms, Field: "attributes.city"},
+			"ages":   {Type: pkg.FacetHistogram, Field: "attributes.age", Interval: 10},
+			"daily":  {Type: pkg.FacetDateHistogram, Field: "createdAt", Unit: pkg.UnitDay, Size: 7},
+		})
+		require.NoError(t, err)
+		require.Equal(t, defaultTermsSize, checked["cities"].Size)
+		require.Equal(t, maxHistogramBuckets, checked["ages"].Size)
+		require.Equal(t, 7, checked["daily"].Size)
+	})
+
+	t.Run("caps the facets", func(t *testing.T) {
+		facets := map[string]pkg.Facet{}
+		for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"} {
+			facets[name] = pkg.Facet{Type: pkg.FacetStats, Field: "attributes.age"}
+		}
+		_, err := checkFacets(facets)
+		require.ErrorIs(t, err, ErrTooManyFacets)
+	})
+
+	tests := []struct {
+		it    string
+		name  string
+		facet pkg.Facet
+	}{
+		{it: "rejects a name that is not an identifier", name: "_total", facet: pkg.Facet{Type: pkg.FacetStats, Field: "attributes.age"}},
+		{it: "rejects a field outside the entity", name: "f", facet: pkg.Facet{Type: pkg.FacetTerms, Field: "accountId"}},
+		{it: "rejects a negative size", name: "f", facet: pkg.Facet{Type: pkg.FacetTerms, Field: "type", Size: -1}},
+		{it: "rejects too many terms", name: "f", facet: pkg.Facet{Type: pkg.FacetTerms, Field: "type", Size: maxTermsSize + 1}},
+		{it: "rejects a histogram without interval", name: "f", facet: pkg.Facet{Type: pkg.FacetHistogram, Field: "attributes.age"}},
+		{it: "rejects too many histogram buckets", name: "f", facet: pkg.Facet{Type: pkg.FacetHistogram, Field: "attributes.age", Interval: 1, Size: maxHistogramBuckets + 1}},
+		{it: "rejects an unknown calendar unit", name: "f", facet: pkg.Facet{Type: pkg.FacetDateHistogram, Field: "createdAt", Unit: "fortnight"}},
+		{it: "rejects an unknown facet type", name: "f", facet: pkg.Facet{Type: "median", Field: "attributes.age"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			_, err := checkFacets(map[string]pkg.Facet{tt.name: tt.facet})
+			require.ErrorIs(t, err, ErrInvalidFacet)
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository[*Entity]()
+	deletedAt := time.Now()
+	for _, e := range []*Entity{
+		{AccountID: "acc", Type: "Contact", Attributes: Attribute{"city": "Rosario"}},
+		{AccountID: "acc", Type: "Contact", Attributes: Attribute{"city": "Salta"}},
+		{AccountID: "acc", Type: "Store", Attributes: Attribute{"city": "Rosario"}},
+		{AccountID: "acc", Type: "Contact", Attributes: Attribute{"city": "Rosario"}, DeletedAt: &deletedAt},
+	} {
+		_, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+	}
+	a := NewAggregator(repo)
+	cities := map[string]pkg.Facet{"cities": {Type: pkg.FacetTerms, Field: "attributes.city"}}
+
+	t.Run("aggregates the active entities", func(t *testing.T) {
+		aggregation, err := a.Aggregate(ctx, "acc", nil, cities, QueryOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 3, aggregation.Total)
+		require.Equal(t, []pkg.Bucket{{Key: "Rosario", Count: 2}, {Key: "Salta", Count: 1}}, aggregation.Facets["cities"].Buckets)
+	})
+
+	t.Run("aggregates the deleted entities when included", func(t *testing.T) {
+		aggregation, err := a.Aggregate(ctx, "acc", nil, cities, QueryOptions{IncludeDeleted: true})
+		require.NoError(t, err)
+		require.Equal(t, 4, aggregation.Total)
+	})
+
+	t.Run("restricts to the type and the query", func(t *testing.T) {
+		query := map[string]any{"attributes.city": "Rosario"}
+		aggregation, err := a.Aggregate(ctx, "acc", query, cities, QueryOptions{Type: "Contact"})
+		require.NoError(t, err)
+		require.Equal(t, 1, aggregation.Total)
+	})
+
+	t.Run("rejects queries outside search criteria", func(t *testing.T) {
+		_, err := a.Aggregate(ctx, "acc", map[string]any{"$where": "true"}, cities, QueryOptions{})
+		require.ErrorIs(t, err, search.ErrInvalidOperator)
+	})
+
+	t.Run("requires the account", func(t *testing.T) {
+		_, err := a.Aggregate(ctx, "", nil, cities, QueryOptions{})
+		require.ErrorIs(t, err, ErrAccountIDMissing)
+	})
+
+	t.Run("lists the types of the active entities", func(t *testing.T) {
+		types, err := a.Types(ctx, "acc")
+		require.NoError(t, err)
+		require.Equal(t, []TypeCount{{Type: "Contact", Count: 2}, {Type: "Store", Count: 1}}, types)
+	})
+
+	t.Run("rejects aggregations when the storage cannot aggregate", func(t *testing.T) {
+		_, err := NewAggregator(nil).Aggregate(ctx, "acc", nil, cities, QueryOptions{})
+		require.ErrorIs(t, err, ErrNotSupported)
+		_, err = NewAggregator(nil).Types(ctx, "acc")
+		require.ErrorIs(t, err, ErrNotSupported)
+	})
+}
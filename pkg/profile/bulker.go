@@ -0,0 +1,205 @@
+package profile
+
+import (
+	"context"
+
+	errstack "github.com/pkg/errors"
+)
+
+const (
+	// maxBulkItems caps the number of operations accepted in a single bulk request.
+	maxBulkItems = 10000
+)
+
+// bulker implements the bulk create/update/delete service.
+type bulker struct {
+	repo      Repository
+	validator Validator
+	types     RelationshipTypes
+	onDelete  string
+}
+
+func NewBulker(repo Repository, validator Validator, types RelationshipTypes, onDelete string) *bulker {
+	return &bulker{repo: repo, validator: validator, types: types, onDelete: onDelete}
+}
+
+// Bulk validates every item with the same rules as the saver and deleter, writes the valid ones in a
+// single bulk write and reports the outcome of each item. Deletes are soft, like the deleter's.
+// Relationship cardinalities are checked against the stored entities, not between items of the request.
+// A failure once the items are written, when removing the relationships targeting the deleted entities,
+// is returned as a *BulkError holding the outcome of each item.
+func (s *bulker) Bulk(ctx context.Context, accountID string, operations BulkOperations) ([]BulkResult, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	total := len(operations.Create) + len(operations.Update) + len(operations.Delete)
+	if total == 0 {
+		return nil, ErrBulkEmpty
+	}
+	if total > maxBulkItems {
+		return nil, ErrBulkTooLarge
+	}
+
+	ids := make([]string, 0, len(operations.Update)+len(operations.Delete))
+	for _, e := range operations.Update {
+		if e != nil && e.ID != "" {
+			ids = append(ids, e.ID)
+		}
+	}
+	for _, id := range operations.Delete {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	existing, err := loadActive(ctx, s.repo, accountID, ids)
+	if err != nil {
+		return nil, err
+	}
+	writes := append(append([]*Entity{}, operations.Create...), operations.Update...)
+	targets, err := loadActive(ctx, s.repo, accountID, relationshipTargets(writes...))
+	if err != nil {
+		return nil, err
+	}
+
+	validate := s.validate(ctx, accountID)
+	results := make([]BulkResult, 0, total)
+	var upserts []*Entity
+	// pending maps each operation sent to the repository to its position in results.
+	var pending []int
+
+	for i, e := range operations.Create {
+		result := BulkResult{Operation: "create", Index: i, Status: BulkStatusCreated}
+		if err := prepareCreate(accountID, e); err != nil {
+			results = append(results, failed(result, err))
+			continue
+		}
+		if err := validate(e); err != nil {
+			results = append(results, failed(result, err))
+			continue
+		}
+		if err := verifyTargets(e, targets); err != nil {
+			results = append(results, failed(result, err))
+			continue
+		}
+		if err := checkRules(ctx, s.repo, s.types, accountID, e, nil, targets); err != nil {
+			results = append(results, failed(result, err))
+			continue
+		}
+		stampRelationships(e, nil)
+		upserts = append(upserts, e)
+		pending = append(pending, len(results))
+		results = append(results, result)
+	}
+
+	for i, e := range operations.Update {
+		result := BulkResult{Operation: "update", Index: i, Status: BulkStatusUpdated}
+		if e == nil {
+			results = append(results, failed(result, ErrInvalid))
+			continue
+		}
+		result.ID = e.ID
+		if e.ID == "" {
+			results = append(results, failed(result, ErrIDMissing))
+			continue
+		}
+		old, ok := existing[e.ID]
+		if !ok {
+			results = append(results, failed(result, ErrNotFound))
+			continue
+		}
+		if err := prepareUpdate(accountID, old, e); err != nil {
+			results = append(results, failed(result, err))
+			continue
+		}
+		if err := validate(e); err != nil {
+			results = append(results, failed(result, err))
+			continue
+		}
+		if err := verifyTargets(e, targets); err != nil {
+			results = append(results, failed(result, err))
+			continue
+		}
+		if err := checkRules(ctx, s.repo, s.types, accountID, e, old.Relationships, targets); err != nil {
+			results = append(results, failed(result, err))
+			continue
+		}
+		stampRelationships(e, old.Relationships)
+		upserts = append(upserts, e)
+		pending = append(pending, len(results))
+		results = append(results, result)
+	}
+
+	for i, id := range operations.Delete {
+		result := BulkResult{Operation: "delete", Index: i, ID: id, Status: BulkStatusDeleted}
+		if id == "" {
+			results = append(results, failed(result, ErrIDMissing))
+			continue
+		}
+		old, ok := existing[id]
+		if !ok {
+			results = append(results, failed(result, ErrNotFound))
+			continue
+		}
+		if err := checkDelete(ctx, s.repo, accountID, id, s.onDelete); err != nil {
+			results = append(results, failed(result, err))
+			continue
+		}
+		tombstone(ctx, old)
+		upserts = append(upserts, old)
+		pending = append(pending, len(results))
+		results = append(results, result)
+	}
+
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	errs, err := s.repo.BulkWrite(ctx, accountID, upserts, nil)
+	if err != nil {
+		return nil, errstack.WithStack(err)
+	}
+	for i, pos := range pending {
+		results[pos].ID = upserts[i].ID
+		if errs[i] != nil {
+			results[pos] = failed(results[pos], errs[i])
+		}
+	}
+	for i, pos := range pending {
+		if errs[i] != nil || results[pos].Operation != "delete" {
+			continue
+		}
+		if err := cascadeDelete(ctx, s.repo, accountID, upserts[i].ID, s.onDelete); err != nil {
+			return results, &BulkError{Results: results, Err: err}
+		}
+	}
+	return results, nil
+}
+
+// validate returns a function validating the attributes of entities of the account, loading the rules of
+// each entity type once when the validator allows it.
+func (s *bulker) validate(ctx context.Context, accountID string) func(e *Entity) error {
+	tv, ok := s.validator.(TypeValidator)
+	if !ok {
+		return func(e *Entity) error {
+			return s.validator.Validate(ctx, accountID, e.Type, e.Attributes)
+		}
+	}
+	checks := map[string]func(map[string]any) error{}
+	return func(e *Entity) error {
+		check, ok := checks[e.Type]
+		if !ok {
+			var err error
+			if check, err = tv.ForType(ctx, accountID, e.Type); err != nil {
+				return err
+			}
+			checks[e.Type] = check
+		}
+		return check(e.Attributes)
+	}
+}
+
+func failed(result BulkResult, err error) BulkResult {
+	result.Status = BulkStatusFailed
+	result.Error = err.Error()
+	return result
+}
@@ -0,0 +1,122 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/pkg/relationship"
+	"github.com/stretchr/testify/require"
+)
+
+// typeValidator is a TypeValidator requiring a name, counting the loads of the rules of each type.
+type typeValidator struct {
+	loads map[string]int
+}
+
+func (v *typeValidator) Validate(ctx context.Context, accountID, entityType string, attributes map[string]any) error {
+	check, err := v.ForType(ctx, accountID, entityType)
+	if err != nil {
+		return err
+	}
+	return check(attributes)
+}
+
+func (v *typeValidator) ForType(_ context.Context, _, entityType string) (func(attributes map[string]any) error, error) {
+	v.loads[entityType]++
+	return func(attributes map[string]any) error {
+		if _, ok := attributes["name"]; !ok {
+			return ErrInvalid
+		}
+		return nil
+	}, nil
+}
+
+// failingUpserts is a Repository whose single upserts fail.
+type failingUpserts struct {
+	Repository
+	err error
+}
+
+func (r *failingUpserts) Upsert(context.Context, string, *Entity) (*Entity, error) {
+	return nil, r.err
+}
+
+func TestBulk(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository[*Entity]()
+	upsert := func(e *Entity) *Entity {
+		e.AccountID = "acc"
+		e, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+		return e
+	}
+	store := upsert(&Entity{Type: "Store", Attributes: Attribute{"name": "Shop"}})
+	contact := upsert(&Entity{Type: "Contact", Attributes: Attribute{"name": "Ana"}})
+	referenced := upsert(&Entity{Type: "Contact", Attributes: Attribute{"name": "Bob"}})
+	upsert(&Entity{Type: "Contact", Attributes: Attribute{"name": "Eve"}, Relationships: []Relationship{{Type: "knows", TargetID: referenced.ID}}})
+
+	types := relationship.NewGetter(repository.NewMemoryRepository[*relationship.Definition]())
+	validator := &typeValidator{loads: map[string]int{}}
+	results, err := NewBulker(repo, validator, types, OnDeleteRestrict).Bulk(ctx, "acc", BulkOperations{
+		Create: []*Entity{
+			{ID: "chosen", Type: "Contact", Attributes: Attribute{"name": "Zoe"}},
+			{Type: "Contact", Attributes: Attribute{"email": "nameless@example.com"}},
+			{Type: "Contact", Attributes: Attribute{"name": "Max"}, Relationships: []Relationship{{Type: "buysFrom", TargetID: "missing"}}},
+			{Type: "Store", Attributes: Attribute{"name": "Other shop"}},
+		},
+		Update: []*Entity{
+			{ID: contact.ID, Type: "Contact", Attributes: Attribute{"name": "Ana Doe"}, Relationships: []Relationship{{Type: "buysFrom", TargetID: store.ID}}},
+			{ID: "missing", Type: "Contact", Attributes: Attribute{"name": "Nobody"}},
+			{ID: store.ID, Type: "Store", Attributes: Attribute{"name": "Shop"}, Version: store.Version + 1},
+		},
+		Delete: []string{referenced.ID, store.ID, ""},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"Contact": 1, "Store": 1}, validator.loads, "rules are loaded once per type")
+
+	statuses := make([]string, 0, len(results))
+	for _, r := range results {
+		statuses = append(statuses, r.Operation+" "+r.Status)
+	}
+	require.Equal(t, []string{
+		"create created", "create failed", "create failed", "create created",
+		"update updated", "update failed", "update failed",
+		"delete failed", "delete deleted", "delete failed",
+	}, statuses)
+	require.NotEqual(t, "chosen", results[0].ID, "the ID of a created entity is left to the repository")
+	require.Equal(t, ErrReferenced.Error(), results[7].Error)
+
+	created, err := repo.GetByID(ctx, "acc", results[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, "Zoe", created.Attributes["name"])
+	deleted, err := repo.GetByID(ctx, "acc", store.ID)
+	require.NoError(t, err)
+	require.NotNil(t, deleted.DeletedAt)
+}
+
+func TestBulkCascadeFailure(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository[*Entity]()
+	upsert := func(e *Entity) *Entity {
+		e.AccountID = "acc"
+		e, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+		return e
+	}
+	store := upsert(&Entity{Type: "Store"})
+	upsert(&Entity{Type: "Contact", Relationships: []Relationship{{Type: "buysFrom", TargetID: store.ID}}})
+
+	errDown := errors.New("database down")
+	validator := validatorFunc(func(context.Context, string, string, map[string]any) error { return nil })
+	types := relationship.NewGetter(repository.NewMemoryRepository[*relationship.Definition]())
+	results, err := NewBulker(&failingUpserts{Repository: repo, err: errDown}, validator, types, OnDeleteCascade).
+		Bulk(ctx, "acc", BulkOperations{Delete: []string{store.ID}})
+	require.ErrorIs(t, err, errDown)
+
+	var bulkErr *BulkError
+	require.ErrorAs(t, err, &bulkErr)
+	require.Equal(t, results, bulkErr.Results)
+	require.Equal(t, []BulkResult{{Operation: "delete", ID: store.ID, Status: BulkStatusDeleted}}, results)
+}
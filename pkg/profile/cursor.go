@@ -0,0 +1,138 @@
+package profile
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
+	"github.com/pkg/errors"
+)
+
+// cursor is the position a page of cursor pagination starts from, encoded as an opaque token so that
+// clients do not depend on its content.
+type cursor struct {
+	After  string `json:"a,omitempty"` // The page follows the entity with this ID
+	Before string `json:"b,omitempty"` // The page precedes the entity with this ID
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a token issued by encodeCursor, an empty one standing for the first page.
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	if token == "" {
+		return c, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err = json.Unmarshal(data, &c); err != nil || (c.After == "") == (c.Before == "") {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Seek returns the page of the entities matching query, or of every entity when nil, starting at the cursor
+// token, the first page when empty. Pages are ordered by opts, or by ID, and read by key range from the
+// entity the cursor points at rather than skipping the preceding entities, so deep pages cost the same as
+// the first one. Counting the matching entities is optional since it costs a scan of all of them. Like
+// in Query, query may only use the fields and operators search criteria compile to.
+func (s *getter) Seek(
+	ctx context.Context,
+	accountId string,
+	query map[string]any,
+	token string,
+	limit int,
+	count bool,
+	opts QueryOptions,
+) (*CursorPage, error) {
+	if err := search.Validate(query); err != nil {
+		return nil, err
+	}
+	if filter := optionsFilter(opts); len(filter) > 0 {
+		if len(query) == 0 {
+			query = filter
+		} else {
+			query = map[string]any{"$and": []any{query, filter}}
+		}
+	}
+	return s.seek(ctx, accountId, query, token, limit, count, opts)
+}
+
+// SeekPipeline is Seek for the entities matching an aggregation expression.
+func (s *getter) SeekPipeline(
+	ctx context.Context,
+	accountId string,
+	pipeline map[string]any,
+	token string,
+	limit int,
+	count bool,
+	opts QueryOptions,
+) (*CursorPage, error) {
+	if expr := optionsExpr(opts); len(expr) > 0 {
+		pipeline = map[string]any{"$and": append([]any{pipeline}, expr...)}
+	}
+	return s.seek(ctx, accountId, map[string]any{"$expr": pipeline}, token, limit, count, opts)
+}
+
+func (s *getter) seek(
+	ctx context.Context,
+	accountId string,
+	query map[string]any,
+	token string,
+	limit int,
+	count bool,
+	opts QueryOptions,
+) (*CursorPage, error) {
+	if accountId == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if limit < 1 {
+		return nil, ErrInvalidPaginationParameters
+	}
+	opts, err := checkSelection(opts)
+	if err != nil {
+		return nil, err
+	}
+	c, err := decodeCursor(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// One more entity than the page holds tells whether there is a page beyond it.
+	entities, err := s.repo.Seek(ctx, accountId, query, opts.Sort, opts.Fields, c.After, c.Before, limit+1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	more := len(entities) > limit
+	if more && c.Before != "" {
+		entities = entities[1:]
+	} else if more {
+		entities = entities[:limit]
+	}
+
+	page := &CursorPage{Entities: entities}
+	if len(entities) > 0 {
+		first, last := entities[0].ID, entities[len(entities)-1].ID
+		// A page read forward from a cursor has a preceding page, one read backward has a following page.
+		if more || c.Before != "" {
+			page.NextCursor = encodeCursor(cursor{After: last})
+		}
+		if (more && c.Before != "") || c.After != "" {
+			page.PrevCursor = encodeCursor(cursor{Before: first})
+		}
+	}
+	if count {
+		total, err := s.repo.Count(ctx, accountId, query)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		page.TotalItems = &total
+	}
+	return page, nil
+}
@@ -0,0 +1,85 @@
+package profile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		it    string
+		token string
+		want  cursor
+		err   error
+	}{
+		{it: "starts at the first page without token", token: ""},
+		{it: "decodes a cursor after an entity", token: encodeCursor(cursor{After: "a"}), want: cursor{After: "a"}},
+		{it: "decodes a cursor before an entity", token: encodeCursor(cursor{Before: "b"}), want: cursor{Before: "b"}},
+		{it: "rejects a token that is not base64", token: "!!", err: ErrInvalidCursor},
+		{it: "rejects a token that is not a cursor", token: "bm90IGpzb24", err: ErrInvalidCursor},
+		{it: "rejects a cursor pointing both ways", token: encodeCursor(cursor{After: "a", Before: "b"}), err: ErrInvalidCursor},
+		{it: "rejects an empty cursor", token: encodeCursor(cursor{}), err: ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			got, err := decodeCursor(tt.token)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSeek(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository[*Entity]()
+	var byRank []string
+	for _, rank := range []int{3, 1, 4, 0, 2} {
+		e, err := repo.Upsert(ctx, "acc", &Entity{AccountID: "acc", Type: "Contact", Attributes: Attribute{"rank": rank}})
+		require.NoError(t, err)
+		byRank = append(byRank, e.ID)
+	}
+	byRank = []string{byRank[3], byRank[1], byRank[4], byRank[0], byRank[2]}
+	g := NewGetter(repo)
+	opts := QueryOptions{Sort: []pkg.Sort{{Field: "attributes.rank"}}}
+	ids := func(page *CursorPage) []string {
+		var found []string
+		for _, e := range page.Entities {
+			found = append(found, e.ID)
+		}
+		return found
+	}
+
+	first, err := g.Seek(ctx, "acc", nil, "", 2, true, opts)
+	require.NoError(t, err)
+	require.Equal(t, byRank[:2], ids(first))
+	require.Empty(t, first.PrevCursor)
+	require.Equal(t, 5, *first.TotalItems)
+
+	second, err := g.Seek(ctx, "acc", nil, first.NextCursor, 2, false, opts)
+	require.NoError(t, err)
+	require.Equal(t, byRank[2:4], ids(second))
+	require.Nil(t, second.TotalItems)
+
+	last, err := g.Seek(ctx, "acc", nil, second.NextCursor, 2, false, opts)
+	require.NoError(t, err)
+	require.Equal(t, byRank[4:], ids(last))
+	require.Empty(t, last.NextCursor)
+
+	back, err := g.Seek(ctx, "acc", nil, last.PrevCursor, 2, false, opts)
+	require.NoError(t, err)
+	require.Equal(t, byRank[2:4], ids(back))
+	require.Equal(t, second.NextCursor, back.NextCursor)
+
+	_, err = g.Seek(ctx, "acc", map[string]any{"$where": "true"}, "", 2, false, opts)
+	require.Error(t, err, "the query is validated like in Query")
+	_, err = g.Seek(ctx, "acc", nil, "", 0, false, opts)
+	require.ErrorIs(t, err, ErrInvalidPaginationParameters)
+}
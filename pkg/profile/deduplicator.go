@@ -0,0 +1,305 @@
+package profile
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// scanPageSize is the page size used when loading the entities of a type to compare them.
+	scanPageSize = 500
+	// maxBlockSize caps the entities compared pairwise under a single blocking key, so that a very common
+	// value, e.g. a relationship to a large store, does not make the comparisons quadratic.
+	maxBlockSize = 200
+	// maxDuplicates caps the duplicates reported for an entity.
+	maxDuplicates = 50
+	// maxSuggestions caps the merge suggestions produced by a scan.
+	maxSuggestions = 1000
+)
+
+// deduplicator implements the detection of likely duplicate entities.
+type deduplicator struct {
+	repo   Repository
+	counts AggregateRepository
+	rules  MatchRulesRepository
+	scans  DuplicateScanRepository
+}
+
+// NewDeduplicator creates the duplicate detection service, listing the types of an account through
+// counts. Counts is nil when the storage cannot aggregate, scans of every type then fail with
+// ErrNotSupported.
+func NewDeduplicator(repo Repository, counts AggregateRepository, rules MatchRulesRepository, scans DuplicateScanRepository) *deduplicator {
+	return &deduplicator{repo: repo, counts: counts, rules: rules, scans: scans}
+}
+
+// Duplicates returns the active entities of the same type likely to be the same as the given one, best
+// first. Only the entities sharing a blocking key with it, e.g. a normalized email, are scored.
+func (s *deduplicator) Duplicates(ctx context.Context, accountID, id string) ([]Duplicate, error) {
+	if id == "" {
+		return nil, ErrIDMissing
+	}
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+
+	e, err := getActive(ctx, s.repo, accountID, id)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.MatchRules(ctx, accountID, e.Type)
+	if err != nil {
+		return nil, err
+	}
+	entities, err := ofType(ctx, s.repo, accountID, e.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for _, k := range blockingKeys(rules.Rules, e) {
+		keys[k] = true
+	}
+	duplicates := []Duplicate{}
+	for _, candidate := range entities {
+		if candidate.ID == e.ID || !sharesKey(keys, blockingKeys(rules.Rules, candidate)) {
+			continue
+		}
+		if p, reasons := score(rules.Rules, e, candidate); p >= rules.Threshold {
+			duplicates = append(duplicates, Duplicate{Entity: candidate, Score: p, Reasons: reasons})
+		}
+	}
+
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].Score > duplicates[j].Score
+	})
+	return duplicates[:min(len(duplicates), maxDuplicates)], nil
+}
+
+// MatchRules returns the rules of an entity type, the default ones unless configured.
+func (s *deduplicator) MatchRules(ctx context.Context, accountID, entityType string) (*MatchRules, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	found, _, err := s.rules.ExecuteQuery(ctx, accountID, map[string]any{"entityType": entityType}, 1, 1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(found) > 0 {
+		return found[0], nil
+	}
+	rules := DefaultMatchRules(entityType)
+	rules.AccountID = accountID
+	return rules, nil
+}
+
+// SetMatchRules replaces the rules of an entity type.
+func (s *deduplicator) SetMatchRules(ctx context.Context, accountID, entityType string, rules *MatchRules) (*MatchRules, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if entityType == "" {
+		return nil, ErrInvalidMatchRules
+	}
+	if err := validateMatchRules(rules); err != nil {
+		return nil, err
+	}
+
+	current, err := s.MatchRules(ctx, accountID, entityType)
+	if err != nil {
+		return nil, err
+	}
+	rules.ID = current.ID
+	rules.AccountID = accountID
+	rules.EntityType = entityType
+	rules.CreatedAt = current.CreatedAt
+	rules.UpdatedAt = nil
+	saved, err := s.rules.Upsert(ctx, accountID, rules)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return saved, nil
+}
+
+// StartScan starts looking for the duplicate entities of a type, or of every type when empty, in the
+// background and returns the running scan.
+func (s *deduplicator) StartScan(ctx context.Context, accountID, entityType string) (*DuplicateScan, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+
+	scan, err := s.scans.Upsert(ctx, accountID, &DuplicateScan{
+		AccountID:   accountID,
+		EntityType:  entityType,
+		Status:      ScanRunning,
+		Suggestions: []MergeSuggestion{},
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	started := *scan
+	go s.run(context.WithoutCancel(ctx), scan)
+	return &started, nil
+}
+
+// Scan returns a scan of the account.
+func (s *deduplicator) Scan(ctx context.Context, accountID, id string) (*DuplicateScan, error) {
+	if id == "" {
+		return nil, ErrIDMissing
+	}
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	scan, err := s.scans.GetByID(ctx, accountID, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if scan.AccountID != accountID {
+		return nil, ErrScanNotFound
+	}
+	return scan, nil
+}
+
+// run completes a scan, recording its suggestions or the reason of its failure.
+func (s *deduplicator) run(ctx context.Context, scan *DuplicateScan) {
+	suggestions, err := s.suggest(ctx, scan.AccountID, scan.EntityType)
+	now := time.Now()
+	scan.CompletedAt = &now
+	if err != nil {
+		scan.Status = ScanFailed
+		scan.Error = err.Error()
+	} else {
+		scan.Status = ScanCompleted
+		scan.Suggestions = suggestions
+	}
+	if _, err = s.scans.Upsert(ctx, scan.AccountID, scan); err != nil {
+		log.Printf("saving duplicate scan %s: %+v", scan.ID, err)
+	}
+}
+
+// suggest compares the entities of a type, or of every type when empty, sharing a blocking key and
+// suggests merging the pairs scoring above the threshold, best first.
+func (s *deduplicator) suggest(ctx context.Context, accountID, entityType string) ([]MergeSuggestion, error) {
+	types := []string{entityType}
+	if entityType == "" {
+		if s.counts == nil {
+			return nil, ErrNotSupported
+		}
+		counts, err := s.counts.CountBy(ctx, accountID, typeKey, activeFilter())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		types = types[:0]
+		for t := range counts {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+	}
+
+	suggestions := []MergeSuggestion{}
+	for _, t := range types {
+		rules, err := s.MatchRules(ctx, accountID, t)
+		if err != nil {
+			return nil, err
+		}
+		entities, err := ofType(ctx, s.repo, accountID, t)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks := map[string][]int{}
+		for i, e := range entities {
+			for _, k := range blockingKeys(rules.Rules, e) {
+				blocks[k] = append(blocks[k], i)
+			}
+		}
+		compared := map[[2]int]bool{}
+		for _, block := range blocks {
+			if len(block) > maxBlockSize {
+				continue
+			}
+			for x := 0; x < len(block); x++ {
+				for y := x + 1; y < len(block); y++ {
+					pair := [2]int{block[x], block[y]}
+					if block[x] == block[y] || compared[pair] {
+						continue
+					}
+					compared[pair] = true
+
+					a, b := entities[pair[0]], entities[pair[1]]
+					p, reasons := score(rules.Rules, a, b)
+					if p < rules.Threshold {
+						continue
+					}
+					if older(b, a) {
+						a, b = b, a
+					}
+					suggestions = append(suggestions, MergeSuggestion{
+						TargetID:   a.ID,
+						SourceID:   b.ID,
+						EntityType: t,
+						Score:      p,
+						Reasons:    reasons,
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		if suggestions[i].TargetID != suggestions[j].TargetID {
+			return suggestions[i].TargetID < suggestions[j].TargetID
+		}
+		return suggestions[i].SourceID < suggestions[j].SourceID
+	})
+	return suggestions[:min(len(suggestions), maxSuggestions)], nil
+}
+
+// ofType loads every active entity of a type of the account.
+func ofType(ctx context.Context, repo Repository, accountID, entityType string) ([]*Entity, error) {
+	query := map[string]any{typeKey: entityType, deletedAtKey: nil}
+
+	var found []*Entity
+	for page := 1; ; page++ {
+		entities, total, err := repo.ExecuteQuery(ctx, accountID, query, page, scanPageSize)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		found = append(found, entities...)
+		if len(entities) == 0 || page*scanPageSize >= total {
+			return found, nil
+		}
+	}
+}
+
+// sharesKey reports whether one of keys is in set.
+func sharesKey(set map[string]bool, keys []string) bool {
+	for _, k := range keys {
+		if set[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// older reports whether a was created before b, comparing IDs when the creation times are equal.
+func older(a, b *Entity) bool {
+	var at, bt time.Time
+	if a.CreatedAt != nil {
+		at = *a.CreatedAt
+	}
+	if b.CreatedAt != nil {
+		bt = *b.CreatedAt
+	}
+	if !at.Equal(bt) {
+		return at.Before(bt)
+	}
+	return a.ID < b.ID
+}
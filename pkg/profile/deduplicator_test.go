@@ -0,0 +1,127 @@
+package profile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScore(t *testing.T) {
+	rules := DefaultMatchRules("Contact").Rules
+	contact := func(attributes Attribute, targets ...string) *Entity {
+		e := &Entity{Type: "Contact", Attributes: attributes}
+		for _, id := range targets {
+			e.Relationships = append(e.Relationships, Relationship{Type: "buysFrom", TargetID: id})
+		}
+		return e
+	}
+
+	tests := []struct {
+		it      string
+		a, b    *Entity
+		want    float64
+		reasons []string
+	}{
+		{
+			it:      "folds the case of emails",
+			a:       contact(Attribute{"email": "Jane@Example.com "}),
+			b:       contact(Attribute{"email": "jane@example.com"}),
+			want:    0.9,
+			reasons: []string{"attributes.email"},
+		},
+		{
+			it:      "matches phones missing their country code",
+			a:       contact(Attribute{"phone": "+54 (11) 4555-1234"}),
+			b:       contact(Attribute{"phone": "11 4555 1234"}),
+			want:    0.8,
+			reasons: []string{"attributes.phone"},
+		},
+		{
+			it:      "combines a similar name and shared relationships",
+			a:       contact(Attribute{"name": "Jon Smith"}, "s1"),
+			b:       contact(Attribute{"name": "John Smith"}, "s1"),
+			want:    0.641,
+			reasons: []string{"attributes.name", "relationships"},
+		},
+		{
+			it: "ignores different names",
+			a:  contact(Attribute{"name": "Jane Doe"}),
+			b:  contact(Attribute{"name": "Mark Twain"}),
+		},
+		{
+			it: "ignores short phones",
+			a:  contact(Attribute{"phone": "1234"}),
+			b:  contact(Attribute{"phone": "991234"}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			got, reasons := score(rules, tt.a, tt.b)
+			require.InDelta(t, tt.want, got, 0.001)
+			require.Equal(t, tt.reasons, reasons)
+		})
+	}
+}
+
+func TestDeduplicator(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository[*Entity]()
+	s := NewDeduplicator(
+		repo,
+		repo,
+		repository.NewMemoryRepository[*MatchRules](),
+		repository.NewMemoryRepository[*DuplicateScan](),
+	)
+	upsert := func(e *Entity) *Entity {
+		e.AccountID = "acc"
+		e, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+		return e
+	}
+
+	jane := upsert(&Entity{Type: "Contact", Attributes: Attribute{"email": "jane@example.com", "name": "Jane Doe"}})
+	janeAgain := upsert(&Entity{Type: "Contact", Attributes: Attribute{"email": "JANE@example.com"}})
+	janet := upsert(&Entity{Type: "Contact", Attributes: Attribute{"name": "Janet Doe"}})
+	upsert(&Entity{Type: "Store", Attributes: Attribute{"email": "jane@example.com"}})
+
+	t.Run("lists the likely duplicates of an entity", func(t *testing.T) {
+		duplicates, err := s.Duplicates(ctx, "acc", jane.ID)
+		require.NoError(t, err)
+		require.Len(t, duplicates, 1)
+		require.Equal(t, janeAgain.ID, duplicates[0].Entity.ID)
+	})
+
+	t.Run("applies the configured rules", func(t *testing.T) {
+		_, err := s.SetMatchRules(ctx, "acc", "Contact", &MatchRules{
+			Rules:     []MatchRule{{Field: "attributes.name", Comparator: MatchName, Weight: 0.9}},
+			Threshold: 0.5,
+		})
+		require.NoError(t, err)
+
+		duplicates, err := s.Duplicates(ctx, "acc", jane.ID)
+		require.NoError(t, err)
+		require.Len(t, duplicates, 1)
+		require.Equal(t, janet.ID, duplicates[0].Entity.ID)
+
+		_, err = s.SetMatchRules(ctx, "acc", "Contact", &MatchRules{Rules: []MatchRule{{Field: "type", Comparator: MatchExact, Weight: 1}}, Threshold: 0.5})
+		require.ErrorIs(t, err, ErrInvalidMatchRules)
+	})
+
+	t.Run("suggests merges into the oldest entity", func(t *testing.T) {
+		scan, err := s.StartScan(ctx, "acc", "")
+		require.NoError(t, err)
+		require.Equal(t, ScanRunning, scan.Status)
+
+		require.Eventually(t, func() bool {
+			scan, err = s.Scan(ctx, "acc", scan.ID)
+			return err == nil && scan.Status != ScanRunning
+		}, time.Second, 10*time.Millisecond)
+		require.Equal(t, ScanCompleted, scan.Status)
+		require.Len(t, scan.Suggestions, 1)
+		require.Equal(t, jane.ID, scan.Suggestions[0].TargetID)
+		require.Equal(t, janet.ID, scan.Suggestions[0].SourceID)
+	})
+}
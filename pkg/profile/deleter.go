@@ -2,34 +2,112 @@ package profile
 
 import (
 	"context"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
 	"github.com/pkg/errors"
 )
 
 // deleter implements the entity deletion service.
+// Deletes are soft: the entity is tombstoned and can be restored until it is purged.
+// The relationships targeting a deleted entity are handled according to onDelete.
 type deleter struct {
-	repo Repository
+	repo     Repository
+	onDelete string
 }
 
-func NewDeleter(repo Repository) *deleter {
-	return &deleter{repo: repo}
+func NewDeleter(repo Repository, onDelete string) *deleter {
+	return &deleter{repo: repo, onDelete: onDelete}
 }
 
-func (s *deleter) Delete(ctx context.Context, accountID, id string) error {
+func (s *deleter) Delete(ctx context.Context, accountID, id string, version int64) error {
 	if id == "" {
 		return ErrIDMissing
 	}
-	e, err := s.repo.GetByID(ctx, accountID, id)
-	if e == nil {
+	e, err := getActive(ctx, s.repo, accountID, id)
+	if err != nil {
+		return err
+	}
+	if err = checkVersion(e, version); err != nil {
+		return err
+	}
+	if err = checkDelete(ctx, s.repo, accountID, id, s.onDelete); err != nil {
+		return err
+	}
+	// The relationships to the entity go first, so that a failure leaves it active and the delete can be
+	// retried, rather than leaving other entities targeting a tombstone
+	if err = cascadeDelete(ctx, s.repo, accountID, id, s.onDelete); err != nil {
+		return err
+	}
+
+	tombstone(ctx, e)
+	_, err = s.repo.Upsert(ctx, accountID, e)
+	if err != nil {
+		if isConflict(err) {
+			return ErrConflict
+		}
 		return errors.WithStack(err)
 	}
+	return nil
+}
 
+// Restore brings back a soft deleted entity.
+func (s *deleter) Restore(ctx context.Context, accountID, id string, version int64) (*Entity, error) {
+	if id == "" {
+		return nil, ErrIDMissing
+	}
+	e, err := s.repo.GetByID(ctx, accountID, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	if e.AccountID != accountID {
-		return ErrInvalid
+		return nil, ErrNotFound
+	}
+	if e.DeletedAt == nil {
+		return nil, ErrNotDeleted
+	}
+	if err = checkVersion(e, version); err != nil {
+		return nil, err
 	}
 
-	err = s.repo.Delete(ctx, accountID, id)
+	e.DeletedAt = nil
+	e.DeletedBy = ""
+	e, err = s.repo.Upsert(ctx, accountID, e)
 	if err != nil {
-		return errors.WithStack(err)
+		if isConflict(err) {
+			return nil, ErrConflict
+		}
+		return nil, errors.WithStack(err)
+	}
+	return e, nil
+}
+
+// tombstone marks an entity as soft deleted by the actor of the request.
+func tombstone(ctx context.Context, e *Entity) {
+	now := time.Now()
+	e.DeletedAt = &now
+	e.DeletedBy = pkg.ActorFrom(ctx)
+}
+
+// checkDelete refuses to delete an entity other active entities relate to when onDelete restricts it.
+func checkDelete(ctx context.Context, repo Repository, accountID, id, onDelete string) error {
+	if onDelete != OnDeleteRestrict {
+		return nil
+	}
+	referenced, err := isReferenced(ctx, repo, accountID, id)
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return ErrReferenced
 	}
 	return nil
 }
+
+// cascadeDelete removes the relationships targeting a deleted entity when onDelete cascades.
+func cascadeDelete(ctx context.Context, repo Repository, accountID, id, onDelete string) error {
+	if onDelete != OnDeleteCascade {
+		return nil
+	}
+	return removeReferences(ctx, repo, accountID, id)
+}
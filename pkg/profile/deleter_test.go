@@ -0,0 +1,76 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// failingUpsertOf is a Repository failing the upserts of the entity with the given ID.
+type failingUpsertOf struct {
+	Repository
+	id  string
+	err error
+}
+
+func (r *failingUpsertOf) Upsert(ctx context.Context, accountID string, e *Entity) (*Entity, error) {
+	if e.ID == r.id {
+		return nil, r.err
+	}
+	return r.Repository.Upsert(ctx, accountID, e)
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (Repository, *Entity, *Entity) {
+		t.Helper()
+		repo := repository.NewMemoryRepository[*Entity]()
+		target, err := repo.Upsert(ctx, "acc", &Entity{AccountID: "acc", Type: "Store"})
+		require.NoError(t, err)
+		target.Relationships = []Relationship{{Type: "self", TargetID: target.ID}}
+		target, err = repo.Upsert(ctx, "acc", target)
+		require.NoError(t, err)
+		owner, err := repo.Upsert(ctx, "acc", &Entity{
+			AccountID:     "acc",
+			Type:          "Contact",
+			Relationships: []Relationship{{Type: "shops", TargetID: target.ID}},
+		})
+		require.NoError(t, err)
+		return repo, target, owner
+	}
+
+	t.Run("restricts the delete of a referenced entity", func(t *testing.T) {
+		repo, target, _ := setup(t)
+		err := NewDeleter(repo, OnDeleteRestrict).Delete(ctx, "acc", target.ID, 0)
+		require.ErrorIs(t, err, ErrReferenced)
+	})
+
+	t.Run("cascades to the other entities before tombstoning", func(t *testing.T) {
+		repo, target, owner := setup(t)
+		require.NoError(t, NewDeleter(repo, OnDeleteCascade).Delete(ctx, "acc", target.ID, target.Version))
+
+		owner, err := repo.GetByID(ctx, "acc", owner.ID)
+		require.NoError(t, err)
+		require.Empty(t, owner.Relationships)
+		target, err = repo.GetByID(ctx, "acc", target.ID)
+		require.NoError(t, err)
+		require.NotNil(t, target.DeletedAt)
+		require.Len(t, target.Relationships, 1, "the relationships of the entity itself are kept for a restore")
+	})
+
+	t.Run("leaves the entity active when the cascade fails", func(t *testing.T) {
+		repo, target, owner := setup(t)
+		errWrite := errors.New("write failed")
+		failing := &failingUpsertOf{Repository: repo, id: owner.ID, err: errWrite}
+
+		err := NewDeleter(failing, OnDeleteCascade).Delete(ctx, "acc", target.ID, 0)
+		require.ErrorIs(t, err, errWrite)
+		_, err = getActive(ctx, repo, "acc", target.ID)
+		require.NoError(t, err)
+
+		require.NoError(t, NewDeleter(repo, OnDeleteCascade).Delete(ctx, "acc", target.ID, 0), "the delete can be retried")
+	})
+}
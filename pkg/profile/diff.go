@@ -0,0 +1,101 @@
+package profile
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Kinds of Change, named after their JSON Patch counterparts.
+const (
+	ChangeAdd     = "add"
+	ChangeRemove  = "remove"
+	ChangeReplace = "replace"
+)
+
+// diff lists the changes to the attributes, metadata and relationships of an entity between two of
+// its states. A nil from stands for an entity that did not exist yet.
+func diff(from, to *Entity) []Change {
+	changes := diffValues("", historyDocument(from), historyDocument(to), nil)
+	if changes == nil {
+		changes = []Change{}
+	}
+	return changes
+}
+
+// historyDocument returns the tracked fields of an entity as plain JSON values, so that values decoded
+// from storage compare equal to the ones received from clients. Attributes and metadata are always
+// present so that their keys are diffed one by one.
+func historyDocument(e *Entity) map[string]any {
+	doc := map[string]any{"attributes": map[string]any{}, "metadata": map[string]any{}}
+	if e == nil {
+		return doc
+	}
+	if len(e.Attributes) > 0 {
+		doc["attributes"] = plain(e.Attributes)
+	}
+	if len(e.Metadata) > 0 {
+		doc["metadata"] = plain(e.Metadata)
+	}
+	if len(e.Relationships) > 0 {
+		doc["relationships"] = plain(e.Relationships)
+	}
+	return doc
+}
+
+func plain(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err = json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// diffValues appends the changes from one value to another, descending into objects key by key.
+// Arrays are compared as a whole.
+func diffValues(path string, from, to any, changes []Change) []Change {
+	fromMap, fromOK := from.(map[string]any)
+	toMap, toOK := to.(map[string]any)
+	if !fromOK || !toOK {
+		if !reflect.DeepEqual(from, to) {
+			changes = append(changes, Change{Op: ChangeReplace, Path: path, OldValue: from, Value: to})
+		}
+		return changes
+	}
+
+	keys := make([]string, 0, len(fromMap)+len(toMap))
+	for k := range fromMap {
+		keys = append(keys, k)
+	}
+	for k := range toMap {
+		if _, ok := fromMap[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escapePointer(k)
+		oldValue, inFrom := fromMap[k]
+		value, inTo := toMap[k]
+		switch {
+		case !inTo:
+			changes = append(changes, Change{Op: ChangeRemove, Path: p, OldValue: oldValue})
+		case !inFrom:
+			changes = append(changes, Change{Op: ChangeAdd, Path: p, Value: value})
+		default:
+			changes = diffValues(p, oldValue, value, changes)
+		}
+	}
+	return changes
+}
+
+// escapePointer escapes a key to be used as a JSON pointer reference token.
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -0,0 +1,49 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		it   string
+		from *Entity
+		to   *Entity
+		want []Change
+	}{
+		{
+			it:   "lists every attribute of a new entity as added",
+			to:   &Entity{Attributes: Attribute{"email": "ana@example.com"}},
+			want: []Change{{Op: ChangeAdd, Path: "/attributes/email", Value: "ana@example.com"}},
+		},
+		{
+			it:   "descends into nested objects and escapes keys",
+			from: &Entity{Attributes: Attribute{"address": map[string]any{"city": "Lima", "a/b": 1}}},
+			to:   &Entity{Attributes: Attribute{"address": map[string]any{"city": "Cusco"}}},
+			want: []Change{
+				{Op: ChangeRemove, Path: "/attributes/address/a~1b", OldValue: float64(1)},
+				{Op: ChangeReplace, Path: "/attributes/address/city", OldValue: "Lima", Value: "Cusco"},
+			},
+		},
+		{
+			it:   "ignores differences in the representation of stored values",
+			from: &Entity{Attributes: Attribute{"tags": primitive.A{"vip"}, "age": int32(31)}},
+			to:   &Entity{Attributes: Attribute{"tags": []any{"vip"}, "age": 31}},
+			want: []Change{},
+		},
+		{
+			it:   "compares relationships as a whole",
+			from: &Entity{Metadata: Metadata{"source": "crm"}},
+			to:   &Entity{Metadata: Metadata{"source": "crm"}, Relationships: []Relationship{{Type: "buysFrom", TargetID: "store"}}},
+			want: []Change{{Op: ChangeAdd, Path: "/relationships", Value: []any{map[string]any{"type": "buysFrom", "targetId": "store"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			require.Equal(t, tt.want, diff(tt.from, tt.to))
+		})
+	}
+}
@@ -3,6 +3,9 @@ package profile
 import (
 	"context"
 	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/dportaluppi/customer-profiles-api/pkg/relationship"
 )
 
 // Metadata represents any additional metadata information.
@@ -13,8 +16,13 @@ type Attribute map[string]any
 
 // Relationship defines a connection between entities.
 type Relationship struct {
-	Type     string `json:"type" bson:"type"`         // Type of relationship, e.g., 'buysFrom', 'sellsFor'
-	TargetID string `json:"targetId" bson:"targetId"` // ID of the target entity in the relationship
+	ID         string         `json:"id,omitempty" bson:"id,omitempty"`                 // Unique identifier for the relationship within its entity
+	Type       string         `json:"type" bson:"type"`                                 // Type of relationship, e.g., 'buysFrom', 'sellsFor'
+	TargetID   string         `json:"targetId" bson:"targetId"`                         // ID of the target entity in the relationship
+	Attributes map[string]any `json:"attributes,omitempty" bson:"attributes,omitempty"` // Attributes of the relationship, as declared by its type
+
+	CreatedAt *time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"` // Timestamp of relationship creation
+	UpdatedAt *time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"` // Timestamp of last update to the relationship attributes
 }
 
 // Entity represents a generic structure for Contact or Store, associated with a specific account.
@@ -26,6 +34,16 @@ type Entity struct {
 	Attributes    Attribute      `json:"attributes" bson:"attributes"`       // Specific attributes of the entity
 	Relationships []Relationship `json:"relationships" bson:"relationships"` // Relationships with other entities
 
+	MergedIDs  []string `json:"mergedIds,omitempty" bson:"mergedIds,omitempty"`   // IDs of the entities merged into this one
+	MergedInto string   `json:"mergedInto,omitempty" bson:"mergedInto,omitempty"` // ID of the surviving entity when this one was merged away
+
+	Version int64 `json:"version" bson:"version"` // Version of the entity, incremented on every write
+
+	SearchPaths []string `json:"-" bson:"searchPaths,omitempty"` // Search fields of the entity type when last indexed
+
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt"` // Timestamp of the soft deletion, nil while active
+	DeletedBy string     `json:"deletedBy,omitempty" bson:"deletedBy"` // Who soft deleted the entity
+
 	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"` // Timestamp of entity creation
 	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"` // Timestamp of last entity update
 }
@@ -40,6 +58,21 @@ func (e *Entity) SetID(id string) {
 	e.ID = id
 }
 
+// GetType returns the entity's type.
+func (e *Entity) GetType() string {
+	return e.Type
+}
+
+// GetVersion returns the entity's version.
+func (e *Entity) GetVersion() int64 {
+	return e.Version
+}
+
+// SetVersion sets the entity's version.
+func (e *Entity) SetVersion(v int64) {
+	e.Version = v
+}
+
 // GetCreatedAt returns the timestamp of when the entity was created.
 func (e *Entity) GetCreatedAt() *time.Time {
 	return e.CreatedAt
@@ -60,6 +93,16 @@ func (e *Entity) SetUpdatedAt(t time.Time) {
 	e.UpdatedAt = &t
 }
 
+// Relationship returns the position of the relationship with the given ID, -1 when the entity holds none.
+func (e *Entity) Relationship(id string) int {
+	for i, r := range e.Relationships {
+		if id != "" && r.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
 func (e *Entity) Add(r Relationship) bool {
 	// Check if relationship already exists
 	for _, existingRelationship := range e.Relationships {
@@ -72,29 +115,530 @@ func (e *Entity) Add(r Relationship) bool {
 	return true
 }
 
+// Behaviours applied to the relationships targeting an entity when it is deleted.
+const (
+	OnDeleteRestrict = "restrict" // Refuse to delete an entity other active entities relate to
+	OnDeleteCascade  = "cascade"  // Remove the relationships targeting the deleted entity
+	OnDeleteIgnore   = "ignore"   // Leave the relationships dangling
+)
+
+// Reasons why a relationship is reported as dangling.
+const (
+	DanglingMissing = "missing" // The target does not exist in the account
+	DanglingDeleted = "deleted" // The target is soft deleted
+	DanglingSelf    = "self"    // The target is the entity itself
+)
+
+// DanglingReference describes a relationship whose target is not a valid entity.
+type DanglingReference struct {
+	EntityID string `json:"entityId"` // ID of the entity holding the relationship
+	Type     string `json:"type"`     // Type of the relationship
+	TargetID string `json:"targetId"` // ID of the relationship target
+	Reason   string `json:"reason"`   // Why the relationship is dangling
+}
+
+// Directions in which relationships are followed.
+const (
+	DirectionOut  = "out"  // From the entity holding the relationship to its target
+	DirectionIn   = "in"   // From the target to the entity holding the relationship
+	DirectionBoth = "both" // Either way
+)
+
+// Edge is a relationship seen from the graph of an account, from the entity holding it to its target.
+type Edge struct {
+	ID         string         `json:"id,omitempty"`         // ID of the relationship
+	SourceID   string         `json:"sourceId"`             // ID of the entity holding the relationship
+	Type       string         `json:"type"`                 // Type of the relationship
+	Inverse    string         `json:"inverse,omitempty"`    // Name of the relationship seen from the target, when its type declares one
+	TargetID   string         `json:"targetId"`             // ID of the relationship target
+	Attributes map[string]any `json:"attributes,omitempty"` // Attributes of the relationship
+}
+
+// TraversalCriteria describes a walk of the relationship graph starting at an entity.
+type TraversalCriteria struct {
+	Direction string   // Direction in which relationships are followed, defaults to DirectionOut
+	Depth     int      // Maximum number of hops from the starting entity, from 1 to 5
+	Types     []string // Relationship types followed, every type when empty
+}
+
+// Graph is the subgraph reached by a traversal, the starting entity first.
+type Graph struct {
+	Nodes []*Entity `json:"nodes"` // Entities reached, in breadth first order
+	Edges []Edge    `json:"edges"` // Relationships of the followed types between the reached entities
+}
+
+// Merge conflict strategies, applied when source and target hold different values for the same key.
+const (
+	MergeTargetWins  = "targetWins"  // Keep the target value
+	MergeNewestWins  = "newestWins"  // Keep the value of the most recently updated entity
+	MergeUnionArrays = "unionArrays" // Union arrays, keep the target value for everything else
+)
+
+// Actions applied to the source entities once merged.
+const (
+	MergeDeleteSources    = "delete"    // Delete the sources, like the entity delete endpoint
+	MergeTombstoneSources = "tombstone" // Soft delete the sources, marked with the surviving entity ID
+)
+
+// MergeCriteria describes which entities are folded into which surviving entity.
+type MergeCriteria struct {
+	SourceIDs    []string `json:"sourceIds"`    // IDs of the entities to be merged
+	TargetID     string   `json:"targetId"`     // ID of the surviving entity
+	Strategy     string   `json:"strategy"`     // Conflict strategy, defaults to MergeTargetWins
+	SourceAction string   `json:"sourceAction"` // What to do with the sources, defaults to MergeDeleteSources
+}
+
+// QueryOptions tunes how entities are retrieved.
+type QueryOptions struct {
+	IncludeDeleted bool       // Also return soft deleted entities
+	Type           string     // Only return entities of this type
+	Sort           []pkg.Sort // Order of listed entities, among the sortable fields, natural order when empty
+	Fields         []string   // Only return these fields of listed entities, along with the ID, all of them when empty
+}
+
+// CursorPage is a page of entities in cursor pagination, linked to its neighbours by opaque cursors.
+type CursorPage struct {
+	Entities   []*Entity // Entities of the page, ordered by ID
+	NextCursor string    // Cursor of the following page, empty on the last one
+	PrevCursor string    // Cursor of the preceding page, empty on the first one
+	TotalItems *int      // Number of matching entities, only when counted
+}
+
+// TypeCount reports how many entities of a type an account holds.
+type TypeCount struct {
+	Type  string `json:"type"`  // Type of the entities
+	Count int    `json:"count"` // Number of entities of the type
+}
+
+// BulkOperations groups the entities to create, update and delete in a single bulk request.
+type BulkOperations struct {
+	Create []*Entity `json:"create"` // Entities to be created
+	Update []*Entity `json:"update"` // Entities to be updated, identified by their ID
+	Delete []string  `json:"delete"` // IDs of the entities to be deleted
+}
+
+// Statuses reported for each item of a bulk request.
+const (
+	BulkStatusCreated = "created"
+	BulkStatusUpdated = "updated"
+	BulkStatusDeleted = "deleted"
+	BulkStatusFailed  = "failed"
+)
+
+// BulkResult reports the outcome of a single item of a bulk request.
+type BulkResult struct {
+	Operation string `json:"operation"`       // Operation of the item, 'create', 'update' or 'delete'
+	Index     int    `json:"index"`           // Position of the item within its operation array
+	ID        string `json:"id,omitempty"`    // ID of the affected entity
+	Status    string `json:"status"`          // Outcome of the item
+	Error     string `json:"error,omitempty"` // Reason of the failure
+}
+
+// BulkError is returned when a bulk request fails after some of its items were written. It carries the
+// outcome of every item as details.
+type BulkError struct {
+	Results []BulkResult
+	Err     error
+}
+
+func (e *BulkError) Error() string {
+	return e.Err.Error()
+}
+
+// Details returns the outcome of every item.
+func (e *BulkError) Details() any {
+	return e.Results
+}
+
+func (e *BulkError) Unwrap() error {
+	return e.Err
+}
+
+// Operations recorded in the history of an entity.
+const (
+	OperationCreate               = "create"
+	OperationUpdate               = "update"
+	OperationPatch                = "patch"
+	OperationDelete               = "delete"
+	OperationRestore              = "restore"
+	OperationAddRelationship      = "addRelationship"
+	OperationReplaceRelationships = "replaceRelationships"
+	OperationUpdateRelationship   = "updateRelationship"
+	OperationRemoveRelationship   = "removeRelationship"
+	OperationMerge                = "merge"
+	OperationRevert               = "revert"
+)
+
+// Change describes a single difference between two revisions of an entity, addressed by a JSON pointer.
+type Change struct {
+	Op       string `json:"op" bson:"op"`             // Kind of change, 'add', 'remove' or 'replace'
+	Path     string `json:"path" bson:"path"`         // JSON pointer to the changed value, e.g. '/attributes/email'
+	OldValue any    `json:"oldValue" bson:"oldValue"` // Value before the change, nil when added
+	Value    any    `json:"value" bson:"value"`       // Value after the change, nil when removed
+}
+
+// Revision records a write to an entity: who made it, when, which operation and what changed.
+type Revision struct {
+	ID        string   `json:"id"`                         // Unique identifier for the revision
+	AccountID string   `json:"accountId" bson:"accountId"` // ID of the associated account
+	EntityID  string   `json:"entityId" bson:"entityId"`   // ID of the revised entity
+	Version   int64    `json:"version" bson:"version"`     // Version of the entity after the write
+	Operation string   `json:"operation" bson:"operation"` // Operation that produced the revision
+	Actor     string   `json:"actor" bson:"actor"`         // Who made the change, empty when unknown
+	Changes   []Change `json:"changes" bson:"changes"`     // Differences of attributes, metadata and relationships
+	Snapshot  *Entity  `json:"snapshot" bson:"snapshot"`   // State of the entity after the write
+
+	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"` // Timestamp of the write
+	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"` // Timestamp of last revision update
+}
+
+// GetID returns the revision's unique identifier.
+func (r *Revision) GetID() string {
+	return r.ID
+}
+
+// SetID sets the revision's unique identifier.
+func (r *Revision) SetID(id string) {
+	r.ID = id
+}
+
+// GetCreatedAt returns the timestamp of the write.
+func (r *Revision) GetCreatedAt() *time.Time {
+	return r.CreatedAt
+}
+
+// SetCreatedAt sets the timestamp of the write.
+func (r *Revision) SetCreatedAt(t time.Time) {
+	r.CreatedAt = &t
+}
+
+// GetUpdatedAt returns the timestamp of the last update to the revision.
+func (r *Revision) GetUpdatedAt() *time.Time {
+	return r.UpdatedAt
+}
+
+// SetUpdatedAt sets the timestamp of the last update to the revision.
+func (r *Revision) SetUpdatedAt(t time.Time) {
+	r.UpdatedAt = &t
+}
+
+// Comparators of a MatchRule.
+const (
+	MatchExact         = "exact"         // Values are equal
+	MatchEmail         = "email"         // Email addresses are equal ignoring case and surrounding spaces
+	MatchPhone         = "phone"         // Phone numbers hold the same digits, or the same trailing ones when a prefix is missing
+	MatchName          = "name"          // Names are similar ignoring case and punctuation, scored by Jaro-Winkler similarity
+	MatchRelationships = "relationships" // Entities share relationship targets, scored by the share of common targets
+)
+
+// MatchRule is a piece of evidence that two entities are the same one.
+type MatchRule struct {
+	Field      string  `json:"field" bson:"field"`           // Compared field, e.g. 'attributes.email', ignored by MatchRelationships
+	Comparator string  `json:"comparator" bson:"comparator"` // How the values are compared
+	Weight     float64 `json:"weight" bson:"weight"`         // Confidence, from 0 to 1, given by a full match
+}
+
+// MatchRules configure the duplicate detection of an entity type of an account.
+type MatchRules struct {
+	ID         string      `json:"id"`                           // Unique identifier for the rules
+	AccountID  string      `json:"accountId" bson:"accountId"`   // ID of the associated account
+	EntityType string      `json:"entityType" bson:"entityType"` // Type of the compared entities
+	Rules      []MatchRule `json:"rules" bson:"rules"`           // Evidence weighed when comparing two entities
+	Threshold  float64     `json:"threshold" bson:"threshold"`   // Score, from 0 to 1, from which two entities are duplicates
+
+	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"` // Timestamp of rules creation
+	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"` // Timestamp of last rules update
+}
+
+// GetID returns the rules' unique identifier.
+func (m *MatchRules) GetID() string {
+	return m.ID
+}
+
+// SetID sets the rules' unique identifier.
+func (m *MatchRules) SetID(id string) {
+	m.ID = id
+}
+
+// GetCreatedAt returns the timestamp of when the rules were created.
+func (m *MatchRules) GetCreatedAt() *time.Time {
+	return m.CreatedAt
+}
+
+// SetCreatedAt sets the timestamp of when the rules were created.
+func (m *MatchRules) SetCreatedAt(t time.Time) {
+	m.CreatedAt = &t
+}
+
+// GetUpdatedAt returns the timestamp of the last update to the rules.
+func (m *MatchRules) GetUpdatedAt() *time.Time {
+	return m.UpdatedAt
+}
+
+// SetUpdatedAt sets the timestamp of the last update to the rules.
+func (m *MatchRules) SetUpdatedAt(t time.Time) {
+	m.UpdatedAt = &t
+}
+
+// Duplicate is an entity likely to be the same as another one.
+type Duplicate struct {
+	Entity  *Entity  `json:"entity"`  // The likely duplicate
+	Score   float64  `json:"score"`   // Likelihood, from 0 to 1, of both being the same entity
+	Reasons []string `json:"reasons"` // Fields of the matching rules
+}
+
+// MergeSuggestion proposes merging a likely duplicate into the oldest of the two entities.
+type MergeSuggestion struct {
+	TargetID   string   `json:"targetId" bson:"targetId"`     // ID of the entity to keep, the oldest one
+	SourceID   string   `json:"sourceId" bson:"sourceId"`     // ID of the entity to merge into the target
+	EntityType string   `json:"entityType" bson:"entityType"` // Type of both entities
+	Score      float64  `json:"score" bson:"score"`           // Likelihood, from 0 to 1, of both being the same entity
+	Reasons    []string `json:"reasons" bson:"reasons"`       // Fields of the matching rules
+}
+
+// Statuses of a DuplicateScan.
+const (
+	ScanRunning   = "running"
+	ScanCompleted = "completed"
+	ScanFailed    = "failed"
+)
+
+// DuplicateScan is a batch job looking for the duplicate entities of an account, producing merge
+// suggestions ranked by score.
+type DuplicateScan struct {
+	ID          string            `json:"id"`                             // Unique identifier for the scan
+	AccountID   string            `json:"accountId" bson:"accountId"`     // ID of the associated account
+	EntityType  string            `json:"entityType" bson:"entityType"`   // Scanned entity type, every type when empty
+	Status      string            `json:"status" bson:"status"`           // Progress of the scan
+	Error       string            `json:"error,omitempty" bson:"error"`   // Reason of the failure
+	Suggestions []MergeSuggestion `json:"suggestions" bson:"suggestions"` // Suggested merges, best first
+	CompletedAt *time.Time        `json:"completedAt" bson:"completedAt"` // Timestamp of the end of the scan
+
+	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"` // Timestamp of the start of the scan
+	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"` // Timestamp of last scan update
+}
+
+// GetID returns the scan's unique identifier.
+func (d *DuplicateScan) GetID() string {
+	return d.ID
+}
+
+// SetID sets the scan's unique identifier.
+func (d *DuplicateScan) SetID(id string) {
+	d.ID = id
+}
+
+// GetCreatedAt returns the timestamp of when the scan was created.
+func (d *DuplicateScan) GetCreatedAt() *time.Time {
+	return d.CreatedAt
+}
+
+// SetCreatedAt sets the timestamp of when the scan was created.
+func (d *DuplicateScan) SetCreatedAt(t time.Time) {
+	d.CreatedAt = &t
+}
+
+// GetUpdatedAt returns the timestamp of the last update to the scan.
+func (d *DuplicateScan) GetUpdatedAt() *time.Time {
+	return d.UpdatedAt
+}
+
+// SetUpdatedAt sets the timestamp of the last update to the scan.
+func (d *DuplicateScan) SetUpdatedAt(t time.Time) {
+	d.UpdatedAt = &t
+}
+
+// SearchFields configure the free-text search of an entity type of an account.
+type SearchFields struct {
+	ID         string   `json:"id"`                           // Unique identifier for the configuration
+	AccountID  string   `json:"accountId" bson:"accountId"`   // ID of the associated account
+	EntityType string   `json:"entityType" bson:"entityType"` // Type of the searched entities
+	Fields     []string `json:"fields" bson:"fields"`         // Dotted paths of the attributes and metadata entries matched, e.g. 'attributes.name'
+
+	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"` // Timestamp of configuration creation
+	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"` // Timestamp of last configuration update
+}
+
+// GetID returns the configuration's unique identifier.
+func (f *SearchFields) GetID() string {
+	return f.ID
+}
+
+// SetID sets the configuration's unique identifier.
+func (f *SearchFields) SetID(id string) {
+	f.ID = id
+}
+
+// GetCreatedAt returns the timestamp of when the configuration was created.
+func (f *SearchFields) GetCreatedAt() *time.Time {
+	return f.CreatedAt
+}
+
+// SetCreatedAt sets the timestamp of when the configuration was created.
+func (f *SearchFields) SetCreatedAt(t time.Time) {
+	f.CreatedAt = &t
+}
+
+// GetUpdatedAt returns the timestamp of the last update to the configuration.
+func (f *SearchFields) GetUpdatedAt() *time.Time {
+	return f.UpdatedAt
+}
+
+// SetUpdatedAt sets the timestamp of the last update to the configuration.
+func (f *SearchFields) SetUpdatedAt(t time.Time) {
+	f.UpdatedAt = &t
+}
+
+// Validator checks the attributes of an entity against the rules registered for its type.
+type Validator interface {
+	Validate(ctx context.Context, accountId, entityType string, attributes map[string]any) error
+}
+
+// TypeValidator is a Validator able to load the rules of an entity type once to check many entities of
+// the type, e.g. those of a bulk request.
+type TypeValidator interface {
+	Validator
+	ForType(ctx context.Context, accountId, entityType string) (func(attributes map[string]any) error, error)
+}
+
+// RelationshipTypes looks up the relationship types registered for an account, answering
+// relationship.ErrNotFound for the free form ones.
+type RelationshipTypes interface {
+	Get(ctx context.Context, accountId, name string) (*relationship.Definition, error)
+}
+
+// IdentityKeys looks up the identity keys registered for an account.
+type IdentityKeys interface {
+	Types(ctx context.Context, accountId, field string) ([]string, error)
+}
+
 type Saver interface {
 	Create(ctx context.Context, accountId string, entity *Entity) (*Entity, error)
 	Update(ctx context.Context, accountId, id string, entity *Entity) (*Entity, error)
-	AddRelationship(ctx context.Context, accountId, id string, relationship Relationship) (*Entity, error)
-	ReplaceRelationships(ctx context.Context, accountId, id string, relationship []Relationship) (*Entity, error)
+	Patch(ctx context.Context, accountId, id string, version int64, format string, patch []byte) (*Entity, error)
+	AddRelationship(ctx context.Context, accountId, id string, version int64, relationship Relationship) (*Entity, error)
+	ReplaceRelationships(ctx context.Context, accountId, id string, version int64, relationship []Relationship) (*Entity, error)
+	UpdateRelationship(ctx context.Context, accountId, id string, version int64, relationshipId string, attributes map[string]any) (*Entity, error)
+	RemoveRelationship(ctx context.Context, accountId, id string, version int64, relationshipId string) (*Entity, error)
 }
 
 type Deleter interface {
-	Delete(ctx context.Context, accountId, id string) error
+	Delete(ctx context.Context, accountId, id string, version int64) error
+	Restore(ctx context.Context, accountId, id string, version int64) (*Entity, error)
+}
+
+type Purger interface {
+	Purge(ctx context.Context) (int, error)
+}
+
+type Bulker interface {
+	Bulk(ctx context.Context, accountId string, operations BulkOperations) ([]BulkResult, error)
+}
+
+type Merger interface {
+	Merge(ctx context.Context, accountId string, criteria MergeCriteria) (*Entity, error)
+}
+
+type Checker interface {
+	DanglingReferences(ctx context.Context, accountId string) ([]DanglingReference, error)
+}
+
+type Navigator interface {
+	Relationships(ctx context.Context, accountId, id, direction string, types []string, currentPage, perPage int) ([]Edge, int, error)
+	Traverse(ctx context.Context, accountId, id string, criteria TraversalCriteria) (*Graph, error)
+}
+
+type Resolver interface {
+	Resolve(ctx context.Context, accountId, field, value, entityType string) (*Entity, error)
+	Upsert(ctx context.Context, accountId, field, value string, entity *Entity) (*Entity, bool, error)
+}
+
+type Deduplicator interface {
+	Duplicates(ctx context.Context, accountId, id string) ([]Duplicate, error)
+	MatchRules(ctx context.Context, accountId, entityType string) (*MatchRules, error)
+	SetMatchRules(ctx context.Context, accountId, entityType string, rules *MatchRules) (*MatchRules, error)
+	StartScan(ctx context.Context, accountId, entityType string) (*DuplicateScan, error)
+	Scan(ctx context.Context, accountId, id string) (*DuplicateScan, error)
+}
+
+type Searcher interface {
+	Search(ctx context.Context, accountId, text string, query map[string]any, currentPage, perPage int, opts QueryOptions) ([]*Entity, int, error)
+	SearchFields(ctx context.Context, accountId, entityType string) (*SearchFields, error)
+	SetSearchFields(ctx context.Context, accountId, entityType string, fields *SearchFields) (*SearchFields, error)
+}
+
+type Aggregator interface {
+	Aggregate(ctx context.Context, accountId string, query map[string]any, facets map[string]pkg.Facet, opts QueryOptions) (*pkg.Aggregation, error)
+	Types(ctx context.Context, accountId string) ([]TypeCount, error)
 }
 
 type Getter interface {
-	GetByID(ctx context.Context, accountId, id string) (*Entity, error)
-	GetAll(ctx context.Context, accountId string, page, limit int) ([]*Entity, int, error)
-	Query(ctx context.Context, accountId string, query map[string]any, currentPage, perPage int) ([]*Entity, int, error)
-	Pipeline(ctx context.Context, accountId string, pipeline map[string]any, currentPage, perPage int) ([]*Entity, int, error)
+	GetByID(ctx context.Context, accountId, id string, opts QueryOptions) (*Entity, error)
+	GetAll(ctx context.Context, accountId string, page, limit int, opts QueryOptions) ([]*Entity, int, error)
+	Query(ctx context.Context, accountId string, query map[string]any, currentPage, perPage int, opts QueryOptions) ([]*Entity, int, error)
+	Pipeline(ctx context.Context, accountId string, pipeline map[string]any, currentPage, perPage int, opts QueryOptions) ([]*Entity, int, error)
+	Seek(ctx context.Context, accountId string, query map[string]any, cursor string, limit int, count bool, opts QueryOptions) (*CursorPage, error)
+	SeekPipeline(ctx context.Context, accountId string, pipeline map[string]any, cursor string, limit int, count bool, opts QueryOptions) (*CursorPage, error)
 }
 
 type Repository interface {
 	Upsert(ctx context.Context, accountId string, entity *Entity) (*Entity, error)
+	BulkWrite(ctx context.Context, accountId string, upserts []*Entity, deleteIDs []string) ([]error, error)
+	Patch(ctx context.Context, accountId, id string, version int64, set map[string]any, unset []string) (*Entity, error)
 	GetByID(ctx context.Context, accountId, id string) (*Entity, error)
 	Delete(ctx context.Context, accountId, id string) error
-	GetAll(ctx context.Context, accountId string, page, limit int) ([]*Entity, int, error)
+	GetAll(ctx context.Context, accountId string, page, limit int, includeDeleted bool) ([]*Entity, int, error)
+	GetByIDs(ctx context.Context, accountId string, ids []string, includeDeleted bool) ([]*Entity, error)
 	ExecuteQuery(ctx context.Context, accountId string, query map[string]interface{}, page, limit int) ([]*Entity, int, error)
 	ExecutePipeline(ctx context.Context, accountId string, pipeline map[string]any, currentPage, perPage int) ([]*Entity, int, error)
+	Find(ctx context.Context, accountId string, query map[string]any, sort []pkg.Sort, fields []string, currentPage, perPage int) ([]*Entity, int, error)
+	Seek(ctx context.Context, accountId string, query map[string]any, sort []pkg.Sort, fields []string, after, before string, limit int) ([]*Entity, error)
+	Count(ctx context.Context, accountId string, query map[string]any) (int, error)
+	DeleteOlderThan(ctx context.Context, field string, before time.Time) (map[string][]string, error)
+	EnsureIndex(ctx context.Context, name string, fields []string) error
+}
+
+// AggregateRepository summarizes the stored entities of an account.
+type AggregateRepository interface {
+	Aggregate(ctx context.Context, accountId string, query map[string]any, facets map[string]pkg.Facet) (*pkg.Aggregation, error)
+	CountBy(ctx context.Context, accountId, field string, query map[string]any) (map[string]int, error)
+}
+
+// GraphRepository walks the references between the stored entities of an account.
+type GraphRepository interface {
+	GraphLookup(ctx context.Context, accountId, id, connectFromField, connectToField string, maxDepth int, query map[string]any) ([]*Entity, error)
+}
+
+// TextRepository finds the stored entities of an account by their search terms.
+type TextRepository interface {
+	TextSearch(ctx context.Context, accountId string, terms []string, query map[string]any, limit int) ([]*Entity, error)
+	Reindex(ctx context.Context, accountId string, query map[string]any, set map[string]any) ([]string, error)
+}
+
+type Historian interface {
+	History(ctx context.Context, accountId, id string, currentPage, perPage int) ([]*Revision, int, error)
+	AsOf(ctx context.Context, accountId, id string, at time.Time) (*Entity, error)
+	Revert(ctx context.Context, accountId, id string, version, toVersion int64) (*Entity, error)
+}
+
+type HistoryRepository interface {
+	Upsert(ctx context.Context, accountId string, revision *Revision) (*Revision, error)
+	BulkWrite(ctx context.Context, accountId string, upserts []*Revision, deleteIDs []string) ([]error, error)
+	Find(ctx context.Context, accountId string, query map[string]any, sort []pkg.Sort, fields []string, page, limit int) ([]*Revision, int, error)
+	EnsureIndex(ctx context.Context, name string, fields []string) error
+}
+
+type MatchRulesRepository interface {
+	Upsert(ctx context.Context, accountId string, rules *MatchRules) (*MatchRules, error)
+	ExecuteQuery(ctx context.Context, accountId string, query map[string]any, page, limit int) ([]*MatchRules, int, error)
+}
+
+type SearchFieldsRepository interface {
+	Upsert(ctx context.Context, accountId string, fields *SearchFields) (*SearchFields, error)
+	ExecuteQuery(ctx context.Context, accountId string, query map[string]any, page, limit int) ([]*SearchFields, int, error)
+}
+
+type DuplicateScanRepository interface {
+	Upsert(ctx context.Context, accountId string, scan *DuplicateScan) (*DuplicateScan, error)
+	GetByID(ctx context.Context, accountId, id string) (*DuplicateScan, error)
 }
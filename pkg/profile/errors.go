@@ -6,8 +6,50 @@ var (
 	ErrIDMissing                   = pkg.NewErrID("missing entity id")
 	ErrAccountIDMissing            = pkg.NewErrID("missing account id")
 	ErrInvalid                     = pkg.NewErrInvalid("invalid entity data")
+	ErrTypeMismatch                = pkg.NewErrInvalid("entity type does not match the path type")
 	ErrNotFound                    = pkg.NewErrNotFound("entity not found")
+	ErrNotDeleted                  = pkg.NewErrConflict("entity is not deleted")
 	ErrConflict                    = pkg.NewErrConflict("entity conflict occurred")
+	ErrVersionMismatch             = pkg.NewErrPreconditionFailed("entity version does not match")
 	ErrInternalError               = pkg.NewErrInternalError("entity internal error")
 	ErrInvalidPaginationParameters = pkg.NewErrInvalid("invalid entity pagination parameters")
+	ErrInvalidCursor               = pkg.NewErrInvalid("invalid pagination cursor")
+	ErrInvalidSort                 = pkg.NewErrInvalid("invalid sort, fields must be sortable and not repeated")
+	ErrInvalidFields               = pkg.NewErrInvalid("invalid fields selection")
+	ErrBulkEmpty                   = pkg.NewErrInvalid("bulk request has no operations")
+	ErrBulkTooLarge                = pkg.NewErrInvalid("bulk request exceeds the maximum number of operations")
+	ErrMergeTargetMissing          = pkg.NewErrID("missing merge target id")
+	ErrMergeSourcesMissing         = pkg.NewErrID("missing merge source ids")
+	ErrMergeTargetInSources        = pkg.NewErrInvalid("merge target cannot be one of the sources")
+	ErrMergeTypeMismatch           = pkg.NewErrInvalid("merged entities must share the same type")
+	ErrMergeInvalidStrategy        = pkg.NewErrInvalid("invalid merge strategy")
+	ErrMergeInvalidSourceAction    = pkg.NewErrInvalid("invalid merge source action")
+	ErrReferenced                  = pkg.NewErrConflict("entity is the target of relationships")
+	ErrRelationshipTargetMissing   = pkg.NewErrInvalid("missing relationship target id")
+	ErrRelationshipTargetNotFound  = pkg.NewErrInvalid("relationship target not found")
+	ErrRelationshipSelf            = pkg.NewErrInvalid("an entity cannot be related to itself")
+	ErrInvalidDirection            = pkg.NewErrInvalid("invalid relationship direction")
+	ErrInvalidDepth                = pkg.NewErrInvalid("invalid traversal depth")
+	ErrRelationshipNotFound        = pkg.NewErrNotFound("relationship not found")
+	ErrRelationshipTypeMismatch    = pkg.NewErrInvalid("the relationship type does not connect these entity types")
+	ErrRelationshipCardinality     = pkg.NewErrConflict("the relationship exceeds the cardinality of its type")
+	ErrNotIdentityKey              = pkg.NewErrInvalid("the field is not an identity key of the entity type")
+	ErrIdentityTypeMissing         = pkg.NewErrInvalid("the identity key is shared by several entity types, the entity type is required")
+	ErrIdentityAmbiguous           = pkg.NewErrConflict("several entities hold the identity")
+	ErrInvalidMatchRules           = pkg.NewErrInvalid("invalid match rules")
+	ErrScanNotFound                = pkg.NewErrNotFound("duplicate scan not found")
+	ErrUnsupportedPatch            = pkg.NewErrInvalid("unsupported patch format, use application/merge-patch+json or application/json-patch+json")
+	ErrInvalidPatch                = pkg.NewErrInvalid("invalid patch")
+	ErrPatchForbiddenPath          = pkg.NewErrInvalid("patch changes a field that cannot be patched")
+	ErrPatchPathNotFound           = pkg.NewErrInvalid("patch path not found")
+	ErrPatchTestFailed             = pkg.NewErrConflict("patch test operation failed")
+	ErrInvalidSearchFields         = pkg.NewErrInvalid("invalid search fields")
+	ErrInvalidTextQuery            = pkg.NewErrInvalid("invalid free-text query")
+	ErrTextSearchSorted            = pkg.NewErrInvalid("free-text search results are ordered by relevance and cannot be sorted")
+	ErrTextSearchCursor            = pkg.NewErrInvalid("free-text search does not support cursor pagination")
+	ErrInvalidFacet                = pkg.NewErrInvalid("invalid facet")
+	ErrTooManyFacets               = pkg.NewErrInvalid("too many facets")
+	ErrRevisionNotFound            = pkg.NewErrNotFound("entity revision not found")
+	ErrInvalidRevision             = pkg.NewErrInvalid("invalid entity revision")
+	ErrNotSupported                = pkg.NewErrNotImplemented("the entity storage does not support this operation")
 )
@@ -2,9 +2,20 @@ package profile
 
 import (
 	"context"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
 	"github.com/pkg/errors"
 )
 
+const (
+	// deletedAtKey is the document field holding the soft deletion timestamp.
+	deletedAtKey = "deletedAt"
+	// typeKey is the document field holding the entity type.
+	typeKey = "type"
+	// lookupSize is the number of IDs resolved per query when loading entities by ID.
+	lookupSize = 1000
+)
+
 // getter implements the entity retrieval service.
 type getter struct {
 	repo Repository
@@ -14,7 +25,7 @@ func NewGetter(repo Repository) Getter {
 	return &getter{repo: repo}
 }
 
-func (s *getter) GetByID(ctx context.Context, accountID, id string) (*Entity, error) {
+func (s *getter) GetByID(ctx context.Context, accountID, id string, opts QueryOptions) (*Entity, error) {
 	if id == "" {
 		return nil, ErrIDMissing
 	}
@@ -30,28 +41,133 @@ func (s *getter) GetByID(ctx context.Context, accountID, id string) (*Entity, er
 	if p.AccountID != accountID {
 		return nil, ErrInvalid
 	}
+	if p.DeletedAt != nil && !opts.IncludeDeleted {
+		return nil, ErrNotFound
+	}
+	if opts.Type != "" && p.Type != opts.Type {
+		return nil, ErrNotFound
+	}
 
 	return p, nil
 }
 
-func (s *getter) GetAll(ctx context.Context, accountId string, page, limit int) ([]*Entity, int, error) {
+func (s *getter) GetAll(ctx context.Context, accountId string, page, limit int, opts QueryOptions) ([]*Entity, int, error) {
 	if page < 1 || limit < 1 {
 		return nil, 0, ErrInvalidPaginationParameters
 	}
+	opts, err := checkSelection(opts)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	entities, count, err := s.repo.GetAll(ctx, accountId, page, limit)
+	var (
+		entities []*Entity
+		count    int
+	)
+	// Soft deleted entities are hidden by the repository, so that listing does not need query support
+	if selects(opts) || opts.Type != "" {
+		entities, count, err = s.repo.Find(ctx, accountId, optionsFilter(opts), opts.Sort, opts.Fields, page, limit)
+	} else {
+		entities, count, err = s.repo.GetAll(ctx, accountId, page, limit, opts.IncludeDeleted)
+	}
 	if err != nil {
 		return nil, 0, errors.WithStack(err)
 	}
 	return entities, count, nil
 }
 
-func (s *getter) Query(ctx context.Context, accountId string, query map[string]any, currentPage, perPage int) ([]*Entity, int, error) {
-	// TODO: business logic to query entities, e.g. check semantic and syntactic validity of query
+// Query returns a page of the entities matching a Mongo filter, restricted to the fields and operators
+// search criteria compile to.
+func (s *getter) Query(ctx context.Context, accountId string, query map[string]any, currentPage, perPage int, opts QueryOptions) ([]*Entity, int, error) {
+	if err := search.Validate(query); err != nil {
+		return nil, 0, err
+	}
+	opts, err := checkSelection(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if filter := optionsFilter(opts); len(filter) > 0 {
+		query = map[string]any{"$and": []any{query, filter}}
+	}
+	if selects(opts) {
+		return s.repo.Find(ctx, accountId, query, opts.Sort, opts.Fields, currentPage, perPage)
+	}
 	return s.repo.ExecuteQuery(ctx, accountId, query, currentPage, perPage)
 }
 
-func (s *getter) Pipeline(ctx context.Context, accountId string, pipeline map[string]any, currentPage, perPage int) ([]*Entity, int, error) {
+func (s *getter) Pipeline(ctx context.Context, accountId string, pipeline map[string]any, currentPage, perPage int, opts QueryOptions) ([]*Entity, int, error) {
 	// TODO: business logic to query entities, e.g. check semantic and syntactic validity of query
+	opts, err := checkSelection(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if expr := optionsExpr(opts); len(expr) > 0 {
+		pipeline = map[string]any{"$and": append([]any{pipeline}, expr...)}
+	}
+	if selects(opts) {
+		return s.repo.Find(ctx, accountId, map[string]any{"$expr": pipeline}, opts.Sort, opts.Fields, currentPage, perPage)
+	}
 	return s.repo.ExecutePipeline(ctx, accountId, pipeline, currentPage, perPage)
 }
+
+// getActive loads an entity of the account, hiding soft deleted ones.
+func getActive(ctx context.Context, repo Repository, accountID, id string) (*Entity, error) {
+	e, err := repo.GetByID(ctx, accountID, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if e.AccountID != accountID || e.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return e, nil
+}
+
+// optionsFilter returns the filter restricting a query to the entities selected by opts.
+func optionsFilter(opts QueryOptions) map[string]any {
+	filter := map[string]any{}
+	if !opts.IncludeDeleted {
+		filter = activeFilter()
+	}
+	if opts.Type != "" {
+		filter[typeKey] = opts.Type
+	}
+	return filter
+}
+
+// optionsExpr is optionsFilter as aggregation expressions.
+func optionsExpr(opts QueryOptions) []any {
+	var expr []any
+	if !opts.IncludeDeleted {
+		expr = append(expr, activeExpr())
+	}
+	if opts.Type != "" {
+		expr = append(expr, map[string]any{"$eq": []any{"$" + typeKey, opts.Type}})
+	}
+	return expr
+}
+
+// loadActive loads the active entities of the account with the given IDs, keyed by ID.
+func loadActive(ctx context.Context, repo Repository, accountID string, ids []string) (map[string]*Entity, error) {
+	existing := make(map[string]*Entity, len(ids))
+	for start := 0; start < len(ids); start += lookupSize {
+		end := min(start+lookupSize, len(ids))
+		entities, err := repo.GetByIDs(ctx, accountID, ids[start:end], false)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, e := range entities {
+			existing[e.ID] = e
+		}
+	}
+	return existing, nil
+}
+
+// activeFilter matches the entities that are not soft deleted.
+func activeFilter() map[string]any {
+	return map[string]any{deletedAtKey: nil}
+}
+
+// activeExpr is activeFilter as an aggregation expression.
+func activeExpr() map[string]any {
+	return map[string]any{"$eq": []any{map[string]any{"$ifNull": []any{"$" + deletedAtKey, nil}}, nil}}
+}
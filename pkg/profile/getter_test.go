@@ -0,0 +1,118 @@
+package profile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+// keyValueRepository is a Repository without query support, like Aerospike.
+type keyValueRepository struct {
+	Repository
+}
+
+func (r *keyValueRepository) ExecuteQuery(context.Context, string, map[string]any, int, int) ([]*Entity, int, error) {
+	return nil, 0, repository.ErrQueryNotSupported
+}
+
+func (r *keyValueRepository) Find(context.Context, string, map[string]any, []pkg.Sort, []string, int, int) ([]*Entity, int, error) {
+	return nil, 0, repository.ErrQueryNotSupported
+}
+
+func TestGetterWithoutQueries(t *testing.T) {
+	ctx := context.Background()
+	repo := &keyValueRepository{Repository: repository.NewMemoryRepository[*Entity]()}
+	deletedAt := time.Now()
+	active, err := repo.Upsert(ctx, "acc", &Entity{AccountID: "acc", Type: "Contact"})
+	require.NoError(t, err)
+	deleted, err := repo.Upsert(ctx, "acc", &Entity{AccountID: "acc", Type: "Contact", DeletedAt: &deletedAt})
+	require.NoError(t, err)
+	g := NewGetter(repo)
+
+	t.Run("lists the active entities", func(t *testing.T) {
+		entities, total, err := g.GetAll(ctx, "acc", 1, 10, QueryOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		require.Equal(t, active.ID, entities[0].ID)
+	})
+
+	t.Run("lists the deleted entities when included", func(t *testing.T) {
+		_, total, err := g.GetAll(ctx, "acc", 1, 10, QueryOptions{IncludeDeleted: true})
+		require.NoError(t, err)
+		require.Equal(t, 2, total)
+	})
+
+	t.Run("loads the active entities by ID", func(t *testing.T) {
+		found, err := loadActive(ctx, repo, "acc", []string{active.ID, deleted.ID})
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		require.Contains(t, found, active.ID)
+	})
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository[*Entity]()
+	deletedAt := time.Now()
+	for _, e := range []*Entity{
+		{AccountID: "acc", Type: "Contact", Attributes: Attribute{"city": "Rome", "age": 40}},
+		{AccountID: "acc", Type: "Contact", Attributes: Attribute{"city": "Rome", "age": 30}},
+		{AccountID: "acc", Type: "Store", Attributes: Attribute{"city": "Rome"}},
+		{AccountID: "acc", Type: "Contact", Attributes: Attribute{"city": "Rome"}, DeletedAt: &deletedAt},
+		{AccountID: "acc", Type: "Contact", Attributes: Attribute{"city": "Milan"}},
+	} {
+		_, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+	}
+	g := NewGetter(repo)
+	rome := map[string]any{"attributes.city": "Rome"}
+
+	tests := []struct {
+		it    string
+		query map[string]any
+		opts  QueryOptions
+		total int
+	}{
+		{it: "hides the deleted entities", query: rome, total: 3},
+		{it: "includes the deleted entities when asked", query: rome, opts: QueryOptions{IncludeDeleted: true}, total: 4},
+		{it: "restricts to a type", query: rome, opts: QueryOptions{Type: "Contact"}, total: 2},
+		{it: "matches every operator criteria compile to", query: map[string]any{"$and": []any{
+			rome,
+			map[string]any{"attributes.age": map[string]any{"$gte": 35}},
+		}}, total: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			_, total, err := g.Query(ctx, "acc", tt.query, 1, 10, tt.opts)
+			require.NoError(t, err)
+			require.Equal(t, tt.total, total)
+		})
+	}
+
+	t.Run("sorts and restricts the results", func(t *testing.T) {
+		opts := QueryOptions{Type: "Contact", Sort: []pkg.Sort{{Field: "attributes.age"}}, Fields: []string{"attributes.age"}}
+		entities, _, err := g.Query(ctx, "acc", rome, 1, 10, opts)
+		require.NoError(t, err)
+		require.Equal(t, []any{Attribute{"age": int32(30)}, Attribute{"age": int32(40)}}, []any{entities[0].Attributes, entities[1].Attributes})
+		require.Empty(t, entities[0].Type)
+	})
+
+	rejected := []struct {
+		it    string
+		query map[string]any
+	}{
+		{it: "rejects server side code", query: map[string]any{"$where": "sleep(1000)"}},
+		{it: "rejects fields outside the entity", query: map[string]any{"accountId": "other"}},
+		{it: "rejects operators as fields", query: map[string]any{"attributes.$where": "true"}},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.it, func(t *testing.T) {
+			_, _, err := g.Query(ctx, "acc", tt.query, 1, 10, QueryOptions{})
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package profile
+
+import (
+	"context"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/pkg/errors"
+)
+
+const (
+	// entityIDKey is the revision field holding the ID of the revised entity.
+	entityIDKey = "entityId"
+	// revisionVersionKey is the revision field holding the version of the entity it records.
+	revisionVersionKey = "version"
+	// HistoryIndex is the name of the index of the history repository serving History and AsOf.
+	HistoryIndex = "accountId_entityId_version"
+)
+
+// historyFields are the fields indexed by HistoryIndex, after the account ID.
+var historyFields = []string{entityIDKey, revisionVersionKey}
+
+// EnsureHistoryIndexes creates, unless they exist, the indexes of the history repository.
+func EnsureHistoryIndexes(ctx context.Context, history HistoryRepository) error {
+	return history.EnsureIndex(ctx, HistoryIndex, historyFields)
+}
+
+// historian implements the entity history service on top of the revisions written by the recorder.
+type historian struct {
+	repo    Repository
+	history HistoryRepository
+	saver   Saver
+}
+
+// NewHistorian creates the history service. Reverts are written through saver, so that the restored
+// entity is validated like an update.
+func NewHistorian(repo Repository, history HistoryRepository, saver Saver) *historian {
+	return &historian{repo: repo, history: history, saver: saver}
+}
+
+// History returns the revisions of an entity, oldest first.
+func (s *historian) History(ctx context.Context, accountID, id string, currentPage, perPage int) ([]*Revision, int, error) {
+	if accountID == "" {
+		return nil, 0, ErrAccountIDMissing
+	}
+	if id == "" {
+		return nil, 0, ErrIDMissing
+	}
+	if currentPage < 1 || perPage < 1 {
+		return nil, 0, ErrInvalidPaginationParameters
+	}
+
+	revisions, count, err := s.history.Find(
+		ctx, accountID, map[string]any{entityIDKey: id}, []pkg.Sort{{Field: revisionVersionKey}}, nil, currentPage, perPage,
+	)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return revisions, count, nil
+}
+
+// AsOf reconstructs the entity as it was at the given time, from the last revision written before it.
+func (s *historian) AsOf(ctx context.Context, accountID, id string, at time.Time) (*Entity, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if id == "" {
+		return nil, ErrIDMissing
+	}
+
+	query := map[string]any{entityIDKey: id, "createdAt": map[string]any{"$lte": at}}
+	latest := []pkg.Sort{{Field: revisionVersionKey, Desc: true}}
+	revisions, _, err := s.history.Find(ctx, accountID, query, latest, nil, 1, 1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(revisions) == 0 || revisions[0].Snapshot == nil || revisions[0].Snapshot.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return revisions[0].Snapshot, nil
+}
+
+// Revert brings the attributes, metadata and relationships of an entity back to those of a past version.
+// The revert is itself a new version, so it can be reverted as well. It is checked like an update, against
+// the current schema and relationship types.
+func (s *historian) Revert(ctx context.Context, accountID, id string, version, toVersion int64) (*Entity, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if id == "" {
+		return nil, ErrIDMissing
+	}
+	if toVersion < 1 {
+		return nil, ErrInvalidRevision
+	}
+
+	e, err := getActive(ctx, s.repo, accountID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkVersion(e, version); err != nil {
+		return nil, err
+	}
+
+	query := map[string]any{entityIDKey: id, revisionVersionKey: toVersion}
+	revisions, _, err := s.history.Find(ctx, accountID, query, nil, nil, 1, 1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(revisions) == 0 || revisions[0].Snapshot == nil {
+		return nil, ErrRevisionNotFound
+	}
+
+	past := revisions[0].Snapshot
+	e.Metadata = past.Metadata
+	e.Attributes = past.Attributes
+	e.Relationships = past.Relationships
+	e.Version = version
+	return s.saver.Update(withOperation(ctx, OperationRevert), accountID, id, e)
+}
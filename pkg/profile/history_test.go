@@ -0,0 +1,99 @@
+package profile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/pkg/relationship"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHistory is a HistoryRepository counting the writes of revisions.
+type countingHistory struct {
+	HistoryRepository
+	upserts, bulkWrites int
+}
+
+func (h *countingHistory) Upsert(ctx context.Context, accountID string, revision *Revision) (*Revision, error) {
+	h.upserts++
+	return h.HistoryRepository.Upsert(ctx, accountID, revision)
+}
+
+func (h *countingHistory) BulkWrite(ctx context.Context, accountID string, upserts []*Revision, deleteIDs []string) ([]error, error) {
+	h.bulkWrites++
+	return h.HistoryRepository.BulkWrite(ctx, accountID, upserts, deleteIDs)
+}
+
+func TestHistorian(t *testing.T) {
+	ctx := context.Background()
+	history := &countingHistory{HistoryRepository: repository.NewMemoryRepository[*Revision]()}
+	repo := NewRecorder(repository.NewMemoryRepository[*Entity](), history)
+	types := relationship.NewGetter(repository.NewMemoryRepository[*relationship.Definition]())
+	saver := NewSaver(repo, &typeValidator{loads: map[string]int{}}, types)
+	h := NewHistorian(repo, history, saver)
+
+	// Version 1 predates the name being required
+	e, err := repo.Upsert(ctx, "acc", &Entity{AccountID: "acc", Type: "Contact", Attributes: Attribute{"city": "Rome"}})
+	require.NoError(t, err)
+	for _, name := range []string{"Ana", "Anna"} {
+		e, err = saver.Update(ctx, "acc", e.ID, &Entity{Type: "Contact", Attributes: Attribute{"name": name}, Version: e.Version})
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(3), e.Version)
+
+	t.Run("lists the revisions by version", func(t *testing.T) {
+		revisions, total, err := h.History(ctx, "acc", e.ID, 2, 2)
+		require.NoError(t, err)
+		require.Equal(t, 3, total)
+		require.Len(t, revisions, 1)
+		require.Equal(t, int64(3), revisions[0].Version)
+	})
+
+	t.Run("reconstructs the latest version written before a time", func(t *testing.T) {
+		past, err := h.AsOf(ctx, "acc", e.ID, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, int64(3), past.Version)
+
+		_, err = h.AsOf(ctx, "acc", e.ID, time.Now().Add(-time.Hour))
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("validates a revert like an update", func(t *testing.T) {
+		_, err := h.Revert(ctx, "acc", e.ID, 3, 1)
+		require.ErrorIs(t, err, ErrInvalid)
+
+		reverted, err := h.Revert(ctx, "acc", e.ID, 3, 2)
+		require.NoError(t, err)
+		require.Equal(t, int64(4), reverted.Version)
+		require.Equal(t, "Ana", reverted.Attributes["name"])
+
+		revisions, _, err := h.History(ctx, "acc", e.ID, 1, 10)
+		require.NoError(t, err)
+		require.Equal(t, OperationRevert, revisions[3].Operation)
+	})
+
+	t.Run("rejects a revert of a stale version", func(t *testing.T) {
+		_, err := h.Revert(ctx, "acc", e.ID, 3, 2)
+		require.ErrorIs(t, err, ErrVersionMismatch)
+	})
+
+	t.Run("records a bulk write with a single write of revisions", func(t *testing.T) {
+		upserts := []*Entity{
+			{AccountID: "acc", Type: "Contact", Attributes: Attribute{"name": "Bob"}},
+			{AccountID: "acc", Type: "Contact", Attributes: Attribute{"name": "Eve"}},
+		}
+		upserted := history.upserts
+		errs, err := repo.BulkWrite(ctx, "acc", upserts, nil)
+		require.NoError(t, err)
+		require.Equal(t, []error{nil, nil}, errs)
+		require.Equal(t, 1, history.bulkWrites)
+		require.Equal(t, upserted, history.upserts)
+
+		revisions, _, err := h.History(ctx, "acc", upserts[1].ID, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+		require.Equal(t, OperationCreate, revisions[0].Operation)
+	})
+}
@@ -0,0 +1,70 @@
+package profile
+
+import "context"
+
+// searchPathsKey is the document field holding the search fields an entity was indexed with.
+const searchPathsKey = "searchPaths"
+
+// indexer is a Repository decorator setting the search fields of the written entities, so that only
+// those are indexed for free-text search.
+type indexer struct {
+	Repository
+	fields SearchFieldsRepository
+}
+
+// NewIndexer wraps repo so that every written entity is indexed with the search fields configured for
+// its type.
+func NewIndexer(repo Repository, fields SearchFieldsRepository) Repository {
+	return &indexer{Repository: repo, fields: fields}
+}
+
+func (r *indexer) Upsert(ctx context.Context, accountID string, entity *Entity) (*Entity, error) {
+	paths, err := r.paths(ctx, accountID, entity.Type)
+	if err != nil {
+		return nil, err
+	}
+	entity.SearchPaths = paths
+	return r.Repository.Upsert(ctx, accountID, entity)
+}
+
+func (r *indexer) BulkWrite(ctx context.Context, accountID string, upserts []*Entity, deleteIDs []string) ([]error, error) {
+	byType := map[string][]string{}
+	for _, e := range upserts {
+		paths, ok := byType[e.Type]
+		if !ok {
+			var err error
+			if paths, err = r.paths(ctx, accountID, e.Type); err != nil {
+				return nil, err
+			}
+			byType[e.Type] = paths
+		}
+		e.SearchPaths = paths
+	}
+	return r.Repository.BulkWrite(ctx, accountID, upserts, deleteIDs)
+}
+
+// Patch indexes the entity with the search fields of its new type when the patch changes it.
+func (r *indexer) Patch(ctx context.Context, accountID, id string, version int64, set map[string]any, unset []string) (*Entity, error) {
+	if entityType, ok := set[typeKey].(string); ok {
+		paths, err := r.paths(ctx, accountID, entityType)
+		if err != nil {
+			return nil, err
+		}
+		withPaths := make(map[string]any, len(set)+1)
+		for k, v := range set {
+			withPaths[k] = v
+		}
+		withPaths[searchPathsKey] = paths
+		set = withPaths
+	}
+	return r.Repository.Patch(ctx, accountID, id, version, set, unset)
+}
+
+// paths returns the fields searched for an entity type.
+func (r *indexer) paths(ctx context.Context, accountID, entityType string) ([]string, error) {
+	fields, err := searchFields(ctx, r.fields, accountID, entityType)
+	if err != nil {
+		return nil, err
+	}
+	return fields.Fields, nil
+}
@@ -0,0 +1,197 @@
+package profile
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// referencesPageSize is the page size used when scanning the entities holding relationships.
+const referencesPageSize = 100
+
+// relationshipTargetKey is the document field holding the targets of the relationships of an entity.
+const relationshipTargetKey = "relationships.targetId"
+
+// checker implements the consistency check of the relationships of an account.
+type checker struct {
+	repo Repository
+}
+
+func NewChecker(repo Repository) *checker {
+	return &checker{repo: repo}
+}
+
+// DanglingReferences reports the relationships of the active entities of an account whose target is
+// missing, soft deleted or the entity itself, ordered by entity and target.
+func (s *checker) DanglingReferences(ctx context.Context, accountID string) ([]DanglingReference, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+
+	query := map[string]any{relationshipTargetKey: map[string]any{"$exists": true}, deletedAtKey: nil}
+	owners, err := scan(ctx, s.repo, accountID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := loadActive(ctx, s.repo, accountID, relationshipTargets(owners...))
+	if err != nil {
+		return nil, err
+	}
+
+	var dangling []DanglingReference
+	missing := map[string]bool{}
+	for _, owner := range owners {
+		for _, r := range owner.Relationships {
+			ref := DanglingReference{EntityID: owner.ID, Type: r.Type, TargetID: r.TargetID}
+			switch {
+			case r.TargetID == owner.ID:
+				ref.Reason = DanglingSelf
+			case active[r.TargetID] == nil:
+				ref.Reason = DanglingMissing
+				missing[r.TargetID] = true
+			default:
+				continue
+			}
+			dangling = append(dangling, ref)
+		}
+	}
+
+	// Targets not found among the active entities may still be soft deleted.
+	deleted := map[string]bool{}
+	if len(missing) > 0 {
+		ids := make([]string, 0, len(missing))
+		for id := range missing {
+			ids = append(ids, id)
+		}
+		found, err := s.repo.GetByIDs(ctx, accountID, ids, true)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, e := range found {
+			if e.AccountID == accountID && e.DeletedAt != nil {
+				deleted[e.ID] = true
+			}
+		}
+	}
+	for i := range dangling {
+		if dangling[i].Reason == DanglingMissing && deleted[dangling[i].TargetID] {
+			dangling[i].Reason = DanglingDeleted
+		}
+	}
+
+	sort.SliceStable(dangling, func(i, j int) bool {
+		if dangling[i].EntityID != dangling[j].EntityID {
+			return dangling[i].EntityID < dangling[j].EntityID
+		}
+		return dangling[i].TargetID < dangling[j].TargetID
+	})
+	return dangling, nil
+}
+
+// checkTargets verifies the relationships of an entity point at other active entities of the account,
+// returning the targets.
+func checkTargets(ctx context.Context, repo Repository, accountID string, e *Entity) (map[string]*Entity, error) {
+	active, err := loadActive(ctx, repo, accountID, relationshipTargets(e))
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyTargets(e, active); err != nil {
+		return nil, err
+	}
+	return active, nil
+}
+
+// verifyTargets checks the relationships of an entity against the active entities of its account.
+func verifyTargets(e *Entity, active map[string]*Entity) error {
+	for _, r := range e.Relationships {
+		if r.TargetID == "" {
+			return ErrRelationshipTargetMissing
+		}
+		if e.ID != "" && r.TargetID == e.ID {
+			return ErrRelationshipSelf
+		}
+		if active[r.TargetID] == nil {
+			return errors.Wrap(ErrRelationshipTargetNotFound, r.TargetID)
+		}
+	}
+	return nil
+}
+
+// relationshipTargets returns the distinct targets of the relationships of the given entities.
+func relationshipTargets(entities ...*Entity) []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, e := range entities {
+		if e == nil {
+			continue
+		}
+		for _, r := range e.Relationships {
+			if r.TargetID != "" && !seen[r.TargetID] {
+				seen[r.TargetID] = true
+				ids = append(ids, r.TargetID)
+			}
+		}
+	}
+	return ids
+}
+
+// referencing loads every entity of the account holding a relationship to one of the given IDs.
+func referencing(ctx context.Context, repo Repository, accountID string, ids []string) ([]*Entity, error) {
+	return scan(ctx, repo, accountID, map[string]any{relationshipTargetKey: map[string]any{"$in": ids}})
+}
+
+// scan loads every entity of the account matching query, by pages of referencesPageSize in ID order, each
+// page following the last entity read. Unlike skipping the entities of the previous pages, writes made
+// meanwhile cannot shift the pages, so that no entity is skipped or loaded twice.
+func scan(ctx context.Context, repo Repository, accountID string, query map[string]any) ([]*Entity, error) {
+	var found []*Entity
+	after := ""
+	for {
+		entities, err := repo.Seek(ctx, accountID, query, nil, nil, after, "", referencesPageSize)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		found = append(found, entities...)
+		if len(entities) < referencesPageSize {
+			return found, nil
+		}
+		after = entities[len(entities)-1].ID
+	}
+}
+
+// isReferenced reports whether an active entity of the account holds a relationship to id.
+func isReferenced(ctx context.Context, repo Repository, accountID, id string) (bool, error) {
+	query := map[string]any{relationshipTargetKey: id, deletedAtKey: nil, "id": map[string]any{"$ne": id}}
+	_, count, err := repo.ExecuteQuery(ctx, accountID, query, 1, 1)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return count > 0, nil
+}
+
+// removeReferences drops the relationships to id from every other entity of the account holding one. Those
+// of the entity itself go along with it.
+func removeReferences(ctx context.Context, repo Repository, accountID, id string) error {
+	owners, err := referencing(ctx, repo, accountID, []string{id})
+	if err != nil {
+		return err
+	}
+	for _, owner := range owners {
+		if owner.ID == id {
+			continue
+		}
+		kept := owner.Relationships[:0]
+		for _, r := range owner.Relationships {
+			if r.TargetID != id {
+				kept = append(kept, r)
+			}
+		}
+		owner.Relationships = kept
+		if _, err = repo.Upsert(ctx, accountID, owner); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package profile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrity(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository[*Entity]()
+	upsert := func(e *Entity) *Entity {
+		e.AccountID = "acc"
+		e, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+		return e
+	}
+
+	store := upsert(&Entity{Type: "Store"})
+	gone := upsert(&Entity{Type: "Store"})
+	contact := upsert(&Entity{Type: "Contact", Relationships: []Relationship{
+		{Type: "buysFrom", TargetID: store.ID},
+		{Type: "buysFrom", TargetID: gone.ID},
+		{Type: "knows", TargetID: "65a000000000000000000000"},
+	}})
+	require.NoError(t, NewDeleter(repo, OnDeleteIgnore).Delete(ctx, "acc", gone.ID, 0))
+
+	t.Run("reports missing and deleted targets", func(t *testing.T) {
+		dangling, err := NewChecker(repo).DanglingReferences(ctx, "acc")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []DanglingReference{
+			{EntityID: contact.ID, Type: "buysFrom", TargetID: gone.ID, Reason: DanglingDeleted},
+			{EntityID: contact.ID, Type: "knows", TargetID: "65a000000000000000000000", Reason: DanglingMissing},
+		}, dangling)
+	})
+
+	t.Run("restricts deleting a referenced entity", func(t *testing.T) {
+		err := NewDeleter(repo, OnDeleteRestrict).Delete(ctx, "acc", store.ID, 0)
+		require.ErrorIs(t, err, ErrReferenced)
+	})
+
+	t.Run("cascades the deletion to the relationships", func(t *testing.T) {
+		require.NoError(t, NewDeleter(repo, OnDeleteCascade).Delete(ctx, "acc", store.ID, 0))
+
+		e, err := repo.GetByID(ctx, "acc", contact.ID)
+		require.NoError(t, err)
+		require.Len(t, e.Relationships, 2)
+		for _, r := range e.Relationships {
+			require.NotEqual(t, store.ID, r.TargetID)
+		}
+	})
+}
+
+// countingLookups is a Repository counting the lookups of entities by ID.
+type countingLookups struct {
+	Repository
+	lookups int
+}
+
+func (r *countingLookups) GetByID(ctx context.Context, accountID, id string) (*Entity, error) {
+	r.lookups++
+	return r.Repository.GetByID(ctx, accountID, id)
+}
+
+func (r *countingLookups) GetByIDs(ctx context.Context, accountID string, ids []string, includeDeleted bool) ([]*Entity, error) {
+	r.lookups++
+	return r.Repository.GetByIDs(ctx, accountID, ids, includeDeleted)
+}
+
+func TestDanglingReferencesPages(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingLookups{Repository: repository.NewMemoryRepository[*Entity]()}
+	gone, err := repo.Upsert(ctx, "acc", &Entity{AccountID: "acc", Type: "Store"})
+	require.NoError(t, err)
+	owners := 2*referencesPageSize + 1
+	for i := 0; i < owners; i++ {
+		_, err = repo.Upsert(ctx, "acc", &Entity{AccountID: "acc", Type: "Contact", Relationships: []Relationship{
+			{Type: "buysFrom", TargetID: gone.ID},
+			{Type: "knows", TargetID: "65a000000000000000000000"},
+		}})
+		require.NoError(t, err)
+	}
+	require.NoError(t, NewDeleter(repo, OnDeleteIgnore).Delete(ctx, "acc", gone.ID, 0))
+	repo.lookups = 0
+
+	dangling, err := NewChecker(repo).DanglingReferences(ctx, "acc")
+	require.NoError(t, err)
+	require.Len(t, dangling, 2*owners)
+	seen := map[string]bool{}
+	for _, ref := range dangling {
+		seen[ref.EntityID] = true
+	}
+	require.Len(t, seen, owners, "every owner is reported once")
+	require.Equal(t, 2, repo.lookups, "the active targets, then the missing ones, are loaded at once")
+}
@@ -0,0 +1,279 @@
+package profile
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	// nameSimilarityFloor is the Jaro-Winkler similarity under which two names are considered different.
+	nameSimilarityFloor = 0.85
+	// minPhoneDigits is the number of trailing digits two phone numbers must share when one of them lacks
+	// the country or area code.
+	minPhoneDigits = 7
+	// relationshipsField names the relationships of an entity in a MatchRule.
+	relationshipsField = "relationships"
+)
+
+// DefaultMatchRules returns the rules used for the entity types without configured ones.
+func DefaultMatchRules(entityType string) *MatchRules {
+	return &MatchRules{
+		EntityType: entityType,
+		Rules: []MatchRule{
+			{Field: "attributes.email", Comparator: MatchEmail, Weight: 0.9},
+			{Field: "attributes.phone", Comparator: MatchPhone, Weight: 0.8},
+			{Field: "attributes.name", Comparator: MatchName, Weight: 0.5},
+			{Field: relationshipsField, Comparator: MatchRelationships, Weight: 0.3},
+		},
+		Threshold: 0.6,
+	}
+}
+
+// validateMatchRules checks the comparators, weights and threshold of the rules.
+func validateMatchRules(rules *MatchRules) error {
+	if rules == nil || len(rules.Rules) == 0 {
+		return ErrInvalidMatchRules
+	}
+	if rules.Threshold <= 0 || rules.Threshold > 1 {
+		return ErrInvalidMatchRules
+	}
+	for i, r := range rules.Rules {
+		if r.Weight <= 0 || r.Weight > 1 {
+			return ErrInvalidMatchRules
+		}
+		switch r.Comparator {
+		case MatchExact, MatchEmail, MatchPhone, MatchName:
+			if !strings.HasPrefix(r.Field, "attributes.") && !strings.HasPrefix(r.Field, "metadata.") {
+				return ErrInvalidMatchRules
+			}
+		case MatchRelationships:
+			rules.Rules[i].Field = relationshipsField
+		default:
+			return ErrInvalidMatchRules
+		}
+	}
+	return nil
+}
+
+// score rates how likely two entities are the same one. Each rule matching on both entities contributes
+// its weight scaled by the similarity of their values, and the contributions combine as independent
+// evidence: 1 - Π(1 - weight × similarity). It also returns the fields of the matching rules.
+func score(rules []MatchRule, a, b *Entity) (float64, []string) {
+	remaining := 1.0
+	var reasons []string
+	for _, r := range rules {
+		similarity := compare(r, a, b)
+		if similarity <= 0 {
+			continue
+		}
+		remaining *= 1 - r.Weight*similarity
+		reasons = append(reasons, r.Field)
+	}
+	return math.Round((1-remaining)*1000) / 1000, reasons
+}
+
+// compare returns the similarity, from 0 to 1, of the values two entities hold for a rule.
+func compare(r MatchRule, a, b *Entity) float64 {
+	if r.Comparator == MatchRelationships {
+		return jaccard(relationshipTargets(a), relationshipTargets(b))
+	}
+
+	x, y := matchValue(a, r.Field), matchValue(b, r.Field)
+	if x == nil || y == nil {
+		return 0
+	}
+	switch r.Comparator {
+	case MatchEmail:
+		return same(normalizeEmail(x), normalizeEmail(y))
+	case MatchPhone:
+		p, q := normalizePhone(x), normalizePhone(y)
+		if p == "" || q == "" {
+			return 0
+		}
+		if len(p) > len(q) {
+			p, q = q, p
+		}
+		if len(p) >= minPhoneDigits && strings.HasSuffix(q, p) {
+			return 1
+		}
+		return 0
+	case MatchName:
+		similarity := jaroWinkler(normalizeName(x), normalizeName(y))
+		if similarity < nameSimilarityFloor {
+			return 0
+		}
+		return similarity
+	default:
+		return same(fmt.Sprint(x), fmt.Sprint(y))
+	}
+}
+
+// blockingKeys returns the keys under which an entity is indexed to find its candidate duplicates, only
+// entities sharing a key being compared.
+func blockingKeys(rules []MatchRule, e *Entity) []string {
+	var keys []string
+	for i, r := range rules {
+		prefix := fmt.Sprintf("%d:", i)
+		if r.Comparator == MatchRelationships {
+			for _, id := range relationshipTargets(e) {
+				keys = append(keys, prefix+id)
+			}
+			continue
+		}
+
+		v := matchValue(e, r.Field)
+		if v == nil {
+			continue
+		}
+		switch r.Comparator {
+		case MatchEmail:
+			if email := normalizeEmail(v); email != "" {
+				keys = append(keys, prefix+email)
+			}
+		case MatchPhone:
+			if phone := normalizePhone(v); len(phone) >= minPhoneDigits {
+				keys = append(keys, prefix+phone[len(phone)-minPhoneDigits:])
+			}
+		case MatchName:
+			for _, token := range strings.Fields(normalizeName(v)) {
+				keys = append(keys, prefix+string([]rune(token)[:min(3, len([]rune(token)))]))
+			}
+		default:
+			keys = append(keys, prefix+fmt.Sprint(v))
+		}
+	}
+	return keys
+}
+
+// matchValue returns the value at a dotted attributes or metadata path of an entity, nil if absent.
+func matchValue(e *Entity, field string) any {
+	path := strings.Split(field, ".")
+	var current any
+	switch path[0] {
+	case "attributes":
+		current = map[string]any(e.Attributes)
+	case "metadata":
+		current = map[string]any(e.Metadata)
+	default:
+		return nil
+	}
+	for _, key := range path[1:] {
+		m, ok := asMap(current)
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
+
+func same(x, y string) float64 {
+	if x != "" && x == y {
+		return 1
+	}
+	return 0
+}
+
+func normalizeEmail(v any) string {
+	s, _ := v.(string)
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// normalizePhone keeps the digits of a phone number, dropping an international 00 prefix.
+func normalizePhone(v any) string {
+	var b strings.Builder
+	for _, r := range fmt.Sprint(v) {
+		if unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimPrefix(b.String(), "00")
+}
+
+// normalizeName lowercases a name and reduces it to its letters and digits separated by single spaces.
+func normalizeName(v any) string {
+	s, _ := v.(string)
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// jaccard returns the share of the distinct values of a and b found in both.
+func jaccard(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := map[string]bool{}
+	for _, v := range a {
+		set[v] = true
+	}
+	union := len(set)
+	shared := 0
+	seen := map[string]bool{}
+	for _, v := range b {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		if set[v] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	return float64(shared) / float64(union)
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of two strings, from 0 to 1.
+func jaroWinkler(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 || len(t) == 0 {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	window := max(len(s), len(t))/2 - 1
+	sMatched := make([]bool, len(s))
+	tMatched := make([]bool, len(t))
+	matches := 0
+	for i := range s {
+		for j := max(0, i-window); j < min(len(t), i+window+1); j++ {
+			if !tMatched[j] && s[i] == t[j] {
+				sMatched[i], tMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range s {
+		if !sMatched[i] {
+			continue
+		}
+		for !tMatched[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions/2))/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(s), len(t)) && s[prefix] == t[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
@@ -0,0 +1,365 @@
+package profile
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	errstack "github.com/pkg/errors"
+)
+
+// merger implements the entity merge service.
+type merger struct {
+	repo      Repository
+	validator Validator
+	types     RelationshipTypes
+	deleter   Deleter
+}
+
+func NewMerger(repo Repository, validator Validator, types RelationshipTypes, deleter Deleter) *merger {
+	return &merger{repo: repo, validator: validator, types: types, deleter: deleter}
+}
+
+// Merge folds the source entities into the target: attributes and metadata are combined using the
+// requested strategy, relationships pointing at a source are re-pointed to the target and the
+// sources are deleted like through the deleter, after being marked as merged when tombstoned.
+// The target and the re-pointed entities are checked like through the saver, against the schema and
+// the relationship types, before anything is written. A write failing afterwards, e.g. on a concurrent
+// edit reported as ErrConflict, leaves the writes done so far: the target merged and some of the
+// references re-pointed or sources deleted. Merging the remaining sources again completes the merge.
+func (s *merger) Merge(ctx context.Context, accountID string, criteria MergeCriteria) (*Entity, error) {
+	if err := validateMergeCriteria(accountID, &criteria); err != nil {
+		return nil, err
+	}
+	ctx = withOperation(ctx, OperationMerge)
+
+	target, err := getActive(ctx, s.repo, accountID, criteria.TargetID)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]*Entity, 0, len(criteria.SourceIDs))
+	for _, id := range criteria.SourceIDs {
+		source, err := getActive(ctx, s.repo, accountID, id)
+		if err != nil {
+			return nil, err
+		}
+		if source.Type != target.Type {
+			return nil, ErrMergeTypeMismatch
+		}
+		sources = append(sources, source)
+	}
+
+	// The relationships moved from the sources keep their ID and creation time
+	previous := slices.Clone(target.Relationships)
+	held := slices.Clone(previous)
+	merged := map[string]bool{}
+	for _, source := range sources {
+		held = append(held, source.Relationships...)
+		merged[source.ID] = true
+	}
+	mergeEntities(target, sources, criteria.Strategy)
+	repoint(target, merged, target.ID)
+	if err = s.validator.Validate(ctx, accountID, target.Type, target.Attributes); err != nil {
+		return nil, err
+	}
+	if err = s.checkRelationships(ctx, accountID, target, previous, criteria.SourceIDs); err != nil {
+		return nil, err
+	}
+	stampRelationships(target, held)
+
+	owners, err := s.repointReferences(ctx, accountID, criteria.SourceIDs, merged, target.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if target, err = upsert(ctx, s.repo, accountID, target); err != nil {
+		return nil, err
+	}
+	for _, owner := range owners {
+		if _, err = upsert(ctx, s.repo, accountID, owner); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, source := range sources {
+		if criteria.SourceAction == MergeTombstoneSources {
+			source.MergedInto = target.ID
+			source.Relationships = nil
+			if source, err = upsert(ctx, s.repo, accountID, source); err != nil {
+				return nil, err
+			}
+		}
+		if err = s.deleter.Delete(ctx, accountID, source.ID, source.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	return target, nil
+}
+
+// repointReferences re-points to the target the relationships of every other entity targeting one of the
+// merged sources, and returns those entities checked and ready to be written. Deleted entities are only
+// re-pointed.
+func (s *merger) repointReferences(ctx context.Context, accountID string, sourceIDs []string, merged map[string]bool, targetID string) ([]*Entity, error) {
+	found, err := referencing(ctx, s.repo, accountID, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var owners []*Entity
+	for _, e := range found {
+		if e.ID == targetID || merged[e.ID] {
+			continue
+		}
+		previous := slices.Clone(e.Relationships)
+		repoint(e, merged, targetID)
+		if e.DeletedAt == nil {
+			if err = s.checkRelationships(ctx, accountID, e, previous, sourceIDs); err != nil {
+				return nil, err
+			}
+			stampRelationships(e, previous)
+		}
+		owners = append(owners, e)
+	}
+	if err = s.checkClaims(ctx, accountID, owners, targetID); err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+// checkRelationships verifies the relationships of a merged or re-pointed entity like the saver does, the
+// relationships held by the merged sources not counting against the cardinalities since the sources go.
+func (s *merger) checkRelationships(ctx context.Context, accountID string, e *Entity, previous []Relationship, sourceIDs []string) error {
+	active, err := checkTargets(ctx, s.repo, accountID, e)
+	if err != nil {
+		return err
+	}
+	return checkRules(ctx, s.repo, s.types, accountID, e, previous, active, sourceIDs...)
+}
+
+// checkClaims verifies that no two active re-pointed entities now relate to the target through a
+// relationship type allowing a single source, which the checks against the stored entities cannot see.
+func (s *merger) checkClaims(ctx context.Context, accountID string, owners []*Entity, targetID string) error {
+	var relationships []Relationship
+	holders := map[string]int{}
+	for _, e := range owners {
+		if e.DeletedAt != nil {
+			continue
+		}
+		for _, r := range e.Relationships {
+			if r.TargetID == targetID {
+				relationships = append(relationships, r)
+				holders[r.Type]++
+			}
+		}
+	}
+	definitions, err := definitions(ctx, s.types, accountID, relationships)
+	if err != nil {
+		return err
+	}
+	for relationshipType, n := range holders {
+		if d := definitions[relationshipType]; d != nil && d.SingleSource() && n > 1 {
+			return errstack.Wrap(ErrRelationshipCardinality, relationshipType)
+		}
+	}
+	return nil
+}
+
+func validateMergeCriteria(accountID string, criteria *MergeCriteria) error {
+	if accountID == "" {
+		return ErrAccountIDMissing
+	}
+	if criteria.TargetID == "" {
+		return ErrMergeTargetMissing
+	}
+	if len(criteria.SourceIDs) == 0 {
+		return ErrMergeSourcesMissing
+	}
+
+	seen := map[string]bool{}
+	ids := make([]string, 0, len(criteria.SourceIDs))
+	for _, id := range criteria.SourceIDs {
+		if id == "" {
+			return ErrMergeSourcesMissing
+		}
+		if id == criteria.TargetID {
+			return ErrMergeTargetInSources
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	criteria.SourceIDs = ids
+
+	switch criteria.Strategy {
+	case "":
+		criteria.Strategy = MergeTargetWins
+	case MergeTargetWins, MergeNewestWins, MergeUnionArrays:
+	default:
+		return ErrMergeInvalidStrategy
+	}
+
+	switch criteria.SourceAction {
+	case "":
+		criteria.SourceAction = MergeDeleteSources
+	case MergeDeleteSources, MergeTombstoneSources:
+	default:
+		return ErrMergeInvalidSourceAction
+	}
+	return nil
+}
+
+// mergeEntities folds the sources into target. With MergeNewestWins, each value is taken from the most
+// recently modified entity holding it: the entities are folded oldest first, each one overriding the
+// values of the previous ones.
+func mergeEntities(target *Entity, sources []*Entity, strategy string) {
+	if strategy == MergeNewestWins {
+		ordered := append([]*Entity{target}, sources...)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return lastModified(ordered[i]).Before(lastModified(ordered[j]))
+		})
+		var attributes, metadata map[string]any
+		for _, e := range ordered {
+			attributes = mergeMaps(attributes, e.Attributes, true, false)
+			metadata = mergeMaps(metadata, e.Metadata, true, false)
+		}
+		target.Attributes, target.Metadata = attributes, metadata
+	}
+
+	union := strategy == MergeUnionArrays
+	for _, source := range sources {
+		if strategy != MergeNewestWins {
+			target.Attributes = mergeMaps(target.Attributes, source.Attributes, false, union)
+			target.Metadata = mergeMaps(target.Metadata, source.Metadata, false, union)
+		}
+		for _, r := range source.Relationships {
+			target.Add(r)
+		}
+		target.MergedIDs = appendUnique(target.MergedIDs, source.ID)
+		target.MergedIDs = appendUnique(target.MergedIDs, source.MergedIDs...)
+	}
+}
+
+// mergeMaps merges src into dst recursively, resolving conflicts on scalar values according to sourceWins
+// and unioning arrays when union is set. The maps of src are copied, so that merging another source
+// never changes src.
+func mergeMaps(dst, src map[string]any, sourceWins, union bool) map[string]any {
+	if dst == nil {
+		dst = map[string]any{}
+	}
+	for k, sv := range src {
+		if sm, ok := asMap(sv); ok {
+			sv = mergeMaps(nil, sm, sourceWins, union)
+		}
+		dv, ok := dst[k]
+		if !ok {
+			dst[k] = sv
+			continue
+		}
+
+		dm, dIsMap := asMap(dv)
+		sm, sIsMap := asMap(sv)
+		if dIsMap && sIsMap {
+			dst[k] = mergeMaps(dm, sm, sourceWins, union)
+			continue
+		}
+
+		da, dIsArray := asArray(dv)
+		sa, sIsArray := asArray(sv)
+		if union && dIsArray && sIsArray {
+			dst[k] = unionArrays(da, sa)
+			continue
+		}
+
+		if sourceWins {
+			dst[k] = sv
+		}
+	}
+	return dst
+}
+
+var (
+	mapType   = reflect.TypeOf(map[string]any(nil))
+	arrayType = reflect.TypeOf([]any(nil))
+)
+
+// asMap returns v as a plain map, accepting named map types such as Attribute or decoded BSON documents.
+func asMap(v any) (map[string]any, bool) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !rv.Type().ConvertibleTo(mapType) || rv.Kind() != reflect.Map {
+		return nil, false
+	}
+	return rv.Convert(mapType).Interface().(map[string]any), true
+}
+
+// asArray returns v as a plain slice, accepting named slice types such as decoded BSON arrays.
+func asArray(v any) ([]any, bool) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !rv.Type().ConvertibleTo(arrayType) || rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	return rv.Convert(arrayType).Interface().([]any), true
+}
+
+func unionArrays(dst, src []any) []any {
+	dst = slices.Clip(dst)
+	for _, sv := range src {
+		found := false
+		for _, dv := range dst {
+			if reflect.DeepEqual(dv, sv) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, sv)
+		}
+	}
+	return dst
+}
+
+// repoint rewrites the relationships of owner targeting a merged entity to the survivor,
+// dropping self references and duplicates.
+func repoint(owner *Entity, merged map[string]bool, targetID string) {
+	e := &Entity{}
+	for _, r := range owner.Relationships {
+		if merged[r.TargetID] {
+			r.TargetID = targetID
+		}
+		if r.TargetID == owner.ID {
+			continue
+		}
+		e.Add(r)
+	}
+	owner.Relationships = e.Relationships
+}
+
+func lastModified(e *Entity) time.Time {
+	if e.UpdatedAt != nil {
+		return *e.UpdatedAt
+	}
+	if e.CreatedAt != nil {
+		return *e.CreatedAt
+	}
+	return time.Time{}
+}
+
+func appendUnique(values []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, v := range values {
+			if v == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, item)
+		}
+	}
+	return values
+}
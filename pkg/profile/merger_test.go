@@ -0,0 +1,305 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/pkg/relationship"
+	"github.com/stretchr/testify/require"
+)
+
+// validatorFunc is a Validator calling a function.
+type validatorFunc func(ctx context.Context, accountID, entityType string, attributes map[string]any) error
+
+func (f validatorFunc) Validate(ctx context.Context, accountID, entityType string, attributes map[string]any) error {
+	return f(ctx, accountID, entityType, attributes)
+}
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	errInvalid := errors.New("invalid attributes")
+	rejectLong := validatorFunc(func(_ context.Context, _, _ string, attributes map[string]any) error {
+		if len(attributes) > 2 {
+			return errInvalid
+		}
+		return nil
+	})
+
+	tests := []struct {
+		it        string
+		action    string
+		validator Validator
+		err       error
+		assert    func(t *testing.T, repo Repository, target, source, owner *Entity)
+	}{
+		{
+			it:        "soft deletes the sources and repoints their references",
+			action:    MergeDeleteSources,
+			validator: rejectLong,
+			assert: func(t *testing.T, repo Repository, target, source, owner *Entity) {
+				deleted, err := repo.GetByID(ctx, "acc", source.ID)
+				require.NoError(t, err)
+				require.NotNil(t, deleted.DeletedAt)
+				require.Empty(t, deleted.MergedInto)
+
+				owner, err = repo.GetByID(ctx, "acc", owner.ID)
+				require.NoError(t, err)
+				require.Equal(t, target.ID, owner.Relationships[0].TargetID)
+			},
+		},
+		{
+			it:        "tombstones the sources marked with the target",
+			action:    MergeTombstoneSources,
+			validator: rejectLong,
+			assert: func(t *testing.T, repo Repository, target, source, _ *Entity) {
+				deleted, err := repo.GetByID(ctx, "acc", source.ID)
+				require.NoError(t, err)
+				require.NotNil(t, deleted.DeletedAt)
+				require.Equal(t, target.ID, deleted.MergedInto)
+			},
+		},
+		{
+			it:        "validates the merged target before writing anything",
+			action:    MergeDeleteSources,
+			validator: validatorFunc(func(context.Context, string, string, map[string]any) error { return errInvalid }),
+			err:       errInvalid,
+			assert: func(t *testing.T, repo Repository, target, source, _ *Entity) {
+				stored, err := repo.GetByID(ctx, "acc", target.ID)
+				require.NoError(t, err)
+				require.Equal(t, target.Version, stored.Version)
+				stored, err = repo.GetByID(ctx, "acc", source.ID)
+				require.NoError(t, err)
+				require.Nil(t, stored.DeletedAt)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			repo := repository.NewMemoryRepository[*Entity]()
+			upsert := func(e *Entity) *Entity {
+				e.AccountID = "acc"
+				e, err := repo.Upsert(ctx, "acc", e)
+				require.NoError(t, err)
+				return e
+			}
+			target := upsert(&Entity{Type: "Contact", Attributes: Attribute{"name": "Jane"}})
+			source := upsert(&Entity{Type: "Contact", Attributes: Attribute{"email": "jane@doe.com"}})
+			owner := upsert(&Entity{Type: "Store", Relationships: []Relationship{{Type: "servedBy", TargetID: source.ID}}})
+
+			types := relationship.NewGetter(repository.NewMemoryRepository[*relationship.Definition]())
+			m := NewMerger(repo, tt.validator, types, NewDeleter(repo, OnDeleteRestrict))
+			merged, err := m.Merge(ctx, "acc", MergeCriteria{TargetID: target.ID, SourceIDs: []string{source.ID}, SourceAction: tt.action})
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, Attribute{"name": "Jane", "email": "jane@doe.com"}, merged.Attributes)
+			}
+			tt.assert(t, repo, target, source, owner)
+		})
+	}
+}
+
+func TestMergeEntity(t *testing.T) {
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now()
+	newTarget := func() *Entity {
+		return &Entity{
+			ID:         "target",
+			Attributes: Attribute{"name": "Jane", "tags": []any{"a"}, "address": map[string]any{"city": "Lima"}},
+			UpdatedAt:  &older,
+		}
+	}
+	source := &Entity{
+		ID:            "source",
+		Attributes:    Attribute{"name": "Jane Doe", "email": "jane@doe.com", "tags": []any{"a", "b"}, "address": Attribute{"zip": "15001"}},
+		Relationships: []Relationship{{Type: "buysFrom", TargetID: "store"}},
+		UpdatedAt:     &newer,
+	}
+
+	tests := []struct {
+		it       string
+		strategy string
+		assert   func(t *testing.T, e *Entity)
+	}{
+		{
+			it:       "target wins keeps target values and adds missing keys",
+			strategy: MergeTargetWins,
+			assert: func(t *testing.T, e *Entity) {
+				require.Equal(t, "Jane", e.Attributes["name"])
+				require.Equal(t, "jane@doe.com", e.Attributes["email"])
+				require.Equal(t, []any{"a"}, e.Attributes["tags"])
+				require.Equal(t, map[string]any{"city": "Lima", "zip": "15001"}, e.Attributes["address"])
+			},
+		},
+		{
+			it:       "newest wins takes the most recently updated values",
+			strategy: MergeNewestWins,
+			assert: func(t *testing.T, e *Entity) {
+				require.Equal(t, "Jane Doe", e.Attributes["name"])
+			},
+		},
+		{
+			it:       "union arrays combines array values",
+			strategy: MergeUnionArrays,
+			assert: func(t *testing.T, e *Entity) {
+				require.Equal(t, "Jane", e.Attributes["name"])
+				require.Equal(t, []any{"a", "b"}, e.Attributes["tags"])
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			target := newTarget()
+			mergeEntities(target, []*Entity{source}, tt.strategy)
+			require.Equal(t, []string{"source"}, target.MergedIDs)
+			require.Equal(t, []Relationship{{Type: "buysFrom", TargetID: "store"}}, target.Relationships)
+			tt.assert(t, target)
+		})
+	}
+}
+
+func TestMergeEntitiesNewestWins(t *testing.T) {
+	at := func(hours int) *time.Time {
+		t := time.Date(2024, 1, 1, hours, 0, 0, 0, time.UTC)
+		return &t
+	}
+	target := &Entity{ID: "target", Attributes: Attribute{"name": "Jane", "city": "Lima"}, UpdatedAt: at(2)}
+	newest := &Entity{ID: "newest", Attributes: Attribute{"name": "Jane Doe", "phone": "555"}, UpdatedAt: at(3)}
+	oldest := &Entity{ID: "oldest", Attributes: Attribute{"name": "J.", "city": "Cusco", "phone": "111", "zip": "15001"}, UpdatedAt: at(1)}
+	middle := &Entity{ID: "middle", Attributes: Attribute{"address": map[string]any{"street": "Main"}}, CreatedAt: at(2)}
+
+	mergeEntities(target, []*Entity{newest, oldest, middle}, MergeNewestWins)
+	require.Equal(t, map[string]any{
+		"name":    "Jane Doe",
+		"city":    "Lima",
+		"phone":   "555",
+		"zip":     "15001",
+		"address": map[string]any{"street": "Main"},
+	}, map[string]any(target.Attributes))
+	require.Equal(t, []string{"newest", "oldest", "middle"}, target.MergedIDs)
+
+	target.Attributes["address"].(map[string]any)["street"] = "Other"
+	require.Equal(t, "Main", middle.Attributes["address"].(map[string]any)["street"], "sources are left untouched")
+}
+
+func TestRepoint(t *testing.T) {
+	e := &Entity{
+		ID: "target",
+		Relationships: []Relationship{
+			{Type: "buysFrom", TargetID: "source"},
+			{Type: "buysFrom", TargetID: "store"},
+			{Type: "sellsFor", TargetID: "other"},
+		},
+	}
+	repoint(e, map[string]bool{"source": true, "other": true}, "store")
+	require.Equal(t, []Relationship{{Type: "buysFrom", TargetID: "store"}, {Type: "sellsFor", TargetID: "store"}}, e.Relationships)
+
+	self := &Entity{ID: "target", Relationships: []Relationship{{Type: "knows", TargetID: "source"}}}
+	repoint(self, map[string]bool{"source": true}, "target")
+	require.Empty(t, self.Relationships)
+}
+
+func TestMergeRelationships(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (Repository, *merger, func(e *Entity) *Entity) {
+		repo := repository.NewMemoryRepository[*Entity]()
+		definitions := repository.NewMemoryRepository[*relationship.Definition]()
+		for name, cardinality := range map[string]string{"livesAt": relationship.OneToOne, "manages": relationship.OneToMany} {
+			_, err := definitions.Upsert(ctx, "acc", &relationship.Definition{AccountID: "acc", Name: name, Cardinality: cardinality})
+			require.NoError(t, err)
+		}
+		validator := validatorFunc(func(context.Context, string, string, map[string]any) error { return nil })
+		m := NewMerger(repo, validator, relationship.NewGetter(definitions), NewDeleter(repo, OnDeleteRestrict))
+		upsert := func(e *Entity) *Entity {
+			e.AccountID = "acc"
+			e, err := repo.Upsert(ctx, "acc", e)
+			require.NoError(t, err)
+			return e
+		}
+		return repo, m, upsert
+	}
+
+	t.Run("moves the relationships of the sources once per target, keeping their ID", func(t *testing.T) {
+		repo, m, upsert := setup(t)
+		store := upsert(&Entity{Type: "Store"})
+		home := upsert(&Entity{Type: "Store"})
+		target := upsert(&Entity{Type: "Contact", Relationships: []Relationship{{ID: "served", Type: "servedBy", TargetID: store.ID}}})
+		source := upsert(&Entity{Type: "Contact", Relationships: []Relationship{
+			{ID: "duplicate", Type: "servedBy", TargetID: store.ID},
+			{ID: "home", Type: "livesAt", TargetID: home.ID},
+		}})
+		owner := upsert(&Entity{Type: "Store", Relationships: []Relationship{{Type: "manages", TargetID: source.ID}}})
+
+		merged, err := m.Merge(ctx, "acc", MergeCriteria{TargetID: target.ID, SourceIDs: []string{source.ID}})
+		require.NoError(t, err)
+		require.Len(t, merged.Relationships, 2)
+		require.Equal(t, "served", merged.Relationships[0].ID)
+		require.Equal(t, "home", merged.Relationships[1].ID)
+
+		owner, err = repo.GetByID(ctx, "acc", owner.ID)
+		require.NoError(t, err)
+		require.Equal(t, target.ID, owner.Relationships[0].TargetID)
+		require.NotEmpty(t, owner.Relationships[0].ID)
+	})
+
+	t.Run("rejects exceeding the cardinality of the target", func(t *testing.T) {
+		repo, m, upsert := setup(t)
+		target := upsert(&Entity{Type: "Contact", Relationships: []Relationship{{Type: "livesAt", TargetID: upsert(&Entity{Type: "Store"}).ID}}})
+		source := upsert(&Entity{Type: "Contact", Relationships: []Relationship{{Type: "livesAt", TargetID: upsert(&Entity{Type: "Store"}).ID}}})
+
+		_, err := m.Merge(ctx, "acc", MergeCriteria{TargetID: target.ID, SourceIDs: []string{source.ID}})
+		require.ErrorIs(t, err, ErrRelationshipCardinality)
+		stored, err := repo.GetByID(ctx, "acc", target.ID)
+		require.NoError(t, err)
+		require.Equal(t, target.Version, stored.Version)
+	})
+
+	t.Run("rejects re-pointing several sources of a single source type", func(t *testing.T) {
+		repo, m, upsert := setup(t)
+		target := upsert(&Entity{Type: "Contact"})
+		first := upsert(&Entity{Type: "Contact"})
+		second := upsert(&Entity{Type: "Contact"})
+		owner := upsert(&Entity{Type: "Store", Relationships: []Relationship{{Type: "manages", TargetID: first.ID}}})
+		upsert(&Entity{Type: "Store", Relationships: []Relationship{{Type: "manages", TargetID: second.ID}}})
+
+		_, err := m.Merge(ctx, "acc", MergeCriteria{TargetID: target.ID, SourceIDs: []string{first.ID, second.ID}})
+		require.ErrorIs(t, err, ErrRelationshipCardinality)
+		stored, err := repo.GetByID(ctx, "acc", owner.ID)
+		require.NoError(t, err)
+		require.Equal(t, first.ID, stored.Relationships[0].TargetID)
+	})
+
+	t.Run("reports a concurrent edit of a re-pointed entity as a conflict", func(t *testing.T) {
+		repo, m, upsert := setup(t)
+		target := upsert(&Entity{Type: "Contact"})
+		source := upsert(&Entity{Type: "Contact"})
+		owner := upsert(&Entity{Type: "Store", Relationships: []Relationship{{Type: "knows", TargetID: source.ID}}})
+		m.repo = &staleUpserts{Repository: repo, id: owner.ID}
+
+		_, err := m.Merge(ctx, "acc", MergeCriteria{TargetID: target.ID, SourceIDs: []string{source.ID}})
+		require.ErrorIs(t, err, ErrConflict)
+	})
+}
+
+// staleUpserts is a Repository where the entity with the given ID changes right before being upserted.
+type staleUpserts struct {
+	Repository
+	id string
+}
+
+func (r *staleUpserts) Upsert(ctx context.Context, accountID string, e *Entity) (*Entity, error) {
+	if e.ID == r.id {
+		stored, err := r.Repository.GetByID(ctx, accountID, e.ID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = r.Repository.Upsert(ctx, accountID, stored); err != nil {
+			return nil, err
+		}
+	}
+	return r.Repository.Upsert(ctx, accountID, e)
+}
@@ -0,0 +1,300 @@
+package profile
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTraversalDepth is the number of hops a traversal walks unless requested.
+	DefaultTraversalDepth = 1
+	// maxTraversalDepth is the maximum number of hops a traversal may walk.
+	maxTraversalDepth = 5
+)
+
+// navigator implements the navigation of the relationship graph of an account.
+type navigator struct {
+	repo  Repository
+	graph GraphRepository
+	types RelationshipTypes
+}
+
+// NewNavigator creates the navigation service, walking the graph through graph. Graph is nil when the
+// storage cannot walk it, traversals then fail with ErrNotSupported.
+func NewNavigator(repo Repository, graph GraphRepository, types RelationshipTypes) Navigator {
+	return &navigator{repo: repo, graph: graph, types: types}
+}
+
+// Relationships returns a page of the relationships held by the entity (out), targeting it (in) or both,
+// outbound ones first. Relationships are filtered by the given types, matching either their type or its
+// inverse name, so that they can be named from either side.
+func (s *navigator) Relationships(
+	ctx context.Context,
+	accountID, id, direction string,
+	types []string,
+	currentPage, perPage int,
+) ([]Edge, int, error) {
+	if id == "" {
+		return nil, 0, ErrIDMissing
+	}
+	if accountID == "" {
+		return nil, 0, ErrAccountIDMissing
+	}
+	if currentPage < 1 || perPage < 1 {
+		return nil, 0, ErrInvalidPaginationParameters
+	}
+	direction, err := checkDirection(direction)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	e, err := getActive(ctx, s.repo, accountID, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var held []Edge
+	if direction != DirectionIn {
+		for _, r := range e.Relationships {
+			held = append(held, edge(id, r))
+		}
+	}
+	if direction != DirectionOut {
+		owners, err := referencing(ctx, s.repo, accountID, []string{id})
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, owner := range owners {
+			if owner.DeletedAt != nil {
+				continue
+			}
+			for _, r := range owner.Relationships {
+				if r.TargetID == id {
+					held = append(held, edge(owner.ID, r))
+				}
+			}
+		}
+	}
+
+	relationships := make([]Relationship, 0, len(held))
+	for _, rel := range held {
+		relationships = append(relationships, Relationship{Type: rel.Type})
+	}
+	inverse, err := s.inverses(ctx, accountID, relationships)
+	if err != nil {
+		return nil, 0, err
+	}
+	followed := follows(types, inverse)
+	var edges []Edge
+	for _, rel := range held {
+		if followed(rel.Type) {
+			rel.Inverse = inverse[rel.Type]
+			edges = append(edges, rel)
+		}
+	}
+
+	total := len(edges)
+	start := (currentPage - 1) * perPage
+	if start >= total {
+		return []Edge{}, total, nil
+	}
+	end := min(start+perPage, total)
+	return edges[start:end], total, nil
+}
+
+// Traverse walks the relationships of the given types, or inverse names, from an entity up to the requested
+// depth and returns the active entities reached with the relationships between them.
+func (s *navigator) Traverse(ctx context.Context, accountID, id string, criteria TraversalCriteria) (*Graph, error) {
+	if id == "" {
+		return nil, ErrIDMissing
+	}
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	direction, err := checkDirection(criteria.Direction)
+	if err != nil {
+		return nil, err
+	}
+	depth := criteria.Depth
+	if depth < 1 || depth > maxTraversalDepth {
+		return nil, ErrInvalidDepth
+	}
+	if s.graph == nil {
+		return nil, ErrNotSupported
+	}
+
+	root, err := getActive(ctx, s.repo, accountID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The repository walks every relationship type, the reached entities are then pruned to those
+	// connected to the root through the followed types.
+	var reached []*Entity
+	switch direction {
+	case DirectionOut:
+		reached, err = s.graph.GraphLookup(ctx, accountID, id, relationshipTargetKey, "id", depth, activeFilter())
+	case DirectionIn:
+		reached, err = s.graph.GraphLookup(ctx, accountID, id, "id", relationshipTargetKey, depth, activeFilter())
+	default:
+		reached, err = s.neighbourhood(ctx, accountID, root, depth)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	relationships := append([]Relationship{}, root.Relationships...)
+	for _, e := range reached {
+		relationships = append(relationships, e.Relationships...)
+	}
+	inverse, err := s.inverses(ctx, accountID, relationships)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := subgraph(root, reached, direction, depth, follows(criteria.Types, inverse))
+	for i := range graph.Edges {
+		graph.Edges[i].Inverse = inverse[graph.Edges[i].Type]
+	}
+	return graph, nil
+}
+
+// inverses returns the inverse names declared by the types of the given relationships.
+func (s *navigator) inverses(ctx context.Context, accountID string, relationships []Relationship) (map[string]string, error) {
+	found, err := definitions(ctx, s.types, accountID, relationships)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	for name, d := range found {
+		if d != nil && d.Inverse != "" {
+			names[name] = d.Inverse
+		}
+	}
+	return names, nil
+}
+
+// neighbourhood returns the active entities within depth hops of root following relationships either way,
+// one hop at a time since a graph lookup only walks a single direction.
+func (s *navigator) neighbourhood(ctx context.Context, accountID string, root *Entity, depth int) ([]*Entity, error) {
+	seen := map[string]bool{root.ID: true}
+	frontier := []*Entity{root}
+	var reached []*Entity
+	for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+		ids := make([]string, 0, len(frontier))
+		for _, e := range frontier {
+			ids = append(ids, e.ID)
+		}
+		targets, err := loadActive(ctx, s.repo, accountID, relationshipTargets(frontier...))
+		if err != nil {
+			return nil, err
+		}
+		owners, err := referencing(ctx, s.repo, accountID, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []*Entity
+		visit := func(e *Entity) {
+			if e == nil || e.DeletedAt != nil || seen[e.ID] {
+				return
+			}
+			seen[e.ID] = true
+			next = append(next, e)
+		}
+		for _, e := range frontier {
+			for _, r := range e.Relationships {
+				visit(targets[r.TargetID])
+			}
+		}
+		for _, owner := range owners {
+			visit(owner)
+		}
+		reached = append(reached, next...)
+		frontier = next
+	}
+	return reached, nil
+}
+
+// subgraph keeps the entities connected to root within depth hops through relationships of the followed
+// types in the given direction, along with the followed relationships between them.
+func subgraph(root *Entity, reached []*Entity, direction string, depth int, followed func(string) bool) *Graph {
+	byID := map[string]*Entity{root.ID: root}
+	for _, e := range reached {
+		byID[e.ID] = e
+	}
+
+	outbound := map[string][]string{}
+	inbound := map[string][]string{}
+	for _, e := range append([]*Entity{root}, reached...) {
+		for _, r := range e.Relationships {
+			if followed(r.Type) && byID[r.TargetID] != nil {
+				outbound[e.ID] = append(outbound[e.ID], r.TargetID)
+				inbound[r.TargetID] = append(inbound[r.TargetID], e.ID)
+			}
+		}
+	}
+
+	graph := &Graph{Nodes: []*Entity{root}, Edges: []Edge{}}
+	visited := map[string]bool{root.ID: true}
+	frontier := []string{root.ID}
+	for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+		var next []string
+		for _, id := range frontier {
+			var neighbours []string
+			if direction != DirectionIn {
+				neighbours = append(neighbours, outbound[id]...)
+			}
+			if direction != DirectionOut {
+				neighbours = append(neighbours, inbound[id]...)
+			}
+			for _, n := range neighbours {
+				if !visited[n] {
+					visited[n] = true
+					next = append(next, n)
+					graph.Nodes = append(graph.Nodes, byID[n])
+				}
+			}
+		}
+		frontier = next
+	}
+
+	for _, e := range graph.Nodes {
+		for _, r := range e.Relationships {
+			if followed(r.Type) && visited[r.TargetID] {
+				graph.Edges = append(graph.Edges, edge(e.ID, r))
+			}
+		}
+	}
+	return graph
+}
+
+// edge presents a relationship held by the entity with the given ID as an edge of the graph.
+func edge(sourceID string, r Relationship) Edge {
+	return Edge{ID: r.ID, SourceID: sourceID, Type: r.Type, TargetID: r.TargetID, Attributes: r.Attributes}
+}
+
+// checkDirection validates a relationship direction, defaulting to DirectionOut.
+func checkDirection(direction string) (string, error) {
+	switch direction {
+	case "":
+		return DirectionOut, nil
+	case DirectionOut, DirectionIn, DirectionBoth:
+		return direction, nil
+	default:
+		return "", ErrInvalidDirection
+	}
+}
+
+// follows returns whether a relationship type, or its inverse name, is among types, every type matching
+// when types is empty.
+func follows(types []string, inverse map[string]string) func(string) bool {
+	set := map[string]bool{}
+	for _, t := range types {
+		set[t] = true
+	}
+	return func(t string) bool {
+		return len(set) == 0 || set[t] || (inverse[t] != "" && set[inverse[t]])
+	}
+}
@@ -0,0 +1,84 @@
+package profile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/pkg/relationship"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraverse(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository[*Entity]()
+	types := relationship.NewGetter(repository.NewMemoryRepository[*relationship.Definition]())
+	upsert := func(e *Entity) *Entity {
+		e.AccountID = "acc"
+		e, err := repo.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+		return e
+	}
+
+	// rep -manages-> store <-buysFrom- contact -knows-> friend, with a deleted contact buying from the store.
+	store := upsert(&Entity{Type: "Store"})
+	rep := upsert(&Entity{Type: "SalesRep", Relationships: []Relationship{{Type: "manages", TargetID: store.ID}}})
+	friend := upsert(&Entity{Type: "Contact"})
+	contact := upsert(&Entity{Type: "Contact", Relationships: []Relationship{
+		{Type: "buysFrom", TargetID: store.ID},
+		{Type: "knows", TargetID: friend.ID},
+	}})
+	gone := upsert(&Entity{Type: "Contact", Relationships: []Relationship{{Type: "buysFrom", TargetID: store.ID}}})
+	require.NoError(t, NewDeleter(repo, OnDeleteIgnore).Delete(ctx, "acc", gone.ID, 0))
+
+	nodeIDs := func(g *Graph) []string {
+		var ids []string
+		for _, n := range g.Nodes {
+			ids = append(ids, n.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		it       string
+		from     string
+		criteria TraversalCriteria
+		want     []string
+	}{
+		{it: "follows outbound relationships by default", from: contact.ID, criteria: TraversalCriteria{Depth: 3}, want: []string{contact.ID, store.ID, friend.ID}},
+		{it: "follows inbound relationships", from: store.ID, criteria: TraversalCriteria{Direction: DirectionIn, Depth: 1}, want: []string{store.ID, rep.ID, contact.ID}},
+		{it: "only follows the requested types", from: store.ID, criteria: TraversalCriteria{Direction: DirectionIn, Depth: 1, Types: []string{"buysFrom"}}, want: []string{store.ID, contact.ID}},
+		{it: "walks both ways up to the depth", from: rep.ID, criteria: TraversalCriteria{Direction: DirectionBoth, Depth: 2}, want: []string{rep.ID, store.ID, contact.ID}},
+		{it: "walks both ways through every hop", from: rep.ID, criteria: TraversalCriteria{Direction: DirectionBoth, Depth: 3}, want: []string{rep.ID, store.ID, contact.ID, friend.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			graph, err := NewNavigator(repo, repo, types).Traverse(ctx, "acc", tt.from, tt.criteria)
+			require.NoError(t, err)
+			require.Equal(t, tt.from, graph.Nodes[0].ID)
+			require.ElementsMatch(t, tt.want, nodeIDs(graph))
+		})
+	}
+
+	t.Run("lists the relationships of an entity both ways", func(t *testing.T) {
+		edges, total, err := NewNavigator(repo, repo, types).Relationships(ctx, "acc", contact.ID, DirectionBoth, nil, 1, 10)
+		require.NoError(t, err)
+		require.Equal(t, 2, total)
+		require.Equal(t, []Edge{
+			{SourceID: contact.ID, Type: "buysFrom", TargetID: store.ID},
+			{SourceID: contact.ID, Type: "knows", TargetID: friend.ID},
+		}, edges)
+	})
+
+	t.Run("rejects a depth out of range", func(t *testing.T) {
+		for _, depth := range []int{0, -1, maxTraversalDepth + 1} {
+			_, err := NewNavigator(repo, repo, types).Traverse(ctx, "acc", rep.ID, TraversalCriteria{Depth: depth})
+			require.ErrorIs(t, err, ErrInvalidDepth, depth)
+		}
+	})
+
+	t.Run("rejects traversals when the storage cannot walk the graph", func(t *testing.T) {
+		_, err := NewNavigator(repo, nil, types).Traverse(ctx, "acc", rep.ID, TraversalCriteria{Depth: 1})
+		require.ErrorIs(t, err, ErrNotSupported)
+	})
+}
@@ -0,0 +1,414 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Formats of a partial entity update.
+const (
+	PatchMerge = "application/merge-patch+json" // JSON Merge Patch, RFC 7396
+	PatchJSON  = "application/json-patch+json"  // JSON Patch, RFC 6902
+)
+
+// patchable lists the entity fields a patch may change.
+var patchable = map[string]bool{"type": true, "attributes": true, "metadata": true, "relationships": true}
+
+// patchOperation is an operation of a JSON Patch.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyPatch applies a patch in the given format to the patchable fields of an entity, returned as a
+// plain JSON document.
+func applyPatch(e *Entity, format string, patch []byte) (map[string]any, error) {
+	doc := patchDocument(e)
+	switch format {
+	case PatchMerge:
+		var merge any
+		if err := json.Unmarshal(patch, &merge); err != nil {
+			return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+		}
+		patched, ok := mergePatch(doc, merge).(map[string]any)
+		if !ok {
+			return nil, ErrInvalidPatch
+		}
+		return patched, checkPatched(patched)
+	case PatchJSON:
+		var operations []patchOperation
+		if err := json.Unmarshal(patch, &operations); err != nil {
+			return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+		}
+		var patched any = doc
+		for _, op := range operations {
+			var err error
+			if patched, err = op.apply(patched); err != nil {
+				return nil, err
+			}
+		}
+		m, ok := patched.(map[string]any)
+		if !ok {
+			return nil, ErrInvalidPatch
+		}
+		return m, checkPatched(m)
+	default:
+		return nil, ErrUnsupportedPatch
+	}
+}
+
+// patchDocument returns the patchable fields of an entity as plain JSON values, attributes, metadata and
+// relationships being always present so that patches can add to them.
+func patchDocument(e *Entity) map[string]any {
+	doc := map[string]any{
+		"type":          e.Type,
+		"attributes":    map[string]any{},
+		"metadata":      map[string]any{},
+		"relationships": []any{},
+	}
+	if len(e.Attributes) > 0 {
+		doc["attributes"] = plain(e.Attributes)
+	}
+	if len(e.Metadata) > 0 {
+		doc["metadata"] = plain(e.Metadata)
+	}
+	if len(e.Relationships) > 0 {
+		doc["relationships"] = plain(e.Relationships)
+	}
+	return doc
+}
+
+// checkPatched verifies a patch only touched the patchable fields.
+func checkPatched(doc map[string]any) error {
+	for k := range doc {
+		if !patchable[k] {
+			return errors.Wrap(ErrPatchForbiddenPath, k)
+		}
+	}
+	return nil
+}
+
+// patchedEntity returns a copy of e holding the patchable fields of doc.
+func patchedEntity(e *Entity, doc map[string]any) (*Entity, error) {
+	var fields struct {
+		Type          *string        `json:"type"`
+		Attributes    Attribute      `json:"attributes"`
+		Metadata      Metadata       `json:"metadata"`
+		Relationships []Relationship `json:"relationships"`
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+	if fields.Type == nil || *fields.Type == "" {
+		return nil, errors.Wrap(ErrInvalidPatch, "type")
+	}
+
+	patched := *e
+	patched.Type = *fields.Type
+	patched.Attributes = fields.Attributes
+	patched.Metadata = fields.Metadata
+	patched.Relationships = fields.Relationships
+	return &patched, nil
+}
+
+// fieldUpdates lists the dotted paths to set and unset to turn the stored entity into the patched one.
+// Attributes and metadata are updated key by key, while the type and relationships are set as a whole.
+func fieldUpdates(stored, patched *Entity) (map[string]any, []string) {
+	set := map[string]any{}
+	var unset []string
+	if patched.Type != stored.Type {
+		set[typeKey] = patched.Type
+	}
+	unset = mapUpdates("attributes", plain(stored.Attributes), plain(patched.Attributes), set, unset)
+	unset = mapUpdates("metadata", plain(stored.Metadata), plain(patched.Metadata), set, unset)
+	if (len(stored.Relationships) > 0 || len(patched.Relationships) > 0) &&
+		!reflect.DeepEqual(plain(stored.Relationships), plain(patched.Relationships)) {
+		set["relationships"] = patched.Relationships
+	}
+	return set, unset
+}
+
+// mapUpdates adds to set and unset the updates turning the object at path from one value to another,
+// descending into nested objects. Objects with keys that cannot be addressed by a dotted path are set
+// as a whole.
+func mapUpdates(path string, from, to any, set map[string]any, unset []string) []string {
+	fromMap, fromOK := from.(map[string]any)
+	toMap, toOK := to.(map[string]any)
+	if !toOK || toMap == nil {
+		toMap = map[string]any{}
+	}
+	if !fromOK {
+		if len(toMap) > 0 {
+			set[path] = toMap
+		}
+		return unset
+	}
+	for k := range fromMap {
+		if !dottable(k) {
+			set[path] = toMap
+			return unset
+		}
+	}
+	for k := range toMap {
+		if !dottable(k) {
+			set[path] = toMap
+			return unset
+		}
+	}
+
+	for k, old := range fromMap {
+		if _, ok := toMap[k]; !ok {
+			unset = append(unset, path+"."+k)
+		} else if _, isMap := old.(map[string]any); isMap {
+			if _, stillMap := toMap[k].(map[string]any); stillMap {
+				unset = mapUpdates(path+"."+k, old, toMap[k], set, unset)
+			} else if !reflect.DeepEqual(old, toMap[k]) {
+				set[path+"."+k] = toMap[k]
+			}
+		} else if !reflect.DeepEqual(old, toMap[k]) {
+			set[path+"."+k] = toMap[k]
+		}
+	}
+	for k, v := range toMap {
+		if _, ok := fromMap[k]; !ok {
+			set[path+"."+k] = v
+		}
+	}
+	return unset
+}
+
+// dottable reports whether a key can be addressed in a dotted path.
+func dottable(key string) bool {
+	return key != "" && !strings.ContainsAny(key, ".") && !strings.HasPrefix(key, "$")
+}
+
+// mergePatch applies a JSON Merge Patch to a value: objects are merged recursively, null removing a key,
+// and any other value replaces the target.
+func mergePatch(target, patch any) any {
+	patchMap, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetMap, ok := target.(map[string]any)
+	if !ok {
+		targetMap = map[string]any{}
+	}
+	merged := make(map[string]any, len(targetMap))
+	for k, v := range targetMap {
+		merged[k] = v
+	}
+	for k, v := range patchMap {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = mergePatch(merged[k], v)
+	}
+	return merged
+}
+
+// apply applies a JSON Patch operation to doc, returning the new document.
+func (op patchOperation) apply(doc any) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			if doc, err = remove(doc, path); err != nil {
+				return nil, err
+			}
+			return add(doc, path, value)
+		default:
+			current, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, errors.Wrap(ErrPatchTestFailed, op.Path)
+			}
+			return doc, nil
+		}
+	case "remove":
+		return remove(doc, path)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, errors.Wrap(ErrInvalidPatch, "cannot move a value into itself")
+			}
+			if doc, err = remove(doc, from); err != nil {
+				return nil, err
+			}
+		}
+		return add(doc, path, plain(value))
+	default:
+		return nil, errors.Wrap(ErrInvalidPatch, "unknown operation "+op.Op)
+	}
+}
+
+func (op patchOperation) value() (any, error) {
+	if len(op.Value) == 0 {
+		return nil, errors.Wrap(ErrInvalidPatch, "missing value")
+	}
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(op.Value))
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+	return value, nil
+}
+
+// parsePointer splits a JSON pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.Wrap(ErrInvalidPatch, "invalid path "+pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// get returns the value at path.
+func get(doc any, path []string) (any, error) {
+	current := doc
+	for _, token := range path {
+		switch c := current.(type) {
+		case map[string]any:
+			v, ok := c[token]
+			if !ok {
+				return nil, errors.Wrap(ErrPatchPathNotFound, token)
+			}
+			current = v
+		case []any:
+			i, err := arrayIndex(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			current = c[i]
+		default:
+			return nil, errors.Wrap(ErrPatchPathNotFound, token)
+		}
+	}
+	return current, nil
+}
+
+// add returns doc with value added at path: members are set, array elements inserted, '-' appending.
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[token] = value
+		return doc, nil
+	case []any:
+		i := len(p)
+		if token != "-" {
+			if i, err = arrayIndex(token, len(p)); err != nil {
+				return nil, err
+			}
+		}
+		grown := append(p[:i:i], append([]any{value}, p[i:]...)...)
+		return replaceAt(doc, path[:len(path)-1], grown)
+	default:
+		return nil, errors.Wrap(ErrPatchPathNotFound, token)
+	}
+}
+
+// remove returns doc without the value at path.
+func remove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.Wrap(ErrPatchForbiddenPath, "cannot remove the whole entity")
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		if _, ok := p[token]; !ok {
+			return nil, errors.Wrap(ErrPatchPathNotFound, token)
+		}
+		delete(p, token)
+		return doc, nil
+	case []any:
+		i, err := arrayIndex(token, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		return replaceAt(doc, path[:len(path)-1], append(p[:i:i], p[i+1:]...))
+	default:
+		return nil, errors.Wrap(ErrPatchPathNotFound, token)
+	}
+}
+
+// replaceAt returns doc with the value at path replaced, used for arrays which cannot grow in place.
+func replaceAt(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[token] = value
+	case []any:
+		i, err := arrayIndex(token, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = value
+	}
+	return doc, nil
+}
+
+// arrayIndex parses an array index token, which must be at most maxIndex.
+func arrayIndex(token string, maxIndex int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > maxIndex || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.Wrap(ErrPatchPathNotFound, token)
+	}
+	return i, nil
+}
@@ -0,0 +1,83 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPatch(t *testing.T) {
+	stored := &Entity{
+		Type:       "Contact",
+		Attributes: Attribute{"email": "jane@example.com", "address": map[string]any{"city": "Rosario", "zip": "2000"}},
+		Metadata:   Metadata{"source": "crm"},
+	}
+
+	tests := []struct {
+		it     string
+		format string
+		patch  string
+		set    map[string]any
+		unset  []string
+		err    error
+	}{
+		{
+			it:     "merges attributes, null removing them",
+			format: PatchMerge,
+			patch:  `{"attributes": {"email": "jane@doe.com", "address": {"zip": null}}, "metadata": {"vip": true}}`,
+			set:    map[string]any{"attributes.email": "jane@doe.com", "metadata.vip": true},
+			unset:  []string{"attributes.address.zip"},
+		},
+		{
+			it:     "applies json patch operations in order",
+			format: PatchJSON,
+			patch: `[
+				{"op": "test", "path": "/attributes/email", "value": "jane@example.com"},
+				{"op": "move", "from": "/attributes/email", "path": "/attributes/emails"},
+				{"op": "replace", "path": "/attributes/emails", "value": ["jane@example.com"]},
+				{"op": "add", "path": "/attributes/emails/-", "value": "jane@doe.com"},
+				{"op": "copy", "from": "/attributes/address/city", "path": "/metadata/city"}
+			]`,
+			set: map[string]any{
+				"attributes.emails": []any{"jane@example.com", "jane@doe.com"},
+				"metadata.city":     "Rosario",
+			},
+			unset: []string{"attributes.email"},
+		},
+		{
+			it:     "sets objects with undottable keys as a whole",
+			format: PatchMerge,
+			patch:  `{"metadata": {"a.b": 1}}`,
+			set:    map[string]any{"metadata": map[string]any{"source": "crm", "a.b": float64(1)}},
+		},
+		{
+			it:     "changes nothing with an empty patch",
+			format: PatchMerge,
+			patch:  `{}`,
+			set:    map[string]any{},
+		},
+		{it: "fails a json patch test", format: PatchJSON, patch: `[{"op": "test", "path": "/type", "value": "Store"}]`, err: ErrPatchTestFailed},
+		{it: "rejects a missing path", format: PatchJSON, patch: `[{"op": "remove", "path": "/attributes/phone"}]`, err: ErrPatchPathNotFound},
+		{it: "rejects patching the version", format: PatchMerge, patch: `{"version": 7}`, err: ErrPatchForbiddenPath},
+		{it: "rejects removing the type", format: PatchMerge, patch: `{"type": null}`, err: ErrInvalidPatch},
+		{it: "rejects other formats", format: "application/json", patch: `{}`, err: ErrUnsupportedPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			doc, err := applyPatch(stored, tt.format, []byte(tt.patch))
+			var patched *Entity
+			if err == nil {
+				patched, err = patchedEntity(stored, doc)
+			}
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			set, unset := fieldUpdates(stored, patched)
+			require.Equal(t, tt.set, set)
+			require.ElementsMatch(t, tt.unset, unset)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package profile
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// purger implements the hard deletion of tombstoned entities once their retention expires.
+type purger struct {
+	repo      Repository
+	retention time.Duration
+}
+
+func NewPurger(repo Repository, retention time.Duration) *purger {
+	return &purger{repo: repo, retention: retention}
+}
+
+// Purge hard deletes, across all accounts, the entities soft deleted longer than the retention ago.
+func (s *purger) Purge(ctx context.Context) (int, error) {
+	deleted, err := s.repo.DeleteOlderThan(ctx, deletedAtKey, time.Now().Add(-s.retention))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	n := 0
+	for _, ids := range deleted {
+		n += len(ids)
+	}
+	return n, nil
+}
+
+// Run purges every interval until ctx is done.
+func (s *purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.Purge(ctx)
+			if err != nil {
+				log.Printf("purging deleted entities: %+v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("purged %d deleted entities", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package profile
+
+import (
+	"context"
+	"log"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+)
+
+type operationKey struct{}
+
+// withOperation names the operation recorded for the writes made with ctx.
+func withOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// recorder is a Repository decorator appending a revision to the entity history on every write.
+type recorder struct {
+	Repository
+	history HistoryRepository
+}
+
+// NewRecorder wraps repo so that every upsert and patch is recorded in history.
+// Recording is best effort: a failure is logged and never fails the write, which already happened.
+func NewRecorder(repo Repository, history HistoryRepository) Repository {
+	return &recorder{Repository: repo, history: history}
+}
+
+func (r *recorder) Upsert(ctx context.Context, accountID string, entity *Entity) (*Entity, error) {
+	var previous *Entity
+	if entity.ID != "" {
+		previous, _ = r.Repository.GetByID(ctx, accountID, entity.ID)
+	}
+
+	saved, err := r.Repository.Upsert(ctx, accountID, entity)
+	if err != nil {
+		return saved, err
+	}
+	r.record(ctx, accountID, previous, saved)
+	return saved, nil
+}
+
+func (r *recorder) BulkWrite(ctx context.Context, accountID string, upserts []*Entity, deleteIDs []string) ([]error, error) {
+	var ids []string
+	for _, e := range upserts {
+		if e.ID != "" {
+			ids = append(ids, e.ID)
+		}
+	}
+	previous := map[string]*Entity{}
+	if len(ids) > 0 {
+		if found, err := r.Repository.GetByIDs(ctx, accountID, ids, true); err == nil {
+			for _, e := range found {
+				previous[e.ID] = e
+			}
+		}
+	}
+
+	errs, err := r.Repository.BulkWrite(ctx, accountID, upserts, deleteIDs)
+	if err != nil {
+		return errs, err
+	}
+	var revisions []*Revision
+	for i, e := range upserts {
+		if errs[i] == nil {
+			revisions = append(revisions, revision(ctx, accountID, previous[e.ID], e))
+		}
+	}
+	if len(revisions) == 0 {
+		return errs, nil
+	}
+	recordErrs, err := r.history.BulkWrite(ctx, accountID, revisions, nil)
+	if err != nil {
+		log.Printf("recording %d revisions: %+v", len(revisions), err)
+		return errs, nil
+	}
+	for i, recordErr := range recordErrs {
+		if recordErr != nil {
+			log.Printf("recording version %d of entity %s: %+v", revisions[i].Version, revisions[i].EntityID, recordErr)
+		}
+	}
+	return errs, nil
+}
+
+func (r *recorder) Patch(ctx context.Context, accountID, id string, version int64, set map[string]any, unset []string) (*Entity, error) {
+	previous, _ := r.Repository.GetByID(ctx, accountID, id)
+
+	saved, err := r.Repository.Patch(ctx, accountID, id, version, set, unset)
+	if err != nil {
+		return saved, err
+	}
+	r.record(ctx, accountID, previous, saved)
+	return saved, nil
+}
+
+func (r *recorder) record(ctx context.Context, accountID string, previous, saved *Entity) {
+	if _, err := r.history.Upsert(ctx, accountID, revision(ctx, accountID, previous, saved)); err != nil {
+		log.Printf("recording version %d of entity %s: %+v", saved.Version, saved.ID, err)
+	}
+}
+
+// revision returns the revision recording the write of saved over previous, nil for a new entity.
+func revision(ctx context.Context, accountID string, previous, saved *Entity) *Revision {
+	if previous != nil && previous.AccountID != accountID {
+		previous = nil
+	}
+	snapshot := *saved
+	return &Revision{
+		AccountID: accountID,
+		EntityID:  saved.ID,
+		Version:   saved.Version,
+		Operation: operation(ctx, previous, saved),
+		Actor:     pkg.ActorFrom(ctx),
+		Changes:   diff(previous, saved),
+		Snapshot:  &snapshot,
+	}
+}
+
+// operation returns the operation named with withOperation, or infers it from the change.
+func operation(ctx context.Context, previous, saved *Entity) string {
+	if op, ok := ctx.Value(operationKey{}).(string); ok {
+		return op
+	}
+	switch {
+	case previous == nil:
+		return OperationCreate
+	case previous.DeletedAt == nil && saved.DeletedAt != nil:
+		return OperationDelete
+	case previous.DeletedAt != nil && saved.DeletedAt == nil:
+		return OperationRestore
+	}
+	return OperationUpdate
+}
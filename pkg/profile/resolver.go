@@ -0,0 +1,134 @@
+package profile
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// resolver implements the resolution of entities by their identity keys.
+type resolver struct {
+	repo  Repository
+	keys  IdentityKeys
+	saver Saver
+}
+
+func NewResolver(repo Repository, keys IdentityKeys, saver Saver) Resolver {
+	return &resolver{repo: repo, keys: keys, saver: saver}
+}
+
+// Resolve returns the active entity holding value in the identity key field, among the entity types
+// identified by it or only entityType unless empty.
+func (s *resolver) Resolve(ctx context.Context, accountID, field, value, entityType string) (*Entity, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	types, err := s.identified(ctx, accountID, field, entityType)
+	if err != nil {
+		return nil, err
+	}
+	e, err := s.find(ctx, accountID, field, value, types)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrNotFound
+	}
+	return e, nil
+}
+
+// Upsert updates the active entity holding value in the identity key field or, when none does, creates
+// the entity with the field set to value. It reports whether the entity was created.
+func (s *resolver) Upsert(ctx context.Context, accountID, field, value string, entity *Entity) (*Entity, bool, error) {
+	if accountID == "" {
+		return nil, false, ErrAccountIDMissing
+	}
+	if entity == nil {
+		return nil, false, ErrInvalid
+	}
+	types, err := s.identified(ctx, accountID, field, entity.Type)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(types) > 1 {
+		return nil, false, ErrIdentityTypeMissing
+	}
+	entity.Type = types[0]
+	setIdentity(entity, field, value)
+
+	existing, err := s.find(ctx, accountID, field, value, types)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing == nil {
+		if entity.Version != 0 {
+			return nil, false, ErrVersionMismatch
+		}
+		created, err := s.saver.Create(ctx, accountID, entity)
+		if !errors.Is(err, ErrConflict) {
+			return created, err == nil, err
+		}
+		// A concurrent write may have claimed the identity in the meantime.
+		if existing, err = s.find(ctx, accountID, field, value, types); err != nil || existing == nil {
+			return nil, false, ErrConflict
+		}
+	}
+
+	updated, err := s.saver.Update(ctx, accountID, existing.ID, entity)
+	return updated, false, err
+}
+
+// identified returns the entity types identified by field, only entityType unless empty.
+func (s *resolver) identified(ctx context.Context, accountID, field, entityType string) ([]string, error) {
+	types, err := s.keys.Types(ctx, accountID, field)
+	if err != nil {
+		return nil, err
+	}
+	if entityType != "" {
+		if !slices.Contains(types, entityType) {
+			return nil, ErrNotIdentityKey
+		}
+		return []string{entityType}, nil
+	}
+	if len(types) == 0 {
+		return nil, ErrNotIdentityKey
+	}
+	return types, nil
+}
+
+// find returns the active entity of the given types holding value in field, nil if none.
+func (s *resolver) find(ctx context.Context, accountID, field, value string, types []string) (*Entity, error) {
+	query := map[string]any{
+		field:        value,
+		typeKey:      map[string]any{"$in": types},
+		deletedAtKey: nil,
+	}
+	entities, count, err := s.repo.ExecuteQuery(ctx, accountID, query, 1, 1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if count > 1 {
+		return nil, ErrIdentityAmbiguous
+	}
+	if len(entities) == 0 {
+		return nil, nil
+	}
+	return entities[0], nil
+}
+
+// setIdentity sets the attribute or metadata entry named by field to value.
+func setIdentity(e *Entity, field, value string) {
+	if name, ok := strings.CutPrefix(field, "metadata."); ok {
+		if e.Metadata == nil {
+			e.Metadata = Metadata{}
+		}
+		e.Metadata[name] = value
+		return
+	}
+	if e.Attributes == nil {
+		e.Attributes = Attribute{}
+	}
+	e.Attributes[strings.TrimPrefix(field, "attributes.")] = value
+}
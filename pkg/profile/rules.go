@@ -0,0 +1,106 @@
+package profile
+
+import (
+	"context"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg/relationship"
+	"github.com/pkg/errors"
+)
+
+// checkRules verifies the relationships of an entity against the definitions of their types. Relationships
+// not in previous must connect entity types allowed by their type and respect its cardinality, while the
+// attributes of every relationship must match the declared ones. Free form types are not checked.
+// The targets of the relationships are expected to have been verified and loaded in active. The
+// relationships of the ignored entities, e.g. merged ones about to be deleted, do not count against the
+// cardinalities.
+func checkRules(
+	ctx context.Context,
+	repo Repository,
+	types RelationshipTypes,
+	accountID string,
+	e *Entity,
+	previous []Relationship,
+	active map[string]*Entity,
+	ignored ...string,
+) error {
+	definitions, err := definitions(ctx, types, accountID, e.Relationships)
+	if err != nil {
+		return err
+	}
+
+	held := map[string]int{}
+	for _, r := range e.Relationships {
+		held[r.Type]++
+	}
+	for _, r := range e.Relationships {
+		d := definitions[r.Type]
+		if d == nil {
+			continue
+		}
+		if err = d.CheckAttributes(r.Attributes); err != nil {
+			return err
+		}
+		if holds(previous, r) {
+			continue
+		}
+
+		target := active[r.TargetID]
+		if target == nil || !d.Connects(e.Type, target.Type) {
+			return errors.Wrap(ErrRelationshipTypeMismatch, r.Type)
+		}
+		if d.SingleTarget() && held[r.Type] > 1 {
+			return errors.Wrap(ErrRelationshipCardinality, r.Type)
+		}
+		if d.SingleSource() {
+			taken, err := isRelated(ctx, repo, accountID, r, append([]string{e.ID}, ignored...))
+			if err != nil {
+				return err
+			}
+			if taken {
+				return errors.Wrap(ErrRelationshipCardinality, r.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// definitions loads the definitions of the types of the given relationships, free form types mapping to nil.
+func definitions(ctx context.Context, types RelationshipTypes, accountID string, relationships []Relationship) (map[string]*relationship.Definition, error) {
+	found := map[string]*relationship.Definition{}
+	for _, r := range relationships {
+		if _, ok := found[r.Type]; ok || r.Type == "" {
+			continue
+		}
+		d, err := types.Get(ctx, accountID, r.Type)
+		if err != nil && !errors.Is(err, relationship.ErrNotFound) {
+			return nil, err
+		}
+		found[r.Type] = d
+	}
+	return found, nil
+}
+
+// holds reports whether relationships holds one of the same type and target as r.
+func holds(relationships []Relationship, r Relationship) bool {
+	for _, held := range relationships {
+		if held.Type == r.Type && held.TargetID == r.TargetID {
+			return true
+		}
+	}
+	return false
+}
+
+// isRelated reports whether an active entity of the account other than the ones with the given IDs holds a
+// relationship of the type and target of r.
+func isRelated(ctx context.Context, repo Repository, accountID string, r Relationship, ids []string) (bool, error) {
+	query := map[string]any{
+		"relationships": map[string]any{"$elemMatch": map[string]any{"type": r.Type, "targetId": r.TargetID}},
+		deletedAtKey:    nil,
+		"id":            map[string]any{"$nin": ids},
+	}
+	_, count, err := repo.ExecuteQuery(ctx, accountID, query, 1, 1)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return count > 0, nil
+}
@@ -2,32 +2,38 @@ package profile
 
 import (
 	"context"
+	"reflect"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/google/uuid"
 	errstack "github.com/pkg/errors"
 )
 
 // saver implements the entity saver service.
 type saver struct {
-	repo Repository
+	repo      Repository
+	validator Validator
+	types     RelationshipTypes
 }
 
-func NewSaver(repo Repository) *saver {
-	return &saver{repo: repo}
+func NewSaver(repo Repository, validator Validator, types RelationshipTypes) *saver {
+	return &saver{repo: repo, validator: validator, types: types}
 }
 
 func (s *saver) Create(ctx context.Context, accountID string, entity *Entity) (*Entity, error) {
 	// TODO: business logic to create a entities
-	if accountID == "" {
-		return nil, ErrAccountIDMissing
+	if err := prepareCreate(accountID, entity); err != nil {
+		return nil, err
 	}
-	if entity == nil {
-		return nil, ErrInvalid
+	if err := s.validator.Validate(ctx, accountID, entity.Type, entity.Attributes); err != nil {
+		return nil, err
 	}
-	entity.AccountID = accountID
-	p, err := s.repo.Upsert(ctx, accountID, entity)
-	if err != nil {
-		return nil, errstack.WithStack(err)
+	if err := s.checkRelationships(ctx, accountID, entity, nil); err != nil {
+		return nil, err
 	}
-	return p, nil
+	stampRelationships(entity, nil)
+	return s.save(ctx, accountID, entity)
 }
 
 func (s *saver) Update(ctx context.Context, accountID, id string, entity *Entity) (*Entity, error) {
@@ -39,55 +45,297 @@ func (s *saver) Update(ctx context.Context, accountID, id string, entity *Entity
 		return nil, ErrIDMissing
 	}
 
-	oldEntity, err := s.repo.GetByID(ctx, accountID, id)
+	oldEntity, err := getActive(ctx, s.repo, accountID, id)
 	if err != nil {
-		return nil, errstack.WithStack(err)
+		return nil, err
 	}
 
-	if oldEntity.AccountID != accountID {
-		return nil, ErrInvalid
+	if err = prepareUpdate(accountID, oldEntity, entity); err != nil {
+		return nil, err
 	}
+	if err = s.validator.Validate(ctx, accountID, entity.Type, entity.Attributes); err != nil {
+		return nil, err
+	}
+	if err = s.checkRelationships(ctx, accountID, entity, oldEntity.Relationships); err != nil {
+		return nil, err
+	}
+	stampRelationships(entity, oldEntity.Relationships)
 
-	entity.ID = oldEntity.ID
-	entity.AccountID = oldEntity.AccountID
-	entity.CreatedAt = oldEntity.CreatedAt
-	entity.UpdatedAt = oldEntity.UpdatedAt
+	return s.save(ctx, accountID, entity)
+}
 
-	p, err := s.repo.Upsert(ctx, accountID, entity)
+// Patch applies a JSON Merge Patch or a JSON Patch, as told by format, to the type, attributes, metadata
+// and relationships of an entity. The patched entity is validated like an update, but only the changed
+// fields are written.
+func (s *saver) Patch(ctx context.Context, accountID, id string, version int64, format string, patch []byte) (*Entity, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if id == "" {
+		return nil, ErrIDMissing
+	}
+
+	e, err := getActive(ctx, s.repo, accountID, id)
 	if err != nil {
+		return nil, err
+	}
+	if err = checkVersion(e, version); err != nil {
+		return nil, err
+	}
+
+	doc, err := applyPatch(e, format, patch)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := patchedEntity(e, doc)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.validator.Validate(ctx, accountID, patched.Type, patched.Attributes); err != nil {
+		return nil, err
+	}
+	if err = s.checkRelationships(ctx, accountID, patched, e.Relationships); err != nil {
+		return nil, err
+	}
+	stampRelationships(patched, e.Relationships)
+
+	set, unset := fieldUpdates(e, patched)
+	if len(set) == 0 && len(unset) == 0 {
+		return e, nil
+	}
+	saved, err := s.repo.Patch(withOperation(ctx, OperationPatch), accountID, id, e.Version, set, unset)
+	if err != nil {
+		if isConflict(err) {
+			return nil, ErrConflict
+		}
 		return nil, errstack.WithStack(err)
 	}
-	return p, nil
+	return saved, nil
 }
 
-func (s *saver) AddRelationship(ctx context.Context, accountId, id string, relationship Relationship) (*Entity, error) {
-	e, err := s.repo.GetByID(ctx, accountId, id)
+func (s *saver) AddRelationship(ctx context.Context, accountId, id string, version int64, relationship Relationship) (*Entity, error) {
+	e, err := getActive(ctx, s.repo, accountId, id)
 	if err != nil {
-		return nil, errstack.WithStack(err)
+		return nil, err
+	}
+	if err = checkVersion(e, version); err != nil {
+		return nil, err
 	}
 
+	previous := e.Relationships
 	if !e.Add(relationship) {
 		return e, nil
 	}
+	if err = s.checkRelationships(ctx, accountId, e, previous); err != nil {
+		return nil, err
+	}
+	stampRelationships(e, previous)
+
+	return s.save(withOperation(ctx, OperationAddRelationship), accountId, e)
+}
 
-	e, err = s.repo.Upsert(ctx, accountId, e)
+func (s *saver) ReplaceRelationships(ctx context.Context, accountId, id string, version int64, relationships []Relationship) (*Entity, error) {
+	e, err := getActive(ctx, s.repo, accountId, id)
 	if err != nil {
-		return nil, errstack.WithStack(err)
+		return nil, err
+	}
+	if err = checkVersion(e, version); err != nil {
+		return nil, err
 	}
-	return e, nil
+
+	previous := e.Relationships
+	e.Relationships = relationships
+	if err = s.checkRelationships(ctx, accountId, e, previous); err != nil {
+		return nil, err
+	}
+	stampRelationships(e, previous)
+
+	return s.save(withOperation(ctx, OperationReplaceRelationships), accountId, e)
 }
 
-func (s *saver) ReplaceRelationships(ctx context.Context, accountId, id string, relationships []Relationship) (*Entity, error) {
-	e, err := s.repo.GetByID(ctx, accountId, id)
+// UpdateRelationship merges attributes into those of a relationship of the entity, a null value removing
+// the attribute, like a JSON merge patch.
+func (s *saver) UpdateRelationship(
+	ctx context.Context,
+	accountId, id string,
+	version int64,
+	relationshipId string,
+	attributes map[string]any,
+) (*Entity, error) {
+	e, err := getActive(ctx, s.repo, accountId, id)
 	if err != nil {
-		return nil, errstack.WithStack(err)
+		return nil, err
+	}
+	if err = checkVersion(e, version); err != nil {
+		return nil, err
+	}
+	i := e.Relationship(relationshipId)
+	if i < 0 {
+		return nil, ErrRelationshipNotFound
 	}
 
-	e.Relationships = relationships
+	previous := append([]Relationship{}, e.Relationships...)
+	merged := map[string]any{}
+	for k, v := range e.Relationships[i].Attributes {
+		merged[k] = v
+	}
+	for k, v := range attributes {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+	if len(merged) == 0 {
+		merged = nil
+	}
+	e.Relationships[i].Attributes = merged
+	if err = s.checkRelationships(ctx, accountId, e, previous); err != nil {
+		return nil, err
+	}
+	stampRelationships(e, previous)
+
+	return s.save(withOperation(ctx, OperationUpdateRelationship), accountId, e)
+}
+
+// RemoveRelationship removes a single relationship of the entity.
+func (s *saver) RemoveRelationship(ctx context.Context, accountId, id string, version int64, relationshipId string) (*Entity, error) {
+	e, err := getActive(ctx, s.repo, accountId, id)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkVersion(e, version); err != nil {
+		return nil, err
+	}
+	i := e.Relationship(relationshipId)
+	if i < 0 {
+		return nil, ErrRelationshipNotFound
+	}
 
-	e, err = s.repo.Upsert(ctx, accountId, e)
+	e.Relationships = append(e.Relationships[:i:i], e.Relationships[i+1:]...)
+	return s.save(withOperation(ctx, OperationRemoveRelationship), accountId, e)
+}
+
+// checkRelationships verifies the targets of the relationships of an entity and the rules of their types,
+// the relationships in previous being already held by the stored entity.
+func (s *saver) checkRelationships(ctx context.Context, accountID string, e *Entity, previous []Relationship) error {
+	active, err := checkTargets(ctx, s.repo, accountID, e)
 	if err != nil {
+		return err
+	}
+	return checkRules(ctx, s.repo, s.types, accountID, e, previous, active)
+}
+
+// save upserts the entity, reporting a concurrent modification as ErrConflict.
+func (s *saver) save(ctx context.Context, accountID string, entity *Entity) (*Entity, error) {
+	return upsert(ctx, s.repo, accountID, entity)
+}
+
+// upsert writes the entity, reporting a concurrent modification as ErrConflict.
+func upsert(ctx context.Context, repo Repository, accountID string, entity *Entity) (*Entity, error) {
+	p, err := repo.Upsert(ctx, accountID, entity)
+	if err != nil {
+		if isConflict(err) {
+			return nil, ErrConflict
+		}
 		return nil, errstack.WithStack(err)
 	}
-	return e, nil
+	return p, nil
+}
+
+// prepareCreate validates a new entity and scopes it to the account. The ID and version are left to the
+// repository, and a new entity is neither deleted nor merged, whatever the client sent.
+func prepareCreate(accountID string, entity *Entity) error {
+	if accountID == "" {
+		return ErrAccountIDMissing
+	}
+	if entity == nil {
+		return ErrInvalid
+	}
+	entity.ID = ""
+	entity.Version = 0
+	entity.AccountID = accountID
+	entity.MergedIDs = nil
+	entity.MergedInto = ""
+	entity.DeletedAt = nil
+	entity.DeletedBy = ""
+	return nil
+}
+
+// prepareUpdate validates an update against the stored entity and carries over the fields clients cannot change.
+func prepareUpdate(accountID string, oldEntity, entity *Entity) error {
+	if entity == nil {
+		return ErrInvalid
+	}
+	if oldEntity.AccountID != accountID {
+		return ErrInvalid
+	}
+	if err := checkVersion(oldEntity, entity.Version); err != nil {
+		return err
+	}
+
+	entity.ID = oldEntity.ID
+	entity.Version = oldEntity.Version
+	entity.AccountID = oldEntity.AccountID
+	entity.MergedIDs = oldEntity.MergedIDs
+	entity.MergedInto = oldEntity.MergedInto
+	entity.DeletedAt = oldEntity.DeletedAt
+	entity.DeletedBy = oldEntity.DeletedBy
+	entity.CreatedAt = oldEntity.CreatedAt
+	entity.UpdatedAt = oldEntity.UpdatedAt
+	return nil
+}
+
+// stampRelationships identifies the relationships of an entity. Those already in previous, matched by ID or
+// else by type and target, keep their ID and creation time and are marked as updated when their attributes
+// changed, while the others get a new ID and creation time.
+func stampRelationships(e *Entity, previous []Relationship) {
+	now := time.Now()
+	used := map[int]bool{}
+	match := func(r Relationship) int {
+		for i, p := range previous {
+			if !used[i] && r.ID != "" && p.ID == r.ID {
+				return i
+			}
+		}
+		for i, p := range previous {
+			if !used[i] && p.Type == r.Type && p.TargetID == r.TargetID {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for i := range e.Relationships {
+		r := &e.Relationships[i]
+		j := match(*r)
+		if j < 0 {
+			r.ID = uuid.NewString()
+			r.CreatedAt = &now
+			r.UpdatedAt = nil
+			continue
+		}
+		used[j] = true
+		p := previous[j]
+		r.ID, r.CreatedAt, r.UpdatedAt = p.ID, p.CreatedAt, p.UpdatedAt
+		if r.ID == "" {
+			r.ID = uuid.NewString()
+		}
+		if !reflect.DeepEqual(plain(r.Attributes), plain(p.Attributes)) {
+			r.UpdatedAt = &now
+		}
+	}
+}
+
+// checkVersion verifies the version requested by the client, if any, is the stored one.
+func checkVersion(entity *Entity, version int64) error {
+	if version != 0 && version != entity.Version {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+func isConflict(err error) bool {
+	var conflict pkg.ErrConflictType
+	return errstack.As(err, &conflict)
 }
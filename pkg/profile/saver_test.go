@@ -0,0 +1,60 @@
+package profile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/pkg/relationship"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaverServerFields(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository[*Entity]()
+	types := relationship.NewGetter(repository.NewMemoryRepository[*relationship.Definition]())
+	s := NewSaver(repo, &typeValidator{loads: map[string]int{}}, types)
+	deletedAt := time.Now()
+
+	t.Run("creates ignoring the ID, version, deletion and merge sent", func(t *testing.T) {
+		created, err := s.Create(ctx, "acc", &Entity{
+			ID:         "65a000000000000000000000",
+			Type:       "Contact",
+			Attributes: Attribute{"name": "Ana"},
+			Version:    7,
+			DeletedAt:  &deletedAt,
+			DeletedBy:  "someone",
+			MergedIDs:  []string{"65a000000000000000000001"},
+			MergedInto: "65a000000000000000000002",
+		})
+		require.NoError(t, err)
+		require.NotEqual(t, "65a000000000000000000000", created.ID)
+		require.Equal(t, int64(1), created.Version)
+		require.Nil(t, created.DeletedAt)
+		require.Empty(t, created.DeletedBy)
+		require.Empty(t, created.MergedIDs)
+		require.Empty(t, created.MergedInto)
+
+		stored, err := getActive(ctx, repo, "acc", created.ID)
+		require.NoError(t, err)
+		require.Equal(t, created.ID, stored.ID)
+	})
+
+	t.Run("updates keeping the deletion and merge stored", func(t *testing.T) {
+		created, err := s.Create(ctx, "acc", &Entity{Type: "Contact", Attributes: Attribute{"name": "Bob"}})
+		require.NoError(t, err)
+
+		updated, err := s.Update(ctx, "acc", created.ID, &Entity{
+			Type:       "Contact",
+			Attributes: Attribute{"name": "Bobby"},
+			Version:    created.Version,
+			DeletedAt:  &deletedAt,
+			MergedInto: "65a000000000000000000002",
+		})
+		require.NoError(t, err)
+		require.Nil(t, updated.DeletedAt)
+		require.Empty(t, updated.MergedInto)
+		require.Equal(t, "Bobby", updated.Attributes["name"])
+	})
+}
@@ -0,0 +1,221 @@
+package profile
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxTextWords caps the words of a free-text query.
+	maxTextWords = 8
+	// maxSearchFields caps the fields searched for an entity type.
+	maxSearchFields = 10
+	// maxTextCandidates caps the entities loaded to be ranked for a free-text query, so that the results
+	// of a query matching most of an account stop at its best candidates. The total reported is then
+	// maxTextCandidates at most.
+	maxTextCandidates = 500
+)
+
+// searcher implements the free-text search of entities.
+type searcher struct {
+	text   TextRepository
+	fields SearchFieldsRepository
+}
+
+// NewSearcher creates the free-text search service. Text is nil when the storage cannot search text,
+// searching and setting the search fields then fail with ErrNotSupported.
+func NewSearcher(text TextRepository, fields SearchFieldsRepository) *searcher {
+	return &searcher{text: text, fields: fields}
+}
+
+// DefaultSearchFields returns the fields searched for an entity type unless configured.
+func DefaultSearchFields(entityType string) *SearchFields {
+	return &SearchFields{
+		EntityType: entityType,
+		Fields:     []string{"attributes.name", "attributes.email"},
+	}
+}
+
+// Search returns the entities matching query whose search fields hold the words of text, as whole words,
+// prefixes or with a typo, best matching first. The text index finds the candidates, which are ranked by
+// how well each of their search fields matches. At most maxTextCandidates candidates are ranked, so that
+// the total stops there for a text found in most of the account. Like in Query, query may only use the
+// fields and operators search criteria compile to.
+func (s *searcher) Search(
+	ctx context.Context,
+	accountID, text string,
+	query map[string]any,
+	currentPage, perPage int,
+	opts QueryOptions,
+) ([]*Entity, int, error) {
+	if accountID == "" {
+		return nil, 0, ErrAccountIDMissing
+	}
+	if currentPage < 1 || perPage < 1 {
+		return nil, 0, ErrInvalidPaginationParameters
+	}
+	if s.text == nil {
+		return nil, 0, ErrNotSupported
+	}
+	queryWords := words(text)
+	if len(queryWords) == 0 || len(queryWords) > maxTextWords {
+		return nil, 0, ErrInvalidTextQuery
+	}
+	if err := search.Validate(query); err != nil {
+		return nil, 0, err
+	}
+	opts, err := checkSelection(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(opts.Sort) > 0 {
+		return nil, 0, ErrTextSearchSorted
+	}
+
+	configured, err := s.configured(ctx, accountID, opts.Type)
+	if err != nil {
+		return nil, 0, err
+	}
+	fieldsOf := func(entityType string) []string {
+		if f, ok := configured[entityType]; ok {
+			return f.Fields
+		}
+		return DefaultSearchFields(entityType).Fields
+	}
+	// Without a type, the candidates may be of any type, including those searching the default fields
+	searched := slices.Clone(fieldsOf(opts.Type))
+	if opts.Type == "" {
+		for _, f := range configured {
+			for _, field := range f.Fields {
+				if !slices.Contains(searched, field) {
+					searched = append(searched, field)
+				}
+			}
+		}
+	}
+
+	if filter := optionsFilter(opts); len(filter) > 0 {
+		if len(query) == 0 {
+			query = filter
+		} else {
+			query = map[string]any{"$and": []any{query, filter}}
+		}
+	}
+	candidates, err := s.text.TextSearch(ctx, accountID, textQuery(searched, queryWords), query, maxTextCandidates)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+
+	type hit struct {
+		entity *Entity
+		score  float64
+	}
+	var hits []hit
+	for _, e := range candidates {
+		if score := textScore(e, fieldsOf(e.Type), queryWords); score > 0 {
+			hits = append(hits, hit{entity: e, score: score})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].entity.ID < hits[j].entity.ID
+	})
+
+	start := min((currentPage-1)*perPage, len(hits))
+	end := min(start+perPage, len(hits))
+	entities := make([]*Entity, 0, end-start)
+	for _, h := range hits[start:end] {
+		entities = append(entities, restrict(h.entity, opts.Fields))
+	}
+	return entities, len(hits), nil
+}
+
+// SearchFields returns the fields searched for an entity type, the default ones unless configured.
+func (s *searcher) SearchFields(ctx context.Context, accountID, entityType string) (*SearchFields, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	return searchFields(ctx, s.fields, accountID, entityType)
+}
+
+// SetSearchFields replaces the fields searched for an entity type, then indexes the stored entities of
+// the type again so that they are only found by the new fields.
+func (s *searcher) SetSearchFields(ctx context.Context, accountID, entityType string, fields *SearchFields) (*SearchFields, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if entityType == "" || fields == nil || len(fields.Fields) == 0 || len(fields.Fields) > maxSearchFields {
+		return nil, ErrInvalidSearchFields
+	}
+	if s.text == nil {
+		return nil, ErrNotSupported
+	}
+	seen := map[string]bool{}
+	for _, field := range fields.Fields {
+		if !isEntryPath(field) || seen[field] {
+			return nil, errors.Wrap(ErrInvalidSearchFields, field)
+		}
+		seen[field] = true
+	}
+
+	current, err := s.SearchFields(ctx, accountID, entityType)
+	if err != nil {
+		return nil, err
+	}
+	fields.ID = current.ID
+	fields.AccountID = accountID
+	fields.EntityType = entityType
+	fields.CreatedAt = current.CreatedAt
+	fields.UpdatedAt = nil
+	saved, err := s.fields.Upsert(ctx, accountID, fields)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	query := map[string]any{typeKey: entityType}
+	if _, err = s.text.Reindex(ctx, accountID, query, map[string]any{searchPathsKey: saved.Fields}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return saved, nil
+}
+
+// searchFields loads the fields searched for an entity type, the default ones unless configured.
+func searchFields(ctx context.Context, repo SearchFieldsRepository, accountID, entityType string) (*SearchFields, error) {
+	found, _, err := repo.ExecuteQuery(ctx, accountID, map[string]any{"entityType": entityType}, 1, 1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(found) > 0 {
+		return found[0], nil
+	}
+	fields := DefaultSearchFields(entityType)
+	fields.AccountID = accountID
+	return fields, nil
+}
+
+// configured loads the search fields configured for the entity types of the account, keyed by type, or
+// only for entityType unless empty.
+func (s *searcher) configured(ctx context.Context, accountID, entityType string) (map[string]*SearchFields, error) {
+	query := map[string]any{}
+	if entityType != "" {
+		query["entityType"] = entityType
+	}
+	configured := map[string]*SearchFields{}
+	for page := 1; ; page++ {
+		found, total, err := s.fields.ExecuteQuery(ctx, accountID, query, page, scanPageSize)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, f := range found {
+			configured[f.EntityType] = f
+		}
+		if len(found) == 0 || page*scanPageSize >= total {
+			return configured, nil
+		}
+	}
+}
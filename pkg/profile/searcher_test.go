@@ -0,0 +1,79 @@
+package profile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	fields := repository.NewMemoryRepository[*SearchFields]()
+	store := repository.NewMemoryRepository[*Entity]()
+	repo := NewIndexer(store, fields)
+	s := NewSearcher(store, fields)
+
+	contact := func(name, city string) *Entity {
+		e, err := repo.Upsert(ctx, "acc", &Entity{AccountID: "acc", Type: "Contact", Attributes: Attribute{"name": name, "city": city}})
+		require.NoError(t, err)
+		return e
+	}
+	typo := contact("Gomes", "Rosario")
+	exact := contact("Gomez", "Cordoba")
+	prefix := contact("Gomezano", "Rosario")
+	ids := func(entities []*Entity) []string {
+		var ids []string
+		for _, e := range entities {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	t.Run("ranks exact matches, then prefixes, then typos", func(t *testing.T) {
+		found, total, err := s.Search(ctx, "acc", "gomez", nil, 1, 10, QueryOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 3, total)
+		require.Equal(t, []string{exact.ID, prefix.ID, typo.ID}, ids(found))
+	})
+
+	t.Run("rejects invalid queries", func(t *testing.T) {
+		for _, text := range []string{" ", "a b c d e f g h i"} {
+			_, _, err := s.Search(ctx, "acc", text, nil, 1, 10, QueryOptions{})
+			require.ErrorIs(t, err, ErrInvalidTextQuery, text)
+		}
+		_, _, err := s.Search(ctx, "acc", "gomez", nil, 1, 10, QueryOptions{Sort: []pkg.Sort{{Field: "type"}}})
+		require.ErrorIs(t, err, ErrTextSearchSorted)
+	})
+
+	t.Run("searches the configured fields once set", func(t *testing.T) {
+		_, err := s.SetSearchFields(ctx, "acc", "Contact", &SearchFields{Fields: []string{"attributes.city"}})
+		require.NoError(t, err)
+
+		found, _, err := s.Search(ctx, "acc", "rosario", nil, 1, 10, QueryOptions{})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{typo.ID, prefix.ID}, ids(found))
+		_, total, err := s.Search(ctx, "acc", "gomez", nil, 1, 10, QueryOptions{})
+		require.NoError(t, err)
+		require.Zero(t, total)
+
+		// Entities written later are indexed with the configured fields too
+		later := contact("Ana", "Rosario")
+		found, _, err = s.Search(ctx, "acc", "rosario", nil, 1, 10, QueryOptions{})
+		require.NoError(t, err)
+		require.Contains(t, ids(found), later.ID)
+	})
+
+	t.Run("ranks the best candidates only", func(t *testing.T) {
+		for i := 0; i <= maxTextCandidates; i++ {
+			_, err := repo.Upsert(ctx, "many", &Entity{AccountID: "many", Type: "Store", Attributes: Attribute{"name": "Market"}})
+			require.NoError(t, err)
+		}
+
+		_, total, err := s.Search(ctx, "many", "market", nil, 1, 10, QueryOptions{})
+		require.NoError(t, err)
+		require.Equal(t, maxTextCandidates, total)
+	})
+}
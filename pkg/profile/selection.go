@@ -0,0 +1,154 @@
+package profile
+
+import (
+	"slices"
+	"strings"
+)
+
+// maxSortFields is the maximum number of fields a list may be ordered by.
+const maxSortFields = 3
+
+// sortableFields are the entity fields a list may be ordered by, besides attributes and metadata entries.
+var sortableFields = map[string]bool{
+	"id":        true,
+	"type":      true,
+	"version":   true,
+	"createdAt": true,
+	"updatedAt": true,
+	"deletedAt": true,
+}
+
+// selectableFields are the entity fields a list may be restricted to, besides attributes and metadata
+// entries.
+var selectableFields = map[string]bool{
+	"id":            true,
+	"accountId":     true,
+	"type":          true,
+	"attributes":    true,
+	"metadata":      true,
+	"relationships": true,
+	"mergedIds":     true,
+	"mergedInto":    true,
+	"version":       true,
+	"deletedAt":     true,
+	"deletedBy":     true,
+	"createdAt":     true,
+	"updatedAt":     true,
+}
+
+// checkSelection validates the sort and fields of opts against the allowed fields, dropping the fields
+// nested in another selected one, e.g. 'attributes.email' along with 'attributes'.
+func checkSelection(opts QueryOptions) (QueryOptions, error) {
+	if len(opts.Sort) > maxSortFields {
+		return opts, ErrInvalidSort
+	}
+	seen := map[string]bool{}
+	for _, s := range opts.Sort {
+		if seen[s.Field] || (!sortableFields[s.Field] && !isEntryPath(s.Field)) {
+			return opts, ErrInvalidSort
+		}
+		seen[s.Field] = true
+	}
+
+	var fields []string
+	for _, field := range opts.Fields {
+		if !selectableFields[field] && !isEntryPath(field) {
+			return opts, ErrInvalidFields
+		}
+		nested := slices.ContainsFunc(opts.Fields, func(other string) bool {
+			return strings.HasPrefix(field, other+".")
+		})
+		if !nested && !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	opts.Fields = fields
+	return opts, nil
+}
+
+// isEntryPath reports whether field is the dotted path of an attributes or metadata entry, possibly
+// nested, e.g. 'attributes.address.city'.
+func isEntryPath(field string) bool {
+	path := strings.Split(field, ".")
+	if len(path) < 2 || (path[0] != "attributes" && path[0] != "metadata") {
+		return false
+	}
+	for _, segment := range path[1:] {
+		if segment == "" || strings.HasPrefix(segment, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// selects reports whether opts order or restrict the listed entities.
+func selects(opts QueryOptions) bool {
+	return len(opts.Sort) > 0 || len(opts.Fields) > 0
+}
+
+// restrict returns a copy of an entity holding only the given fields, along with the ID, like the entities
+// Find loads, or the entity itself when fields is empty.
+func restrict(e *Entity, fields []string) *Entity {
+	if len(fields) == 0 {
+		return e
+	}
+	restricted := &Entity{ID: e.ID}
+	for _, field := range fields {
+		name, path, nested := strings.Cut(field, ".")
+		switch name {
+		case "accountId":
+			restricted.AccountID = e.AccountID
+		case "type":
+			restricted.Type = e.Type
+		case "attributes":
+			if !nested {
+				restricted.Attributes = e.Attributes
+			} else if v := matchValue(e, field); v != nil {
+				if restricted.Attributes == nil {
+					restricted.Attributes = Attribute{}
+				}
+				setEntry(restricted.Attributes, path, v)
+			}
+		case "metadata":
+			if !nested {
+				restricted.Metadata = e.Metadata
+			} else if v := matchValue(e, field); v != nil {
+				if restricted.Metadata == nil {
+					restricted.Metadata = Metadata{}
+				}
+				setEntry(restricted.Metadata, path, v)
+			}
+		case "relationships":
+			restricted.Relationships = e.Relationships
+		case "mergedIds":
+			restricted.MergedIDs = e.MergedIDs
+		case "mergedInto":
+			restricted.MergedInto = e.MergedInto
+		case "version":
+			restricted.Version = e.Version
+		case "deletedAt":
+			restricted.DeletedAt = e.DeletedAt
+		case "deletedBy":
+			restricted.DeletedBy = e.DeletedBy
+		case "createdAt":
+			restricted.CreatedAt = e.CreatedAt
+		case "updatedAt":
+			restricted.UpdatedAt = e.UpdatedAt
+		}
+	}
+	return restricted
+}
+
+// setEntry sets the value at a dotted path of entries, creating the intermediate maps.
+func setEntry(entries map[string]any, path string, v any) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := entries[key].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			entries[key] = child
+		}
+		entries = child
+	}
+	entries[keys[len(keys)-1]] = v
+}
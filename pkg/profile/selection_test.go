@@ -0,0 +1,64 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSelection(t *testing.T) {
+	tests := []struct {
+		it     string
+		opts   QueryOptions
+		fields []string
+		err    error
+	}{
+		{it: "accepts entity fields and entries", opts: QueryOptions{
+			Sort:   []pkg.Sort{{Field: "createdAt"}, {Field: "attributes.lastPurchase", Desc: true}},
+			Fields: []string{"type", "metadata.source"},
+		}, fields: []string{"type", "metadata.source"}},
+		{it: "drops the fields nested in a selected one", opts: QueryOptions{
+			Fields: []string{"attributes.email", "attributes", "attributes"},
+		}, fields: []string{"attributes"}},
+		{it: "rejects too many sort fields", opts: QueryOptions{
+			Sort: []pkg.Sort{{Field: "id"}, {Field: "type"}, {Field: "version"}, {Field: "createdAt"}},
+		}, err: ErrInvalidSort},
+		{it: "rejects a repeated sort field", opts: QueryOptions{Sort: []pkg.Sort{{Field: "type"}, {Field: "type", Desc: true}}}, err: ErrInvalidSort},
+		{it: "rejects a field that is not sortable", opts: QueryOptions{Sort: []pkg.Sort{{Field: "relationships"}}}, err: ErrInvalidSort},
+		{it: "rejects an operator in an entry path", opts: QueryOptions{Sort: []pkg.Sort{{Field: "attributes.$where"}}}, err: ErrInvalidSort},
+		{it: "rejects an unknown field", opts: QueryOptions{Fields: []string{"password"}}, err: ErrInvalidFields},
+		{it: "rejects an empty entry path", opts: QueryOptions{Fields: []string{"attributes."}}, err: ErrInvalidFields},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			opts, err := checkSelection(tt.opts)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.fields, opts.Fields)
+		})
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	e := &Entity{
+		ID:         "e1",
+		AccountID:  "acc",
+		Type:       "Contact",
+		Attributes: Attribute{"email": "ana@example.com", "address": map[string]any{"city": "Rome", "zip": "00100"}},
+		Metadata:   Metadata{"source": "crm"},
+		Version:    3,
+	}
+
+	require.Same(t, e, restrict(e, nil))
+	require.Equal(t, &Entity{
+		ID:         "e1",
+		Type:       "Contact",
+		Attributes: Attribute{"address": map[string]any{"city": "Rome"}},
+		Version:    3,
+	}, restrict(e, []string{"type", "attributes.address.city", "attributes.missing", "version"}))
+	require.Equal(t, &Entity{ID: "e1", Metadata: Metadata{"source": "crm"}}, restrict(e, []string{"metadata"}))
+}
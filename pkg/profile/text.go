@@ -0,0 +1,215 @@
+package profile
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxIndexedValue caps the runes of a value indexed for free-text search, so that long texts, e.g. notes,
+	// do not bloat the entity with terms.
+	maxIndexedValue = 256
+	// maxPrefixLength is the length of the longest prefix indexed for a word. Longer prefixes are looked up
+	// by their first maxPrefixLength runes and checked once the entities are loaded.
+	maxPrefixLength = 8
+	// minPrefixLength is the length of the shortest prefix matched.
+	minPrefixLength = 2
+	// minTypoLength is the length of the shortest word matched with a typo.
+	minTypoLength = 4
+	// maxTypoLength is the length of the longest word indexed with its typos.
+	maxTypoLength = 16
+)
+
+// accents folds the accented Latin letters to their base letter, so that 'José' matches 'jose'.
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ñ", "n", "ç", "c", "ý", "y", "ÿ", "y",
+)
+
+// SearchTerms returns the terms free-text search finds the entity by. Every string held in the search
+// fields of the entity, SearchPaths or the default ones, is split into words, each indexed along with its
+// prefixes and, to tolerate a typo, with every variant missing one rune, as in the symmetric delete
+// spelling correction. A typo in a query word then shows up as a shared variant. Terms are tagged with the
+// path of the value they come from, so that a query only matches the fields it searches.
+func (e *Entity) SearchTerms() []string {
+	seen := map[string]bool{}
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	paths := e.SearchPaths
+	if len(paths) == 0 {
+		paths = DefaultSearchFields(e.Type).Fields
+	}
+	texts := e.texts()
+	for _, path := range paths {
+		tag := pathTag(path)
+		for _, value := range texts[path] {
+			for _, word := range words(value) {
+				add(tag + word)
+				runes := []rune(word)
+				for n := minPrefixLength; n < len(runes) && n <= maxPrefixLength; n++ {
+					add(tag + string(runes[:n]))
+				}
+				if len(runes) >= minTypoLength && len(runes) <= maxTypoLength {
+					for _, variant := range deletions(word) {
+						add(tag + variant)
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// texts returns the strings held in the attributes and metadata of the entity, keyed by dotted path. The
+// strings of a list are held under the path of the list.
+func (e *Entity) texts() map[string][]string {
+	texts := map[string][]string{}
+	var walk func(path string, v any)
+	walk = func(path string, v any) {
+		if s, ok := v.(string); ok {
+			runes := []rune(s)
+			texts[path] = append(texts[path], string(runes[:min(len(runes), maxIndexedValue)]))
+		} else if m, ok := asMap(v); ok {
+			for k, child := range m {
+				walk(path+"."+k, child)
+			}
+		} else if a, ok := asArray(v); ok {
+			for _, child := range a {
+				walk(path, child)
+			}
+		}
+	}
+	walk("attributes", map[string]any(e.Attributes))
+	walk("metadata", map[string]any(e.Metadata))
+	return texts
+}
+
+// textQuery returns the terms to look up to find the entities holding the words of a query in one of the
+// given fields: each word as a whole or prefix, and its variants missing one rune.
+func textQuery(fields, queryWords []string) []string {
+	var terms []string
+	for _, field := range fields {
+		tag := pathTag(field)
+		for _, word := range queryWords {
+			runes := []rune(word)
+			terms = append(terms, tag+string(runes[:min(len(runes), maxPrefixLength)]))
+			if len(runes) > maxPrefixLength {
+				terms = append(terms, tag+word)
+			}
+			if len(runes) >= minTypoLength {
+				for _, variant := range deletions(word) {
+					terms = append(terms, tag+variant)
+				}
+			}
+		}
+	}
+	return terms
+}
+
+// words splits a text into lower case words of letters and digits, folding accents.
+func words(text string) []string {
+	return strings.FieldsFunc(accents.Replace(strings.ToLower(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// deletions returns the variants of a word missing one of its runes.
+func deletions(word string) []string {
+	runes := []rune(word)
+	variants := make([]string, 0, len(runes))
+	for i := range runes {
+		variants = append(variants, string(runes[:i])+string(runes[i+1:]))
+	}
+	return variants
+}
+
+// pathTag identifies a dotted path in a search term. It is made of hex digits so that the tagged term
+// remains a single word for the text index.
+func pathTag(path string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(path))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// Scores of a query word matching a word of a searched field.
+const (
+	scoreExact  = 1.0 // The words are equal
+	scorePrefix = 0.8 // The query word starts the field word
+	scoreTypo   = 0.6 // The words differ by one edit
+)
+
+// textScore scores how well an entity matches the words of a query in the given fields, from 0 to 1: the
+// mean of the best match of each word, 0 unless every word matches.
+func textScore(e *Entity, fields, queryWords []string) float64 {
+	texts := e.texts()
+	var fieldWords []string
+	for _, field := range fields {
+		for _, value := range texts[field] {
+			fieldWords = append(fieldWords, words(value)...)
+		}
+	}
+
+	total := 0.0
+	for _, q := range queryWords {
+		best := 0.0
+		for _, w := range fieldWords {
+			best = max(best, wordScore(q, w))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total / float64(len(queryWords))
+}
+
+func wordScore(q, w string) float64 {
+	switch {
+	case q == w:
+		return scoreExact
+	case len([]rune(q)) >= minPrefixLength && strings.HasPrefix(w, q):
+		return scorePrefix
+	case len([]rune(q)) >= minTypoLength && oneEdit(q, w):
+		return scoreTypo
+	}
+	return 0
+}
+
+// oneEdit reports whether two distinct words differ by a single inserted, deleted or substituted rune, or
+// by two swapped adjacent runes.
+func oneEdit(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > len(rb) {
+		ra, rb = rb, ra
+	}
+	if len(rb)-len(ra) > 1 {
+		return false
+	}
+	i := 0
+	for i < len(ra) && ra[i] == rb[i] {
+		i++
+	}
+	if len(ra) < len(rb) {
+		return string(ra[i:]) == string(rb[i+1:])
+	}
+	if i == len(ra) {
+		return false
+	}
+	if string(ra[i+1:]) == string(rb[i+1:]) {
+		return true
+	}
+	return i+1 < len(ra) && ra[i] == rb[i+1] && ra[i+1] == rb[i] && string(ra[i+2:]) == string(rb[i+2:])
+}
@@ -0,0 +1,72 @@
+package profile
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWords(t *testing.T) {
+	require.Equal(t, []string{"jose", "perez", "42"}, words("  José PÉREZ, #42 "))
+	require.Empty(t, words("-- !"))
+}
+
+func TestWordScore(t *testing.T) {
+	tests := []struct {
+		it   string
+		q, w string
+		want float64
+	}{
+		{it: "matches equal words", q: "gomez", w: "gomez", want: scoreExact},
+		{it: "matches prefixes", q: "go", w: "gomez", want: scorePrefix},
+		{it: "ignores single rune prefixes", q: "g", w: "gomez", want: 0},
+		{it: "matches a substituted rune", q: "gomes", w: "gomez", want: scoreTypo},
+		{it: "matches a missing rune", q: "gmez", w: "gomez", want: scoreTypo},
+		{it: "matches an extra rune", q: "gommez", w: "gomez", want: scoreTypo},
+		{it: "matches swapped runes", q: "gmoez", w: "gomez", want: scoreTypo},
+		{it: "ignores typos in short words", q: "ane", w: "ana", want: 0},
+		{it: "ignores two edits", q: "gamas", w: "gomez", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			require.Equal(t, tt.want, wordScore(tt.q, tt.w))
+		})
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	e := &Entity{
+		Type:       "Contact",
+		Attributes: Attribute{"name": "Ana", "city": "Rosario", "tags": []any{"vip"}},
+		Metadata:   Metadata{"source": "crm"},
+	}
+	has := func(terms []string, field, word string) bool {
+		return slices.Contains(terms, pathTag(field)+word)
+	}
+
+	t.Run("indexes the default fields", func(t *testing.T) {
+		terms := e.SearchTerms()
+		require.True(t, has(terms, "attributes.name", "ana"))
+		require.True(t, has(terms, "attributes.name", "an"))
+		require.False(t, has(terms, "attributes.city", "rosario"))
+	})
+
+	t.Run("indexes only the search paths", func(t *testing.T) {
+		e.SearchPaths = []string{"attributes.city", "attributes.tags", "metadata.source"}
+		terms := e.SearchTerms()
+		require.True(t, has(terms, "attributes.city", "rosario"))
+		require.True(t, has(terms, "attributes.city", "rosaro"))
+		require.True(t, has(terms, "attributes.tags", "vip"))
+		require.True(t, has(terms, "metadata.source", "crm"))
+		require.False(t, has(terms, "attributes.name", "ana"))
+	})
+
+	t.Run("finds the indexed words with a query", func(t *testing.T) {
+		terms := e.SearchTerms()
+		for _, q := range []string{"rosario", "ros", "rosarion", "rozario"} {
+			query := textQuery([]string{"attributes.city"}, []string{q})
+			require.True(t, slices.ContainsFunc(query, func(term string) bool { return slices.Contains(terms, term) }), q)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package relationship
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// deleter implements the relationship type deletion service.
+type deleter struct {
+	repo Repository
+}
+
+func NewDeleter(repo Repository) *deleter {
+	return &deleter{repo: repo}
+}
+
+// Delete removes the definition of a relationship type, leaving its relationships free form.
+func (s *deleter) Delete(ctx context.Context, accountID, name string) error {
+	if accountID == "" {
+		return ErrAccountIDMissing
+	}
+	if name == "" {
+		return ErrNameMissing
+	}
+
+	d, err := byName(ctx, s.repo, accountID, name)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(s.repo.Delete(ctx, accountID, d.ID))
+}
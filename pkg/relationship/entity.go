@@ -0,0 +1,171 @@
+package relationship
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Cardinalities of a relationship type, read from the source to the target.
+const (
+	OneToOne   = "one-to-one"   // A source relates to a single target, which relates to a single source
+	OneToMany  = "one-to-many"  // A source relates to many targets, each related to a single source
+	ManyToMany = "many-to-many" // Any number of relationships on either side
+)
+
+// Types of the attributes a relationship may carry.
+const (
+	AttributeString  = "string"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+	AttributeDate    = "date" // RFC 3339 date-time or full date, e.g. '2024-01-31'
+)
+
+// Definition declares a relationship type of an account: which entity types it connects, how many of
+// them, how it reads from the target side and the attributes its relationships may carry.
+// Relationship types without a definition remain free form.
+type Definition struct {
+	ID          string      `json:"id"`                             // Unique identifier for the definition
+	AccountID   string      `json:"accountId" bson:"accountId"`     // ID of the associated account
+	Name        string      `json:"name" bson:"name"`               // Relationship type, e.g. 'buysFrom'
+	Inverse     string      `json:"inverse" bson:"inverse"`         // Name of the relationship seen from the target, e.g. 'sellsTo'
+	SourceTypes []string    `json:"sourceTypes" bson:"sourceTypes"` // Entity types allowed as source, any when empty
+	TargetTypes []string    `json:"targetTypes" bson:"targetTypes"` // Entity types allowed as target, any when empty
+	Cardinality string      `json:"cardinality" bson:"cardinality"` // Cardinality of the relationship, defaults to ManyToMany
+	Attributes  []Attribute `json:"attributes" bson:"attributes"`   // Attributes the relationships may carry
+
+	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"` // Timestamp of definition creation
+	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"` // Timestamp of last definition update
+}
+
+// Attribute declares an attribute relationships of a type may carry.
+type Attribute struct {
+	Name     string `json:"name" bson:"name"`         // Name of the attribute, e.g. 'since'
+	Type     string `json:"type" bson:"type"`         // Type of the attribute value
+	Required bool   `json:"required" bson:"required"` // Whether every relationship must carry the attribute
+}
+
+// GetID returns the definition's unique identifier.
+func (d *Definition) GetID() string {
+	return d.ID
+}
+
+// SetID sets the definition's unique identifier.
+func (d *Definition) SetID(id string) {
+	d.ID = id
+}
+
+// GetCreatedAt returns the timestamp of when the definition was created.
+func (d *Definition) GetCreatedAt() *time.Time {
+	return d.CreatedAt
+}
+
+// SetCreatedAt sets the timestamp of when the definition was created.
+func (d *Definition) SetCreatedAt(t time.Time) {
+	d.CreatedAt = &t
+}
+
+// GetUpdatedAt returns the timestamp of the last update to the definition.
+func (d *Definition) GetUpdatedAt() *time.Time {
+	return d.UpdatedAt
+}
+
+// SetUpdatedAt sets the timestamp of the last update to the definition.
+func (d *Definition) SetUpdatedAt(t time.Time) {
+	d.UpdatedAt = &t
+}
+
+// Connects reports whether the relationship type may go from an entity of sourceType to one of targetType.
+func (d *Definition) Connects(sourceType, targetType string) bool {
+	return allows(d.SourceTypes, sourceType) && allows(d.TargetTypes, targetType)
+}
+
+// SingleTarget reports whether a source may hold a single relationship of the type.
+func (d *Definition) SingleTarget() bool {
+	return d.Cardinality == OneToOne
+}
+
+// SingleSource reports whether a target may be related by a single source through the type.
+func (d *Definition) SingleSource() bool {
+	return d.Cardinality == OneToOne || d.Cardinality == OneToMany
+}
+
+// CheckAttributes verifies the attributes of a relationship against the declared ones.
+func (d *Definition) CheckAttributes(attributes map[string]any) error {
+	declared := map[string]Attribute{}
+	for _, a := range d.Attributes {
+		declared[a.Name] = a
+		if _, ok := attributes[a.Name]; a.Required && !ok {
+			return errors.Wrap(ErrAttributeRequired, a.Name)
+		}
+	}
+	for name, value := range attributes {
+		a, ok := declared[name]
+		if !ok {
+			return errors.Wrap(ErrAttributeUnknown, name)
+		}
+		if !a.accepts(value) {
+			return errors.Wrap(ErrAttributeInvalid, name)
+		}
+	}
+	return nil
+}
+
+// accepts reports whether value is of the attribute type.
+func (a Attribute) accepts(value any) bool {
+	switch a.Type {
+	case AttributeString:
+		_, ok := value.(string)
+		return ok
+	case AttributeNumber:
+		switch value.(type) {
+		case int, int32, int64, float32, float64:
+			return true
+		}
+		return false
+	case AttributeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case AttributeDate:
+		switch v := value.(type) {
+		case time.Time:
+			return true
+		case string:
+			if _, err := time.Parse(time.RFC3339, v); err == nil {
+				return true
+			}
+			_, err := time.Parse(time.DateOnly, v)
+			return err == nil
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func allows(types []string, entityType string) bool {
+	return len(types) == 0 || slices.Contains(types, entityType)
+}
+
+type Saver interface {
+	Create(ctx context.Context, accountId string, definition *Definition) (*Definition, error)
+	Update(ctx context.Context, accountId, name string, definition *Definition) (*Definition, error)
+}
+
+type Deleter interface {
+	Delete(ctx context.Context, accountId, name string) error
+}
+
+type Getter interface {
+	Get(ctx context.Context, accountId, name string) (*Definition, error)
+	GetAll(ctx context.Context, accountId string, currentPage, perPage int) ([]*Definition, int, error)
+}
+
+type Repository interface {
+	Upsert(ctx context.Context, accountId string, definition *Definition) (*Definition, error)
+	Delete(ctx context.Context, accountId, id string) error
+	GetAll(ctx context.Context, accountId string, page, limit int, includeDeleted bool) ([]*Definition, int, error)
+	ExecuteQuery(ctx context.Context, accountId string, query map[string]any, page, limit int) ([]*Definition, int, error)
+}
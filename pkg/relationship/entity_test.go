@@ -0,0 +1,37 @@
+package relationship
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAttributes(t *testing.T) {
+	d := &Definition{Attributes: []Attribute{
+		{Name: "since", Type: AttributeDate, Required: true},
+		{Name: "role", Type: AttributeString},
+		{Name: "share", Type: AttributeNumber},
+	}}
+
+	tests := []struct {
+		it         string
+		attributes map[string]any
+		want       error
+	}{
+		{it: "accepts declared attributes", attributes: map[string]any{"since": "2024-01-31T10:00:00Z", "role": "owner", "share": 0.5}},
+		{it: "accepts a full date", attributes: map[string]any{"since": "2024-01-31"}},
+		{it: "rejects a missing required attribute", attributes: map[string]any{"role": "owner"}, want: ErrAttributeRequired},
+		{it: "rejects an undeclared attribute", attributes: map[string]any{"since": "2024-01-31", "level": 1}, want: ErrAttributeUnknown},
+		{it: "rejects a value of another type", attributes: map[string]any{"since": "2024-01-31", "share": "half"}, want: ErrAttributeInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			err := d.CheckAttributes(tt.attributes)
+			if tt.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package relationship
+
+import "github.com/dportaluppi/customer-profiles-api/pkg"
+
+var (
+	ErrAccountIDMissing            = pkg.NewErrID("missing account id")
+	ErrNameMissing                 = pkg.NewErrInvalid("missing relationship type name")
+	ErrInvalid                     = pkg.NewErrInvalid("invalid relationship type data")
+	ErrInvalidCardinality          = pkg.NewErrInvalid("invalid relationship type cardinality")
+	ErrInvalidAttribute            = pkg.NewErrInvalid("invalid relationship type attribute")
+	ErrAttributeRequired           = pkg.NewErrInvalid("missing required relationship attribute")
+	ErrAttributeUnknown            = pkg.NewErrInvalid("relationship attribute is not declared by its type")
+	ErrAttributeInvalid            = pkg.NewErrInvalid("relationship attribute does not match its declared type")
+	ErrAlreadyExists               = pkg.NewErrConflict("the relationship type name is already in use")
+	ErrNotFound                    = pkg.NewErrNotFound("relationship type not found")
+	ErrInvalidPaginationParameters = pkg.NewErrInvalid("invalid relationship type pagination parameters")
+)
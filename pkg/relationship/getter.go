@@ -0,0 +1,55 @@
+package relationship
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// getter implements the relationship type retrieval service.
+type getter struct {
+	repo Repository
+}
+
+func NewGetter(repo Repository) Getter {
+	return &getter{repo: repo}
+}
+
+// Get returns the definition of a relationship type.
+func (s *getter) Get(ctx context.Context, accountID, name string) (*Definition, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if name == "" {
+		return nil, ErrNameMissing
+	}
+	return byName(ctx, s.repo, accountID, name)
+}
+
+// GetAll lists the relationship types registered for an account.
+func (s *getter) GetAll(ctx context.Context, accountID string, currentPage, perPage int) ([]*Definition, int, error) {
+	if accountID == "" {
+		return nil, 0, ErrAccountIDMissing
+	}
+	if currentPage < 1 || perPage < 1 {
+		return nil, 0, ErrInvalidPaginationParameters
+	}
+
+	definitions, count, err := s.repo.GetAll(ctx, accountID, currentPage, perPage, false)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return definitions, count, nil
+}
+
+// byName returns the definition of the relationship type with the given name.
+func byName(ctx context.Context, repo Repository, accountID, name string) (*Definition, error) {
+	definitions, _, err := repo.ExecuteQuery(ctx, accountID, map[string]any{"name": name}, 1, 1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(definitions) == 0 {
+		return nil, ErrNotFound
+	}
+	return definitions[0], nil
+}
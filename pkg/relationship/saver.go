@@ -0,0 +1,124 @@
+package relationship
+
+import (
+	"context"
+
+	errstack "github.com/pkg/errors"
+)
+
+// saver implements the relationship type saver service.
+type saver struct {
+	repo Repository
+}
+
+func NewSaver(repo Repository) *saver {
+	return &saver{repo: repo}
+}
+
+// Create registers a relationship type, whose name and inverse name must not be in use by another one.
+func (s *saver) Create(ctx context.Context, accountID string, definition *Definition) (*Definition, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if err := validate(definition); err != nil {
+		return nil, err
+	}
+	if err := s.checkNames(ctx, accountID, definition, ""); err != nil {
+		return nil, err
+	}
+
+	definition.ID = ""
+	definition.CreatedAt = nil
+	return s.save(ctx, accountID, definition)
+}
+
+// Update replaces the definition of a relationship type. Existing relationships are not checked against
+// the new rules, which apply to the following writes.
+func (s *saver) Update(ctx context.Context, accountID, name string, definition *Definition) (*Definition, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if definition == nil {
+		return nil, ErrInvalid
+	}
+	definition.Name = name
+	if err := validate(definition); err != nil {
+		return nil, err
+	}
+
+	current, err := byName(ctx, s.repo, accountID, name)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.checkNames(ctx, accountID, definition, current.ID); err != nil {
+		return nil, err
+	}
+
+	definition.ID = current.ID
+	definition.CreatedAt = current.CreatedAt
+	return s.save(ctx, accountID, definition)
+}
+
+// checkNames verifies no other relationship type than the one with the given ID uses the name or the
+// inverse name of the definition, so that either identifies a single type.
+func (s *saver) checkNames(ctx context.Context, accountID string, definition *Definition, id string) error {
+	names := []string{definition.Name}
+	if definition.Inverse != "" && definition.Inverse != definition.Name {
+		names = append(names, definition.Inverse)
+	}
+	query := map[string]any{"$or": []any{
+		map[string]any{"name": map[string]any{"$in": names}},
+		map[string]any{"inverse": map[string]any{"$in": names}},
+	}}
+	found, _, err := s.repo.ExecuteQuery(ctx, accountID, query, 1, 2)
+	if err != nil {
+		return errstack.WithStack(err)
+	}
+	for _, d := range found {
+		if d.ID != id {
+			return ErrAlreadyExists
+		}
+	}
+	return nil
+}
+
+func (s *saver) save(ctx context.Context, accountID string, definition *Definition) (*Definition, error) {
+	definition.AccountID = accountID
+	definition.UpdatedAt = nil
+	d, err := s.repo.Upsert(ctx, accountID, definition)
+	if err != nil {
+		return nil, errstack.WithStack(err)
+	}
+	return d, nil
+}
+
+func validate(definition *Definition) error {
+	if definition == nil {
+		return ErrInvalid
+	}
+	if definition.Name == "" {
+		return ErrNameMissing
+	}
+
+	switch definition.Cardinality {
+	case "":
+		definition.Cardinality = ManyToMany
+	case OneToOne, OneToMany, ManyToMany:
+	default:
+		return ErrInvalidCardinality
+	}
+
+	seen := map[string]bool{}
+	for _, a := range definition.Attributes {
+		if a.Name == "" || seen[a.Name] {
+			return errstack.Wrap(ErrInvalidAttribute, a.Name)
+		}
+		seen[a.Name] = true
+		switch a.Type {
+		case AttributeString, AttributeNumber, AttributeBoolean, AttributeDate:
+		default:
+			return errstack.Wrap(ErrInvalidAttribute, a.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package schema
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// deleter implements the schema deletion service.
+type deleter struct {
+	repo Repository
+}
+
+func NewDeleter(repo Repository) *deleter {
+	return &deleter{repo: repo}
+}
+
+// Delete removes every version of the schema of an entity type, which stops its validation.
+func (s *deleter) Delete(ctx context.Context, accountID, entityType string) error {
+	if accountID == "" {
+		return ErrAccountIDMissing
+	}
+	if entityType == "" {
+		return ErrEntityTypeMissing
+	}
+
+	versions, err := all(ctx, s.repo, accountID, entityType)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return ErrNotFound
+	}
+	for _, sc := range versions {
+		if err = s.repo.Delete(ctx, accountID, sc.ID); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Schema is a version of the JSON Schema the attributes of an entity type must conform to.
+// Every update of the schema of a type registers a new version; writes are validated against the latest.
+// Definition is kept as raw JSON so keywords like '$schema' survive being persisted.
+type Schema struct {
+	ID         string          `json:"id"`                           // Unique identifier for the schema version
+	AccountID  string          `json:"accountId" bson:"accountId"`   // ID of the associated account
+	EntityType string          `json:"entityType" bson:"entityType"` // Entity type validated by the schema, e.g. 'Contact'
+	Version    int             `json:"version" bson:"version"`       // Version of the schema, starting at 1
+	Definition json.RawMessage `json:"schema" bson:"schema"`         // JSON Schema of the entity attributes
+
+	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"` // Timestamp of schema version creation
+	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"` // Timestamp of last schema version update
+}
+
+// GetID returns the schema's unique identifier.
+func (s *Schema) GetID() string {
+	return s.ID
+}
+
+// SetID sets the schema's unique identifier.
+func (s *Schema) SetID(id string) {
+	s.ID = id
+}
+
+// GetCreatedAt returns the timestamp of when the schema version was created.
+func (s *Schema) GetCreatedAt() *time.Time {
+	return s.CreatedAt
+}
+
+// SetCreatedAt sets the timestamp of when the schema version was created.
+func (s *Schema) SetCreatedAt(t time.Time) {
+	s.CreatedAt = &t
+}
+
+// GetUpdatedAt returns the timestamp of the last update to the schema version.
+func (s *Schema) GetUpdatedAt() *time.Time {
+	return s.UpdatedAt
+}
+
+// SetUpdatedAt sets the timestamp of the last update to the schema version.
+func (s *Schema) SetUpdatedAt(t time.Time) {
+	s.UpdatedAt = &t
+}
+
+// Validate checks attributes against the schema and returns the violations found, if any.
+func (s *Schema) Validate(attributes map[string]any) ([]FieldError, error) {
+	n, err := compile(s.Definition)
+	if err != nil {
+		return nil, err
+	}
+	return n.check(attributes)
+}
+
+// FieldError reports an attribute that does not conform to the schema.
+type FieldError struct {
+	Field   string `json:"field"`   // JSON pointer to the offending value, e.g. '/attributes/email'
+	Message string `json:"message"` // What is wrong with the value
+}
+
+// ValidationError is returned when the attributes of an entity do not conform to the schema of its type.
+// It is an ErrAttributesInvalid carrying the field level errors as details.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		messages[i] = fe.Field + " " + fe.Message
+	}
+	return ErrAttributesInvalid.Error() + ": " + strings.Join(messages, ", ")
+}
+
+// Details returns the field level errors.
+func (e *ValidationError) Details() any {
+	return e.Errors
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrAttributesInvalid
+}
+
+type Saver interface {
+	Create(ctx context.Context, accountId string, schema *Schema) (*Schema, error)
+	Update(ctx context.Context, accountId, entityType string, schema *Schema) (*Schema, error)
+}
+
+type Deleter interface {
+	Delete(ctx context.Context, accountId, entityType string) error
+}
+
+type Getter interface {
+	Get(ctx context.Context, accountId, entityType string, version int) (*Schema, error)
+	GetAll(ctx context.Context, accountId, entityType string, currentPage, perPage int) ([]*Schema, int, error)
+}
+
+type Validator interface {
+	Validate(ctx context.Context, accountId, entityType string, attributes map[string]any) error
+	ForType(ctx context.Context, accountId, entityType string) (func(attributes map[string]any) error, error)
+}
+
+type Repository interface {
+	Upsert(ctx context.Context, accountId string, schema *Schema) (*Schema, error)
+	Delete(ctx context.Context, accountId, id string) error
+	GetAll(ctx context.Context, accountId string, page, limit int, includeDeleted bool) ([]*Schema, int, error)
+	ExecuteQuery(ctx context.Context, accountId string, query map[string]any, page, limit int) ([]*Schema, int, error)
+}
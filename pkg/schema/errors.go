@@ -0,0 +1,15 @@
+package schema
+
+import "github.com/dportaluppi/customer-profiles-api/pkg"
+
+var (
+	ErrAccountIDMissing            = pkg.NewErrID("missing account id")
+	ErrEntityTypeMissing           = pkg.NewErrInvalid("missing schema entity type")
+	ErrInvalid                     = pkg.NewErrInvalid("invalid schema data")
+	ErrInvalidSchema               = pkg.NewErrInvalid("invalid json schema")
+	ErrInvalidVersion              = pkg.NewErrInvalid("invalid schema version")
+	ErrAttributesInvalid           = pkg.NewErrInvalid("attributes do not conform to the entity type schema")
+	ErrAlreadyExists               = pkg.NewErrConflict("a schema is already registered for the entity type")
+	ErrNotFound                    = pkg.NewErrNotFound("schema not found")
+	ErrInvalidPaginationParameters = pkg.NewErrInvalid("invalid schema pagination parameters")
+)
@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// versionsPageSize is the page size used when loading every version of a schema.
+const versionsPageSize = 100
+
+// getter implements the schema retrieval service.
+type getter struct {
+	repo Repository
+}
+
+func NewGetter(repo Repository) Getter {
+	return &getter{repo: repo}
+}
+
+// Get returns a version of the schema of an entity type, the latest one when version is 0.
+func (s *getter) Get(ctx context.Context, accountID, entityType string, version int) (*Schema, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if entityType == "" {
+		return nil, ErrEntityTypeMissing
+	}
+	if version < 0 {
+		return nil, ErrInvalidVersion
+	}
+	if version == 0 {
+		return latest(ctx, s.repo, accountID, entityType)
+	}
+
+	query := map[string]any{"entityType": entityType, "version": version}
+	schemas, _, err := s.repo.ExecuteQuery(ctx, accountID, query, 1, 1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(schemas) == 0 {
+		return nil, ErrNotFound
+	}
+	return schemas[0], nil
+}
+
+// GetAll lists the schema versions of an account, optionally only those of an entity type.
+func (s *getter) GetAll(ctx context.Context, accountID, entityType string, currentPage, perPage int) ([]*Schema, int, error) {
+	if currentPage < 1 || perPage < 1 {
+		return nil, 0, ErrInvalidPaginationParameters
+	}
+
+	if entityType == "" {
+		schemas, count, err := s.repo.GetAll(ctx, accountID, currentPage, perPage, false)
+		if err != nil {
+			return nil, 0, errors.WithStack(err)
+		}
+		return schemas, count, nil
+	}
+
+	schemas, count, err := s.repo.ExecuteQuery(ctx, accountID, map[string]any{"entityType": entityType}, currentPage, perPage)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return schemas, count, nil
+}
+
+// latest returns the highest version of the schema of an entity type.
+func latest(ctx context.Context, repo Repository, accountID, entityType string) (*Schema, error) {
+	versions, err := all(ctx, repo, accountID, entityType)
+	if err != nil {
+		return nil, err
+	}
+	var last *Schema
+	for _, sc := range versions {
+		if last == nil || sc.Version > last.Version {
+			last = sc
+		}
+	}
+	if last == nil {
+		return nil, ErrNotFound
+	}
+	return last, nil
+}
+
+// all returns every version of the schema of an entity type.
+func all(ctx context.Context, repo Repository, accountID, entityType string) ([]*Schema, error) {
+	query := map[string]any{"entityType": entityType}
+
+	var versions []*Schema
+	for page := 1; ; page++ {
+		schemas, total, err := repo.ExecuteQuery(ctx, accountID, query, page, versionsPageSize)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		versions = append(versions, schemas...)
+		if len(schemas) == 0 || page*versionsPageSize >= total {
+			break
+		}
+	}
+	return versions, nil
+}
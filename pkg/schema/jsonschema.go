@@ -0,0 +1,450 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// node is a compiled JSON Schema. It supports the validation keywords of draft 2020-12 that make sense
+// for attributes: type, enum, const, the numeric, string, array and object keywords, format and the
+// allOf, anyOf, oneOf and not combinators. Other keywords, e.g. $ref or patternProperties, are rejected
+// rather than ignored, so that a schema never accepts the attributes it was written to reject.
+type node struct {
+	types                []string
+	enum                 []any
+	constant             any
+	hasConst             bool
+	minimum              *float64
+	maximum              *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	multipleOf           *float64
+	minLength            *int
+	maxLength            *int
+	pattern              *regexp.Regexp
+	format               string
+	items                *node
+	minItems             *int
+	maxItems             *int
+	uniqueItems          bool
+	properties           map[string]*node
+	required             []string
+	additionalProperties *node
+	noAdditional         bool
+	allOf                []*node
+	anyOf                []*node
+	oneOf                []*node
+	not                  *node
+}
+
+var jsonTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// annotations are the keywords accepted without affecting validation.
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true, "default": true,
+	"examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// compile parses a JSON Schema definition, reporting malformed keywords as ErrInvalidSchema.
+func compile(definition json.RawMessage) (*node, error) {
+	if len(definition) == 0 {
+		return nil, errors.Wrap(ErrInvalidSchema, "empty schema")
+	}
+	var raw any
+	if err := json.Unmarshal(definition, &raw); err != nil {
+		return nil, errors.Wrap(ErrInvalidSchema, err.Error())
+	}
+	return compileNode("#", raw)
+}
+
+func compileNode(path string, raw any) (*node, error) {
+	invalid := func(keyword, msg string) error {
+		return errors.Wrapf(ErrInvalidSchema, "%s/%s %s", path, keyword, msg)
+	}
+
+	n := &node{}
+	if b, ok := raw.(bool); ok {
+		if !b {
+			n.not = &node{}
+		}
+		return n, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidSchema, "%s must be an object or a boolean", path)
+	}
+	var err error
+	for keyword, value := range m {
+		switch keyword {
+		case "type":
+			switch t := value.(type) {
+			case string:
+				n.types = []string{t}
+			case []any:
+				for _, item := range t {
+					s, _ := item.(string)
+					n.types = append(n.types, s)
+				}
+			default:
+				return nil, invalid(keyword, "must be a string or an array of strings")
+			}
+			for _, t := range n.types {
+				if !jsonTypes[t] {
+					return nil, invalid(keyword, fmt.Sprintf("has an unknown type %q", t))
+				}
+			}
+		case "enum":
+			if n.enum, ok = value.([]any); !ok {
+				return nil, invalid(keyword, "must be an array")
+			}
+		case "const":
+			n.constant, n.hasConst = value, true
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			f, ok := value.(float64)
+			if !ok {
+				return nil, invalid(keyword, "must be a number")
+			}
+			switch keyword {
+			case "minimum":
+				n.minimum = &f
+			case "maximum":
+				n.maximum = &f
+			case "exclusiveMinimum":
+				n.exclusiveMinimum = &f
+			case "exclusiveMaximum":
+				n.exclusiveMaximum = &f
+			case "multipleOf":
+				if f <= 0 {
+					return nil, invalid(keyword, "must be greater than 0")
+				}
+				n.multipleOf = &f
+			}
+		case "minLength", "maxLength", "minItems", "maxItems":
+			f, ok := value.(float64)
+			if !ok || f < 0 || f != math.Trunc(f) {
+				return nil, invalid(keyword, "must be a non-negative integer")
+			}
+			i := int(f)
+			switch keyword {
+			case "minLength":
+				n.minLength = &i
+			case "maxLength":
+				n.maxLength = &i
+			case "minItems":
+				n.minItems = &i
+			case "maxItems":
+				n.maxItems = &i
+			}
+		case "pattern":
+			s, ok := value.(string)
+			if !ok {
+				return nil, invalid(keyword, "must be a string")
+			}
+			if n.pattern, err = regexp.Compile(s); err != nil {
+				return nil, invalid(keyword, "must be a valid regular expression")
+			}
+		case "format":
+			if n.format, ok = value.(string); !ok {
+				return nil, invalid(keyword, "must be a string")
+			}
+		case "uniqueItems":
+			if n.uniqueItems, ok = value.(bool); !ok {
+				return nil, invalid(keyword, "must be a boolean")
+			}
+		case "items":
+			if n.items, err = compileNode(path+"/items", value); err != nil {
+				return nil, err
+			}
+		case "properties":
+			props, ok := value.(map[string]any)
+			if !ok {
+				return nil, invalid(keyword, "must be an object")
+			}
+			n.properties = make(map[string]*node, len(props))
+			for name, prop := range props {
+				if n.properties[name], err = compileNode(path+"/properties/"+name, prop); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			list, ok := value.([]any)
+			if !ok {
+				return nil, invalid(keyword, "must be an array of strings")
+			}
+			for _, item := range list {
+				s, ok := item.(string)
+				if !ok {
+					return nil, invalid(keyword, "must be an array of strings")
+				}
+				n.required = append(n.required, s)
+			}
+		case "additionalProperties":
+			if b, ok := value.(bool); ok {
+				n.noAdditional = !b
+				continue
+			}
+			if n.additionalProperties, err = compileNode(path+"/additionalProperties", value); err != nil {
+				return nil, err
+			}
+		case "allOf", "anyOf", "oneOf":
+			list, ok := value.([]any)
+			if !ok || len(list) == 0 {
+				return nil, invalid(keyword, "must be a non-empty array")
+			}
+			nodes := make([]*node, len(list))
+			for i, item := range list {
+				if nodes[i], err = compileNode(path+"/"+keyword+"/"+strconv.Itoa(i), item); err != nil {
+					return nil, err
+				}
+			}
+			switch keyword {
+			case "allOf":
+				n.allOf = nodes
+			case "anyOf":
+				n.anyOf = nodes
+			case "oneOf":
+				n.oneOf = nodes
+			}
+		case "not":
+			if n.not, err = compileNode(path+"/not", value); err != nil {
+				return nil, err
+			}
+		default:
+			if !annotations[keyword] {
+				return nil, invalid(keyword, "is not supported")
+			}
+		}
+	}
+	return n, nil
+}
+
+// check validates attributes, which may hold values decoded from BSON, returning their violations.
+func (n *node) check(attributes map[string]any) ([]FieldError, error) {
+	var doc any = map[string]any{}
+	if attributes != nil {
+		var err error
+		if doc, err = plain(attributes); err != nil {
+			return nil, err
+		}
+	}
+	return n.validate("/attributes", doc, nil), nil
+}
+
+// validate appends the violations of value, found at path, to errs.
+func (n *node) validate(path string, value any, errs []FieldError) []FieldError {
+	fail := func(format string, args ...any) {
+		errs = append(errs, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(n.types) > 0 && !n.hasType(value) {
+		fail("must be of type %s", strings.Join(n.types, " or "))
+		return errs
+	}
+	if n.enum != nil && !contains(n.enum, value) {
+		fail("must be one of the allowed values")
+	}
+	if n.hasConst && !reflect.DeepEqual(n.constant, value) {
+		fail("must be equal to the constant value")
+	}
+
+	switch v := value.(type) {
+	case float64:
+		switch {
+		case n.minimum != nil && v < *n.minimum:
+			fail("must be greater than or equal to %v", *n.minimum)
+		case n.maximum != nil && v > *n.maximum:
+			fail("must be less than or equal to %v", *n.maximum)
+		case n.exclusiveMinimum != nil && v <= *n.exclusiveMinimum:
+			fail("must be greater than %v", *n.exclusiveMinimum)
+		case n.exclusiveMaximum != nil && v >= *n.exclusiveMaximum:
+			fail("must be less than %v", *n.exclusiveMaximum)
+		}
+		if n.multipleOf != nil {
+			if q := v / *n.multipleOf; q != math.Trunc(q) {
+				fail("must be a multiple of %v", *n.multipleOf)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if n.minLength != nil && length < *n.minLength {
+			fail("must be at least %d characters long", *n.minLength)
+		}
+		if n.maxLength != nil && length > *n.maxLength {
+			fail("must be at most %d characters long", *n.maxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(v) {
+			fail("must match the pattern %q", n.pattern.String())
+		}
+		if n.format != "" && !validFormat(n.format, v) {
+			fail("must be a valid %s", n.format)
+		}
+	case []any:
+		if n.minItems != nil && len(v) < *n.minItems {
+			fail("must have at least %d items", *n.minItems)
+		}
+		if n.maxItems != nil && len(v) > *n.maxItems {
+			fail("must have at most %d items", *n.maxItems)
+		}
+		if n.uniqueItems {
+			for i := range v {
+				if contains(v[:i], v[i]) {
+					fail("must not have duplicate items")
+					break
+				}
+			}
+		}
+		if n.items != nil {
+			for i, item := range v {
+				errs = n.items.validate(path+"/"+strconv.Itoa(i), item, errs)
+			}
+		}
+	case map[string]any:
+		for _, name := range n.required {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, FieldError{Field: path + "/" + escape(name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p := path + "/" + escape(name)
+			if prop, ok := n.properties[name]; ok {
+				errs = prop.validate(p, v[name], errs)
+				continue
+			}
+			if n.noAdditional {
+				errs = append(errs, FieldError{Field: p, Message: "is not allowed"})
+			} else if n.additionalProperties != nil {
+				errs = n.additionalProperties.validate(p, v[name], errs)
+			}
+		}
+	}
+
+	for _, sub := range n.allOf {
+		errs = sub.validate(path, value, errs)
+	}
+	if n.anyOf != nil {
+		matched := 0
+		for _, sub := range n.anyOf {
+			if len(sub.validate(path, value, nil)) == 0 {
+				matched++
+				break
+			}
+		}
+		if matched == 0 {
+			fail("must match at least one of the allowed schemas")
+		}
+	}
+	if n.oneOf != nil {
+		matched := 0
+		for _, sub := range n.oneOf {
+			if len(sub.validate(path, value, nil)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("must match exactly one of the allowed schemas")
+		}
+	}
+	if n.not != nil && len(n.not.validate(path, value, nil)) == 0 {
+		fail("must not match the disallowed schema")
+	}
+	return errs
+}
+
+func (n *node) hasType(value any) bool {
+	for _, t := range n.types {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []any:
+			if t == "array" {
+				return true
+			}
+		case map[string]any:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	dateLayout = "2006-01-02"
+	uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// validFormat checks the formats commonly used for attributes; unknown formats are not asserted.
+func validFormat(format, value string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(value)
+		return err == nil && addr.Address == value
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse(dateLayout, value)
+		return err == nil
+	case "uuid":
+		return uuidRegexp.MatchString(value)
+	}
+	return true
+}
+
+func contains(list []any, value any) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// escape escapes a property name to be used as a JSON pointer reference token.
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// plain converts attributes, which may hold values decoded from BSON, to plain JSON values.
+func plain(attributes map[string]any) (any, error) {
+	data, err := json.Marshal(attributes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var out any
+	if err = json.Unmarshal(data, &out); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return out, nil
+}
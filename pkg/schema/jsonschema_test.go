@@ -0,0 +1,197 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const contactSchema = `{
+	"type": "object",
+	"required": ["email"],
+	"additionalProperties": false,
+	"properties": {
+		"email": {"type": "string", "format": "email"},
+		"age": {"type": "integer", "minimum": 0},
+		"tier": {"enum": ["gold", "silver"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+		"address": {"type": "object", "properties": {"zip": {"type": "string", "pattern": "^[0-9]{5}$"}}}
+	}
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	sc := &Schema{Definition: json.RawMessage(contactSchema)}
+
+	tests := []struct {
+		it         string
+		attributes string
+		want       []FieldError
+	}{
+		{
+			it:         "accepts conforming attributes",
+			attributes: `{"email": "ana@example.com", "age": 31, "tier": "gold", "tags": ["vip"], "address": {"zip": "15001"}}`,
+		},
+		{
+			it:         "reports missing required properties",
+			attributes: `{"age": 31}`,
+			want:       []FieldError{{Field: "/attributes/email", Message: "is required"}},
+		},
+		{
+			it:         "reports every violation with its path",
+			attributes: `{"email": "not-an-email", "age": 1.5, "tier": "bronze", "tags": ["a", 1], "address": {"zip": "ABC"}, "extra": true}`,
+			want: []FieldError{
+				{Field: "/attributes/address/zip", Message: `must match the pattern "^[0-9]{5}$"`},
+				{Field: "/attributes/age", Message: "must be of type integer"},
+				{Field: "/attributes/email", Message: "must be a valid email"},
+				{Field: "/attributes/extra", Message: "is not allowed"},
+				{Field: "/attributes/tags/1", Message: "must be of type string"},
+				{Field: "/attributes/tier", Message: "must be one of the allowed values"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			var attributes map[string]any
+			require.NoError(t, json.Unmarshal([]byte(tt.attributes), &attributes))
+
+			got, err := sc.Validate(attributes)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		it         string
+		definition string
+		valid      bool
+	}{
+		{it: "accepts boolean schemas", definition: `true`, valid: true},
+		{it: "rejects unknown types", definition: `{"type": "date"}`},
+		{it: "rejects malformed patterns", definition: `{"pattern": "("}`},
+		{it: "accepts annotations", definition: `{"title": "Contact", "description": "A person", "examples": [{}]}`, valid: true},
+		{it: "rejects references", definition: `{"$ref": "#/definitions/contact"}`},
+		{it: "rejects pattern properties", definition: `{"patternProperties": {"^x-": {"type": "string"}}}`},
+		{it: "rejects dependent requirements", definition: `{"dependentRequired": {"phone": ["country"]}}`},
+		{it: "rejects unsupported nested keywords", definition: `{"items": {"contains": {"type": "string"}}}`},
+		{it: "rejects malformed nested schemas", definition: `{"properties": {"age": {"minimum": "0"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			_, err := compile(json.RawMessage(tt.definition))
+			if tt.valid {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidSchema)
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	ctx := context.Background()
+	repo := &memoryRepository{}
+	_, err := NewSaver(repo).Create(ctx, "acc", &Schema{EntityType: "Contact", Definition: json.RawMessage(contactSchema)})
+	require.NoError(t, err)
+
+	validator := NewValidator(repo)
+
+	t.Run("accepts any attributes for types without a schema", func(t *testing.T) {
+		require.NoError(t, validator.Validate(ctx, "acc", "Store", map[string]any{"anything": 1}))
+	})
+
+	t.Run("returns an invalid error with field details", func(t *testing.T) {
+		err := validator.Validate(ctx, "acc", "Contact", map[string]any{})
+
+		var invalid pkg.ErrInvalidType
+		require.True(t, errors.As(err, &invalid))
+		var validationErr *ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		require.Equal(t, []FieldError{{Field: "/attributes/email", Message: "is required"}}, validationErr.Details())
+	})
+
+	t.Run("validates against the latest version", func(t *testing.T) {
+		_, err := NewSaver(repo).Update(ctx, "acc", "Contact", &Schema{Definition: json.RawMessage(`{"type": "object"}`)})
+		require.NoError(t, err)
+
+		require.NoError(t, validator.Validate(ctx, "acc", "Contact", map[string]any{}))
+	})
+}
+
+func TestValidatorCompilesOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := &memoryRepository{}
+	_, err := NewSaver(repo).Create(ctx, "acc", &Schema{EntityType: "Contact", Definition: json.RawMessage(`{"type": "object"}`)})
+	require.NoError(t, err)
+
+	v := newValidator(repo, maxCompiled)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, v.Validate(ctx, "acc", "Contact", map[string]any{}))
+	}
+	require.Len(t, v.compiled, 1)
+
+	_, err = NewSaver(repo).Update(ctx, "acc", "Contact", &Schema{Definition: json.RawMessage(`{"type": "object", "required": ["name"]}`)})
+	require.NoError(t, err)
+	require.Error(t, v.Validate(ctx, "acc", "Contact", map[string]any{}))
+	require.Len(t, v.compiled, 2)
+}
+
+func TestValidatorEvictsLeastRecentlyUsed(t *testing.T) {
+	v := newValidator(&memoryRepository{}, 2)
+	schema := func(entityType string) *Schema {
+		return &Schema{AccountID: "acc", EntityType: entityType, Version: 1, Definition: json.RawMessage(`{"type": "object"}`)}
+	}
+	compile := func(entityType string) *node {
+		n, err := v.compile(schema(entityType))
+		require.NoError(t, err)
+		return n
+	}
+
+	contact := compile("Contact")
+	compile("Store")
+	require.Same(t, contact, compile("Contact"), "a compiled version is reused")
+	compile("Rep")
+
+	require.Len(t, v.compiled, 2)
+	require.Contains(t, v.compiled, "acc/Contact/1")
+	require.Contains(t, v.compiled, "acc/Rep/1")
+	require.NotContains(t, v.compiled, "acc/Store/1", "the least recently used version is evicted")
+	require.Same(t, contact, compile("Contact"))
+}
+
+// memoryRepository is a minimal Repository matching schemas by entity type and version.
+type memoryRepository struct {
+	schemas []*Schema
+}
+
+func (r *memoryRepository) Upsert(_ context.Context, _ string, schema *Schema) (*Schema, error) {
+	r.schemas = append(r.schemas, schema)
+	return schema, nil
+}
+
+func (r *memoryRepository) Delete(context.Context, string, string) error {
+	return nil
+}
+
+func (r *memoryRepository) GetAll(_ context.Context, _ string, _, _ int, _ bool) ([]*Schema, int, error) {
+	return r.schemas, len(r.schemas), nil
+}
+
+func (r *memoryRepository) ExecuteQuery(_ context.Context, _ string, query map[string]any, _, _ int) ([]*Schema, int, error) {
+	var found []*Schema
+	for _, sc := range r.schemas {
+		if sc.EntityType != query["entityType"] {
+			continue
+		}
+		if v, ok := query["version"]; ok && v != sc.Version {
+			continue
+		}
+		found = append(found, sc)
+	}
+	return found, len(found), nil
+}
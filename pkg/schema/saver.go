@@ -0,0 +1,83 @@
+package schema
+
+import (
+	"context"
+
+	errstack "github.com/pkg/errors"
+)
+
+// saver implements the schema saver service.
+type saver struct {
+	repo Repository
+}
+
+func NewSaver(repo Repository) *saver {
+	return &saver{repo: repo}
+}
+
+// Create registers the first version of the schema of an entity type.
+func (s *saver) Create(ctx context.Context, accountID string, schema *Schema) (*Schema, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if err := validate(schema); err != nil {
+		return nil, err
+	}
+
+	_, err := latest(ctx, s.repo, accountID, schema.EntityType)
+	if err == nil {
+		return nil, ErrAlreadyExists
+	}
+	if !errstack.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	return s.save(ctx, accountID, schema, 1)
+}
+
+// Update registers a new version of the schema of an entity type.
+func (s *saver) Update(ctx context.Context, accountID, entityType string, schema *Schema) (*Schema, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if schema == nil {
+		return nil, ErrInvalid
+	}
+	schema.EntityType = entityType
+	if err := validate(schema); err != nil {
+		return nil, err
+	}
+
+	current, err := latest(ctx, s.repo, accountID, entityType)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.save(ctx, accountID, schema, current.Version+1)
+}
+
+func (s *saver) save(ctx context.Context, accountID string, schema *Schema, version int) (*Schema, error) {
+	schema.ID = ""
+	schema.AccountID = accountID
+	schema.Version = version
+	schema.CreatedAt = nil
+	schema.UpdatedAt = nil
+	sc, err := s.repo.Upsert(ctx, accountID, schema)
+	if err != nil {
+		return nil, errstack.WithStack(err)
+	}
+	return sc, nil
+}
+
+func validate(schema *Schema) error {
+	if schema == nil {
+		return ErrInvalid
+	}
+	if schema.EntityType == "" {
+		return ErrEntityTypeMissing
+	}
+	if _, err := compile(schema.Definition); err != nil {
+		return err
+	}
+	return nil
+}
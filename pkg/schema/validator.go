@@ -0,0 +1,107 @@
+package schema
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// maxCompiled caps the schema versions kept compiled by a validator.
+const maxCompiled = 1000
+
+// validator implements the validation of entity attributes against the schema registry. Schema versions
+// are immutable, so each is compiled once and kept by account, entity type and version. Past limit, the
+// least recently used version is dropped, so that the schemas of a busy account do not evict every other.
+type validator struct {
+	repo  Repository
+	limit int
+
+	mu       sync.Mutex
+	compiled map[string]*list.Element // Elements of recent, keyed by account, entity type and version
+	recent   *list.List               // Compiled versions, the most recently used first
+}
+
+// compiledSchema is a compiled schema version kept by a validator.
+type compiledSchema struct {
+	key  string
+	node *node
+}
+
+func NewValidator(repo Repository) Validator {
+	return newValidator(repo, maxCompiled)
+}
+
+func newValidator(repo Repository, limit int) *validator {
+	return &validator{repo: repo, limit: limit, compiled: map[string]*list.Element{}, recent: list.New()}
+}
+
+// Validate checks attributes against the latest schema of the entity type, returning a *ValidationError
+// listing every violation. Entity types without a schema accept any attributes.
+func (s *validator) Validate(ctx context.Context, accountID, entityType string, attributes map[string]any) error {
+	check, err := s.ForType(ctx, accountID, entityType)
+	if err != nil {
+		return err
+	}
+	return check(attributes)
+}
+
+// ForType loads the latest schema of the entity type once and returns a function checking attributes
+// against it like Validate, for validating many entities of the type.
+func (s *validator) ForType(ctx context.Context, accountID, entityType string) (func(attributes map[string]any) error, error) {
+	sc, err := latest(ctx, s.repo, accountID, entityType)
+	if errors.Is(err, ErrNotFound) {
+		return func(map[string]any) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.compile(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(attributes map[string]any) error {
+		fieldErrors, err := n.check(attributes)
+		if err != nil {
+			return err
+		}
+		if len(fieldErrors) > 0 {
+			return &ValidationError{Errors: fieldErrors}
+		}
+		return nil
+	}, nil
+}
+
+// compile returns the compiled schema version, compiling it unless already done.
+func (s *validator) compile(sc *Schema) (*node, error) {
+	key := fmt.Sprintf("%s/%s/%d", sc.AccountID, sc.EntityType, sc.Version)
+	s.mu.Lock()
+	if e, ok := s.compiled[key]; ok {
+		s.recent.MoveToFront(e)
+		s.mu.Unlock()
+		return e.Value.(*compiledSchema).node, nil
+	}
+	s.mu.Unlock()
+
+	n, err := compile(sc.Definition)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Another validation may have compiled the version meanwhile
+	if e, ok := s.compiled[key]; ok {
+		s.recent.MoveToFront(e)
+		return e.Value.(*compiledSchema).node, nil
+	}
+	if s.recent.Len() >= s.limit {
+		oldest := s.recent.Back()
+		s.recent.Remove(oldest)
+		delete(s.compiled, oldest.Value.(*compiledSchema).key)
+	}
+	s.compiled[key] = s.recent.PushFront(&compiledSchema{key: key, node: n})
+	return n, nil
+}
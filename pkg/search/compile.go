@@ -0,0 +1,168 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Compile validates the criteria and translates them to a Mongo filter. Errors name the offending
+// condition by its position, e.g. 'filters[1].or[0]'.
+func Compile(c Criteria) (map[string]any, error) {
+	cp := &compiler{}
+	clauses, err := cp.all(c.Filters, "filters", 0)
+	if err != nil {
+		return nil, err
+	}
+	switch len(clauses) {
+	case 0:
+		return map[string]any{}, nil
+	case 1:
+		return clauses[0].(map[string]any), nil
+	default:
+		return map[string]any{"$and": clauses}, nil
+	}
+}
+
+// compiler counts the comparisons of the criteria being compiled.
+type compiler struct {
+	comparisons int
+}
+
+func (cp *compiler) all(conds []Condition, path string, depth int) ([]any, error) {
+	clauses := make([]any, 0, len(conds))
+	for i, cond := range conds {
+		clause, err := cp.condition(cond, fmt.Sprintf("%s[%d]", path, i), depth)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, clause)
+	}
+	return clauses, nil
+}
+
+func (cp *compiler) condition(cond Condition, path string, depth int) (map[string]any, error) {
+	groups := 0
+	for _, set := range []bool{cond.And != nil, cond.Or != nil, cond.Not != nil} {
+		if set {
+			groups++
+		}
+	}
+	if groups == 0 {
+		return cp.comparison(cond, path)
+	}
+	if groups > 1 || cond.Field != "" || cond.Operator != "" || cond.Value != nil {
+		return nil, errors.Wrap(ErrInvalidCondition, path)
+	}
+	if depth >= MaxDepth {
+		return nil, errors.Wrap(ErrTooDeep, path)
+	}
+
+	if cond.Not != nil {
+		clause, err := cp.condition(*cond.Not, path+".not", depth+1)
+		if err != nil {
+			return nil, err
+		}
+		// $not only applies to a field, a whole condition is negated by $nor.
+		return map[string]any{"$nor": []any{clause}}, nil
+	}
+	op, conds := "$and", cond.And
+	if cond.Or != nil {
+		op, conds = "$or", cond.Or
+	}
+	if len(conds) == 0 {
+		return nil, errors.Wrap(ErrInvalidCondition, path)
+	}
+	clauses, err := cp.all(conds, path+"."+op[1:], depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{op: clauses}, nil
+}
+
+func (cp *compiler) comparison(cond Condition, path string) (map[string]any, error) {
+	cp.comparisons++
+	if cp.comparisons > MaxConditions {
+		return nil, errors.Wrap(ErrTooLarge, path)
+	}
+	if !ValidField(cond.Field) {
+		return nil, errors.Wrapf(ErrInvalidField, "%s field %q", path, cond.Field)
+	}
+	invalid := errors.Wrapf(ErrInvalidValue, "%s %s", path, cond.Operator)
+
+	var expr map[string]any
+	switch op := cond.Operator; op {
+	case "", OpEq, OpNe, OpGt, OpGte, OpLt, OpLte:
+		if op == "" {
+			op = OpEq
+		}
+		v, err := scalar(cond.Field, cond.Value)
+		if err != nil || (v == nil && op != OpEq && op != OpNe) {
+			return nil, invalid
+		}
+		expr = map[string]any{"$" + op: v}
+	case OpIn, OpNin:
+		list, ok := cond.Value.([]any)
+		if !ok || len(list) == 0 || len(list) > MaxValues {
+			return nil, invalid
+		}
+		values := make([]any, 0, len(list))
+		for _, item := range list {
+			v, err := scalar(cond.Field, item)
+			if err != nil {
+				return nil, invalid
+			}
+			values = append(values, v)
+		}
+		expr = map[string]any{"$" + op: values}
+	case OpExists:
+		exists, ok := cond.Value.(bool)
+		if !ok {
+			return nil, invalid
+		}
+		expr = map[string]any{"$exists": exists}
+	case OpContains, OpStartsWith:
+		s, ok := cond.Value.(string)
+		if !ok || s == "" || len(s) > MaxValueLength {
+			return nil, invalid
+		}
+		// The value is matched literally, never as a pattern.
+		if op == OpContains {
+			expr = map[string]any{"$regex": regexp.QuoteMeta(s), "$options": "i"}
+		} else {
+			expr = map[string]any{"$regex": "^" + regexp.QuoteMeta(s)}
+		}
+	default:
+		return nil, errors.Wrapf(ErrInvalidOperator, "%s operator %q", path, op)
+	}
+	return map[string]any{cond.Field: expr}, nil
+}
+
+// scalar checks a compared value is a string, number, boolean or null, parsing the values compared with
+// timestamps.
+func scalar(field string, v any) (any, error) {
+	switch value := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if len(value) > MaxValueLength {
+			return nil, ErrInvalidValue
+		}
+		if timeFields[field] {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, ErrInvalidValue
+			}
+			return t, nil
+		}
+		return value, nil
+	case bool, float64, int, int32, int64:
+		if timeFields[field] {
+			return nil, ErrInvalidValue
+		}
+		return value, nil
+	}
+	return nil, ErrInvalidValue
+}
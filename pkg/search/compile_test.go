@@ -0,0 +1,151 @@
+package search
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		it       string
+		criteria string
+		want     map[string]any
+		err      error
+	}{
+		{
+			it:       "matches everything without filters",
+			criteria: `{}`,
+			want:     map[string]any{},
+		},
+		{
+			it:       "defaults to equality",
+			criteria: `{"filters": [{"field": "attributes.email", "value": "ana@example.com"}]}`,
+			want:     map[string]any{"attributes.email": map[string]any{"$eq": "ana@example.com"}},
+		},
+		{
+			it: "combines the filters and groups",
+			criteria: `{"filters": [
+				{"field": "type", "value": "Contact"},
+				{"or": [
+					{"field": "attributes.age", "operator": "gte", "value": 18},
+					{"not": {"field": "attributes.phone", "operator": "exists", "value": true}}
+				]}
+			]}`,
+			want: map[string]any{"$and": []any{
+				map[string]any{"type": map[string]any{"$eq": "Contact"}},
+				map[string]any{"$or": []any{
+					map[string]any{"attributes.age": map[string]any{"$gte": float64(18)}},
+					map[string]any{"$nor": []any{map[string]any{"attributes.phone": map[string]any{"$exists": true}}}},
+				}},
+			}},
+		},
+		{
+			it:       "matches strings literally",
+			criteria: `{"filters": [{"field": "attributes.email", "operator": "contains", "value": "a.b+c"}]}`,
+			want:     map[string]any{"attributes.email": map[string]any{"$regex": `a\.b\+c`, "$options": "i"}},
+		},
+		{
+			it:       "parses timestamps",
+			criteria: `{"filters": [{"field": "createdAt", "operator": "lt", "value": "2024-01-02T03:04:05Z"}]}`,
+			want:     map[string]any{"createdAt": map[string]any{"$lt": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}},
+		},
+		{
+			it:       "compiles lists",
+			criteria: `{"filters": [{"field": "relationships.type", "operator": "in", "value": ["buysFrom", "sellsFor"]}]}`,
+			want:     map[string]any{"relationships.type": map[string]any{"$in": []any{"buysFrom", "sellsFor"}}},
+		},
+		{
+			it:       "rejects fields outside attributes and metadata",
+			criteria: `{"filters": [{"field": "accountId", "value": "other"}]}`,
+			err:      ErrInvalidField,
+		},
+		{
+			it:       "rejects operators in paths",
+			criteria: `{"filters": [{"field": "attributes.$where", "value": "sleep(1000)"}]}`,
+			err:      ErrInvalidField,
+		},
+		{
+			it:       "rejects unknown operators",
+			criteria: `{"filters": [{"field": "attributes.name", "operator": "regex", "value": ".*"}]}`,
+			err:      ErrInvalidOperator,
+		},
+		{
+			it:       "rejects documents as values",
+			criteria: `{"filters": [{"field": "attributes.name", "value": {"$ne": null}}]}`,
+			err:      ErrInvalidValue,
+		},
+		{
+			it:       "rejects invalid timestamps",
+			criteria: `{"filters": [{"field": "updatedAt", "operator": "gt", "value": "yesterday"}]}`,
+			err:      ErrInvalidValue,
+		},
+		{
+			it:       "rejects conditions mixing a comparison and a group",
+			criteria: `{"filters": [{"field": "type", "value": "Contact", "or": [{"field": "type", "value": "Store"}]}]}`,
+			err:      ErrInvalidCondition,
+		},
+		{
+			it:       "rejects empty groups",
+			criteria: `{"filters": [{"and": []}]}`,
+			err:      ErrInvalidCondition,
+		},
+		{
+			it:       "rejects deep nesting",
+			criteria: `{"filters": [` + strings.Repeat(`{"not": `, MaxDepth+1) + `{"field": "type", "value": "Contact"}` + strings.Repeat(`}`, MaxDepth+1) + `]}`,
+			err:      ErrTooDeep,
+		},
+		{
+			it:       "rejects too many conditions",
+			criteria: `{"filters": [` + strings.Repeat(`{"field": "type", "value": "Contact"},`, MaxConditions) + `{"field": "type", "value": "Contact"}]}`,
+			err:      ErrTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			var c Criteria
+			require.NoError(t, json.Unmarshal([]byte(tt.criteria), &c))
+			got, err := Compile(c)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+			require.NoError(t, Validate(got))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		it     string
+		filter string
+		err    error
+	}{
+		{it: "accepts comparisons", filter: `{"attributes.age": {"$gte": 18, "$lt": 65}, "type": "Contact"}`},
+		{it: "accepts logical operators", filter: `{"$or": [{"metadata.source": "crm"}, {"deletedAt": null}]}`},
+		{it: "accepts element matches", filter: `{"relationships": {"$elemMatch": {"type": "buysFrom", "targetId": {"$in": ["a", "b"]}}}}`},
+		{it: "rejects $where", filter: `{"$where": "sleep(1000)"}`, err: ErrInvalidOperator},
+		{it: "rejects $expr", filter: `{"$expr": {"$function": {"body": "return true", "args": [], "lang": "js"}}}`, err: ErrInvalidOperator},
+		{it: "rejects operators hidden in values", filter: `{"attributes.name": {"$in": [{"$ne": null}]}}`, err: ErrInvalidValue},
+		{it: "rejects document equality", filter: `{"attributes.address": {"city": "Rosario"}}`, err: ErrInvalidValue},
+		{it: "rejects other fields", filter: `{"accountId": "other"}`, err: ErrInvalidField},
+		{it: "rejects element fields outside the entity", filter: `{"relationships": {"$elemMatch": {"$where": "true"}}}`, err: ErrInvalidOperator},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			var filter map[string]any
+			require.NoError(t, json.Unmarshal([]byte(tt.filter), &filter))
+			err := Validate(filter)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
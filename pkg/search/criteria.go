@@ -0,0 +1,93 @@
+package search
+
+import "strings"
+
+// Operators comparing the value of a field in a Condition.
+const (
+	OpEq         = "eq"         // Equal to the value, the default operator
+	OpNe         = "ne"         // Not equal to the value, or missing
+	OpGt         = "gt"         // Greater than the value
+	OpGte        = "gte"        // Greater than or equal to the value
+	OpLt         = "lt"         // Less than the value
+	OpLte        = "lte"        // Less than or equal to the value
+	OpIn         = "in"         // Equal to one of the values of a list
+	OpNin        = "nin"        // Equal to none of the values of a list
+	OpExists     = "exists"     // Present when the value is true, missing when false
+	OpContains   = "contains"   // String holding the value, ignoring case
+	OpStartsWith = "startsWith" // String starting with the value, case sensitive so an index can serve it
+)
+
+// Limits of the criteria, bounding the cost of the filters they compile to.
+const (
+	MaxDepth       = 5    // Maximum nesting of groups
+	MaxConditions  = 50   // Maximum number of field comparisons
+	MaxValues      = 100  // Maximum number of values of an in or nin list
+	MaxValueLength = 1024 // Maximum length of a string value
+)
+
+// Criteria selects the entities matching every one of its filters and, unless empty, the free-text query.
+//
+//	{"query": "ana gomez", "filters": [
+//	  {"field": "type", "value": "Contact"},
+//	  {"or": [
+//	    {"field": "attributes.email", "operator": "contains", "value": "@example.com"},
+//	    {"not": {"field": "attributes.phone", "operator": "exists", "value": true}}
+//	  ]}
+//	]}
+type Criteria struct {
+	Query   string      `json:"query,omitempty"` // Words searched in the search fields of the entities, results then ordered by relevance
+	Filters []Condition `json:"filters"`         // Conditions the entities must all match
+}
+
+// Condition is either a comparison of a field with a value, or a group matching all of the And conditions,
+// any of the Or conditions or not the Not condition.
+type Condition struct {
+	Field    string      `json:"field,omitempty"`    // Dotted path of the compared field, e.g. 'attributes.email'
+	Operator string      `json:"operator,omitempty"` // Comparison operator, OpEq when empty
+	Value    any         `json:"value"`              // Compared value, a string, number, boolean or null, or a list of them for in and nin
+	And      []Condition `json:"and,omitempty"`      // Conditions all matching
+	Or       []Condition `json:"or,omitempty"`       // Conditions one of which at least matching
+	Not      *Condition  `json:"not,omitempty"`      // Condition not matching
+}
+
+// searchableFields are the entity fields that may be searched, besides attributes and metadata entries.
+var searchableFields = map[string]bool{
+	"id":                     true,
+	"type":                   true,
+	"version":                true,
+	"mergedIds":              true,
+	"mergedInto":             true,
+	"deletedAt":              true,
+	"deletedBy":              true,
+	"createdAt":              true,
+	"updatedAt":              true,
+	"relationships":          true,
+	"relationships.id":       true,
+	"relationships.type":     true,
+	"relationships.targetId": true,
+}
+
+// timeFields are the searchable fields holding timestamps, compared with RFC 3339 values.
+var timeFields = map[string]bool{
+	"deletedAt": true,
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+// ValidField reports whether a field may be searched: a searchable entity field or the dotted path of an
+// attributes or metadata entry, possibly nested, e.g. 'attributes.address.city'.
+func ValidField(field string) bool {
+	if searchableFields[field] {
+		return true
+	}
+	path := strings.Split(field, ".")
+	if len(path) < 2 || (path[0] != "attributes" && path[0] != "metadata") {
+		return false
+	}
+	for _, segment := range path[1:] {
+		if segment == "" || strings.HasPrefix(segment, "$") {
+			return false
+		}
+	}
+	return true
+}
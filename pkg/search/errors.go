@@ -0,0 +1,12 @@
+package search
+
+import "github.com/dportaluppi/customer-profiles-api/pkg"
+
+var (
+	ErrInvalidCondition = pkg.NewErrInvalid("a condition must be either a field comparison or a single and, or or not group")
+	ErrInvalidOperator  = pkg.NewErrInvalid("unsupported search operator")
+	ErrInvalidField     = pkg.NewErrInvalid("field cannot be searched, expected an attributes or metadata path or a searchable entity field")
+	ErrInvalidValue     = pkg.NewErrInvalid("invalid value for the search operator")
+	ErrTooDeep          = pkg.NewErrInvalid("search criteria nest too many groups")
+	ErrTooLarge         = pkg.NewErrInvalid("search criteria hold too many conditions")
+)
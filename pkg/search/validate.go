@@ -0,0 +1,165 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// fieldOperators are the Mongo operators a raw filter may apply to a field, those criteria compile to along
+// with $not and $elemMatch.
+var fieldOperators = map[string]bool{
+	"$eq":        true,
+	"$ne":        true,
+	"$gt":        true,
+	"$gte":       true,
+	"$lt":        true,
+	"$lte":       true,
+	"$in":        true,
+	"$nin":       true,
+	"$exists":    true,
+	"$regex":     true,
+	"$options":   true,
+	"$not":       true,
+	"$elemMatch": true,
+}
+
+// Validate checks a raw Mongo filter, e.g. the one of a segment, only compares searchable fields with
+// literal values through the operators criteria compile to, within the limits of criteria. It rejects
+// anything running code on the server, such as $where or $function, or matching whole documents.
+func Validate(filter map[string]any) error {
+	v := &validator{}
+	return v.filter(filter, 0)
+}
+
+// validator counts the comparisons of the filter being validated.
+type validator struct {
+	comparisons int
+}
+
+func (v *validator) filter(filter map[string]any, depth int) error {
+	if depth > MaxDepth {
+		return ErrTooDeep
+	}
+	for key, cond := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			clauses, ok := cond.([]any)
+			if !ok || len(clauses) == 0 {
+				return errors.Wrap(ErrInvalidCondition, key)
+			}
+			for _, clause := range clauses {
+				nested, ok := clause.(map[string]any)
+				if !ok {
+					return errors.Wrap(ErrInvalidCondition, key)
+				}
+				if err := v.filter(nested, depth+1); err != nil {
+					return err
+				}
+			}
+		default:
+			if strings.HasPrefix(key, "$") {
+				return errors.Wrapf(ErrInvalidOperator, "operator %q", key)
+			}
+			if !ValidField(key) {
+				return errors.Wrapf(ErrInvalidField, "field %q", key)
+			}
+			v.comparisons++
+			if v.comparisons > MaxConditions {
+				return ErrTooLarge
+			}
+			if err := v.condition(key, cond, depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// condition checks the condition of a field, either a literal or a document of operators.
+func (v *validator) condition(field string, cond any, depth int) error {
+	ops, ok := cond.(map[string]any)
+	if !ok {
+		return literal(field, cond)
+	}
+	for op, arg := range ops {
+		if !strings.HasPrefix(op, "$") {
+			// A document compared as a whole, which may hide operators deeper in it.
+			return errors.Wrapf(ErrInvalidValue, "field %q", field)
+		}
+		if !fieldOperators[op] {
+			return errors.Wrapf(ErrInvalidOperator, "field %q operator %q", field, op)
+		}
+		var err error
+		switch op {
+		case "$not":
+			if _, ok := arg.(map[string]any); !ok {
+				return errors.Wrapf(ErrInvalidValue, "field %q operator %q", field, op)
+			}
+			err = v.condition(field, arg, depth+1)
+		case "$elemMatch":
+			err = v.elements(field, arg, depth+1)
+		default:
+			err = literal(field, arg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// elements checks the condition an $elemMatch applies to the elements of an array field, either field
+// operators or conditions on the fields of the elements.
+func (v *validator) elements(field string, arg any, depth int) error {
+	cond, ok := arg.(map[string]any)
+	if !ok || len(cond) == 0 {
+		return errors.Wrapf(ErrInvalidValue, "field %q operator %q", field, "$elemMatch")
+	}
+	if depth > MaxDepth {
+		return ErrTooDeep
+	}
+	for key, nested := range cond {
+		if strings.HasPrefix(key, "$") {
+			if err := v.condition(field, map[string]any{key: nested}, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		if !ValidField(field + "." + key) {
+			return errors.Wrapf(ErrInvalidField, "field %q", field+"."+key)
+		}
+		if err := v.condition(field+"."+key, nested, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// literal checks a compared value holds no document, which could hide operators, and respects the size
+// limits of criteria values.
+func literal(field string, value any) error {
+	invalid := errors.Wrapf(ErrInvalidValue, "field %q", field)
+	switch v := value.(type) {
+	case map[string]any:
+		return invalid
+	case []any:
+		if len(v) > MaxValues {
+			return invalid
+		}
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				return invalid
+			}
+			if s, ok := item.(string); ok && len(s) > MaxValueLength {
+				return invalid
+			}
+		}
+	case string:
+		if len(v) > MaxValueLength {
+			return invalid
+		}
+	}
+	return nil
+}
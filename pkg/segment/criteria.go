@@ -0,0 +1,71 @@
+package segment
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
+	gojsonlogicmongodb "github.com/kubeesio/go-jsonlogic-mongodb"
+	"github.com/pkg/errors"
+)
+
+// condition is an extra equality constraint added on top of the segment criteria.
+type condition struct {
+	field  string
+	negate bool
+	value  any
+}
+
+// query is a criteria compiled to the representation expected by profile.Getter.
+type query struct {
+	dialect string
+	filter  map[string]any
+}
+
+// compile validates the criteria and converts it into a query.
+func (c Criteria) compile() (*query, error) {
+	if len(c.Filter) == 0 {
+		return nil, ErrInvalidCriteria
+	}
+
+	switch c.Dialect {
+	case DialectMongo:
+		var filter map[string]any
+		if err := json.Unmarshal(c.Filter, &filter); err != nil {
+			return nil, errors.Wrap(ErrInvalidCriteria, err.Error())
+		}
+		if err := search.Validate(filter); err != nil {
+			return nil, errors.Wrap(ErrInvalidCriteria, err.Error())
+		}
+		return &query{dialect: c.Dialect, filter: filter}, nil
+	case DialectJSONLogic:
+		expr, err := gojsonlogicmongodb.Convert(bytes.NewReader(c.Filter))
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidCriteria, err.Error())
+		}
+		return &query{dialect: c.Dialect, filter: expr.Map()}, nil
+	default:
+		return nil, ErrUnsupportedDialect
+	}
+}
+
+// and returns a new query matching both q and the given conditions.
+func (q *query) and(conds ...condition) *query {
+	if len(conds) == 0 {
+		return q
+	}
+
+	clauses := []any{q.filter}
+	for _, cond := range conds {
+		op := "$eq"
+		if cond.negate {
+			op = "$ne"
+		}
+		if q.dialect == DialectJSONLogic {
+			clauses = append(clauses, map[string]any{op: []any{"$" + cond.field, cond.value}})
+		} else {
+			clauses = append(clauses, map[string]any{cond.field: map[string]any{op: cond.value}})
+		}
+	}
+	return &query{dialect: q.dialect, filter: map[string]any{"$and": clauses}}
+}
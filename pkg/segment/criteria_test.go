@@ -0,0 +1,111 @@
+package segment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCriteriaCompile(t *testing.T) {
+	tests := []struct {
+		it       string
+		criteria Criteria
+		want     map[string]any
+		err      error
+	}{
+		{
+			it:       "compiles mongo filters",
+			criteria: Criteria{Dialect: DialectMongo, Filter: []byte(`{"attributes.city": "Paris", "attributes.age": {"$gte": 18}}`)},
+			want:     map[string]any{"attributes.city": "Paris", "attributes.age": map[string]any{"$gte": float64(18)}},
+		},
+		{
+			it:       "compiles jsonlogic rules",
+			criteria: Criteria{Dialect: DialectJSONLogic, Filter: []byte(`{"==": [{"var": ".attributes.city"}, "Paris"]}`)},
+			want:     map[string]any{"$eq": primitive.A{"$attributes.city", "Paris"}},
+		},
+		{
+			it:       "rejects missing filters",
+			criteria: Criteria{Dialect: DialectMongo},
+			err:      ErrInvalidCriteria,
+		},
+		{
+			it:       "rejects malformed mongo filters",
+			criteria: Criteria{Dialect: DialectMongo, Filter: []byte(`["attributes.city"]`)},
+			err:      ErrInvalidCriteria,
+		},
+		{
+			it:       "rejects mongo operators running code",
+			criteria: Criteria{Dialect: DialectMongo, Filter: []byte(`{"$where": "sleep(1000)"}`)},
+			err:      ErrInvalidCriteria,
+		},
+		{
+			it:       "rejects mongo filters on other fields than searchable ones",
+			criteria: Criteria{Dialect: DialectMongo, Filter: []byte(`{"accountId": "other"}`)},
+			err:      ErrInvalidCriteria,
+		},
+		{
+			it:       "rejects malformed jsonlogic rules",
+			criteria: Criteria{Dialect: DialectJSONLogic, Filter: []byte(`{"==": [{"var": ".type"}`)},
+			err:      ErrInvalidCriteria,
+		},
+		{
+			it:       "rejects unknown dialects",
+			criteria: Criteria{Dialect: "sql", Filter: []byte(`"type = 'Contact'"`)},
+			err:      ErrUnsupportedDialect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			q, err := tt.criteria.compile()
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.criteria.Dialect, q.dialect)
+			require.Equal(t, tt.want, q.filter)
+		})
+	}
+}
+
+func TestQueryAnd(t *testing.T) {
+	tests := []struct {
+		it    string
+		query *query
+		conds []condition
+		want  map[string]any
+	}{
+		{
+			it:    "leaves the query alone without conditions",
+			query: &query{dialect: DialectMongo, filter: map[string]any{"type": "Contact"}},
+			want:  map[string]any{"type": "Contact"},
+		},
+		{
+			it:    "adds mongo comparisons",
+			query: &query{dialect: DialectMongo, filter: map[string]any{"type": "Contact"}},
+			conds: []condition{{field: "id", value: "e1"}, {field: "type", value: "Store", negate: true}},
+			want: map[string]any{"$and": []any{
+				map[string]any{"type": "Contact"},
+				map[string]any{"id": map[string]any{"$eq": "e1"}},
+				map[string]any{"type": map[string]any{"$ne": "Store"}},
+			}},
+		},
+		{
+			it:    "adds aggregation expressions to jsonlogic queries",
+			query: &query{dialect: DialectJSONLogic, filter: map[string]any{"$eq": []any{"$type", "Contact"}}},
+			conds: []condition{{field: "id", value: "e1"}},
+			want: map[string]any{"$and": []any{
+				map[string]any{"$eq": []any{"$type", "Contact"}},
+				map[string]any{"$eq": []any{"$id", "e1"}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.it, func(t *testing.T) {
+			q := tt.query.and(tt.conds...)
+			require.Equal(t, tt.query.dialect, q.dialect)
+			require.Equal(t, tt.want, q.filter)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package segment
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// deleter implements the segment deletion service.
+type deleter struct {
+	repo Repository
+}
+
+func NewDeleter(repo Repository) *deleter {
+	return &deleter{repo: repo}
+}
+
+func (s *deleter) Delete(ctx context.Context, accountID, id string) error {
+	if id == "" {
+		return ErrIDMissing
+	}
+	sg, err := s.repo.GetByID(ctx, accountID, id)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if sg.AccountID != accountID {
+		return ErrNotFound
+	}
+
+	if err = s.repo.Delete(ctx, accountID, id); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
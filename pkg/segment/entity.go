@@ -0,0 +1,99 @@
+package segment
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+)
+
+// Dialects supported by segment criteria, the same ones accepted by the entity search endpoints.
+const (
+	DialectMongo     = "mongo"
+	DialectJSONLogic = "jsonlogic"
+)
+
+// Criteria defines which entities belong to a segment.
+// Filter is kept as raw JSON so operators like '$gt' survive being persisted.
+type Criteria struct {
+	Dialect string          `json:"dialect" bson:"dialect"` // Query dialect of the filter, 'mongo' or 'jsonlogic'
+	Filter  json.RawMessage `json:"filter" bson:"filter"`   // Filter expressed in the given dialect
+}
+
+// Segment represents a dynamic group of entities of an account that match some criteria.
+type Segment struct {
+	ID          string   `json:"id"`                             // Unique identifier for the segment
+	AccountID   string   `json:"accountId" bson:"accountId"`     // ID of the associated account
+	Name        string   `json:"name" bson:"name"`               // Human-readable name of the segment
+	Description string   `json:"description" bson:"description"` // Optional description of the segment
+	Criteria    Criteria `json:"criteria" bson:"criteria"`       // Criteria defining the segment members
+
+	CreatedAt *time.Time `json:"createdAt" bson:"createdAt"` // Timestamp of segment creation
+	UpdatedAt *time.Time `json:"updatedAt" bson:"updatedAt"` // Timestamp of last segment update
+}
+
+// GetID returns the segment's unique identifier.
+func (s *Segment) GetID() string {
+	return s.ID
+}
+
+// SetID sets the segment's unique identifier.
+func (s *Segment) SetID(id string) {
+	s.ID = id
+}
+
+// GetCreatedAt returns the timestamp of when the segment was created.
+func (s *Segment) GetCreatedAt() *time.Time {
+	return s.CreatedAt
+}
+
+// SetCreatedAt sets the timestamp of when the segment was created.
+func (s *Segment) SetCreatedAt(t time.Time) {
+	s.CreatedAt = &t
+}
+
+// GetUpdatedAt returns the timestamp of the last update to the segment.
+func (s *Segment) GetUpdatedAt() *time.Time {
+	return s.UpdatedAt
+}
+
+// SetUpdatedAt sets the timestamp of the last update to the segment.
+func (s *Segment) SetUpdatedAt(t time.Time) {
+	s.UpdatedAt = &t
+}
+
+// MemberFilter narrows down the members of a segment.
+type MemberFilter struct {
+	EntityType  string // Only include entities of this type
+	ExcludeType string // Exclude entities of this type
+}
+
+type Saver interface {
+	Create(ctx context.Context, accountId string, segment *Segment) (*Segment, error)
+	Update(ctx context.Context, accountId, id string, segment *Segment) (*Segment, error)
+}
+
+type Deleter interface {
+	Delete(ctx context.Context, accountId, id string) error
+}
+
+type Getter interface {
+	GetByID(ctx context.Context, accountId, id string) (*Segment, error)
+	GetAll(ctx context.Context, accountId, name string, currentPage, perPage int) ([]*Segment, int, error)
+}
+
+type Evaluator interface {
+	Count(ctx context.Context, accountId, id string) (int, error)
+	Members(ctx context.Context, accountId, id string, filter MemberFilter, currentPage, perPage int) ([]*profile.Entity, int, error)
+	IsMember(ctx context.Context, accountId, id, entityId string) (bool, error)
+	SegmentsOf(ctx context.Context, accountId, entityId string) ([]*Segment, error)
+}
+
+type Repository interface {
+	Upsert(ctx context.Context, accountId string, segment *Segment) (*Segment, error)
+	GetByID(ctx context.Context, accountId, id string) (*Segment, error)
+	Delete(ctx context.Context, accountId, id string) error
+	GetAll(ctx context.Context, accountId string, page, limit int, includeDeleted bool) ([]*Segment, int, error)
+	ExecuteQuery(ctx context.Context, accountId string, query map[string]any, page, limit int) ([]*Segment, int, error)
+}
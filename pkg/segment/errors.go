@@ -0,0 +1,15 @@
+package segment
+
+import "github.com/dportaluppi/customer-profiles-api/pkg"
+
+var (
+	ErrIDMissing                   = pkg.NewErrID("missing segment id")
+	ErrAccountIDMissing            = pkg.NewErrID("missing account id")
+	ErrEntityIDMissing             = pkg.NewErrID("missing entity id")
+	ErrInvalid                     = pkg.NewErrInvalid("invalid segment data")
+	ErrNameMissing                 = pkg.NewErrInvalid("missing segment name")
+	ErrInvalidCriteria             = pkg.NewErrInvalid("invalid segment criteria")
+	ErrUnsupportedDialect          = pkg.NewErrInvalid("unsupported segment criteria dialect")
+	ErrNotFound                    = pkg.NewErrNotFound("segment not found")
+	ErrInvalidPaginationParameters = pkg.NewErrInvalid("invalid segment pagination parameters")
+)
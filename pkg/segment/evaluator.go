@@ -0,0 +1,209 @@
+package segment
+
+import (
+	"context"
+
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/dportaluppi/customer-profiles-api/pkg/search"
+	"github.com/pkg/errors"
+)
+
+const (
+	// scanPageSize is the page size used when walking every segment of an account.
+	scanPageSize = 100
+	// maxUnscreened is the size of the largest group of segments whose criteria are matched one by one
+	// rather than first as a whole.
+	maxUnscreened = 2
+)
+
+// evaluator resolves segment membership by running the segment criteria against the entities.
+type evaluator struct {
+	segments Getter
+	entities profile.Getter
+}
+
+func NewEvaluator(segments Getter, entities profile.Getter) Evaluator {
+	return &evaluator{segments: segments, entities: entities}
+}
+
+// Count returns the number of entities in a segment.
+func (s *evaluator) Count(ctx context.Context, accountID, id string) (int, error) {
+	q, err := s.query(ctx, accountID, id)
+	if err != nil {
+		return 0, err
+	}
+	_, count, err := s.run(ctx, accountID, q, 1, 1)
+	return count, err
+}
+
+// Members lists the entities in a segment.
+func (s *evaluator) Members(ctx context.Context, accountID, id string, filter MemberFilter, currentPage, perPage int) ([]*profile.Entity, int, error) {
+	if currentPage < 1 || perPage < 1 {
+		return nil, 0, ErrInvalidPaginationParameters
+	}
+
+	q, err := s.query(ctx, accountID, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var conds []condition
+	if filter.EntityType != "" {
+		conds = append(conds, condition{field: "type", value: filter.EntityType})
+	}
+	if filter.ExcludeType != "" {
+		conds = append(conds, condition{field: "type", value: filter.ExcludeType, negate: true})
+	}
+	return s.run(ctx, accountID, q.and(conds...), currentPage, perPage)
+}
+
+// IsMember reports whether an entity belongs to a segment.
+func (s *evaluator) IsMember(ctx context.Context, accountID, id, entityID string) (bool, error) {
+	if entityID == "" {
+		return false, ErrEntityIDMissing
+	}
+	if _, err := s.entities.GetByID(ctx, accountID, entityID, profile.QueryOptions{}); err != nil {
+		return false, err
+	}
+
+	q, err := s.query(ctx, accountID, id)
+	if err != nil {
+		return false, err
+	}
+	return s.matches(ctx, accountID, q, entityID)
+}
+
+// SegmentsOf returns every segment of the account the entity belongs to. The segments of a page are
+// screened together, see matching, so that an entity belonging to few segments costs few queries.
+func (s *evaluator) SegmentsOf(ctx context.Context, accountID, entityID string) ([]*Segment, error) {
+	if entityID == "" {
+		return nil, ErrEntityIDMissing
+	}
+	if _, err := s.entities.GetByID(ctx, accountID, entityID, profile.QueryOptions{}); err != nil {
+		return nil, err
+	}
+
+	result := make([]*Segment, 0)
+	for page := 1; ; page++ {
+		segments, total, err := s.segments.GetAll(ctx, accountID, "", page, scanPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		byDialect := map[string][]candidate{}
+		for _, sg := range segments {
+			q, err := sg.Criteria.compile()
+			if err != nil {
+				return nil, err
+			}
+			byDialect[q.dialect] = append(byDialect[q.dialect], candidate{segment: sg, query: q})
+		}
+		for _, dialect := range []string{DialectMongo, DialectJSONLogic} {
+			matched, err := s.matching(ctx, accountID, entityID, byDialect[dialect])
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, matched...)
+		}
+
+		if len(segments) == 0 || page*scanPageSize >= total {
+			return result, nil
+		}
+	}
+}
+
+// candidate is a segment whose membership is being evaluated, along with its compiled criteria.
+type candidate struct {
+	segment *Segment
+	query   *query
+}
+
+// matching returns the segments among candidates of the same dialect the entity belongs to. The criteria
+// of the candidates are first matched as a whole, one query telling the entity belongs to none of them.
+// Otherwise the candidates are split in halves until each matching one is found. A group too large to be
+// matched as a whole by the search limits is split right away.
+func (s *evaluator) matching(ctx context.Context, accountID, entityID string, candidates []candidate) ([]*Segment, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	if len(candidates) <= maxUnscreened {
+		var matched []*Segment
+		for _, c := range candidates {
+			ok, err := s.matches(ctx, accountID, c.query, entityID)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matched = append(matched, c.segment)
+			}
+		}
+		return matched, nil
+	}
+
+	if screen := anyOf(candidates); screen != nil {
+		ok, err := s.matches(ctx, accountID, screen, entityID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, nil
+		}
+	}
+	half := len(candidates) / 2
+	matched, err := s.matching(ctx, accountID, entityID, candidates[:half])
+	if err != nil {
+		return nil, err
+	}
+	rest, err := s.matching(ctx, accountID, entityID, candidates[half:])
+	if err != nil {
+		return nil, err
+	}
+	return append(matched, rest...), nil
+}
+
+// anyOf returns the query matching the entities matching the criteria of any of the candidates, nil when
+// it would break the search limits once restricted to an entity.
+func anyOf(candidates []candidate) *query {
+	clauses := make([]any, 0, len(candidates))
+	for _, c := range candidates {
+		clauses = append(clauses, c.query.filter)
+	}
+	q := &query{dialect: candidates[0].query.dialect, filter: map[string]any{"$or": clauses}}
+	if q.dialect == DialectMongo && search.Validate(q.and(condition{field: "id", value: ""}).filter) != nil {
+		return nil
+	}
+	return q
+}
+
+func (s *evaluator) query(ctx context.Context, accountID, id string) (*query, error) {
+	sg, err := s.segments.GetByID(ctx, accountID, id)
+	if err != nil {
+		return nil, err
+	}
+	return sg.Criteria.compile()
+}
+
+func (s *evaluator) matches(ctx context.Context, accountID string, q *query, entityID string) (bool, error) {
+	_, count, err := s.run(ctx, accountID, q.and(condition{field: "id", value: entityID}), 1, 1)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *evaluator) run(ctx context.Context, accountID string, q *query, currentPage, perPage int) ([]*profile.Entity, int, error) {
+	var (
+		entities []*profile.Entity
+		count    int
+		err      error
+	)
+	if q.dialect == DialectJSONLogic {
+		entities, count, err = s.entities.Pipeline(ctx, accountID, q.filter, currentPage, perPage, profile.QueryOptions{})
+	} else {
+		entities, count, err = s.entities.Query(ctx, accountID, q.filter, currentPage, perPage, profile.QueryOptions{})
+	}
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return entities, count, nil
+}
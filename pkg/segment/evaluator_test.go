@@ -0,0 +1,129 @@
+package segment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/dportaluppi/customer-profiles-api/internal/repository"
+	"github.com/dportaluppi/customer-profiles-api/pkg/profile"
+	"github.com/stretchr/testify/require"
+)
+
+// countingGetter counts the entity queries run by the evaluator.
+type countingGetter struct {
+	profile.Getter
+	queries int
+}
+
+func (g *countingGetter) Query(ctx context.Context, accountID string, query map[string]any, currentPage, perPage int, opts profile.QueryOptions) ([]*profile.Entity, int, error) {
+	g.queries++
+	return g.Getter.Query(ctx, accountID, query, currentPage, perPage, opts)
+}
+
+func (g *countingGetter) Pipeline(ctx context.Context, accountID string, pipeline map[string]any, currentPage, perPage int, opts profile.QueryOptions) ([]*profile.Entity, int, error) {
+	g.queries++
+	return g.Getter.Pipeline(ctx, accountID, pipeline, currentPage, perPage, opts)
+}
+
+func TestEvaluator(t *testing.T) {
+	ctx := context.Background()
+	entities := repository.NewMemoryRepository[*profile.Entity]()
+	segments := repository.NewMemoryRepository[*Segment]()
+	upsert := func(e *profile.Entity) *profile.Entity {
+		e.AccountID = "acc"
+		e, err := entities.Upsert(ctx, "acc", e)
+		require.NoError(t, err)
+		return e
+	}
+	create := func(name, dialect, filter string) *Segment {
+		sg, err := NewSaver(segments).Create(ctx, "acc", &Segment{Name: name, Criteria: Criteria{Dialect: dialect, Filter: []byte(filter)}})
+		require.NoError(t, err)
+		return sg
+	}
+
+	ana := upsert(&profile.Entity{Type: "Contact", Attributes: profile.Attribute{"city": "Paris"}})
+	bob := upsert(&profile.Entity{Type: "Contact", Attributes: profile.Attribute{"city": "Rome"}})
+	shop := upsert(&profile.Entity{Type: "Store", Attributes: profile.Attribute{"city": "Paris"}})
+	parisians := create("parisians", DialectMongo, `{"attributes.city": "Paris"}`)
+	contacts := create("contacts", DialectJSONLogic, `{"==": [{"var": ".type"}, "Contact"]}`)
+
+	getter := &countingGetter{Getter: profile.NewGetter(entities)}
+	evaluator := NewEvaluator(NewGetter(segments), getter)
+
+	t.Run("counts the members", func(t *testing.T) {
+		count, err := evaluator.Count(ctx, "acc", parisians.ID)
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+	})
+
+	t.Run("filters the members by type", func(t *testing.T) {
+		members, total, err := evaluator.Members(ctx, "acc", parisians.ID, MemberFilter{ExcludeType: "Store"}, 1, 10)
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		require.Equal(t, ana.ID, members[0].ID)
+
+		members, total, err = evaluator.Members(ctx, "acc", contacts.ID, MemberFilter{EntityType: "Contact"}, 1, 10)
+		require.NoError(t, err)
+		require.Equal(t, 2, total)
+		require.ElementsMatch(t, []string{ana.ID, bob.ID}, []string{members[0].ID, members[1].ID})
+	})
+
+	t.Run("tells membership", func(t *testing.T) {
+		tests := []struct {
+			it      string
+			segment string
+			entity  string
+			want    bool
+		}{
+			{it: "matches mongo criteria", segment: parisians.ID, entity: shop.ID, want: true},
+			{it: "does not match other mongo entities", segment: parisians.ID, entity: bob.ID},
+			{it: "matches jsonlogic criteria", segment: contacts.ID, entity: bob.ID, want: true},
+			{it: "does not match other jsonlogic entities", segment: contacts.ID, entity: shop.ID},
+		}
+		for _, tt := range tests {
+			t.Run(tt.it, func(t *testing.T) {
+				ok, err := evaluator.IsMember(ctx, "acc", tt.segment, tt.entity)
+				require.NoError(t, err)
+				require.Equal(t, tt.want, ok)
+			})
+		}
+	})
+
+	t.Run("rejects unknown entities", func(t *testing.T) {
+		_, err := evaluator.IsMember(ctx, "acc", parisians.ID, "missing")
+		require.Error(t, err)
+		_, err = evaluator.SegmentsOf(ctx, "acc", "missing")
+		require.Error(t, err)
+	})
+
+	t.Run("lists the segments of an entity", func(t *testing.T) {
+		for i := 0; i < 40; i++ {
+			create(fmt.Sprintf("city %d", i), DialectMongo, fmt.Sprintf(`{"attributes.city": "City %d"}`, i))
+			create(fmt.Sprintf("type %d", i), DialectJSONLogic, fmt.Sprintf(`{"==": [{"var": ".type"}, "Type %d"]}`, i))
+		}
+
+		tests := []struct {
+			it     string
+			entity string
+			want   []string
+		}{
+			{it: "finds segments of both dialects", entity: ana.ID, want: []string{parisians.ID, contacts.ID}},
+			{it: "finds a single segment", entity: bob.ID, want: []string{contacts.ID}},
+			{it: "finds segments of a dialect", entity: shop.ID, want: []string{parisians.ID}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.it, func(t *testing.T) {
+				getter.queries = 0
+				found, err := evaluator.SegmentsOf(ctx, "acc", tt.entity)
+				require.NoError(t, err)
+				var ids []string
+				for _, sg := range found {
+					ids = append(ids, sg.ID)
+				}
+				require.ElementsMatch(t, tt.want, ids)
+				require.Less(t, getter.queries, 30, "82 segments should be screened in batches")
+			})
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package segment
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// getter implements the segment retrieval service.
+type getter struct {
+	repo Repository
+}
+
+func NewGetter(repo Repository) Getter {
+	return &getter{repo: repo}
+}
+
+func (s *getter) GetByID(ctx context.Context, accountID, id string) (*Segment, error) {
+	if id == "" {
+		return nil, ErrIDMissing
+	}
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+
+	sg, err := s.repo.GetByID(ctx, accountID, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if sg.AccountID != accountID {
+		return nil, ErrNotFound
+	}
+	return sg, nil
+}
+
+// GetAll lists the segments of an account, optionally filtered by a case-insensitive name match.
+func (s *getter) GetAll(ctx context.Context, accountID, name string, currentPage, perPage int) ([]*Segment, int, error) {
+	if currentPage < 1 || perPage < 1 {
+		return nil, 0, ErrInvalidPaginationParameters
+	}
+
+	if name == "" {
+		segments, count, err := s.repo.GetAll(ctx, accountID, currentPage, perPage, false)
+		if err != nil {
+			return nil, 0, errors.WithStack(err)
+		}
+		return segments, count, nil
+	}
+
+	query := map[string]any{
+		"name": map[string]any{"$regex": regexp.QuoteMeta(name), "$options": "i"},
+	}
+	segments, count, err := s.repo.ExecuteQuery(ctx, accountID, query, currentPage, perPage)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return segments, count, nil
+}
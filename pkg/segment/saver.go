@@ -0,0 +1,77 @@
+package segment
+
+import (
+	"context"
+
+	errstack "github.com/pkg/errors"
+)
+
+// saver implements the segment saver service.
+type saver struct {
+	repo Repository
+}
+
+func NewSaver(repo Repository) *saver {
+	return &saver{repo: repo}
+}
+
+func (s *saver) Create(ctx context.Context, accountID string, segment *Segment) (*Segment, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if err := validate(segment); err != nil {
+		return nil, err
+	}
+
+	segment.ID = ""
+	segment.AccountID = accountID
+	sg, err := s.repo.Upsert(ctx, accountID, segment)
+	if err != nil {
+		return nil, errstack.WithStack(err)
+	}
+	return sg, nil
+}
+
+func (s *saver) Update(ctx context.Context, accountID, id string, segment *Segment) (*Segment, error) {
+	if accountID == "" {
+		return nil, ErrAccountIDMissing
+	}
+	if id == "" {
+		return nil, ErrIDMissing
+	}
+	if err := validate(segment); err != nil {
+		return nil, err
+	}
+
+	old, err := s.repo.GetByID(ctx, accountID, id)
+	if err != nil {
+		return nil, errstack.WithStack(err)
+	}
+	if old.AccountID != accountID {
+		return nil, ErrNotFound
+	}
+
+	segment.ID = old.ID
+	segment.AccountID = old.AccountID
+	segment.CreatedAt = old.CreatedAt
+	segment.UpdatedAt = old.UpdatedAt
+
+	sg, err := s.repo.Upsert(ctx, accountID, segment)
+	if err != nil {
+		return nil, errstack.WithStack(err)
+	}
+	return sg, nil
+}
+
+func validate(segment *Segment) error {
+	if segment == nil {
+		return ErrInvalid
+	}
+	if segment.Name == "" {
+		return ErrNameMissing
+	}
+	if _, err := segment.Criteria.compile(); err != nil {
+		return err
+	}
+	return nil
+}
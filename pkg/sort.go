@@ -0,0 +1,7 @@
+package pkg
+
+// Sort orders results by the value at a dotted field path, e.g. 'attributes.lastPurchase'.
+type Sort struct {
+	Field string
+	Desc  bool
+}